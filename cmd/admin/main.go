@@ -0,0 +1,142 @@
+// Command admin manages users, roles and the database schema of the DVD
+// rental API without going through HTTP. It reads the same configuration as
+// the API:
+//
+//	admin [config flags] <command> <subcommand> [flags]
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/config"
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+)
+
+// errUsage is returned by a command that was called with bad arguments. Its
+// usage has been printed already.
+var errUsage = errors.New("usage")
+
+type cli struct {
+	db     *sql.DB
+	store  *store.Store
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(c *cli, ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{name: "users list", usage: "list users with their role and email", run: (*cli).listUsers},
+	{name: "users create", usage: "create the user of a staff member or customer", run: (*cli).createUser},
+	{name: "users promote", usage: "change the role of a user", run: (*cli).promoteUser},
+	{name: "users reset-password", usage: "set a new password and revoke the user's tokens", run: (*cli).resetPassword},
+	{name: "users revoke-tokens", usage: "reject every token issued to a user so far", run: (*cli).revokeTokens},
+	{name: "roles list", usage: "list roles", run: (*cli).listRoles},
+	{name: "migrate up", usage: "apply pending migrations", run: (*cli).migrateUp},
+	{name: "migrate down", usage: "roll back migrations", run: (*cli).migrateDown},
+	{name: "migrate version", usage: "print the applied and the latest migration version", run: (*cli).migrateVersion},
+	{name: "migrate force", usage: "record a version as applied after fixing a failed migration", run: (*cli).migrateForce},
+}
+
+func findCommand(args []string) (*command, []string) {
+	if len(args) < 2 {
+		return nil, nil
+	}
+	name := args[0] + " " + args[1]
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i], args[2:]
+		}
+	}
+	return nil, nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: admin [config flags] <command> <subcommand> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Configuration is read like the API's: flags, the environment, then CONFIG_FILE or .env.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-22s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Getenv))
+}
+
+func run(args []string, getenv func(string) string) int {
+	cfg, args, err := config.Parse(args, getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin: invalid configuration: %v\n", err)
+		return 2
+	}
+
+	cmd, cmdArgs := findCommand(args)
+	if cmd == nil {
+		usage(os.Stderr)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conn, err := db.Open(cfg.DB.Addr, 2, 1, cfg.DB.MaxIdleTime)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "admin: failed to connect to the database: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	c := &cli{db: conn, store: store.NewStore(db.NewDB(conn, nil, 0)), stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	if err := c.run(ctx, cmd, cmdArgs); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "admin: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// run runs cmd with its changes attributed to the CLI in the audit log.
+func (c *cli) run(ctx context.Context, cmd *command, args []string) error {
+	ctx = store.WithAuditActor(ctx, store.AuditActor{Role: "admin-cli"})
+	return cmd.run(c, ctx, args)
+}
+
+// flags returns a flag set for cmd that prints its usage to stderr.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// parse parses args, turning help and parse errors into errUsage.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
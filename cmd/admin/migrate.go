@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/migrate"
+	"github.com/andras-szesztai/dev-rental-api/migrations"
+)
+
+// migrator returns a migrator for the migrations embedded in the binary, the
+// same ones the API applies at startup.
+func (c *cli) migrator() (*migrate.Migrator, error) {
+	return migrate.New(c.db, migrations.FS)
+}
+
+func (c *cli) migrateUp(ctx context.Context, args []string) error {
+	fs := c.flags("migrate up")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	migrator, err := c.migrator()
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		fmt.Fprintf(c.stdout, "applied %d %s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(c.stdout, "no pending migrations")
+	}
+	return c.printVersion(ctx, migrator)
+}
+
+func (c *cli) migrateDown(ctx context.Context, args []string) error {
+	fs := c.flags("migrate down")
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		fmt.Fprintln(c.stderr, "-steps must be at least 1")
+		fs.Usage()
+		return errUsage
+	}
+	migrator, err := c.migrator()
+	if err != nil {
+		return err
+	}
+
+	rolledBack, err := migrator.Down(ctx, *steps)
+	for _, migration := range rolledBack {
+		fmt.Fprintf(c.stdout, "rolled back %d %s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	return c.printVersion(ctx, migrator)
+}
+
+func (c *cli) migrateVersion(ctx context.Context, args []string) error {
+	fs := c.flags("migrate version")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	migrator, err := c.migrator()
+	if err != nil {
+		return err
+	}
+	return c.printVersion(ctx, migrator)
+}
+
+func (c *cli) migrateForce(ctx context.Context, args []string) error {
+	fs := c.flags("migrate force")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: admin migrate force VERSION")
+	}
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	version, err := strconv.ParseUint(fs.Arg(0), 10, 0)
+	if fs.NArg() != 1 || err != nil {
+		fs.Usage()
+		return errUsage
+	}
+	migrator, err := c.migrator()
+	if err != nil {
+		return err
+	}
+
+	if err := migrator.Force(ctx, uint(version)); err != nil {
+		return err
+	}
+	return c.printVersion(ctx, migrator)
+}
+
+func (c *cli) printVersion(ctx context.Context, migrator *migrate.Migrator) error {
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the migration version: %w", err)
+	}
+
+	state := ""
+	if dirty {
+		state = " (dirty)"
+	}
+	fmt.Fprintf(c.stdout, "database version %d%s, latest migration %d\n", version, state, migrator.Latest())
+	return nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+)
+
+func (c *cli) listUsers(ctx context.Context, args []string) error {
+	fs := c.flags("users list")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	users, err := c.store.Users.ListUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLE\tTOKENS REVOKED")
+	for _, user := range users {
+		revoked := "-"
+		if user.TokensValidAfter != nil {
+			revoked = user.TokensValidAfter.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", user.ID, user.Username, user.Email, user.Role.Name, revoked)
+	}
+	return w.Flush()
+}
+
+func (c *cli) listRoles(ctx context.Context, args []string) error {
+	fs := c.flags("roles list")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	roles, err := c.store.Roles.ListRoles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tLEVEL")
+	for _, role := range roles {
+		fmt.Fprintf(w, "%d\t%s\t%d\n", role.ID, role.Name, role.Level)
+	}
+	return w.Flush()
+}
+
+// createUser registers a user the way POST /auth/register does: staff
+// members become admins and customers get the customer role.
+func (c *cli) createUser(ctx context.Context, args []string) error {
+	fs := c.flags("users create")
+	email := fs.String("email", "", "email of the staff member or customer (required)")
+	username := fs.String("username", "", "username, 3 to 20 characters (required)")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *email == "" || len(*username) < 3 || len(*username) > 20 {
+		fmt.Fprintln(c.stderr, "-email and a -username of 3 to 20 characters are required")
+		fs.Usage()
+		return errUsage
+	}
+
+	roleName, err := c.accountRole(ctx, *email)
+	if err != nil {
+		return err
+	}
+	role, err := c.store.Roles.GetRoleByName(ctx, roleName)
+	if err != nil {
+		return fmt.Errorf("failed to get role %s: %w", roleName, err)
+	}
+
+	password, generated, err := c.password(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	user := &store.User{Email: *email, Username: *username, Role: role}
+	if err := user.Password.Set(password); err != nil {
+		return err
+	}
+	if err := c.store.Users.RegisterUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	fmt.Fprintf(c.stdout, "created user %d (%s) with role %s\n", user.ID, user.Username, role.Name)
+	if generated {
+		fmt.Fprintf(c.stdout, "password: %s\n", password)
+	}
+	return nil
+}
+
+// accountRole returns the role for a new user with email, which must belong
+// to a staff member or customer without a user.
+func (c *cli) accountRole(ctx context.Context, email string) (string, error) {
+	staff, err := c.store.Staff.GetStaffByEmail(ctx, email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to get staff member: %w", err)
+	}
+	if err == nil {
+		if staff.UserID != nil {
+			return "", store.ErrStaffAlreadyRegistered
+		}
+		return "admin", nil
+	}
+
+	customer, err := c.store.Customers.GetCustomerByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", store.ErrNoAccountForEmail
+		}
+		return "", fmt.Errorf("failed to get customer: %w", err)
+	}
+	if customer.UserID != nil {
+		return "", store.ErrCustomerAlreadyRegistered
+	}
+	return "customer", nil
+}
+
+func (c *cli) promoteUser(ctx context.Context, args []string) error {
+	fs := c.flags("users promote")
+	ref := fs.String("user", "", "user id, username or email (required)")
+	roleName := fs.String("role", "admin", "role to give the user")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	user, err := c.findUser(ctx, fs, *ref)
+	if err != nil {
+		return err
+	}
+
+	role, err := c.store.Roles.GetRoleByName(ctx, *roleName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("unknown role %s", *roleName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get role %s: %w", *roleName, err)
+	}
+
+	if user.Role.ID == role.ID {
+		fmt.Fprintf(c.stdout, "user %d (%s) already has role %s\n", user.ID, user.Username, role.Name)
+		return nil
+	}
+	if err := c.store.Users.UpdateUserRole(ctx, int64(user.ID), role); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	fmt.Fprintf(c.stdout, "user %d (%s) now has role %s\n", user.ID, user.Username, role.Name)
+	return nil
+}
+
+func (c *cli) resetPassword(ctx context.Context, args []string) error {
+	fs := c.flags("users reset-password")
+	ref := fs.String("user", "", "user id, username or email (required)")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	user, err := c.findUser(ctx, fs, *ref)
+	if err != nil {
+		return err
+	}
+
+	password, generated, err := c.password(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	if err := user.Password.Set(password); err != nil {
+		return err
+	}
+	if _, err := c.store.Users.UpdateUserPassword(ctx, int64(user.ID), user.Password); err != nil {
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	fmt.Fprintf(c.stdout, "reset the password of user %d (%s) and revoked their tokens\n", user.ID, user.Username)
+	if generated {
+		fmt.Fprintf(c.stdout, "password: %s\n", password)
+	}
+	return nil
+}
+
+func (c *cli) revokeTokens(ctx context.Context, args []string) error {
+	fs := c.flags("users revoke-tokens")
+	ref := fs.String("user", "", "user id, username or email (required)")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	user, err := c.findUser(ctx, fs, *ref)
+	if err != nil {
+		return err
+	}
+
+	validAfter, err := c.store.Users.RevokeUserTokens(ctx, int64(user.ID))
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	fmt.Fprintf(c.stdout, "revoked the tokens of user %d (%s) issued until %s\n", user.ID, user.Username, validAfter.Format(time.RFC3339))
+	return nil
+}
+
+// findUser looks a user up by id, email or username, in that order of
+// guessing.
+func (c *cli) findUser(ctx context.Context, fs *flag.FlagSet, ref string) (*store.User, error) {
+	if ref == "" {
+		fmt.Fprintln(c.stderr, "-user is required")
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	var user *store.User
+	var err error
+	if id, parseErr := strconv.ParseInt(ref, 10, 64); parseErr == nil {
+		user, err = c.store.Users.GetUserByID(ctx, id)
+	} else if strings.Contains(ref, "@") {
+		user, err = c.store.Users.GetUserByEmail(ctx, ref)
+	} else {
+		user, err = c.store.Users.GetUserByUsername(ctx, ref)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user %s not found", ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %s: %w", ref, err)
+	}
+	return user, nil
+}
+
+// password reads a password from the first line of stdin, or generates one
+// when fromStdin is false.
+func (c *cli) password(fromStdin bool) (password string, generated bool, err error) {
+	if !fromStdin {
+		b := make([]byte, 18)
+		if _, err := rand.Read(b); err != nil {
+			return "", false, err
+		}
+		return base64.RawURLEncoding.EncodeToString(b), true, nil
+	}
+
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", false, fmt.Errorf("failed to read the password from stdin: %w", err)
+	}
+	password = strings.TrimRight(line, "\r\n")
+	if len(password) < 8 || len(password) > 72 {
+		return "", false, errors.New("the password must be 8 to 72 characters")
+	}
+	return password, false, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCLI(stdin string) (*cli, *bytes.Buffer) {
+	var stdout bytes.Buffer
+	return &cli{
+		store:  store.NewMockStore(),
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &bytes.Buffer{},
+	}, &stdout
+}
+
+func runCommand(c *cli, args ...string) error {
+	cmd, cmdArgs := findCommand(args)
+	if cmd == nil {
+		return errUsage
+	}
+	return c.run(context.Background(), cmd, cmdArgs)
+}
+
+func TestCreateUser(t *testing.T) {
+	t.Run("staff members should become admins", func(t *testing.T) {
+		c, stdout := newTestCLI("")
+		c.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
+			return &store.Staff{ID: 1}, nil
+		}
+		var registered *store.User
+		c.store.Users.(*store.MockUserStore).RegisterUserFunc = func(ctx context.Context, user *store.User) error {
+			assert.Equal(t, "admin-cli", store.AuditActorFromContext(ctx).Role)
+			registered = user
+			user.ID = 3
+			return nil
+		}
+
+		err := runCommand(c, "users", "create", "-email", "mike.hillyer@sakilastaff.com", "-username", "mike")
+		require.NoError(t, err)
+
+		require.NotNil(t, registered)
+		assert.Equal(t, "admin", registered.Role.Name)
+		assert.Contains(t, stdout.String(), "created user 3 (mike) with role admin")
+
+		password := strings.TrimPrefix(strings.Split(stdout.String(), "\n")[1], "password: ")
+		assert.NoError(t, registered.Password.Compare(password))
+	})
+
+	t.Run("customers should read the password from stdin", func(t *testing.T) {
+		c, stdout := newTestCLI("correct horse battery\n")
+		c.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
+			return nil, sql.ErrNoRows
+		}
+		c.store.Customers.(*store.MockCustomerStore).GetCustomerByEmailFunc = func(ctx context.Context, email string) (*store.Customer, error) {
+			return &store.Customer{ID: 1}, nil
+		}
+		var registered *store.User
+		c.store.Users.(*store.MockUserStore).RegisterUserFunc = func(ctx context.Context, user *store.User) error {
+			registered = user
+			return nil
+		}
+
+		err := runCommand(c, "users", "create", "-email", "mary.smith@sakilacustomer.org", "-username", "mary", "-password-stdin")
+		require.NoError(t, err)
+
+		assert.Equal(t, "customer", registered.Role.Name)
+		assert.NoError(t, registered.Password.Compare("correct horse battery"))
+		assert.NotContains(t, stdout.String(), "password:")
+	})
+
+	t.Run("it should fail for an email without an account", func(t *testing.T) {
+		c, _ := newTestCLI("")
+		c.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
+			return nil, sql.ErrNoRows
+		}
+		c.store.Customers.(*store.MockCustomerStore).GetCustomerByEmailFunc = func(ctx context.Context, email string) (*store.Customer, error) {
+			return nil, sql.ErrNoRows
+		}
+
+		err := runCommand(c, "users", "create", "-email", "nobody@example.com", "-username", "nobody")
+		assert.ErrorIs(t, err, store.ErrNoAccountForEmail)
+	})
+
+	t.Run("it should require a username", func(t *testing.T) {
+		c, _ := newTestCLI("")
+
+		err := runCommand(c, "users", "create", "-email", "mike.hillyer@sakilastaff.com")
+		assert.ErrorIs(t, err, errUsage)
+	})
+}
+
+func TestPromoteUser(t *testing.T) {
+	c, stdout := newTestCLI("")
+	c.store.Users.(*store.MockUserStore).GetUserByEmailFunc = func(ctx context.Context, email string) (*store.User, error) {
+		assert.Equal(t, "mary.smith@sakilacustomer.org", email)
+		return &store.User{ID: 5, Username: "mary", Role: &store.Role{ID: 2, Name: "customer"}}, nil
+	}
+	var promotedTo *store.Role
+	c.store.Users.(*store.MockUserStore).UpdateUserRoleFunc = func(ctx context.Context, id int64, role *store.Role) error {
+		assert.Equal(t, int64(5), id)
+		promotedTo = role
+		return nil
+	}
+
+	err := runCommand(c, "users", "promote", "-user", "mary.smith@sakilacustomer.org")
+	require.NoError(t, err)
+
+	require.NotNil(t, promotedTo)
+	assert.Equal(t, "admin", promotedTo.Name)
+	assert.Contains(t, stdout.String(), "user 5 (mary) now has role admin")
+}
+
+func TestResetPassword(t *testing.T) {
+	c, stdout := newTestCLI("")
+	c.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: int(id), Username: "mike", Role: &store.Role{ID: 1}}, nil
+	}
+	var hash []byte
+	c.store.Users.(*store.MockUserStore).UpdateUserPasswordFunc = func(ctx context.Context, id int64, password utils.Password) (time.Time, error) {
+		assert.Equal(t, int64(3), id)
+		hash = password.Hash
+		return time.Now(), nil
+	}
+
+	err := runCommand(c, "users", "reset-password", "-user", "3")
+	require.NoError(t, err)
+
+	require.NotEmpty(t, hash)
+	assert.Contains(t, stdout.String(), "revoked their tokens")
+	assert.Contains(t, stdout.String(), "password: ")
+}
+
+func TestRevokeTokens(t *testing.T) {
+	t.Run("it should revoke the tokens of a user found by username", func(t *testing.T) {
+		c, stdout := newTestCLI("")
+		c.store.Users.(*store.MockUserStore).GetUserByUsernameFunc = func(ctx context.Context, username string) (*store.User, error) {
+			return &store.User{ID: 4, Username: username, Role: &store.Role{ID: 2}}, nil
+		}
+		revokedAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+		c.store.Users.(*store.MockUserStore).RevokeUserTokensFunc = func(ctx context.Context, id int64) (time.Time, error) {
+			assert.Equal(t, int64(4), id)
+			return revokedAt, nil
+		}
+
+		err := runCommand(c, "users", "revoke-tokens", "-user", "jon")
+		require.NoError(t, err)
+		assert.Contains(t, stdout.String(), "revoked the tokens of user 4 (jon) issued until 2025-05-01T12:00:00Z")
+	})
+
+	t.Run("it should fail for an unknown user", func(t *testing.T) {
+		c, _ := newTestCLI("")
+
+		err := runCommand(c, "users", "revoke-tokens", "-user", "nobody")
+		assert.ErrorContains(t, err, "user nobody not found")
+	})
+}
+
+func TestListRoles(t *testing.T) {
+	c, stdout := newTestCLI("")
+
+	require.NoError(t, runCommand(c, "roles", "list"))
+	assert.Equal(t, "ID  NAME      LEVEL\n1   admin     10\n2   customer  1\n", stdout.String())
+}
+
+func TestFindCommand(t *testing.T) {
+	cmd, args := findCommand([]string{"migrate", "down", "-steps", "2"})
+	require.NotNil(t, cmd)
+	assert.Equal(t, "migrate down", cmd.name)
+	assert.Equal(t, []string{"-steps", "2"}, args)
+
+	cmd, _ = findCommand([]string{"users"})
+	assert.Nil(t, cmd)
+}
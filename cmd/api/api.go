@@ -1,38 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/andras-szesztai/dev-rental-api/docs"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/validation"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-playground/validator/v10"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
 type contextKey string
 
-var Validator *validator.Validate
-
-func init() {
-	Validator = validator.New(validator.WithRequiredStructEnabled())
-}
+var Validator = validation.New()
 
 func (app *application) mountRoutes() http.Handler {
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
+	router.Use(app.TracingMiddleware)
 	router.Use(middleware.RealIP)
-	router.Use(middleware.Logger)
+	router.Use(app.AuditActorMiddleware)
+	router.Use(app.ReadYourWritesMiddleware)
+	router.Use(app.AccessLogMiddleware)
+	router.Use(app.MetricsMiddleware)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.StripSlashes)
 
+	router.Handle("/metrics", metrics.Handler())
+
 	router.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
+		r.Get("/health/live", app.livenessHandler)
+		r.Get("/health/ready", app.readinessHandler)
 
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
+		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.Addr)
 		r.Get("/swagger/*", httpSwagger.Handler(
 			httpSwagger.URL(docsURL),
 			httpSwagger.DeepLinking(true),
@@ -47,18 +58,79 @@ func (app *application) mountRoutes() http.Handler {
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/register", app.registerUser)
 			r.Post("/sign-in", app.signInUser)
+
+			if app.identityProvider != nil {
+				r.Get("/oidc/login", app.oidcLogin)
+				r.Get("/oidc/callback", app.oidcCallback)
+			}
 		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.IdempotencyMiddleware)
 			r.Route("/rentals", func(r chi.Router) {
+				r.Get("/", app.CheckAdminMiddleware(app.listRentals))
+				r.Post("/", app.CheckAdminMiddleware(app.createRental))
 				r.Route("/{id}", func(r chi.Router) {
 					r.Get("/", app.getRentalByID)
+					r.Post("/return", app.CheckAdminMiddleware(app.returnRental))
+					r.Route("/dunning", func(r chi.Router) {
+						r.Get("/", app.CheckAdminMiddleware(app.getDunningCase))
+						r.Post("/pause", app.CheckAdminMiddleware(app.pauseDunning))
+						r.Post("/resume", app.CheckAdminMiddleware(app.resumeDunning))
+						r.Post("/waive", app.CheckAdminMiddleware(app.waiveDunning))
+					})
 				})
 			})
 			r.Route("/customers", func(r chi.Router) {
+				r.Get("/", app.CheckAdminMiddleware(app.listCustomers))
 				r.Post("/", app.CheckAdminMiddleware(app.createCustomer))
+				r.Route("/{id}", func(r chi.Router) {
+					r.Get("/", app.CheckAdminMiddleware(app.getCustomerByID))
+					r.Put("/", app.CheckAdminMiddleware(app.updateCustomer))
+					r.Get("/notification-preferences", app.getNotificationPreferences)
+					r.Put("/notification-preferences", app.updateNotificationPreferences)
+				})
+			})
+			r.Route("/films", func(r chi.Router) {
+				r.Get("/", app.listFilms)
+				r.Get("/{id}", app.getFilmByID)
+			})
+			r.Route("/payments", func(r chi.Router) {
+				r.Get("/", app.CheckAdminMiddleware(app.listPayments))
+				r.Post("/", app.CheckAdminMiddleware(app.createPayment))
+			})
+			r.Route("/staff", func(r chi.Router) {
+				r.Get("/{id}", app.CheckAdminMiddleware(app.getStaffByID))
+			})
+			r.Route("/stores", func(r chi.Router) {
+				r.Get("/{id}", app.getStoreByID)
+				r.Get("/{id}/events", app.streamStoreEvents)
 			})
+			r.Route("/exports", func(r chi.Router) {
+				r.Get("/rentals", app.CheckAdminMiddleware(app.exportRentals))
+				r.Get("/payments", app.CheckAdminMiddleware(app.exportPayments))
+			})
+			r.Route("/admin", func(r chi.Router) {
+				r.Get("/audit", app.CheckAdminMiddleware(app.listAuditEntries))
+				r.Route("/jobs", func(r chi.Router) {
+					r.Get("/", app.CheckAdminMiddleware(app.listJobs))
+					r.Get("/runs", app.CheckAdminMiddleware(app.listJobRuns))
+					r.Post("/{name}/run", app.CheckAdminMiddleware(app.triggerJob))
+				})
+				r.Get("/notifications", app.CheckAdminMiddleware(app.listNotifications))
+				r.Route("/webhooks", func(r chi.Router) {
+					r.Get("/", app.CheckAdminMiddleware(app.listWebhookEndpoints))
+					r.Post("/", app.CheckAdminMiddleware(app.createWebhookEndpoint))
+					r.Delete("/{id}", app.CheckAdminMiddleware(app.deleteWebhookEndpoint))
+					r.Get("/{id}/dead-letters", app.CheckAdminMiddleware(app.listDeadWebhookDeliveries))
+					r.Post("/deliveries/{id}/replay", app.CheckAdminMiddleware(app.replayWebhookDelivery))
+				})
+			})
+
+			graphql := app.graphqlHandler()
+			r.Get("/graphql", graphql)
+			r.Post("/graphql", graphql)
 		})
 	})
 
@@ -66,21 +138,101 @@ func (app *application) mountRoutes() http.Handler {
 }
 
 func (app *application) serve(router http.Handler) error {
-	docs.SwaggerInfo.Version = app.config.version
-	docs.SwaggerInfo.Host = app.config.apiURL
+	docs.SwaggerInfo.Version = app.config.Version
+	docs.SwaggerInfo.Host = app.config.APIURL
 	docs.SwaggerInfo.BasePath = "/v1"
 	docs.SwaggerInfo.Title = "DVD Rental API"
 	docs.SwaggerInfo.Description = "API for a DVD Rental management application"
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", app.config.Addr)
+	if err != nil {
+		return err
+	}
+
+	if app.config.GRPC.Addr == "" {
+		return app.serveListener(ctx, listener, router)
+	}
+
+	grpcListener, err := net.Listen("tcp", app.config.GRPC.Addr)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	// Either server failing shuts down the other.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	grpcErr := make(chan error, 1)
+	go func() {
+		err := app.serveGRPC(ctx, grpcListener)
+		cancel()
+		grpcErr <- err
+	}()
+
+	err = app.serveListener(ctx, listener, router)
+	cancel()
+	return errors.Join(err, <-grpcErr)
+}
+
+// serveListener serves until ctx is cancelled, then fails readiness for the
+// drain delay so load balancers stop routing to the server, stops accepting
+// connections and drains in-flight requests within the shutdown timeout.
+// Background workers are stopped even if draining times out.
+func (app *application) serveListener(ctx context.Context, listener net.Listener, router http.Handler) error {
 	srv := &http.Server{
-		Addr:         app.config.addr,
 		Handler:      router,
 		WriteTimeout: 30 * time.Second,
 		ReadTimeout:  10 * time.Second,
 		IdleTimeout:  time.Minute,
 	}
+	// Event streams only end when their client leaves, so they are closed
+	// for Shutdown to be able to drain.
+	if app.activity != nil {
+		srv.RegisterOnShutdown(app.activity.Close)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	app.logger.Infow("starting server", "addr", listener.Addr().String(), "env", app.config.Env, "version", app.config.Version)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	app.shuttingDown.Store(true)
+	app.logger.Infow("shutting down server", "drain_delay", app.config.ShutdownDrainDelay.String(), "timeout", app.config.ShutdownTimeout.String())
+	time.Sleep(app.config.ShutdownDrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		errs = append(errs, fmt.Errorf("failed to drain connections: %w", err))
+	}
+
+	// A drain that used up the timeout still leaves the workers their own.
+	workersCtx, cancelWorkers := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+	defer cancelWorkers()
+
+	if err := app.workers.Stop(workersCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop background workers: %w", err))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
 
-	app.logger.Infow("starting server", "addr", srv.Addr, "env", app.config.env, "version", app.config.version)
+	app.logger.Info("server stopped")
 
-	return srv.ListenAndServe()
+	return nil
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.ShutdownTimeout = 5 * time.Second
+	app.config.ShutdownDrainDelay = 500 * time.Millisecond
+
+	started := make(chan struct{})
+	router := chi.NewRouter()
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(800 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+	router.Mount("/", app.mountRoutes())
+
+	workerStopped := make(chan struct{})
+	app.workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serveListener(ctx, listener, router)
+	}()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			slow <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slow <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	assert.Eventually(t, app.shuttingDown.Load, time.Second, 10*time.Millisecond)
+
+	// The listener stays open for the drain delay so the load balancer can
+	// see the failing readiness probe.
+	resp, err := http.Get("http://" + listener.Addr().String() + "/v1/health/ready")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Contains(t, string(body), "server is shutting down")
+
+	res := <-slow
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "done", res.body)
+
+	assert.NoError(t, <-serveErr)
+
+	select {
+	case <-workerStopped:
+	default:
+		t.Fatal("background worker was not stopped")
+	}
+
+	_, err = http.Get("http://" + listener.Addr().String() + "/v1/health")
+	assert.Error(t, err)
+}
+
+func TestShutdownStopsWorkersAfterDrainTimeout(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.ShutdownTimeout = 100 * time.Millisecond
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	router := chi.NewRouter()
+	router.Get("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	workerStopped := make(chan struct{})
+	app.workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serveListener(ctx, listener, router)
+	}()
+
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/stuck")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+
+	assert.ErrorContains(t, <-serveErr, "failed to drain connections")
+
+	select {
+	case <-workerStopped:
+	default:
+		t.Fatal("background worker was not stopped")
+	}
+}
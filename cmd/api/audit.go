@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// AuditActorMiddleware stores the request id and client IP for audit entries.
+// AuthTokenMiddleware adds the user once the request is authenticated.
+func (app *application) AuditActorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := store.WithAuditActor(r.Context(), store.AuditActor{
+			RequestID: middleware.GetReqID(r.Context()),
+			IP:        clientIP(r),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type auditEntriesResponse struct {
+	Data []store.AuditEntry `json:"data"`
+}
+
+// ListAuditEntries godoc
+//
+//	@Summary		List audit entries
+//	@Description	List audit entries for state-changing requests, newest first
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Param			actor_user_id	query		int		false	"Actor user ID"
+//	@Param			action			query		string	false	"Action"
+//	@Param			entity_type		query		string	false	"Entity type"
+//	@Param			entity_id		query		int		false	"Entity ID"
+//	@Param			from			query		string	false	"From (RFC3339, inclusive)"
+//	@Param			to				query		string	false	"To (RFC3339, exclusive)"
+//	@Param			limit			query		int		false	"Limit"		default(50)
+//	@Param			offset			query		int		false	"Offset"	default(0)
+//	@Success		200				{object}	auditEntriesResponse
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/audit [get]
+func (app *application) listAuditEntries(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	entries, err := app.store.Audit.ListAuditEntries(r.Context(), *filter)
+	if err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, auditEntriesResponse{Data: entries}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+func parseAuditFilter(r *http.Request) (*store.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := &store.AuditFilter{
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+		Limit:      defaultAuditLimit,
+	}
+
+	if value := query.Get("actor_user_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("actor_user_id must be an integer")
+		}
+		filter.ActorUserID = &id
+	}
+	if value := query.Get("entity_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("entity_id must be an integer")
+		}
+		filter.EntityID = &id
+	}
+	if value := query.Get("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("from must be an RFC3339 timestamp")
+		}
+		filter.From = &from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, errors.New("to must be an RFC3339 timestamp")
+		}
+		filter.To = &to
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return nil, errors.New("limit must be between 1 and 500")
+		}
+		filter.Limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return nil, errors.New("offset must be a non-negative integer")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
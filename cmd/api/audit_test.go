@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAuditEntries(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{
+			ID: 1,
+			Role: &store.Role{
+				ID: 1,
+			},
+		}, nil
+	}
+
+	t.Run("admin should be able to list audit entries with filters", func(t *testing.T) {
+		var filter store.AuditFilter
+		app.store.Audit.(*store.MockAuditStore).ListAuditEntriesFunc = func(ctx context.Context, f store.AuditFilter) ([]store.AuditEntry, error) {
+			filter = f
+			return []store.AuditEntry{
+				{
+					ID:         1,
+					Action:     "create",
+					EntityType: "customer",
+					EntityID:   600,
+					Diff: map[string]store.AuditChange{
+						"email": {After: "john.doe@example.com"},
+					},
+				},
+			}, nil
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/audit?entity_type=customer&entity_id=600&actor_user_id=1&from=2025-01-01T00:00:00Z&limit=10", nil)
+		assert.NoError(t, err)
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "john.doe@example.com")
+		assert.Equal(t, "customer", filter.EntityType)
+		assert.Equal(t, int64(600), *filter.EntityID)
+		assert.Equal(t, int64(1), *filter.ActorUserID)
+		assert.NotNil(t, filter.From)
+		assert.Nil(t, filter.To)
+		assert.Equal(t, 10, filter.Limit)
+	})
+
+	t.Run("bad request if a filter is invalid", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/audit?from=yesterday", nil)
+		assert.NoError(t, err)
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "from must be an RFC3339 timestamp")
+	})
+
+	t.Run("internal server error if listing fails", func(t *testing.T) {
+		app.store.Audit.(*store.MockAuditStore).ListAuditEntriesFunc = func(ctx context.Context, f store.AuditFilter) ([]store.AuditEntry, error) {
+			return nil, errors.New("database error")
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/audit", nil)
+		assert.NoError(t, err)
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	})
+
+	t.Run("non-admin user should not be able to list audit entries", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{
+				ID: 1,
+				Role: &store.Role{
+					ID: 2,
+				},
+			}, nil
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/audit", nil)
+		assert.NoError(t, err)
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
+
+func TestAuditActorMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{
+			ID: 7,
+			Role: &store.Role{
+				ID: 1,
+			},
+		}, nil
+	}
+
+	var actor store.AuditActor
+	app.store.Customers.(*store.MockCustomerStore).CreateCustomerFunc = func(ctx context.Context, customer *store.Customer) error {
+		actor = store.AuditActorFromContext(ctx)
+		return nil
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/customers", bytes.NewBufferString(`{"store_id": 1, "first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, 7, *actor.UserID)
+	assert.Equal(t, "admin", actor.Role)
+	assert.Equal(t, "203.0.113.7", actor.IP)
+	assert.NotEmpty(t, actor.RequestID)
+}
@@ -3,12 +3,14 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
@@ -29,8 +31,9 @@ type registerUserPayload struct {
 //	@Produce		json
 //	@Param			request	body		registerUserPayload	true	"Register user request"
 //	@Success		201		{object}	nil
-//	@Failure		400		{object}	utils.ErrorResponse
-//	@Failure		500		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		409		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
 //	@Router			/auth/register [post]
 func (app *application) registerUser(w http.ResponseWriter, r *http.Request) {
 	var payload registerUserPayload
@@ -41,7 +44,7 @@ func (app *application) registerUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = Validator.Struct(payload)
+	err = Validator.Struct(r, payload)
 	if err != nil {
 		app.errorHandler.BadRequest(w, r, err)
 		return
@@ -50,11 +53,11 @@ func (app *application) registerUser(w http.ResponseWriter, r *http.Request) {
 	var roleName string
 	staff, err := app.store.Staff.GetStaffByEmail(r.Context(), payload.Email)
 	if err != nil && err != sql.ErrNoRows {
-		app.errorHandler.BadRequest(w, r, fmt.Errorf("f	: %w", err))
+		app.errorHandler.InternalServerError(w, r, fmt.Errorf("failed to get staff member: %w", err))
 		return
 	}
 	if staff != nil && staff.UserID != nil {
-		app.errorHandler.BadRequest(w, r, fmt.Errorf("staff member already registered"))
+		app.errorHandler.Error(w, r, store.ErrStaffAlreadyRegistered)
 		return
 	}
 	if staff != nil && staff.ID > 0 {
@@ -64,11 +67,15 @@ func (app *application) registerUser(w http.ResponseWriter, r *http.Request) {
 	if err == sql.ErrNoRows {
 		customer, err := app.store.Customers.GetCustomerByEmail(r.Context(), payload.Email)
 		if err != nil {
-			app.errorHandler.BadRequest(w, r, fmt.Errorf("failed to get customer: %w", err))
+			if err == sql.ErrNoRows {
+				app.errorHandler.Error(w, r, store.ErrNoAccountForEmail)
+				return
+			}
+			app.errorHandler.InternalServerError(w, r, fmt.Errorf("failed to get customer: %w", err))
 			return
 		}
 		if customer.UserID != nil {
-			app.errorHandler.BadRequest(w, r, fmt.Errorf("customer already registered"))
+			app.errorHandler.Error(w, r, store.ErrCustomerAlreadyRegistered)
 			return
 		}
 		roleName = "customer"
@@ -76,7 +83,7 @@ func (app *application) registerUser(w http.ResponseWriter, r *http.Request) {
 
 	role, err := app.store.Roles.GetRoleByName(r.Context(), roleName)
 	if err != nil {
-		app.errorHandler.BadRequest(w, r, err)
+		app.errorHandler.InternalServerError(w, r, fmt.Errorf("failed to get role: %w", err))
 		return
 	}
 
@@ -93,7 +100,7 @@ func (app *application) registerUser(w http.ResponseWriter, r *http.Request) {
 
 	err = app.store.Users.RegisterUser(r.Context(), user)
 	if err != nil {
-		app.errorHandler.BadRequest(w, r, err)
+		app.errorHandler.Error(w, r, err)
 		return
 	}
 
@@ -121,8 +128,9 @@ type signInResponse struct {
 //	@Produce		json
 //	@Param			request	body		signInPayload	true	"Sign in user request"
 //	@Success		200		{object}	signInResponse	"JWT token"
-//	@Failure		400		{object}	utils.ErrorResponse
-//	@Failure		500		{object}	utils.ErrorResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
 //	@Router			/auth/sign-in [post]
 func (app *application) signInUser(w http.ResponseWriter, r *http.Request) {
 	var payload signInPayload
@@ -133,115 +141,106 @@ func (app *application) signInUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = Validator.Struct(payload)
+	err = Validator.Struct(r, payload)
 	if err != nil {
 		app.errorHandler.BadRequest(w, r, err)
 		return
 	}
 
-	var userID int
-	customer, err := app.store.Customers.GetCustomerByEmail(r.Context(), payload.Email)
-	if err != nil && err != sql.ErrNoRows {
-		app.errorHandler.BadRequest(w, r, err)
+	token, err := app.signIn(r.Context(), payload.Email, payload.Password)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, signInResponse{Data: token}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
 		return
 	}
 
+}
+
+// signIn checks the credentials of a customer or staff member and returns a
+// new token for their user.
+func (app *application) signIn(ctx context.Context, email, password string) (string, error) {
+	var userID *int
+	customer, err := app.store.Customers.GetCustomerByEmail(ctx, email)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get customer: %w", err)
+	}
+
 	if customer == nil {
-		staff, err := app.store.Staff.GetStaffByEmail(r.Context(), payload.Email)
+		staff, err := app.store.Staff.GetStaffByEmail(ctx, email)
 		if err != nil {
-			app.errorHandler.BadRequest(w, r, err)
-			return
-		}
-		if staff.UserID != nil {
-			userID = *staff.UserID
+			if err == sql.ErrNoRows {
+				return "", store.ErrInvalidCredentials
+			}
+			return "", fmt.Errorf("failed to get staff member: %w", err)
 		}
+		userID = staff.UserID
 	} else {
-		userID = *customer.UserID
+		userID = customer.UserID
 	}
 
-	fmt.Println("customer error", userID)
-	user, err := app.store.Users.GetUserByID(r.Context(), int64(userID))
+	if userID == nil {
+		return "", store.ErrInvalidCredentials
+	}
+
+	user, err := app.store.Users.GetUserByID(ctx, int64(*userID))
 	if err != nil {
-		app.errorHandler.BadRequest(w, r, err)
-		return
+		if err == sql.ErrNoRows {
+			return "", store.ErrInvalidCredentials
+		}
+		return "", fmt.Errorf("failed to get user: %w", err)
 	}
 
-	if err := user.Password.Compare(payload.Password); err != nil {
-		app.errorHandler.Unauthorized(w, r, err)
-		return
+	if err := user.Password.Compare(password); err != nil {
+		return "", store.ErrInvalidCredentials.Wrap(err)
 	}
 
+	return app.generateUserToken(user.ID)
+}
+
+func (app *application) generateUserToken(userID int) (string, error) {
 	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
+		"sub": userID,
+		"exp": time.Now().Add(app.config.Auth.Token.Exp).Unix(),
 		"nbf": time.Now().Unix(),
 		"iat": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.aud,
-	}
-
-	token, err := app.authenticator.GenerateToken(claims)
-	if err != nil {
-		app.errorHandler.InternalServerError(w, r, err)
-		return
-	}
-
-	if err := utils.WriteJSONResponse(w, http.StatusOK, signInResponse{Data: token}); err != nil {
-		app.errorHandler.InternalServerError(w, r, err)
-		return
+		"iss": app.config.Auth.Token.Iss,
+		"aud": app.config.Auth.Token.Aud,
 	}
 
+	return app.authenticator.GenerateToken(claims)
 }
 
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("authorization header is required"))
+			app.authFailure(w, r, "missing_header", fmt.Errorf("authorization header is required"))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("invalid authorization header"))
+			app.authFailure(w, r, "malformed_header", fmt.Errorf("invalid authorization header"))
 			return
 		}
 
-		token := parts[1]
-		if token == "" {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("token is required"))
-			return
-		}
-
-		jwtToken, err := app.authenticator.ValidateToken(token)
+		user, reason, err := app.authenticateToken(r.Context(), parts[1])
 		if err != nil {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("invalid token"))
+			app.authFailure(w, r, reason, err)
 			return
 		}
 
-		claims := jwtToken.Claims.(jwt.MapClaims)
-		userId, err := strconv.ParseInt(fmt.Sprintf("%.0f", claims["sub"].(float64)), 10, 64)
-		if err != nil {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("invalid token"))
-			return
-		}
-
-		user, err := app.store.Users.GetUserByID(r.Context(), userId)
-		if err != nil {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("invalid token"))
-			return
-		}
-
-		// TODO Add cache
-		role, err := app.store.Roles.GetRoleByID(r.Context(), int64(user.Role.ID))
-		if err != nil {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("invalid token"))
-			return
-		}
-
-		user.Role = role
+		actor := store.AuditActorFromContext(r.Context())
+		actor.UserID = &user.ID
+		actor.Role = user.Role.Name
 
 		ctx := context.WithValue(r.Context(), contextKey("user"), user)
+		ctx = store.WithAuditActor(ctx, actor)
+		ctx = setLogUser(ctx, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -249,15 +248,57 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 func (app *application) CheckAdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(contextKey("user")).(*store.User)
-		fmt.Println("user.Role.Name", user.Role.Name)
 		if user.Role.Name != "admin" {
-			app.errorHandler.Unauthorized(w, r, fmt.Errorf("unauthorized"))
+			metrics.AuthFailures.WithLabelValues("insufficient_role").Inc()
+			app.errorHandler.Forbidden(w, r, errors.New("admin role required"))
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticateToken validates a bearer token and loads its user with the
+// role resolved. On failure, reason labels the auth failure metric.
+func (app *application) authenticateToken(ctx context.Context, token string) (user *store.User, reason string, err error) {
+	if token == "" {
+		return nil, "missing_token", fmt.Errorf("token is required")
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, "invalid_token", fmt.Errorf("invalid token")
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+	userId, err := strconv.ParseInt(fmt.Sprintf("%.0f", claims["sub"].(float64)), 10, 64)
+	if err != nil {
+		return nil, "invalid_subject", fmt.Errorf("invalid token")
+	}
+
+	user, err = app.store.Users.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, "unknown_user", fmt.Errorf("invalid token")
+	}
+
+	// iat has second precision, so a token issued in the same second as the
+	// revocation is rejected too
+	if user.TokensValidAfter != nil {
+		issuedAt, err := claims.GetIssuedAt()
+		if err != nil || issuedAt == nil || !issuedAt.After(*user.TokensValidAfter) {
+			return nil, "revoked_token", fmt.Errorf("invalid token")
+		}
+	}
+
+	// TODO Add cache
+	role, err := app.store.Roles.GetRoleByID(ctx, int64(user.Role.ID))
+	if err != nil {
+		return nil, "unknown_role", fmt.Errorf("invalid token")
+	}
+
+	user.Role = role
+	return user, "", nil
+}
+
 const userContextKey = contextKey("user")
 
 func (app *application) getUserContext(r *http.Request) *store.User {
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
@@ -29,7 +30,7 @@ func TestRegisterUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'registerUserPayload.Username' Error:Field validation for 'Username' failed on the 'required' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"username","rule":"required"`)
 
 		// request with invalid email
 		req, err = http.NewRequest(http.MethodPost, "/v1/auth/register", bytes.NewBufferString(`{"email": "test@test", "username": "test", "password": "password"}`))
@@ -39,7 +40,7 @@ func TestRegisterUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'registerUserPayload.Email' Error:Field validation for 'Email' failed on the 'email' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"email","rule":"email"`)
 
 		// no email
 		req, err = http.NewRequest(http.MethodPost, "/v1/auth/register", bytes.NewBufferString(`{"username": "test", "password": "password"}`))
@@ -49,7 +50,7 @@ func TestRegisterUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'registerUserPayload.Email' Error:Field validation for 'Email' failed on the 'required' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"email","rule":"required"`)
 
 		// request with invalid password
 		req, err = http.NewRequest(http.MethodPost, "/v1/auth/register", bytes.NewBufferString(`{"email": "test@test.com", "username": "test", "password": "pass"}`))
@@ -59,7 +60,7 @@ func TestRegisterUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'registerUserPayload.Password' Error:Field validation for 'Password' failed on the 'min' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"password","rule":"min"`)
 
 		// no password
 		req, err = http.NewRequest(http.MethodPost, "/v1/auth/register", bytes.NewBufferString(`{"email": "test@test.com", "username": "test"}`))
@@ -69,7 +70,7 @@ func TestRegisterUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'registerUserPayload.Password' Error:Field validation for 'Password' failed on the 'required' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"password","rule":"required"`)
 
 		// request with invalid username
 		req, err = http.NewRequest(http.MethodPost, "/v1/auth/register", bytes.NewBufferString(`{"email": "test@test.com", "username": "te", "password": "password"}`))
@@ -79,10 +80,10 @@ func TestRegisterUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'registerUserPayload.Username' Error:Field validation for 'Username' failed on the 'min' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"username","rule":"min"`)
 	})
 
-	t.Run("it should return bad request if staff member already registered", func(t *testing.T) {
+	t.Run("it should return conflict if staff member already registered", func(t *testing.T) {
 		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
 			return &store.Staff{
 				UserID: &[]int{1}[0],
@@ -95,11 +96,11 @@ func TestRegisterUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "staff member already registered")
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"staff_already_registered"`)
 	})
 
-	t.Run("it should return bad request if staff member lookup returns error that is not sql.ErrNoRows", func(t *testing.T) {
+	t.Run("it should return internal server error without details if staff member lookup returns error that is not sql.ErrNoRows", func(t *testing.T) {
 		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
 			return nil, errors.New("some error")
 		}
@@ -110,8 +111,8 @@ func TestRegisterUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "some error")
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "some error")
 	})
 
 	t.Run("it should assign admin role if staff member is found and not registered", func(t *testing.T) {
@@ -136,7 +137,7 @@ func TestRegisterUser(t *testing.T) {
 		assert.Equal(t, http.StatusCreated, recorder.Code)
 	})
 
-	t.Run("it should go ahead if sql no rows for staff but it should return bad request if customer lookup returns error, even if sql.ErrNoRows", func(t *testing.T) {
+	t.Run("it should go ahead if sql no rows for staff but it should return bad request if there is no customer either", func(t *testing.T) {
 		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
 			return nil, sql.ErrNoRows
 		}
@@ -151,10 +152,10 @@ func TestRegisterUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "failed to get customer")
+		assert.Contains(t, recorder.Body.String(), `"code":"no_account_for_email"`)
 	})
 
-	t.Run("it should return bad request if customer is already registered", func(t *testing.T) {
+	t.Run("it should return conflict if customer is already registered", func(t *testing.T) {
 		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
 			return nil, sql.ErrNoRows
 		}
@@ -170,11 +171,11 @@ func TestRegisterUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "customer already registered")
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"customer_already_registered"`)
 	})
 
-	t.Run("bad request if role lookup returns error", func(t *testing.T) {
+	t.Run("internal server error if role lookup returns error", func(t *testing.T) {
 		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
 			return nil, sql.ErrNoRows
 		}
@@ -193,11 +194,11 @@ func TestRegisterUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "some error")
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "some error")
 	})
 
-	t.Run("bad request if user registration returns error", func(t *testing.T) {
+	t.Run("internal server error if user registration returns error", func(t *testing.T) {
 		app.store.Users.(*store.MockUserStore).RegisterUserFunc = func(ctx context.Context, user *store.User) error {
 			return errors.New("some error")
 		}
@@ -208,8 +209,8 @@ func TestRegisterUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "some error")
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "some error")
 	})
 }
 
@@ -229,7 +230,7 @@ func TestSignInUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'signInPayload.Email' Error:Field validation for 'Email' failed on the 'email' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"email","rule":"email"`)
 
 		req, err = http.NewRequest(http.MethodPost, "/v1/auth/sign-in", bytes.NewBufferString(`{"password": "password"}`))
 		assert.NoError(t, err)
@@ -238,7 +239,7 @@ func TestSignInUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'signInPayload.Email' Error:Field validation for 'Email' failed on the 'required' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"email","rule":"required"`)
 
 		req, err = http.NewRequest(http.MethodPost, "/v1/auth/sign-in", bytes.NewBufferString(`{"email": "test@test.com"}`))
 		assert.NoError(t, err)
@@ -247,10 +248,10 @@ func TestSignInUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'signInPayload.Password' Error:Field validation for 'Password' failed on the 'required' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"password","rule":"required"`)
 	})
 
-	t.Run("bad request if user lookup returns error", func(t *testing.T) {
+	t.Run("internal server error if user lookup returns error", func(t *testing.T) {
 		app.store.Customers.(*store.MockCustomerStore).GetCustomerByEmailFunc = func(ctx context.Context, email string) (*store.Customer, error) {
 			return nil, errors.New("some error")
 		}
@@ -261,8 +262,8 @@ func TestSignInUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "some error")
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "some error")
 	})
 
 	t.Run("no bad request if user lookup returns no rows and staff returns user id (happy staff path)", func(t *testing.T) {
@@ -290,7 +291,7 @@ func TestSignInUser(t *testing.T) {
 		assert.Equal(t, http.StatusOK, recorder.Code)
 	})
 
-	t.Run("bad request if staff lookup returns error (even no rows)", func(t *testing.T) {
+	t.Run("unauthorized if there is no staff member for the email", func(t *testing.T) {
 		app.store.Customers.(*store.MockCustomerStore).GetCustomerByEmailFunc = func(ctx context.Context, email string) (*store.Customer, error) {
 			return nil, sql.ErrNoRows
 		}
@@ -304,11 +305,11 @@ func TestSignInUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "sql: no rows in result set")
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"invalid_credentials"`)
 	})
 
-	t.Run("bad request if user lookup returns error (even no rows)", func(t *testing.T) {
+	t.Run("unauthorized if there is no user for the staff member", func(t *testing.T) {
 		app.store.Customers.(*store.MockCustomerStore).GetCustomerByEmailFunc = func(ctx context.Context, email string) (*store.Customer, error) {
 			return nil, sql.ErrNoRows
 		}
@@ -327,11 +328,11 @@ func TestSignInUser(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "sql: no rows in result set")
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"invalid_credentials"`)
 	})
 
-	t.Run("bad request if password is incorrect", func(t *testing.T) {
+	t.Run("unauthorized if password is incorrect", func(t *testing.T) {
 		wrongPassword := "wrong"
 		hashedPassword := utils.Password{Plaintext: &wrongPassword}
 		err := hashedPassword.Set(wrongPassword)
@@ -358,7 +359,7 @@ func TestSignInUser(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "unauthorized")
+		assert.Contains(t, recorder.Body.String(), `"code":"invalid_credentials"`)
 	})
 
 	t.Run("otherwise happy path for customer as well (happy customer path)", func(t *testing.T) {
@@ -474,4 +475,27 @@ func TestAuthTokenMiddleware(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
 		assert.Contains(t, recorder.Body.String(), "unauthorized")
 	})
+	t.Run("unauthorized if the user's tokens were revoked after it was issued", func(t *testing.T) {
+		app.store.Roles.(*store.MockRoleStore).GetRoleByIDFunc = nil
+		revokedAt := time.Now().Add(time.Second)
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: 1}, TokensValidAfter: &revokedAt}, nil
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/rentals/1", nil)
+		assert.NoError(t, err)
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		revokedAt = time.Now().Add(-time.Hour)
+		recorder = httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.NotEqual(t, http.StatusUnauthorized, recorder.Code)
+	})
 }
@@ -2,9 +2,12 @@ package main
 
 import (
 	"net/http"
+	"strconv"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-chi/chi/v5"
 )
 
 type createCustomerPayload struct {
@@ -17,14 +20,19 @@ type createCustomerPayload struct {
 // CreateCustomer godoc
 //
 //	@Summary		Create customer
-//	@Description	Create a new customer for store by admin user
+//	@Description	Create a new customer for store by admin user. Publishes the customer.created webhook event.
 //	@Tags			3. Customers
 //	@Accept			json
 //	@Produce		json
-//	@Param			request	body		createCustomerPayload	true	"Create customer request"
-//	@Success		201		{object}	nil
-//	@Failure		400		{object}	utils.ErrorResponse
-//	@Failure		500		{object}	utils.ErrorResponse
+//	@Param			request			body		createCustomerPayload	true	"Create customer request"
+//	@Param			Idempotency-Key	header		string					false	"Key that makes retries of this request safe"
+//	@Success		201				{object}	nil
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		409				{object}	utils.Problem
+//	@Failure		422				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
 //	@Security		ApiKeyAuth
 //	@Router			/customers [post]
 func (app *application) createCustomer(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +44,7 @@ func (app *application) createCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = Validator.Struct(payload)
+	err = Validator.Struct(r, payload)
 	if err != nil {
 		app.errorHandler.BadRequest(w, r, err)
 		return
@@ -51,7 +59,7 @@ func (app *application) createCustomer(w http.ResponseWriter, r *http.Request) {
 
 	err = app.store.Customers.CreateCustomer(r.Context(), customer)
 	if err != nil {
-		app.errorHandler.InternalServerError(w, r, err)
+		app.errorHandler.Error(w, r, err)
 		return
 	}
 
@@ -60,3 +68,154 @@ func (app *application) createCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type customerResponse struct {
+	Data store.Customer `json:"data"`
+}
+
+type customerListResponse struct {
+	Data       []store.Customer `json:"data"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+// ListCustomers godoc
+//
+//	@Summary		List customers
+//	@Description	List customers. Filter with filter[field]=value or filter[field][op]=value (ops: eq, ne, lt, lte, gt, gte, like, in) on id, store_id, first_name, last_name, email, active and last_update. Sort by id, first_name, last_name or last_update.
+//	@Tags			3. Customers
+//	@Produce		json
+//	@Param			filter[store_id]	query		int		false	"Store ID"
+//	@Param			filter[active]		query		bool	false	"Active"
+//	@Param			sort				query		string	false	"Comma separated fields, prefix with - for descending"	default(last_name,first_name)
+//	@Param			limit				query		int		false	"Limit"													default(50)
+//	@Param			cursor				query		string	false	"next_cursor of the previous page"
+//	@Success		200					{object}	customerListResponse
+//	@Failure		400					{object}	utils.Problem
+//	@Failure		401					{object}	utils.Problem
+//	@Failure		403					{object}	utils.Problem
+//	@Failure		500					{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/customers [get]
+func (app *application) listCustomers(w http.ResponseWriter, r *http.Request) {
+	q, err := listquery.Parse(r.URL.Query(), store.CustomerList)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	customers, cursor, err := app.store.Customers.ListCustomers(r.Context(), q)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, customerListResponse{Data: customers, NextCursor: cursor}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// GetCustomerByID godoc
+//
+//	@Summary		Get customer by ID
+//	@Description	Get a customer by ID. The ETag header carries the customer's version for If-Match and If-None-Match.
+//	@Tags			3. Customers
+//	@Produce		json
+//	@Param			id				path		int		true	"Customer ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{object}	customerResponse
+//	@Success		304
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		404				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/customers/{id} [get]
+func (app *application) getCustomerByID(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	customer, err := app.store.Customers.GetCustomerByID(r.Context(), customerID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	app.writeVersioned(w, r, customer.LastUpdate, http.StatusOK, customerResponse{Data: *customer})
+}
+
+type updateCustomerPayload struct {
+	StoreID   int64  `json:"store_id" validate:"required,min=1" example:"1"`
+	FirstName string `json:"first_name" validate:"required,min=3,max=20" example:"John"`
+	LastName  string `json:"last_name" validate:"required,min=3,max=20" example:"Doe"`
+	Email     string `json:"email" validate:"required,email" example:"john.doe@example.com"`
+	Active    *bool  `json:"active" validate:"required" example:"true"`
+}
+
+// UpdateCustomer godoc
+//
+//	@Summary		Update customer
+//	@Description	Replace a customer. If-Match must carry the ETag of the version being edited, so concurrent edits are not overwritten. Publishes the customer.updated webhook event.
+//	@Tags			3. Customers
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Customer ID"
+//	@Param			If-Match	header		string					true	"ETag of the version being edited"
+//	@Param			request		body		updateCustomerPayload	true	"Update customer request"
+//	@Success		200			{object}	customerResponse
+//	@Failure		400			{object}	utils.Problem
+//	@Failure		401			{object}	utils.Problem
+//	@Failure		403			{object}	utils.Problem
+//	@Failure		404			{object}	utils.Problem
+//	@Failure		409			{object}	utils.Problem
+//	@Failure		412			{object}	utils.Problem
+//	@Failure		428			{object}	utils.Problem
+//	@Failure		500			{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/customers/{id} [put]
+func (app *application) updateCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	version, ok := app.expectedVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var payload updateCustomerPayload
+
+	err = utils.ReadJSON(w, r, &payload)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	err = Validator.Struct(r, payload)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	customer := &store.Customer{
+		ID:        int(customerID),
+		StoreID:   payload.StoreID,
+		FirstName: payload.FirstName,
+		LastName:  payload.LastName,
+		Email:     payload.Email,
+		Active:    *payload.Active,
+	}
+
+	err = app.store.Customers.UpdateCustomer(r.Context(), customer, version)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	app.writeVersioned(w, r, customer.LastUpdate, http.StatusOK, customerResponse{Data: *customer})
+}
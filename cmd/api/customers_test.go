@@ -3,15 +3,20 @@ package main
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateCustomer(t *testing.T) {
@@ -41,7 +46,7 @@ func TestCreateCustomer(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "Key: 'createCustomerPayload.Email' Error:Field validation for 'Email' failed on the 'required' tag")
+		assert.Contains(t, recorder.Body.String(), `"field":"email","rule":"required"`)
 	})
 
 	t.Run("customer cannot create customers", func(t *testing.T) {
@@ -63,8 +68,8 @@ func TestCreateCustomer(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "unauthorized")
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"forbidden"`)
 	})
 
 	t.Run("only staff should be able to create customers (happy path)", func(t *testing.T) {
@@ -113,4 +118,117 @@ func TestCreateCustomer(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 		assert.Contains(t, recorder.Body.String(), "the server encountered a problem and could not process your request")
 	})
+	t.Run("it returns a conflict problem if the email is taken", func(t *testing.T) {
+		app.store.Customers.(*store.MockCustomerStore).CreateCustomerFunc = func(ctx context.Context, customer *store.Customer) error {
+			return store.ErrCustomerEmailTaken.Wrap(errors.New("duplicate key value violates unique constraint"))
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/customers", bytes.NewBufferString(`{"store_id": 1, "first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`))
+		assert.NoError(t, err)
+
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+
+		var problem utils.Problem
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, "customer_email_taken", problem.Code)
+		assert.Equal(t, http.StatusConflict, problem.Status)
+		assert.Equal(t, "/v1/customers", problem.Instance)
+		assert.NotEmpty(t, problem.RequestID)
+		assert.NotContains(t, problem.Detail, "duplicate key")
+	})
+}
+
+func TestCustomerVersioning(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+
+	lastUpdate := time.Date(2025, 5, 1, 12, 30, 0, 123456000, time.UTC)
+	app.store.Customers.(*store.MockCustomerStore).GetCustomerByIDFunc = func(ctx context.Context, id int64) (*store.Customer, error) {
+		if id != 11 {
+			return nil, sql.ErrNoRows
+		}
+		return &store.Customer{ID: 11, FirstName: "Lisa", LastUpdate: lastUpdate}, nil
+	}
+
+	request := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	const body = `{"store_id": 1, "first_name": "Lisa", "last_name": "Anderson", "email": "lisa@example.com", "active": true}`
+
+	t.Run("it should return the customer with an ETag", func(t *testing.T) {
+		recorder := request(http.MethodGet, "/v1/customers/11", "", nil)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, etag(lastUpdate), recorder.Header().Get("ETag"))
+		assert.Contains(t, recorder.Body.String(), `"first_name":"Lisa"`)
+	})
+
+	t.Run("it should return not modified for a matching If-None-Match", func(t *testing.T) {
+		recorder := request(http.MethodGet, "/v1/customers/11", "", map[string]string{"If-None-Match": `"other", ` + etag(lastUpdate)})
+
+		assert.Equal(t, http.StatusNotModified, recorder.Code)
+		assert.Empty(t, recorder.Body.String())
+	})
+
+	t.Run("it should return not found for a missing customer", func(t *testing.T) {
+		recorder := request(http.MethodGet, "/v1/customers/12", "", nil)
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("it should require If-Match on update", func(t *testing.T) {
+		recorder := request(http.MethodPut, "/v1/customers/11", body, nil)
+
+		assert.Equal(t, http.StatusPreconditionRequired, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"if_match_required"`)
+	})
+
+	t.Run("it should return precondition failed when the customer changed", func(t *testing.T) {
+		app.store.Customers.(*store.MockCustomerStore).UpdateCustomerFunc = func(ctx context.Context, customer *store.Customer, expectedVersion *time.Time) error {
+			return store.ErrVersionMismatch
+		}
+
+		recorder := request(http.MethodPut, "/v1/customers/11", body, map[string]string{"If-Match": etag(lastUpdate)})
+
+		assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"version_mismatch"`)
+	})
+
+	t.Run("it should update the customer and return the new ETag", func(t *testing.T) {
+		updated := lastUpdate.Add(time.Minute)
+		app.store.Customers.(*store.MockCustomerStore).UpdateCustomerFunc = func(ctx context.Context, customer *store.Customer, expectedVersion *time.Time) error {
+			require.NotNil(t, expectedVersion)
+			assert.True(t, store.SameVersion(lastUpdate, *expectedVersion))
+			assert.Equal(t, 11, customer.ID)
+			assert.True(t, customer.Active)
+			customer.LastUpdate = updated
+			return nil
+		}
+
+		recorder := request(http.MethodPut, "/v1/customers/11", body, map[string]string{"If-Match": etag(lastUpdate)})
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, etag(updated), recorder.Header().Get("ETag"))
+	})
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type dunningCaseResponse struct {
+	Data store.DunningCase `json:"data"`
+}
+
+type dunningStepPayload struct {
+	Note string `json:"note" validate:"max=500" example:"Customer called, returning on Friday"`
+}
+
+// GetDunningCase godoc
+//
+//	@Summary		Get dunning case
+//	@Description	Get the dunning case of an overdue rental with every step taken so far: opened, reminder, checkout_blocked, lost, paused, resumed, waived and returned.
+//	@Tags			4. Rentals
+//	@Produce		json
+//	@Param			id	path		int	true	"Rental ID"
+//	@Success		200	{object}	dunningCaseResponse
+//	@Failure		400	{object}	utils.Problem
+//	@Failure		401	{object}	utils.Problem
+//	@Failure		403	{object}	utils.Problem
+//	@Failure		404	{object}	utils.Problem
+//	@Failure		500	{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/rentals/{id}/dunning [get]
+func (app *application) getDunningCase(w http.ResponseWriter, r *http.Request) {
+	rentalID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	dunningCase, err := app.store.Dunning.GetDunningCase(r.Context(), rentalID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, dunningCaseResponse{Data: *dunningCase}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// PauseDunning godoc
+//
+//	@Summary		Pause dunning
+//	@Description	Stop sending reminders and escalating an open dunning case. Checkout stays blocked if it already was.
+//	@Tags			4. Rentals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Rental ID"
+//	@Param			request	body		dunningStepPayload	false	"Reason"
+//	@Success		200		{object}	dunningCaseResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		404		{object}	utils.Problem
+//	@Failure		409		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/rentals/{id}/dunning/pause [post]
+func (app *application) pauseDunning(w http.ResponseWriter, r *http.Request) {
+	app.updateDunningCase(w, r, store.DunningStepPaused)
+}
+
+// ResumeDunning godoc
+//
+//	@Summary		Resume dunning
+//	@Description	Resume a paused dunning case. Reminders missed while it was paused are not sent; the case continues with the next due step.
+//	@Tags			4. Rentals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Rental ID"
+//	@Param			request	body		dunningStepPayload	false	"Reason"
+//	@Success		200		{object}	dunningCaseResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		404		{object}	utils.Problem
+//	@Failure		409		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/rentals/{id}/dunning/resume [post]
+func (app *application) resumeDunning(w http.ResponseWriter, r *http.Request) {
+	app.updateDunningCase(w, r, store.DunningStepResumed)
+}
+
+// WaiveDunning godoc
+//
+//	@Summary		Waive dunning
+//	@Description	End an open, paused or lost dunning case. The customer is no longer blocked by it and a replacement charge is voided.
+//	@Tags			4. Rentals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Rental ID"
+//	@Param			request	body		dunningStepPayload	false	"Reason"
+//	@Success		200		{object}	dunningCaseResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		404		{object}	utils.Problem
+//	@Failure		409		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/rentals/{id}/dunning/waive [post]
+func (app *application) waiveDunning(w http.ResponseWriter, r *http.Request) {
+	app.updateDunningCase(w, r, store.DunningStepWaived)
+}
+
+func (app *application) updateDunningCase(w http.ResponseWriter, r *http.Request, step string) {
+	rentalID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	// The note is optional, and so is the body.
+	var payload dunningStepPayload
+	if err := utils.ReadJSON(w, r, &payload); err != nil && !errors.Is(err, io.EOF) {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(r, payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	dunningCase, err := app.store.Dunning.UpdateDunningCase(r.Context(), rentalID, step, payload.Note)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, dunningCaseResponse{Data: *dunningCase}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDunning(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	require.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{
+			ID: 1,
+			Role: &store.Role{
+				ID: 1,
+			},
+		}, nil
+	}
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("admin should be able to get the dunning case of a rental", func(t *testing.T) {
+		app.store.Dunning.(*store.MockDunningStore).GetDunningCaseFunc = func(ctx context.Context, rentalID int64) (*store.DunningCase, error) {
+			return &store.DunningCase{
+				RentalID: int(rentalID),
+				Status:   store.DunningOpen,
+				Steps:    []store.DunningStep{{ID: 1, Step: store.DunningStepOpened}},
+			}, nil
+		}
+
+		recorder := send(http.MethodGet, "/v1/rentals/7/dunning", "")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"rental_id":7`)
+		assert.Contains(t, recorder.Body.String(), `"step":"opened"`)
+	})
+
+	t.Run("not found if the rental has no dunning case", func(t *testing.T) {
+		app.store.Dunning.(*store.MockDunningStore).GetDunningCaseFunc = nil
+
+		recorder := send(http.MethodGet, "/v1/rentals/7/dunning", "")
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	for path, step := range map[string]string{
+		"pause":  store.DunningStepPaused,
+		"resume": store.DunningStepResumed,
+		"waive":  store.DunningStepWaived,
+	} {
+		t.Run("admin should be able to "+path+" dunning", func(t *testing.T) {
+			var gotStep, gotNote string
+			app.store.Dunning.(*store.MockDunningStore).UpdateDunningCaseFunc = func(ctx context.Context, rentalID int64, step, note string) (*store.DunningCase, error) {
+				gotStep, gotNote = step, note
+				return &store.DunningCase{RentalID: int(rentalID)}, nil
+			}
+
+			recorder := send(http.MethodPost, "/v1/rentals/7/dunning/"+path, `{"note": "Customer called"}`)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, step, gotStep)
+			assert.Equal(t, "Customer called", gotNote)
+		})
+	}
+
+	t.Run("the note should be optional", func(t *testing.T) {
+		recorder := send(http.MethodPost, "/v1/rentals/7/dunning/pause", "")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("conflict if the step does not apply to the case", func(t *testing.T) {
+		app.store.Dunning.(*store.MockDunningStore).UpdateDunningCaseFunc = func(ctx context.Context, rentalID int64, step, note string) (*store.DunningCase, error) {
+			return nil, store.ErrDunningStepInvalid
+		}
+
+		recorder := send(http.MethodPost, "/v1/rentals/7/dunning/resume", "")
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "dunning_step_invalid")
+	})
+
+	t.Run("bad request if the note is too long", func(t *testing.T) {
+		recorder := send(http.MethodPost, "/v1/rentals/7/dunning/pause", fmt.Sprintf(`{"note": %q}`, strings.Repeat("a", 501)))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("non-admin user should not be able to waive dunning", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: 2}}, nil
+		}
+
+		recorder := send(http.MethodPost, "/v1/rentals/7/dunning/waive", "")
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+)
+
+// etag derives a weak entity tag from a row's last_update column.
+func etag(lastUpdate time.Time) string {
+	return `W/"` + strconv.FormatInt(lastUpdate.UnixMicro(), 36) + `"`
+}
+
+// parseETag returns the last_update encoded in an entity tag made by etag.
+func parseETag(value string) (time.Time, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+	value, ok := strings.CutPrefix(value, `"`)
+	if !ok {
+		return time.Time{}, false
+	}
+	value, ok = strings.CutSuffix(value, `"`)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	micros, err := strconv.ParseInt(value, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMicro(micros).UTC(), true
+}
+
+// writeVersioned writes data with an ETag for lastUpdate. When the request's
+// If-None-Match already names that version, it responds 304 without a body.
+func (app *application) writeVersioned(w http.ResponseWriter, r *http.Request, lastUpdate time.Time, status int, data any) {
+	tag := etag(lastUpdate)
+	w.Header().Set("ETag", tag)
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	if err := utils.WriteJSONResponse(w, status, data); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// expectedVersion reads the If-Match header that requests modifying a
+// versioned resource must carry. It returns nil for "*", which matches any
+// version. Since our tags are weak, If-Match uses the weak comparison.
+// On failure the error response has been written and ok is false.
+func (app *application) expectedVersion(w http.ResponseWriter, r *http.Request) (version *time.Time, ok bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		app.errorHandler.Error(w, r, store.ErrPreconditionRequired)
+		return nil, false
+	}
+	if ifMatch == "*" {
+		return nil, true
+	}
+
+	lastUpdate, ok := parseETag(ifMatch)
+	if !ok {
+		app.errorHandler.Error(w, r, store.ErrVersionMismatch)
+		return nil, false
+	}
+	return &lastUpdate, true
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	lastUpdate := time.Date(2013, 5, 26, 14, 50, 58, 951000000, time.UTC)
+
+	tag := etag(lastUpdate)
+	assert.Regexp(t, `^W/"[0-9a-z]+"$`, tag)
+
+	parsed, ok := parseETag(tag)
+	assert.True(t, ok)
+	assert.True(t, parsed.Equal(lastUpdate))
+
+	parsed, ok = parseETag(tag[2:])
+	assert.True(t, ok, "strong form of the tag should be accepted")
+	assert.True(t, parsed.Equal(lastUpdate))
+
+	for _, invalid := range []string{"", "abc", `W/"`, `"not base36!"`} {
+		_, ok := parseETag(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
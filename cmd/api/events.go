@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/activity"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// eventReplayPageSize is how many outbox events are read per query when
+	// a stream resumes from Last-Event-ID.
+	eventReplayPageSize = 500
+	// eventRetry tells clients how long to wait before reconnecting, in
+	// milliseconds.
+	eventRetry = 3000
+)
+
+// StreamStoreEvents godoc
+//
+//	@Summary		Stream store activity
+//	@Description	Server-Sent Events stream of a store's rental.created, rental.returned and inventory.availability events, for staff of that store. Event IDs can be sent back in Last-Event-ID, or the last_event_id query parameter, to resume after a disconnect. Comment lines are sent as heartbeats while there is no activity. A client that falls too far behind is disconnected and should resume.
+//	@Tags			6. Catalog
+//	@Produce		text/event-stream
+//	@Param			id				path		int		true	"Store ID"
+//	@Param			Last-Event-ID	header		string	false	"ID of the last event received"
+//	@Param			last_event_id	query		string	false	"ID of the last event received, for clients that cannot set headers"
+//	@Success		200				{string}	string	"text/event-stream"
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/stores/{id}/events [get]
+func (app *application) streamStoreEvents(w http.ResponseWriter, r *http.Request) {
+	storeID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	user := app.getUserContext(r)
+	if user == nil {
+		app.errorHandler.Unauthorized(w, r, errors.New("unauthorized"))
+		return
+	}
+	staff, err := app.store.Staff.GetStaffByUserID(r.Context(), int64(user.ID))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	if staff == nil || !staff.Active || staff.StoreID != storeID {
+		app.errorHandler.Forbidden(w, r, errors.New("staff of the store required"))
+		return
+	}
+
+	var resume *activity.Cursor
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventID != "" {
+		cursor, err := activity.ParseCursor(lastEventID)
+		if err != nil {
+			app.errorHandler.BadRequest(w, r, err)
+			return
+		}
+		resume = &cursor
+	}
+
+	// Subscribing before replaying means no event falls between the two;
+	// events seen in both are only sent once.
+	sub := app.activity.Subscribe(storeID)
+	defer app.activity.Unsubscribe(sub)
+
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &eventStream{w: w, controller: controller}
+	stream.writeLine(fmt.Sprintf("retry: %d", eventRetry), false)
+
+	replayed := map[int64]bool{}
+	if resume != nil {
+		if err := app.replayStoreEvents(r, stream, storeID, *resume, replayed); err != nil {
+			app.logger.Errorw("failed to replay store events", "store_id", storeID, "error", err)
+			return
+		}
+	}
+	if err := stream.flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(app.config.Stream.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := stream.writeLine(": heartbeat", true); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged {
+					app.logger.Infow("dropped lagging event stream", "store_id", storeID, "user_id", user.ID)
+				}
+				return
+			}
+			if replayed[event.ID.Seq] || (resume != nil && !event.ID.After(*resume)) {
+				continue
+			}
+			if err := stream.send(event, true); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// replayStoreEvents sends the events of a store after cursor and records
+// which outbox events it sent.
+func (app *application) replayStoreEvents(r *http.Request, stream *eventStream, storeID int64, cursor activity.Cursor, replayed map[int64]bool) error {
+	// The outbox event the cursor points into may still have parts to send.
+	afterID := cursor.Seq - 1
+	for {
+		page, err := app.store.Outbox.ListStoreRentalEvents(r.Context(), storeID, max(afterID, 0), eventReplayPageSize)
+		if err != nil {
+			return err
+		}
+		for i := range page {
+			events, err := activity.FromRentalEvent(&page[i].Event, page[i].Item)
+			if err != nil {
+				return err
+			}
+			for _, event := range events {
+				if event.ID.After(cursor) {
+					if err := stream.send(event, false); err != nil {
+						return err
+					}
+				}
+			}
+			replayed[page[i].Event.ID] = true
+			afterID = page[i].Event.ID
+		}
+		if len(page) < eventReplayPageSize {
+			return nil
+		}
+	}
+}
+
+// eventStream writes Server-Sent Events.
+type eventStream struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+}
+
+func (s *eventStream) send(event activity.Event, flush bool) error {
+	if _, err := fmt.Fprintf(s.w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data); err != nil {
+		return err
+	}
+	if flush {
+		return s.flush()
+	}
+	return nil
+}
+
+// writeLine writes a line that is not an event, such as the retry field or a
+// heartbeat comment.
+func (s *eventStream) writeLine(line string, flush bool) error {
+	if _, err := fmt.Fprintf(s.w, "%s\n\n", line); err != nil {
+		return err
+	}
+	if flush {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *eventStream) flush() error {
+	return s.controller.Flush()
+}
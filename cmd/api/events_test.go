@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/activity"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamStoreEvents(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.Stream.HeartbeatInterval = 20 * time.Millisecond
+	server := httptest.NewServer(app.mountRoutes())
+	defer server.Close()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+	app.store.Staff.(*store.MockStaffStore).GetStaffByUserIDFunc = func(ctx context.Context, userID int64) (*store.Staff, error) {
+		return &store.Staff{ID: 3, StoreID: 1, Active: true}, nil
+	}
+
+	open := func(t *testing.T, path, lastEventID string) (*http.Response, *bufio.Reader) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		t.Cleanup(cancel)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp, bufio.NewReader(resp.Body)
+	}
+
+	// nextEvent returns the id and event fields of the next event, skipping
+	// heartbeats and the retry field.
+	nextEvent := func(t *testing.T, reader *bufio.Reader) (string, string) {
+		var id, event string
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case line == "" && id != "":
+				return id, event
+			}
+		}
+	}
+
+	rentalEvent := func(id int64, eventType string) store.StoreRentalEvent {
+		return store.StoreRentalEvent{
+			Event: store.OutboxEvent{ID: id, AggregateType: "rental", EventType: eventType, Payload: json.RawMessage(`{"id":1,"inventory_id":5}`)},
+			Item:  store.InventoryItem{ID: 5, FilmID: 6, StoreID: 1},
+		}
+	}
+
+	publish := func(e store.StoreRentalEvent) {
+		events, err := activity.FromRentalEvent(&e.Event, e.Item)
+		require.NoError(t, err)
+		for _, event := range events {
+			app.activity.Publish(event)
+		}
+	}
+
+	t.Run("staff should receive live events and heartbeats", func(t *testing.T) {
+		resp, reader := open(t, "/v1/stores/1/events", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		// The handler subscribes before it responds, so events published
+		// from here on reach the stream.
+
+		heartbeat, err := reader.ReadString('\n')
+		for err == nil && heartbeat != ": heartbeat\n" {
+			heartbeat, err = reader.ReadString('\n')
+		}
+		require.NoError(t, err)
+
+		publish(rentalEvent(7, store.EventRentalCreated))
+
+		id, event := nextEvent(t, reader)
+		assert.Equal(t, "7:0", id)
+		assert.Equal(t, store.EventRentalCreated, event)
+
+		id, event = nextEvent(t, reader)
+		assert.Equal(t, "7:1", id)
+		assert.Equal(t, activity.TypeInventoryAvailability, event)
+	})
+
+	t.Run("it should resume after Last-Event-ID without duplicates", func(t *testing.T) {
+		var afterID int64
+		app.store.Outbox.(*store.MockOutboxStore).ListStoreRentalEventsFunc = func(ctx context.Context, storeID, after int64, limit int) ([]store.StoreRentalEvent, error) {
+			afterID = after
+			return []store.StoreRentalEvent{rentalEvent(7, store.EventRentalCreated), rentalEvent(8, store.EventRentalReturned)}, nil
+		}
+
+		resp, reader := open(t, "/v1/stores/1/events", "7:0")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(6), afterID)
+
+		for _, want := range []string{"7:1", "8:0", "8:1"} {
+			id, _ := nextEvent(t, reader)
+			assert.Equal(t, want, id)
+		}
+
+		// Already replayed, then new.
+		publish(rentalEvent(8, store.EventRentalReturned))
+		publish(rentalEvent(9, store.EventRentalCreated))
+
+		id, _ := nextEvent(t, reader)
+		assert.Equal(t, "9:0", id)
+	})
+
+	t.Run("bad request for a malformed Last-Event-ID", func(t *testing.T) {
+		resp, _ := open(t, "/v1/stores/1/events", "yesterday")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("forbidden for staff of another store", func(t *testing.T) {
+		resp, _ := open(t, "/v1/stores/2/events", "")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("forbidden for users who are not staff", func(t *testing.T) {
+		app.store.Staff.(*store.MockStaffStore).GetStaffByUserIDFunc = nil
+
+		resp, _ := open(t, "/v1/stores/1/events", "")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
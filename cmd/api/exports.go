@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	csvContentType    = "text/csv"
+
+	// An export is flushed to the client after exportFlushRows rows or
+	// exportFlushInterval, whichever comes first.
+	exportFlushRows     = 500
+	exportFlushInterval = time.Second
+
+	// exportWriteTimeout replaces the server's write timeout for exports. It
+	// is extended on every flush, so only a stalled export times out.
+	exportWriteTimeout = 30 * time.Second
+)
+
+var ErrNotAcceptable = utils.NewError(utils.KindNotAcceptable, "not_acceptable", "exports are available as application/x-ndjson or text/csv")
+
+// exportWriter writes rows as NDJSON or CSV and flushes them periodically.
+// The response header is written with the first row.
+type exportWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	filename   string
+	columns    []string
+	csv        *csv.Writer
+	json       *json.Encoder
+	started    bool
+	pending    int
+	lastFlush  time.Time
+}
+
+// newExportWriter picks the format from the Accept header. NDJSON is the
+// default when the client accepts anything.
+func newExportWriter(w http.ResponseWriter, r *http.Request, name string, columns []string) (*exportWriter, error) {
+	contentType, err := negotiateExportFormat(r.Header.Get("Accept"))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &exportWriter{
+		w:          w,
+		controller: http.NewResponseController(w),
+		columns:    columns,
+		lastFlush:  time.Now(),
+	}
+	if contentType == csvContentType {
+		e.csv = csv.NewWriter(w)
+		e.filename = name + ".csv"
+	} else {
+		e.json = json.NewEncoder(w)
+		e.filename = name + ".ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	return e, nil
+}
+
+func negotiateExportFormat(accept string) (string, error) {
+	if accept == "" {
+		return ndjsonContentType, nil
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case ndjsonContentType, "application/*", "*/*":
+			return ndjsonContentType, nil
+		case csvContentType, "text/*":
+			return csvContentType, nil
+		}
+	}
+	return "", ErrNotAcceptable
+}
+
+func (e *exportWriter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+
+	if err := e.extendDeadline(); err != nil {
+		return err
+	}
+
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+	e.w.Header().Set("Cache-Control", "no-store")
+	e.w.WriteHeader(http.StatusOK)
+
+	if e.csv != nil {
+		return e.csv.Write(e.columns)
+	}
+	return nil
+}
+
+// write writes a row. row is encoded as JSON for NDJSON and record is used
+// for CSV, in the order of the columns.
+func (e *exportWriter) write(row any, record func() []string) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+
+	if e.csv != nil {
+		if err := e.csv.Write(record()); err != nil {
+			return err
+		}
+	} else if err := e.json.Encode(row); err != nil {
+		return err
+	}
+
+	e.pending++
+	if e.pending >= exportFlushRows || time.Since(e.lastFlush) >= exportFlushInterval {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	e.pending = 0
+	e.lastFlush = time.Now()
+	if err := e.controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return e.extendDeadline()
+}
+
+func (e *exportWriter) extendDeadline() error {
+	err := e.controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// finish writes the header of an empty export and flushes the last rows.
+func (e *exportWriter) finish() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// stream runs the export and reports its outcome. Errors before the first
+// row are sent as a problem response. Once rows have been sent the status
+// can't change, so the connection is aborted to make the truncation visible
+// to the client, unless the client went away.
+func (app *application) stream(w http.ResponseWriter, r *http.Request, e *exportWriter, export func(ctx context.Context) error) {
+	err := export(r.Context())
+	if err == nil {
+		err = e.finish()
+	}
+	if err == nil {
+		return
+	}
+
+	if !e.started {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+
+	logger, ok := utils.LoggerFromContext(r.Context())
+	if !ok {
+		logger = app.logger.With(utils.RequestLogFields(r)...)
+	}
+	logger.Errorw("export failed", "url", r.URL.Path, "error", err.Error())
+	panic(http.ErrAbortHandler)
+}
+
+func parseExportFilter(r *http.Request) (*store.ExportFilter, error) {
+	query := r.URL.Query()
+
+	from, err := parseExportTime(query.Get("from"))
+	if err != nil {
+		return nil, errors.New("from must be an RFC3339 timestamp or a YYYY-MM-DD date")
+	}
+	to, err := parseExportTime(query.Get("to"))
+	if err != nil {
+		return nil, errors.New("to must be an RFC3339 timestamp or a YYYY-MM-DD date")
+	}
+	if !to.After(from) {
+		return nil, errors.New("to must be after from")
+	}
+
+	return &store.ExportFilter{From: from, To: to}, nil
+}
+
+// parseExportTime parses value in UTC, which is how the dvdrental timestamps
+// are stored.
+func parseExportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func formatNullableTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+var rentalExportColumns = []string{"id", "rental_date", "inventory_id", "customer_id", "return_date", "staff_id", "last_update"}
+
+// ExportRentals godoc
+//
+//	@Summary		Export rentals
+//	@Description	Stream all rentals made in a date range, oldest first, as NDJSON or CSV depending on the Accept header
+//	@Tags			5. Admin
+//	@Produce		application/x-ndjson,text/csv
+//	@Param			from	query		string	true	"From (RFC3339 or YYYY-MM-DD, inclusive)"
+//	@Param			to		query		string	true	"To (RFC3339 or YYYY-MM-DD, exclusive)"
+//	@Success		200		{object}	store.Rental	"One rental per line"
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		406		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/exports/rentals [get]
+func (app *application) exportRentals(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseExportFilter(r)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	e, err := newExportWriter(w, r, exportName("rentals", filter), rentalExportColumns)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	app.stream(w, r, e, func(ctx context.Context) error {
+		return app.store.Rentals.StreamRentals(ctx, *filter, func(rental *store.Rental) error {
+			return e.write(rental, func() []string {
+				return []string{
+					strconv.Itoa(rental.ID),
+					rental.RentalDate.Format(time.RFC3339),
+					strconv.Itoa(rental.InventoryID),
+					strconv.Itoa(rental.CustomerID),
+					formatNullableTime(rental.ReturnDate),
+					strconv.Itoa(rental.StaffID),
+					rental.LastUpdate.Format(time.RFC3339),
+				}
+			})
+		})
+	})
+}
+
+var paymentExportColumns = []string{"id", "customer_id", "staff_id", "rental_id", "amount", "payment_date"}
+
+// ExportPayments godoc
+//
+//	@Summary		Export payments
+//	@Description	Stream all payments made in a date range, oldest first, as NDJSON or CSV depending on the Accept header
+//	@Tags			5. Admin
+//	@Produce		application/x-ndjson,text/csv
+//	@Param			from	query		string	true	"From (RFC3339 or YYYY-MM-DD, inclusive)"
+//	@Param			to		query		string	true	"To (RFC3339 or YYYY-MM-DD, exclusive)"
+//	@Success		200		{object}	store.Payment	"One payment per line"
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		406		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/exports/payments [get]
+func (app *application) exportPayments(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseExportFilter(r)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	e, err := newExportWriter(w, r, exportName("payments", filter), paymentExportColumns)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	app.stream(w, r, e, func(ctx context.Context) error {
+		return app.store.Payments.StreamPayments(ctx, *filter, func(payment *store.Payment) error {
+			return e.write(payment, func() []string {
+				return []string{
+					strconv.Itoa(payment.ID),
+					strconv.Itoa(payment.CustomerID),
+					strconv.Itoa(payment.StaffID),
+					strconv.Itoa(payment.RentalID),
+					strconv.FormatFloat(payment.Amount, 'f', 2, 64),
+					payment.PaymentDate.Format(time.RFC3339),
+				}
+			})
+		})
+	})
+}
+
+func exportName(resource string, filter *store.ExportFilter) string {
+	return fmt.Sprintf("%s-%s-%s", resource, filter.From.Format("20060102"), filter.To.Format("20060102"))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportRentals(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+
+	rentalDate := time.Date(2005, 6, 14, 22, 53, 30, 0, time.UTC)
+	returnDate := rentalDate.Add(72 * time.Hour)
+	var filter store.ExportFilter
+	app.store.Rentals.(*store.MockRentalStore).StreamRentalsFunc = func(ctx context.Context, f store.ExportFilter, fn func(rental *store.Rental) error) error {
+		filter = f
+		for _, rental := range []*store.Rental{
+			{ID: 1, RentalDate: rentalDate, InventoryID: 367, CustomerID: 130, ReturnDate: &returnDate, StaffID: 1, LastUpdate: rentalDate},
+			{ID: 2, RentalDate: rentalDate, InventoryID: 1525, CustomerID: 459, StaffID: 1, LastUpdate: rentalDate},
+		} {
+			if err := fn(rental); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	export := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/exports/rentals"+query, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("it should stream NDJSON by default", func(t *testing.T) {
+		recorder := export("?from=2005-06-01&to=2005-07-01", "")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, ndjsonContentType, recorder.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="rentals-20050601-20050701.ndjson"`, recorder.Header().Get("Content-Disposition"))
+		assert.Equal(t, store.ExportFilter{From: time.Date(2005, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2005, 7, 1, 0, 0, 0, 0, time.UTC)}, filter)
+
+		lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+		assert.Len(t, lines, 2)
+		var rental store.Rental
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &rental))
+		assert.Equal(t, 459, rental.CustomerID)
+		assert.Nil(t, rental.ReturnDate)
+	})
+
+	t.Run("it should stream CSV with a header row", func(t *testing.T) {
+		recorder := export("?from=2005-06-01&to=2005-07-01", "text/csv")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, csvContentType, recorder.Header().Get("Content-Type"))
+		assert.Equal(t, strings.Join([]string{
+			"id,rental_date,inventory_id,customer_id,return_date,staff_id,last_update",
+			"1,2005-06-14T22:53:30Z,367,130,2005-06-17T22:53:30Z,1,2005-06-14T22:53:30Z",
+			"2,2005-06-14T22:53:30Z,1525,459,,1,2005-06-14T22:53:30Z",
+		}, "\n")+"\n", recorder.Body.String())
+	})
+
+	t.Run("it should reject unsupported formats", func(t *testing.T) {
+		recorder := export("?from=2005-06-01&to=2005-07-01", "application/xml")
+
+		assert.Equal(t, http.StatusNotAcceptable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"not_acceptable"`)
+	})
+
+	t.Run("it should require a date range", func(t *testing.T) {
+		recorder := export("?from=2005-07-01&to=2005-06-01", "")
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "to must be after from")
+	})
+
+	t.Run("it should report errors before the first row as a problem", func(t *testing.T) {
+		app.store.Rentals.(*store.MockRentalStore).StreamRentalsFunc = func(ctx context.Context, f store.ExportFilter, fn func(rental *store.Rental) error) error {
+			return errors.New("connection refused")
+		}
+
+		recorder := export("?from=2005-06-01&to=2005-07-01", "")
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+	})
+
+	t.Run("customer should not be able to export rentals", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 2, Role: &store.Role{ID: 2}}, nil
+		}
+
+		recorder := export("?from=2005-06-01&to=2005-07-01", "")
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
+
+func TestExportPaymentsCancellation(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	streamed := 0
+	app.store.Payments.(*store.MockPaymentStore).StreamPaymentsFunc = func(ctx context.Context, f store.ExportFilter, fn func(payment *store.Payment) error) error {
+		for i := 1; ; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(&store.Payment{ID: i, Amount: 2.99}); err != nil {
+				return err
+			}
+			streamed++
+			if streamed == 3 {
+				cancel()
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/exports/payments?from=2007-01-01&to=2007-06-01", nil).WithContext(ctx)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+
+	assert.Equal(t, 3, streamed)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, strings.Split(strings.TrimSpace(recorder.Body.String()), "\n"), 3)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type filmResponse struct {
+	Data store.Film `json:"data"`
+}
+
+type filmListResponse struct {
+	Data       []store.Film `json:"data"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// ratingFilter is a single value of filter[rating], validated so that a
+// misspelled rating is reported instead of matching no films.
+type ratingFilter struct {
+	Rating string `json:"filter[rating]" validate:"film_rating"`
+}
+
+func validateRatingFilters(r *http.Request, filters []listquery.Filter) error {
+	for _, filter := range filters {
+		if filter.Field != "rating" || filter.Operator == listquery.Like {
+			continue
+		}
+		values, ok := filter.Value.([]any)
+		if !ok {
+			values = []any{filter.Value}
+		}
+		for _, value := range values {
+			rating, _ := value.(string)
+			if err := Validator.Struct(r, ratingFilter{Rating: rating}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListFilms godoc
+//
+//	@Summary		List films
+//	@Description	List films. Filter with filter[field]=value or filter[field][op]=value (ops: eq, ne, lt, lte, gt, gte, like, in) on id, title, release_year, language_id, rental_rate, length, replacement_cost, rating and last_update. Sort by id, title, rental_rate, replacement_cost or last_update.
+//	@Tags			6. Catalog
+//	@Produce		json
+//	@Param			filter[rating]	query		string	false	"Rating: G, PG, PG-13, R or NC-17"
+//	@Param			sort			query		string	false	"Comma separated fields, prefix with - for descending"	default(title)
+//	@Param			limit			query		int		false	"Limit"													default(50)
+//	@Param			cursor			query		string	false	"next_cursor of the previous page"
+//	@Success		200				{object}	filmListResponse
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/films [get]
+func (app *application) listFilms(w http.ResponseWriter, r *http.Request) {
+	q, err := listquery.Parse(r.URL.Query(), store.FilmList)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+	if err := validateRatingFilters(r, q.Filters); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	films, cursor, err := app.store.Films.ListFilms(r.Context(), q)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, filmListResponse{Data: films, NextCursor: cursor}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// GetFilmByID godoc
+//
+//	@Summary		Get film by ID
+//	@Description	Get a film by ID. The ETag header carries the film's version for If-None-Match.
+//	@Tags			6. Catalog
+//	@Produce		json
+//	@Param			id				path		int		true	"Film ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{object}	filmResponse
+//	@Success		304
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		404				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/films/{id} [get]
+func (app *application) getFilmByID(w http.ResponseWriter, r *http.Request) {
+	filmID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	film, err := app.store.Films.GetFilmByID(r.Context(), filmID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	app.writeVersioned(w, r, film.LastUpdate, http.StatusOK, filmResponse{Data: *film})
+}
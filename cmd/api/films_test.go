@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFilmByID(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 2, Role: &store.Role{ID: 2}}, nil
+	}
+
+	lastUpdate := time.Date(2013, 5, 26, 14, 50, 58, 951000000, time.UTC)
+	app.store.Films.(*store.MockFilmStore).GetFilmByIDFunc = func(ctx context.Context, id int64) (*store.Film, error) {
+		if id != 1 {
+			return nil, sql.ErrNoRows
+		}
+		return &store.Film{ID: 1, Title: "Academy Dinosaur", Rating: "PG", LastUpdate: lastUpdate}, nil
+	}
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("customers should be able to get a film", func(t *testing.T) {
+		recorder := get("/v1/films/1", "")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, etag(lastUpdate), recorder.Header().Get("ETag"))
+		assert.Contains(t, recorder.Body.String(), "Academy Dinosaur")
+	})
+
+	t.Run("it should return not modified for the current ETag", func(t *testing.T) {
+		recorder := get("/v1/films/1", etag(lastUpdate))
+
+		assert.Equal(t, http.StatusNotModified, recorder.Code)
+	})
+
+	t.Run("it should return the film for a stale ETag", func(t *testing.T) {
+		recorder := get("/v1/films/1", etag(lastUpdate.Add(-time.Hour)))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("it should return not found for a missing film", func(t *testing.T) {
+		recorder := get("/v1/films/2", "")
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestListFilms(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 2, Role: &store.Role{ID: 2}}, nil
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("it should pass the parsed query to the store and return the next cursor", func(t *testing.T) {
+		var query *listquery.Query
+		app.store.Films.(*store.MockFilmStore).ListFilmsFunc = func(ctx context.Context, q *listquery.Query) ([]store.Film, string, error) {
+			query = q
+			return []store.Film{{ID: 1, Title: "Academy Dinosaur", Rating: "PG"}}, "next-page", nil
+		}
+
+		recorder := get("/v1/films?filter[rating]=PG&sort=-rental_rate&limit=10")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"next_cursor":"next-page"`)
+		assert.Contains(t, recorder.Body.String(), "Academy Dinosaur")
+		if assert.NotNil(t, query) {
+			assert.Equal(t, 10, query.Limit)
+			assert.Equal(t, []listquery.Filter{{Field: "rating", Operator: listquery.Eq, Value: "PG"}}, query.Filters)
+			assert.Equal(t, []listquery.Sort{{Field: "rental_rate", Descending: true}, {Field: "id"}}, query.Sort)
+		}
+	})
+
+	t.Run("it should reject filters outside the whitelist", func(t *testing.T) {
+		recorder := get("/v1/films?filter[fulltext]=dinosaur")
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"invalid_list_query"`)
+	})
+
+	t.Run("it should reject an unknown rating with a localized message", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/films?filter[rating][in]=PG,PG-18", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("Accept-Language", "de")
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"field":"filter[rating]","rule":"film_rating"`)
+		assert.Contains(t, recorder.Body.String(), "filter[rating] muss einer der folgenden Werte sein: G, PG, PG-13, R, NC-17")
+	})
+
+	t.Run("it should reject a tampered cursor", func(t *testing.T) {
+		recorder := get("/v1/films?cursor=tampered")
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"invalid_cursor"`)
+	})
+}
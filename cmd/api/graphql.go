@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/graph"
+)
+
+type graphqlRequest struct {
+	Query         string         `json:"query" example:"{ films(first: 5) { nodes { title actors { firstName lastName } } nextCursor } }"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type graphqlResponse struct {
+	Data   map[string]any   `json:"data"`
+	Errors []map[string]any `json:"errors"`
+}
+
+// GraphQL godoc
+//
+//	@Summary		GraphQL
+//	@Description	Query films, actors, categories, stores, customers, rentals, payments and staff in one request. Customer, rental, payment and staff data and store managers require the admin role, like their REST endpoints. Queries deeper than GRAPHQL_MAX_DEPTH or with a complexity above GRAPHQL_MAX_COMPLEXITY are rejected; every element of a list counts as one resolution of its fields. Errors are reported in the errors array with a code extension.
+//	@Tags			6. Catalog
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		graphqlRequest	true	"GraphQL request"
+//	@Success		200		{object}	graphqlResponse
+//	@Failure		400		{object}	graphqlResponse
+//	@Failure		401		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/graphql [post]
+func (app *application) graphqlHandler() http.HandlerFunc {
+	handler := graph.NewHandler(app.store, graph.Options{
+		MaxDepth:      app.config.GraphQL.MaxDepth,
+		MaxComplexity: app.config.GraphQL.MaxComplexity,
+		Logger:        app.logger,
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := graph.WithUser(r.Context(), app.getUserContext(r))
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
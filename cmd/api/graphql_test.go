@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQL(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Customers.(*store.MockCustomerStore).GetCustomersByIDsFunc = func(ctx context.Context, ids []int64) ([]store.Customer, error) {
+		return []store.Customer{{ID: 1, FirstName: "Mary"}}, nil
+	}
+
+	query := func(role int, authorization string) *httptest.ResponseRecorder {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: role}}, nil
+		}
+		req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(`{"query":"{ customer(id: 1) { firstName } }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("it should require authentication", func(t *testing.T) {
+		recorder := query(1, "")
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("admins should be able to query customers", func(t *testing.T) {
+		recorder := query(1, fmt.Sprintf("Bearer %s", token))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"firstName":"Mary"`)
+	})
+
+	t.Run("customers should not be able to query customers", func(t *testing.T) {
+		recorder := query(2, fmt.Sprintf("Bearer %s", token))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"forbidden"`)
+		assert.NotContains(t, recorder.Body.String(), "Mary")
+	})
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	rentalv1 "github.com/andras-szesztai/dev-rental-api/proto/rental/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// grpcPublicMethods can be called without a token. The health and
+// reflection services are matched by prefix.
+var grpcPublicMethods = map[string]bool{
+	rentalv1.AuthService_SignIn_FullMethodName: true,
+}
+
+var grpcPublicPrefixes = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+// grpcAdminMethods require the admin role, like the REST routes wrapped in
+// CheckAdminMiddleware.
+var grpcAdminMethods = map[string]bool{
+	rentalv1.CustomerService_GetCustomer_FullMethodName:   true,
+	rentalv1.CustomerService_ListCustomers_FullMethodName: true,
+	rentalv1.RentalService_GetRental_FullMethodName:       true,
+	rentalv1.RentalService_ListRentals_FullMethodName:     true,
+}
+
+// newGRPCServer builds the gRPC server that serves the rental.v1 services
+// next to the REST router, backed by the same store.
+func (app *application) newGRPCServer() (*grpc.Server, *health.Server) {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(app.grpcLoggingInterceptor, app.grpcRecoveryInterceptor, app.grpcAuthInterceptor),
+		grpc.ChainStreamInterceptor(app.grpcStreamAuthInterceptor),
+	)
+
+	rentalv1.RegisterAuthServiceServer(srv, &authService{app: app})
+	rentalv1.RegisterFilmServiceServer(srv, &filmService{app: app})
+	rentalv1.RegisterCustomerServiceServer(srv, &customerService{app: app})
+	rentalv1.RegisterRentalServiceServer(srv, &rentalService{app: app})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthServer)
+	reflection.Register(srv)
+
+	return srv, healthServer
+}
+
+// serveGRPC serves until ctx is cancelled, then reports NOT_SERVING for the
+// drain delay and drains in-flight calls within the shutdown timeout before
+// closing the remaining connections.
+func (app *application) serveGRPC(ctx context.Context, listener net.Listener) error {
+	srv, healthServer := app.newGRPCServer()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	app.logger.Infow("starting grpc server", "addr", listener.Addr().String())
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	healthServer.Shutdown()
+	time.Sleep(app.config.ShutdownDrainDelay)
+
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(app.config.ShutdownTimeout):
+		srv.Stop()
+	}
+
+	app.logger.Info("grpc server stopped")
+
+	return nil
+}
+
+// grpcLoggingInterceptor is the gRPC counterpart of AuditActorMiddleware and
+// AccessLogMiddleware.
+func (app *application) grpcLoggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+
+	entry := &accessLogEntry{}
+	ctx = context.WithValue(ctx, accessLogContextKey, entry)
+	ctx = utils.WithLogger(ctx, app.logger.With("method", info.FullMethod))
+	ctx = store.WithAuditActor(ctx, store.AuditActor{IP: ip})
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := zapcore.InfoLevel
+	if code == codes.Internal || code == codes.Unknown {
+		level = zapcore.ErrorLevel
+	}
+
+	fields := []any{
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latency", time.Since(start),
+		"client_ip", ip,
+	}
+	if entry.userID != nil {
+		fields = append(fields, "user_id", *entry.userID, "role", entry.role)
+	}
+
+	app.logger.Logw(level, "rpc completed", fields...)
+
+	return resp, err
+}
+
+func (app *application) grpcRecoveryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			app.grpcLogger(ctx).Errorw("panic in grpc handler", "panic", p)
+			err = status.Error(codes.Internal, internalErrorMessage)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// grpcAuthInterceptor authenticates the bearer token of the authorization
+// metadata the same way AuthTokenMiddleware does for REST requests.
+func (app *application) grpcAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isPublicGRPCMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	user, err := app.authenticateGRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if grpcAdminMethods[info.FullMethod] && user.Role.Name != "admin" {
+		metrics.AuthFailures.WithLabelValues("insufficient_role").Inc()
+		return nil, status.Error(codes.PermissionDenied, "admin role required")
+	}
+
+	actor := store.AuditActorFromContext(ctx)
+	actor.UserID = &user.ID
+	actor.Role = user.Role.Name
+
+	ctx = context.WithValue(ctx, userContextKey, user)
+	ctx = store.WithAuditActor(ctx, actor)
+	ctx = setLogUser(ctx, user)
+	return handler(ctx, req)
+}
+
+// grpcStreamAuthInterceptor only lets the public health and reflection
+// streams through. None of the rental.v1 services stream.
+func (app *application) grpcStreamAuthInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isPublicGRPCMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	if _, err := app.authenticateGRPC(ss.Context()); err != nil {
+		return err
+	}
+	return status.Error(codes.PermissionDenied, "streaming is not supported")
+}
+
+func (app *application) authenticateGRPC(ctx context.Context) (*store.User, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		metrics.AuthFailures.WithLabelValues("missing_header").Inc()
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+	}
+
+	parts := strings.Split(values[0], " ")
+	if len(parts) != 2 {
+		metrics.AuthFailures.WithLabelValues("malformed_header").Inc()
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata")
+	}
+
+	user, reason, err := app.authenticateToken(ctx, parts[1])
+	if err != nil {
+		metrics.AuthFailures.WithLabelValues(reason).Inc()
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return user, nil
+}
+
+func isPublicGRPCMethod(method string) bool {
+	if grpcPublicMethods[method] {
+		return true
+	}
+	for _, prefix := range grpcPublicPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+const internalErrorMessage = "the server encountered a problem and could not process your request"
+
+// grpcError converts an error into a status the same way ErrorHandler maps
+// errors to HTTP responses. Unexpected errors are logged and hidden.
+func (app *application) grpcError(ctx context.Context, err error) error {
+	var appErr *utils.Error
+	switch {
+	case errors.As(err, &appErr):
+		return status.Error(grpcCode(appErr.Kind), appErr.Message)
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "the requested resource could not be found")
+	default:
+		app.grpcLogger(ctx).Errorw("grpc handler error", "error", err)
+		return status.Error(codes.Internal, internalErrorMessage)
+	}
+}
+
+func grpcCode(kind utils.Kind) codes.Code {
+	switch kind {
+	case utils.KindInvalid:
+		return codes.InvalidArgument
+	case utils.KindUnauthorized:
+		return codes.Unauthenticated
+	case utils.KindForbidden:
+		return codes.PermissionDenied
+	case utils.KindNotFound:
+		return codes.NotFound
+	case utils.KindConflict:
+		return codes.AlreadyExists
+	case utils.KindUnprocessable, utils.KindPreconditionFailed, utils.KindPreconditionRequired:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+func (app *application) grpcLogger(ctx context.Context) *zap.SugaredLogger {
+	if logger, ok := utils.LoggerFromContext(ctx); ok {
+		return logger
+	}
+	return app.logger
+}
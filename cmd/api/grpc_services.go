@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	rentalv1 "github.com/andras-szesztai/dev-rental-api/proto/rental/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type authService struct {
+	rentalv1.UnimplementedAuthServiceServer
+	app *application
+}
+
+func (s *authService) SignIn(ctx context.Context, req *rentalv1.SignInRequest) (*rentalv1.SignInResponse, error) {
+	if req.GetEmail() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email and password are required")
+	}
+
+	token, err := s.app.signIn(ctx, req.GetEmail(), req.GetPassword())
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+	return &rentalv1.SignInResponse{Token: token}, nil
+}
+
+type filmService struct {
+	rentalv1.UnimplementedFilmServiceServer
+	app *application
+}
+
+func (s *filmService) GetFilm(ctx context.Context, req *rentalv1.GetFilmRequest) (*rentalv1.Film, error) {
+	film, err := s.app.store.Films.GetFilmByID(ctx, req.GetId())
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+	return filmToProto(film), nil
+}
+
+func (s *filmService) ListFilms(ctx context.Context, req *rentalv1.ListRequest) (*rentalv1.ListFilmsResponse, error) {
+	q, err := parseListRequest(req, store.FilmList)
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	films, cursor, err := s.app.store.Films.ListFilms(ctx, q)
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	res := &rentalv1.ListFilmsResponse{NextPageToken: cursor}
+	for i := range films {
+		res.Films = append(res.Films, filmToProto(&films[i]))
+	}
+	return res, nil
+}
+
+type customerService struct {
+	rentalv1.UnimplementedCustomerServiceServer
+	app *application
+}
+
+func (s *customerService) GetCustomer(ctx context.Context, req *rentalv1.GetCustomerRequest) (*rentalv1.Customer, error) {
+	customer, err := s.app.store.Customers.GetCustomerByID(ctx, req.GetId())
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+	return customerToProto(customer), nil
+}
+
+func (s *customerService) ListCustomers(ctx context.Context, req *rentalv1.ListRequest) (*rentalv1.ListCustomersResponse, error) {
+	q, err := parseListRequest(req, store.CustomerList)
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	customers, cursor, err := s.app.store.Customers.ListCustomers(ctx, q)
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	res := &rentalv1.ListCustomersResponse{NextPageToken: cursor}
+	for i := range customers {
+		res.Customers = append(res.Customers, customerToProto(&customers[i]))
+	}
+	return res, nil
+}
+
+type rentalService struct {
+	rentalv1.UnimplementedRentalServiceServer
+	app *application
+}
+
+func (s *rentalService) GetRental(ctx context.Context, req *rentalv1.GetRentalRequest) (*rentalv1.Rental, error) {
+	rental, err := s.app.store.Rentals.GetRental(ctx, req.GetId())
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+	return rentalToProto(rental), nil
+}
+
+func (s *rentalService) ListRentals(ctx context.Context, req *rentalv1.ListRequest) (*rentalv1.ListRentalsResponse, error) {
+	q, err := parseListRequest(req, store.RentalList)
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	rentals, cursor, err := s.app.store.Rentals.ListRentals(ctx, q)
+	if err != nil {
+		return nil, s.app.grpcError(ctx, err)
+	}
+
+	res := &rentalv1.ListRentalsResponse{NextPageToken: cursor}
+	for i := range rentals {
+		res.Rentals = append(res.Rentals, rentalToProto(&rentals[i]))
+	}
+	return res, nil
+}
+
+// parseListRequest translates a ListRequest into the query parameters of
+// the REST API, so both share the whitelist and cursor format.
+func parseListRequest(req *rentalv1.ListRequest, resource *listquery.Resource) (*listquery.Query, error) {
+	values := url.Values{}
+	if req.GetPageSize() != 0 {
+		values.Set("limit", strconv.Itoa(int(req.GetPageSize())))
+	}
+	if req.GetPageToken() != "" {
+		values.Set("cursor", req.GetPageToken())
+	}
+	if req.GetSort() != "" {
+		values.Set("sort", req.GetSort())
+	}
+	for key, value := range req.GetFilter() {
+		values.Set("filter["+key+"]", value)
+	}
+	return listquery.Parse(values, resource)
+}
+
+func filmToProto(film *store.Film) *rentalv1.Film {
+	return &rentalv1.Film{
+		Id:              int64(film.ID),
+		Title:           film.Title,
+		Description:     film.Description,
+		ReleaseYear:     int32Ptr(film.ReleaseYear),
+		LanguageId:      int32(film.LanguageID),
+		RentalDuration:  int32(film.RentalDuration),
+		RentalRate:      film.RentalRate,
+		Length:          int32Ptr(film.Length),
+		ReplacementCost: film.ReplacementCost,
+		Rating:          film.Rating,
+		SpecialFeatures: film.SpecialFeatures,
+		LastUpdate:      timestamppb.New(film.LastUpdate),
+	}
+}
+
+func customerToProto(customer *store.Customer) *rentalv1.Customer {
+	return &rentalv1.Customer{
+		Id:         int64(customer.ID),
+		StoreId:    customer.StoreID,
+		FirstName:  customer.FirstName,
+		LastName:   customer.LastName,
+		Email:      customer.Email,
+		Active:     customer.Active,
+		LastUpdate: timestamppb.New(customer.LastUpdate),
+	}
+}
+
+func rentalToProto(rental *store.Rental) *rentalv1.Rental {
+	return &rentalv1.Rental{
+		Id:          int64(rental.ID),
+		RentalDate:  timestamppb.New(rental.RentalDate),
+		InventoryId: int64(rental.InventoryID),
+		CustomerId:  int64(rental.CustomerID),
+		ReturnDate:  timestampPtr(rental.ReturnDate),
+		StaffId:     int64(rental.StaffID),
+		LastUpdate:  timestamppb.New(rental.LastUpdate),
+	}
+}
+
+func int32Ptr(n *int) *int32 {
+	if n == nil {
+		return nil
+	}
+	v := int32(*n)
+	return &v
+}
+
+func timestampPtr(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	rentalv1 "github.com/andras-szesztai/dev-rental-api/proto/rental/v1"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPCClient serves the gRPC API of app over an in-memory listener.
+func newTestGRPCClient(t *testing.T, app *application) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	srv, _ := app.newGRPCServer()
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func withToken(t *testing.T, app *application) context.Context {
+	t.Helper()
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGRPCAuth(t *testing.T) {
+	app := newTestApplication(t)
+	conn := newTestGRPCClient(t, app)
+	films := rentalv1.NewFilmServiceClient(conn)
+
+	app.store.Films.(*store.MockFilmStore).GetFilmByIDFunc = func(ctx context.Context, id int64) (*store.Film, error) {
+		return &store.Film{ID: int(id), Title: "Academy Dinosaur"}, nil
+	}
+
+	t.Run("it should reject calls without a token", func(t *testing.T) {
+		_, err := films.GetFilm(context.Background(), &rentalv1.GetFilmRequest{Id: 1})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("it should reject an invalid token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+		_, err := films.GetFilm(ctx, &rentalv1.GetFilmRequest{Id: 1})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("it should accept a valid token", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: 2}}, nil
+		}
+
+		film, err := films.GetFilm(withToken(t, app), &rentalv1.GetFilmRequest{Id: 1})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Academy Dinosaur", film.GetTitle())
+	})
+
+	t.Run("it should require the admin role for customers", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: 2}}, nil
+		}
+
+		_, err := rentalv1.NewCustomerServiceClient(conn).GetCustomer(withToken(t, app), &rentalv1.GetCustomerRequest{Id: 1})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("it should sign in without a token", func(t *testing.T) {
+		password := "password"
+		hashed := utils.Password{}
+		assert.NoError(t, hashed.Set(password))
+		app.store.Customers.(*store.MockCustomerStore).GetCustomerByEmailFunc = func(ctx context.Context, email string) (*store.Customer, error) {
+			return &store.Customer{ID: 1, UserID: &[]int{1}[0]}, nil
+		}
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Password: hashed}, nil
+		}
+
+		res, err := rentalv1.NewAuthServiceClient(conn).SignIn(context.Background(), &rentalv1.SignInRequest{Email: "test@test.com", Password: password})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.GetToken())
+
+		_, err = rentalv1.NewAuthServiceClient(conn).SignIn(context.Background(), &rentalv1.SignInRequest{Email: "test@test.com", Password: "wrong-password"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("it should serve health checks without a token", func(t *testing.T) {
+		res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+	})
+}
+
+func TestGRPCRentals(t *testing.T) {
+	app := newTestApplication(t)
+	conn := newTestGRPCClient(t, app)
+	rentals := rentalv1.NewRentalServiceClient(conn)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+
+	t.Run("it should list rentals with the REST list query semantics", func(t *testing.T) {
+		var query *listquery.Query
+		app.store.Rentals.(*store.MockRentalStore).ListRentalsFunc = func(ctx context.Context, q *listquery.Query) ([]store.Rental, string, error) {
+			query = q
+			return []store.Rental{{ID: 1, CustomerID: 5}, {ID: 2, CustomerID: 5}}, "next-page", nil
+		}
+
+		res, err := rentals.ListRentals(withToken(t, app), &rentalv1.ListRequest{
+			PageSize: 2,
+			Filter:   map[string]string{"customer_id": "5"},
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, res.GetRentals(), 2)
+		assert.Nil(t, res.GetRentals()[0].GetReturnDate())
+		assert.Equal(t, "next-page", res.GetNextPageToken())
+		if assert.NotNil(t, query) {
+			assert.Equal(t, 2, query.Limit)
+			assert.Equal(t, []listquery.Filter{{Field: "customer_id", Operator: listquery.Eq, Value: int64(5)}}, query.Filters)
+		}
+	})
+
+	t.Run("it should reject an invalid page token", func(t *testing.T) {
+		_, err := rentals.ListRentals(withToken(t, app), &rentalv1.ListRequest{PageToken: "tampered"})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("it should return not found for a missing rental", func(t *testing.T) {
+		app.store.Rentals.(*store.MockRentalStore).GetRentalFunc = func(ctx context.Context, id int64) (*store.Rental, error) {
+			return nil, sql.ErrNoRows
+		}
+
+		_, err := rentals.GetRental(withToken(t, app), &rentalv1.GetRentalRequest{Id: 1})
+
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("it should hide unexpected errors", func(t *testing.T) {
+		app.store.Rentals.(*store.MockRentalStore).GetRentalFunc = func(ctx context.Context, id int64) (*store.Rental, error) {
+			return nil, errors.New("connection refused")
+		}
+
+		_, err := rentals.GetRental(withToken(t, app), &rentalv1.GetRentalRequest{Id: 1})
+
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.NotContains(t, err.Error(), "connection refused")
+	})
+}
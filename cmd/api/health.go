@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
 )
@@ -10,6 +13,8 @@ type healthCheckData struct {
 	Status      string `json:"status"`
 	Environment string `json:"environment"`
 	Version     string `json:"version"`
+	// SchemaVersion is the latest migration built into the binary.
+	SchemaVersion uint `json:"schema_version"`
 }
 
 type healthCheckResponse struct {
@@ -19,20 +24,184 @@ type healthCheckResponse struct {
 // HealthCheck godoc
 //
 //	@Summary		Health check
-//	@Description	Check if the server is running
+//	@Description	Check if the server is running. Returns 503 once shutdown has started.
 //	@Tags			1. Health
 //	@Accept			json
 //	@Produce		json
 //	@Success		200	{object} healthCheckResponse
+//	@Failure		503	{object} healthCheckResponse
 //	@Router			/health [get]
 func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	data := healthCheckData{
-		Status:      "ok",
-		Environment: app.config.env,
-		Version:     app.config.version,
+		Status:        "ok",
+		Environment:   app.config.Env,
+		Version:       app.config.Version,
+		SchemaVersion: app.schemaVersion,
 	}
-	if err := utils.WriteJSONResponse(w, http.StatusOK, healthCheckResponse{Data: data}); err != nil {
+	status := http.StatusOK
+	if app.shuttingDown.Load() {
+		data.Status = "shutting down"
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := utils.WriteJSONResponse(w, status, healthCheckResponse{Data: data}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+
+}
+
+const readinessTimeout = 2 * time.Second
+
+type livenessResponse struct {
+	Data livenessData `json:"data"`
+}
+
+type livenessData struct {
+	Status string `json:"status"`
+}
+
+type readinessCheck struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+}
+
+type migrationCheck struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Version  uint   `json:"version"`
+	Expected uint   `json:"expected"`
+	Dirty    bool   `json:"dirty"`
+}
+
+// replicaCheck reports the read replica. It never fails readiness: while the
+// replica is down or lagging, reads fall back to the primary.
+type replicaCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	LagMS  int64  `json:"lag_ms"`
+}
+
+type poolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMS     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+type readinessChecks struct {
+	Shutdown   readinessCheck `json:"shutdown"`
+	Database   readinessCheck `json:"database"`
+	Migrations migrationCheck `json:"migrations"`
+	Replica    replicaCheck   `json:"replica"`
+}
+
+type readinessData struct {
+	Status string          `json:"status"`
+	Checks readinessChecks `json:"checks"`
+	Pool   poolStats       `json:"pool"`
+}
+
+type readinessResponse struct {
+	Data readinessData `json:"data"`
+}
+
+// Liveness godoc
+//
+//	@Summary		Liveness probe
+//	@Description	Report that the process is running. Does not check dependencies.
+//	@Tags			1. Health
+//	@Produce		json
+//	@Success		200	{object}	livenessResponse
+//	@Router			/health/live [get]
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	if err := utils.WriteJSONResponse(w, http.StatusOK, livenessResponse{Data: livenessData{Status: "ok"}}); err != nil {
 		app.errorHandler.InternalServerError(w, r, err)
 	}
+}
+
+// Readiness godoc
+//
+//	@Summary		Readiness probe
+//	@Description	Check the database, the applied migration version and shutdown state, and report the read replica. Returns 503 with per-check details when degraded.
+//	@Tags			1. Health
+//	@Produce		json
+//	@Success		200	{object}	readinessResponse
+//	@Failure		503	{object}	readinessResponse
+//	@Router			/health/ready [get]
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	data := readinessData{
+		Status: "ok",
+		Checks: readinessChecks{
+			Shutdown:   readinessCheck{Status: "ok"},
+			Database:   readinessCheck{Status: "ok"},
+			Migrations: migrationCheck{Status: "ok", Expected: app.schemaVersion},
+		},
+	}
+
+	if app.shuttingDown.Load() {
+		data.Checks.Shutdown = readinessCheck{Status: "failed", Error: "server is shutting down"}
+	}
 
+	start := time.Now()
+	if err := app.store.Health.Ping(ctx); err != nil {
+		data.Checks.Database.Status = "failed"
+		data.Checks.Database.Error = err.Error()
+	}
+	data.Checks.Database.LatencyMS = time.Since(start).Milliseconds()
+
+	version, dirty, err := app.store.Health.MigrationVersion(ctx)
+	data.Checks.Migrations.Version = version
+	data.Checks.Migrations.Dirty = dirty
+	switch {
+	case err != nil:
+		data.Checks.Migrations.Status = "failed"
+		data.Checks.Migrations.Error = err.Error()
+	case dirty:
+		data.Checks.Migrations.Status = "failed"
+		data.Checks.Migrations.Error = "database is dirty"
+	case version != app.schemaVersion:
+		data.Checks.Migrations.Status = "failed"
+		data.Checks.Migrations.Error = fmt.Sprintf("expected migration version %d, database is at %d", app.schemaVersion, version)
+	}
+
+	replica := app.store.Health.Replica()
+	data.Checks.Replica = replicaCheck{Status: "disabled", Error: replica.Error, LagMS: replica.Lag.Milliseconds()}
+	switch {
+	case replica.InUse:
+		data.Checks.Replica.Status = "ok"
+	case replica.Configured:
+		data.Checks.Replica.Status = "fallback"
+	}
+
+	stats := app.store.Health.Stats()
+	data.Pool = poolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMS:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+
+	status := http.StatusOK
+	if data.Checks.Shutdown.Status != "ok" || data.Checks.Database.Status != "ok" || data.Checks.Migrations.Status != "ok" {
+		data.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	if err := utils.WriteJSONResponse(w, status, readinessResponse{Data: data}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthProbes(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	healthStore := app.store.Health.(*store.MockHealthStore)
+	healthStore.MigrationVersionFunc = func(ctx context.Context) (uint, bool, error) {
+		return app.schemaVersion, false, nil
+	}
+	healthStore.StatsFunc = func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 50, OpenConnections: 3, InUse: 1, Idle: 2}
+	}
+
+	t.Run("liveness should not depend on the database", func(t *testing.T) {
+		healthStore.PingFunc = func(ctx context.Context) error {
+			return errors.New("connection refused")
+		}
+		defer func() { healthStore.PingFunc = nil }()
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/live", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"status":"ok"`)
+	})
+
+	t.Run("health should report the schema version of the binary", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), fmt.Sprintf(`"schema_version":%d`, app.schemaVersion))
+	})
+
+	t.Run("readiness should report ok with pool stats", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"open_connections":3`)
+		assert.Contains(t, recorder.Body.String(), `"max_open_connections":50`)
+	})
+
+	t.Run("readiness should report a disabled replica", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"replica":{"status":"disabled","lag_ms":0}`)
+	})
+
+	t.Run("readiness should not fail while reads fall back to the primary", func(t *testing.T) {
+		healthStore.ReplicaFunc = func() db.ReplicaStatus {
+			return db.ReplicaStatus{Configured: true, Lag: 12 * time.Second, Error: "replica lag exceeds the limit"}
+		}
+		defer func() { healthStore.ReplicaFunc = nil }()
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"replica":{"status":"fallback","error":"replica lag exceeds the limit","lag_ms":12000}`)
+	})
+
+	t.Run("readiness should fail if the database is down", func(t *testing.T) {
+		healthStore.PingFunc = func(ctx context.Context) error {
+			return errors.New("connection refused")
+		}
+		defer func() { healthStore.PingFunc = nil }()
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"database":{"status":"failed","error":"connection refused"`)
+	})
+
+	t.Run("readiness should fail if the migration version does not match", func(t *testing.T) {
+		healthStore.MigrationVersionFunc = func(ctx context.Context) (uint, bool, error) {
+			return app.schemaVersion - 1, false, nil
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "expected migration version")
+	})
+
+	t.Run("readiness should fail if the database is dirty", func(t *testing.T) {
+		healthStore.MigrationVersionFunc = func(ctx context.Context) (uint, bool, error) {
+			return app.schemaVersion, true, nil
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "database is dirty")
+	})
+
+	t.Run("readiness should fail once shutdown has started", func(t *testing.T) {
+		healthStore.MigrationVersionFunc = func(ctx context.Context) (uint, bool, error) {
+			return app.schemaVersion, false, nil
+		}
+		app.shuttingDown.Store(true)
+		defer app.shuttingDown.Store(false)
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "server is shutting down")
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentBodyBytes    = 1_048_576
+)
+
+// idempotentResponseHeaders are the response headers stored for replay.
+var idempotentResponseHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// IdempotencyMiddleware makes POST requests carrying an Idempotency-Key safe
+// to retry. The first request with a key is handled and its response stored
+// per user; retries get the stored response back. A key reused with another
+// request is rejected with 422 and a retry while the first request is still
+// running with 409. Server errors are not stored, so they can be retried.
+func (app *application) IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		user := app.getUserContext(r)
+		if r.Method != http.MethodPost || key == "" || user == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			app.errorHandler.Error(w, r, store.ErrIdempotencyKeyInvalid)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			app.errorHandler.BadRequest(w, r, fmt.Errorf("failed to read request body: %w", err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, created, err := app.store.Idempotency.BeginIdempotentRequest(r.Context(), &store.IdempotencyRecord{
+			UserID:      user.ID,
+			Key:         key,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Fingerprint: requestFingerprint(r, body),
+		})
+		if err != nil {
+			app.errorHandler.InternalServerError(w, r, err)
+			return
+		}
+
+		if !created {
+			app.replayIdempotentResponse(w, r, record, body)
+			return
+		}
+
+		var response bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&response)
+
+		// The client may be gone already, the outcome must be recorded anyway.
+		ctx := context.WithoutCancel(r.Context())
+
+		// A panicking handler would otherwise leave the key in flight until it
+		// times out. Recoverer still turns the panic into a 500.
+		defer func() {
+			if rec := recover(); rec != nil {
+				app.releaseIdempotencyKey(ctx, user.ID, key)
+				panic(rec)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status >= http.StatusInternalServerError {
+			app.releaseIdempotencyKey(ctx, user.ID, key)
+			return
+		}
+
+		headers := map[string]string{}
+		for _, name := range idempotentResponseHeaders {
+			if value := ww.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		if err := app.store.Idempotency.CompleteIdempotentRequest(ctx, user.ID, key, status, headers, response.Bytes()); err != nil {
+			app.logger.Errorw("failed to store idempotent response", "user_id", user.ID, "key", key, "error", err)
+		}
+	})
+}
+
+// releaseIdempotencyKey deletes the record of a failed request, so the client
+// can retry it.
+func (app *application) releaseIdempotencyKey(ctx context.Context, userID int, key string) {
+	if err := app.store.Idempotency.ReleaseIdempotentRequest(ctx, userID, key); err != nil {
+		app.logger.Errorw("failed to release idempotency key", "user_id", userID, "key", key, "error", err)
+	}
+}
+
+func (app *application) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, record *store.IdempotencyRecord, body []byte) {
+	if record.Fingerprint != requestFingerprint(r, body) {
+		app.errorHandler.Error(w, r, store.ErrIdempotencyKeyReused)
+		return
+	}
+	if record.Status == nil {
+		w.Header().Set("Retry-After", "1")
+		app.errorHandler.Error(w, r, store.ErrIdempotencyKeyInFlight)
+		return
+	}
+
+	for name, value := range record.ResponseHeaders {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(*record.Status)
+	if _, err := w.Write(record.ResponseBody); err != nil {
+		app.logger.Errorw("failed to replay idempotent response", "error", err)
+	}
+}
+
+// requestFingerprint identifies a request by method, path and body, so the
+// same key cannot be used for a different request.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIdempotencyStore backs the idempotency mock with a map.
+type memoryIdempotencyStore struct {
+	mu       sync.Mutex
+	records  map[string]*store.IdempotencyRecord
+	released []string
+}
+
+func newMemoryIdempotencyStore(mock *store.MockIdempotencyStore) *memoryIdempotencyStore {
+	m := &memoryIdempotencyStore{records: map[string]*store.IdempotencyRecord{}}
+	mock.BeginIdempotentRequestFunc = func(ctx context.Context, record *store.IdempotencyRecord) (*store.IdempotencyRecord, bool, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		id := fmt.Sprintf("%d/%s", record.UserID, record.Key)
+		if existing, ok := m.records[id]; ok {
+			return existing, false, nil
+		}
+		m.records[id] = record
+		return record, true, nil
+	}
+	mock.CompleteIdempotentRequestFunc = func(ctx context.Context, userID int, key string, status int, headers map[string]string, body []byte) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		record := m.records[fmt.Sprintf("%d/%s", userID, key)]
+		record.Status = &status
+		record.ResponseHeaders = headers
+		record.ResponseBody = body
+		return nil
+	}
+	mock.ReleaseIdempotentRequestFunc = func(ctx context.Context, userID int, key string) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.records, fmt.Sprintf("%d/%s", userID, key))
+		m.released = append(m.released, key)
+		return nil
+	}
+	return m
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+	records := newMemoryIdempotencyStore(app.store.Idempotency.(*store.MockIdempotencyStore))
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	require.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+
+	var created int
+	app.store.Customers.(*store.MockCustomerStore).CreateCustomerFunc = func(ctx context.Context, customer *store.Customer) error {
+		created++
+		return nil
+	}
+
+	const body = `{"store_id": 1, "first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/customers", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("it should replay the stored response on retry", func(t *testing.T) {
+		first := post("key-1", body)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+		retry := post("key-1", body)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("it should reject a key reused with a different body", func(t *testing.T) {
+		recorder := post("key-1", `{"store_id": 2, "first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"idempotency_key_reused"`)
+	})
+
+	t.Run("it should reject a retry while the first request is in flight", func(t *testing.T) {
+		inFlight := &store.IdempotencyRecord{UserID: 1, Key: "key-2", Method: http.MethodPost, Path: "/v1/customers"}
+		inFlight.Fingerprint = requestFingerprint(httptest.NewRequest(http.MethodPost, "/v1/customers", nil), []byte(body))
+		records.records["1/key-2"] = inFlight
+
+		recorder := post("key-2", body)
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"idempotency_key_in_flight"`)
+		assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+	})
+
+	t.Run("it should release the key when the request fails", func(t *testing.T) {
+		app.store.Customers.(*store.MockCustomerStore).CreateCustomerFunc = func(ctx context.Context, customer *store.Customer) error {
+			return errors.New("database error")
+		}
+
+		recorder := post("key-3", body)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Contains(t, records.released, "key-3")
+		assert.NotContains(t, records.records, "1/key-3")
+	})
+
+	t.Run("it should release the key when the handler panics", func(t *testing.T) {
+		app.store.Customers.(*store.MockCustomerStore).CreateCustomerFunc = func(ctx context.Context, customer *store.Customer) error {
+			panic("unexpected nil")
+		}
+
+		recorder := post("key-4", body)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Contains(t, records.released, "key-4")
+		assert.NotContains(t, records.records, "1/key-4")
+	})
+
+	t.Run("it should pass requests without a key through", func(t *testing.T) {
+		app.store.Customers.(*store.MockCustomerStore).CreateCustomerFunc = func(ctx context.Context, customer *store.Customer) error {
+			created++
+			return nil
+		}
+		created = 0
+
+		post("", body)
+		post("", body)
+
+		assert.Equal(t, 2, created)
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/dunning"
+	"github.com/andras-szesztai/dev-rental-api/internal/jobs"
+	"github.com/andras-szesztai/dev-rental-api/internal/outbox"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultJobRunLimit = 50
+	maxJobRunLimit     = 500
+)
+
+// registerJobs adds the maintenance jobs to the scheduler. Schedules are in
+// UTC.
+func (app *application) registerJobs(relay *outbox.Relay, dunning *dunning.Processor) error {
+	return errors.Join(
+		app.jobs.Register(jobs.Job{
+			Name:     "overdue-rentals",
+			Schedule: "*/15 * * * *",
+			Timeout:  5 * time.Minute,
+			Run:      app.markOverdueRentals,
+		}),
+		// Runs between the overdue-rentals runs, so newly overdue rentals
+		// get their case within minutes.
+		app.jobs.Register(jobs.Job{
+			Name:     "overdue-dunning",
+			Schedule: "5-59/15 * * * *",
+			Timeout:  10 * time.Minute,
+			Run:      dunning.Run,
+		}),
+		app.jobs.Register(jobs.Job{
+			Name:     "idempotency-cleanup",
+			Schedule: "@hourly",
+			Timeout:  5 * time.Minute,
+			Run:      app.deleteExpiredIdempotencyKeys,
+		}),
+		app.jobs.Register(jobs.Job{
+			Name:     "outbox-cleanup",
+			Schedule: "30 3 * * *",
+			Timeout:  30 * time.Minute,
+			Run: func(ctx context.Context) error {
+				deleted, err := relay.DeletePublished(ctx)
+				if deleted > 0 {
+					app.logger.Infow("deleted published outbox events", "count", deleted)
+				}
+				return err
+			},
+		}),
+		app.jobs.Register(jobs.Job{
+			Name:     "job-runs-cleanup",
+			Schedule: "45 3 * * *",
+			Timeout:  10 * time.Minute,
+			Run:      app.deleteOldJobRuns,
+		}),
+	)
+}
+
+// markOverdueRentals publishes rental.overdue for rentals that passed their
+// due date since the last run.
+func (app *application) markOverdueRentals(ctx context.Context) error {
+	overdue, err := app.store.Rentals.MarkOverdueRentals(ctx)
+	if len(overdue) > 0 {
+		app.logger.Infow("marked overdue rentals", "count", len(overdue))
+	}
+	return err
+}
+
+// deleteExpiredIdempotencyKeys deletes keys past their retention and keys
+// left locked by requests that never completed.
+func (app *application) deleteExpiredIdempotencyKeys(ctx context.Context) error {
+	now := time.Now()
+	deleted, err := app.store.Idempotency.DeleteExpiredIdempotencyKeys(ctx,
+		now.Add(-app.config.Idempotency.Retention),
+		now.Add(-app.config.Idempotency.InFlightTimeout),
+	)
+	if deleted > 0 {
+		app.logger.Infow("deleted expired idempotency keys", "count", deleted)
+	}
+	return err
+}
+
+// deleteOldJobRuns deletes the history of runs that finished before the
+// retention period.
+func (app *application) deleteOldJobRuns(ctx context.Context) error {
+	deleted, err := app.store.Jobs.DeleteJobRuns(ctx, time.Now().Add(-app.config.Jobs.RunRetention))
+	if deleted > 0 {
+		app.logger.Infow("deleted old job runs", "count", deleted)
+	}
+	return err
+}
+
+type jobListResponse struct {
+	Data []jobs.Info `json:"data"`
+}
+
+type jobRunResponse struct {
+	Data store.JobRun `json:"data"`
+}
+
+type jobRunListResponse struct {
+	Data []store.JobRun `json:"data"`
+}
+
+// ListJobs godoc
+//
+//	@Summary		List background jobs
+//	@Description	List the scheduled maintenance jobs with their cron schedule (UTC), timeout and next run on this replica. Each run happens on a single replica.
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Success		200	{object}	jobListResponse
+//	@Failure		401	{object}	utils.Problem
+//	@Failure		403	{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/jobs [get]
+func (app *application) listJobs(w http.ResponseWriter, r *http.Request) {
+	if err := utils.WriteJSONResponse(w, http.StatusOK, jobListResponse{Data: app.jobs.Jobs()}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// ListJobRuns godoc
+//
+//	@Summary		List job runs
+//	@Description	List the run history of the background jobs, newest first
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Param			job		query		string	false	"Job name"
+//	@Param			limit	query		int		false	"Limit"	default(50)
+//	@Success		200		{object}	jobRunListResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/jobs/runs [get]
+func (app *application) listJobRuns(w http.ResponseWriter, r *http.Request) {
+	limit := defaultJobRunLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxJobRunLimit {
+			app.errorHandler.BadRequest(w, r, errors.New("limit must be between 1 and 500"))
+			return
+		}
+	}
+
+	runs, err := app.store.Jobs.ListJobRuns(r.Context(), r.URL.Query().Get("job"), limit)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, jobRunListResponse{Data: runs}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// TriggerJob godoc
+//
+//	@Summary		Run job now
+//	@Description	Start a background job outside of its schedule. The job runs asynchronously; follow its outcome in the run history.
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Param			name	path		string	true	"Job name"
+//	@Success		202		{object}	jobRunResponse
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		404		{object}	utils.Problem
+//	@Failure		409		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/jobs/{name}/run [post]
+func (app *application) triggerJob(w http.ResponseWriter, r *http.Request) {
+	user := app.getUserContext(r)
+
+	run, err := app.jobs.Trigger(r.Context(), chi.URLParam(r, "name"), &user.ID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusAccepted, jobRunResponse{Data: *run}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
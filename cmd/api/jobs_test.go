@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/jobs"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobs(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	require.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{
+			ID: 1,
+			Role: &store.Role{
+				ID: 1,
+			},
+		}, nil
+	}
+
+	ran := make(chan struct{}, 1)
+	require.NoError(t, app.jobs.Register(jobs.Job{Name: "cleanup", Schedule: "@daily", Run: func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}}))
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("admin should be able to list jobs", func(t *testing.T) {
+		recorder := send(http.MethodGet, "/v1/admin/jobs")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"name":"cleanup","schedule":"@daily","timeout":"10m0s"`)
+	})
+
+	t.Run("admin should be able to trigger a job", func(t *testing.T) {
+		recorder := send(http.MethodPost, "/v1/admin/jobs/cleanup/run")
+
+		assert.Equal(t, http.StatusAccepted, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"trigger":"manual","triggered_by":1,"status":"running"`)
+		<-ran
+	})
+
+	t.Run("conflict if the job is already running", func(t *testing.T) {
+		app.store.Jobs.(*store.MockJobStore).StartJobRunFunc = func(ctx context.Context, name, trigger string, triggeredBy *int) (*store.JobRun, error) {
+			return nil, store.ErrJobRunning
+		}
+		defer func() { app.store.Jobs.(*store.MockJobStore).StartJobRunFunc = nil }()
+
+		recorder := send(http.MethodPost, "/v1/admin/jobs/cleanup/run")
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "job_running")
+	})
+
+	t.Run("not found if the job does not exist", func(t *testing.T) {
+		recorder := send(http.MethodPost, "/v1/admin/jobs/unknown/run")
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("admin should be able to list job runs", func(t *testing.T) {
+		var name string
+		var limit int
+		app.store.Jobs.(*store.MockJobStore).ListJobRunsFunc = func(ctx context.Context, n string, l int) ([]store.JobRun, error) {
+			name, limit = n, l
+			return []store.JobRun{{ID: 3, JobName: "cleanup", Status: store.JobRunSucceeded}}, nil
+		}
+
+		recorder := send(http.MethodGet, "/v1/admin/jobs/runs?job=cleanup&limit=10")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"status":"succeeded"`)
+		assert.Equal(t, "cleanup", name)
+		assert.Equal(t, 10, limit)
+
+		recorder = send(http.MethodGet, "/v1/admin/jobs/runs?limit=0")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("non-admin user should not be able to trigger jobs", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{
+				ID: 1,
+				Role: &store.Role{
+					ID: 2,
+				},
+			}, nil
+		}
+
+		recorder := send(http.MethodPost, "/v1/admin/jobs/cleanup/run")
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
+
+func TestDeleteOldJobRuns(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.Jobs.RunRetention = 24 * time.Hour
+
+	var before time.Time
+	app.store.Jobs.(*store.MockJobStore).DeleteJobRunsFunc = func(ctx context.Context, b time.Time) (int64, error) {
+		before = b
+		return 3, nil
+	}
+
+	require.NoError(t, app.deleteOldJobRuns(context.Background()))
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+}
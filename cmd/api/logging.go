@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const accessLogContextKey = contextKey("access_log")
+
+// accessLogEntry is filled in by handlers further down the chain, so the
+// access log line can include the authenticated user.
+type accessLogEntry struct {
+	userID *int
+	role   string
+}
+
+// AccessLogMiddleware writes one structured log line per request and stores
+// a request-scoped logger in the context for handlers and the ErrorHandler.
+//
+// Lines are sampled per level, and LOG_ROUTE_LEVELS lowers noisy routes such
+// as the health checks to debug. Server errors are always logged at error.
+func (app *application) AccessLogMiddleware(next http.Handler) http.Handler {
+	logger := app.logger.Desugar()
+	if cfg := app.config.Logging; cfg.SampleInitial > 0 {
+		logger = logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, time.Second, cfg.SampleInitial, cfg.SampleThereafter)
+		}))
+	}
+	accessLogger := logger.Sugar()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		entry := &accessLogEntry{}
+		ctx := context.WithValue(r.Context(), accessLogContextKey, entry)
+		ctx = utils.WithLogger(ctx, app.logger.With(utils.RequestLogFields(r)...))
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := zapcore.InfoLevel
+		if override, ok := app.config.Logging.RouteLevels[route]; ok {
+			level = override
+		}
+		if status >= http.StatusInternalServerError {
+			level = zapcore.ErrorLevel
+		}
+
+		fields := append(utils.RequestLogFields(r),
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"latency", time.Since(start),
+			"client_ip", clientIP(r),
+		)
+		if entry.userID != nil {
+			fields = append(fields, "user_id", *entry.userID, "role", entry.role)
+		}
+
+		accessLogger.Logw(level, "request completed", fields...)
+	})
+}
+
+// setLogUser records the authenticated user on the access log line and the
+// request-scoped logger.
+func setLogUser(ctx context.Context, user *store.User) context.Context {
+	if entry, ok := ctx.Value(accessLogContextKey).(*accessLogEntry); ok {
+		entry.userID = &user.ID
+		entry.role = user.Role.Name
+	}
+	if logger, ok := utils.LoggerFromContext(ctx); ok {
+		ctx = utils.WithLogger(ctx, logger.With("user_id", user.ID, "role", user.Role.Name))
+	}
+	return ctx
+}
+
+// clientIP returns the host part of RemoteAddr, which middleware.RealIP has
+// already replaced with the forwarded client address.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	app := newTestApplication(t)
+	app.logger = zap.New(core).Sugar()
+	app.errorHandler = utils.NewErrorHandler(app.logger)
+	app.config.Logging.RouteLevels = map[string]zapcore.Level{"/v1/health": zapcore.DebugLevel}
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	require.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 7, Role: &store.Role{ID: 1}}, nil
+	}
+	app.store.Roles.(*store.MockRoleStore).GetRoleByIDFunc = func(ctx context.Context, id int64) (*store.Role, error) {
+		return &store.Role{ID: 1, Name: "admin"}, nil
+	}
+
+	t.Run("it should log the route, status and user", func(t *testing.T) {
+		app.store.Rentals.(*store.MockRentalStore).GetRentalFunc = func(ctx context.Context, id int64) (*store.Rental, error) {
+			return &store.Rental{ID: int(id), RentalDate: time.Now()}, nil
+		}
+		logs.TakeAll()
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/rentals/1", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		entries := logs.FilterMessage("request completed").All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+		assert.Equal(t, "/v1/rentals/{id}", fields["route"])
+		assert.EqualValues(t, http.StatusOK, fields["status"])
+		assert.EqualValues(t, 7, fields["user_id"])
+		assert.Equal(t, "admin", fields["role"])
+		assert.Equal(t, "203.0.113.7", fields["client_ip"])
+		assert.NotEmpty(t, fields["request_id"])
+	})
+
+	t.Run("it should log server errors at error level with the request logger", func(t *testing.T) {
+		app.store.Rentals.(*store.MockRentalStore).GetRentalFunc = func(ctx context.Context, id int64) (*store.Rental, error) {
+			return nil, fmt.Errorf("connection refused")
+		}
+		logs.TakeAll()
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/rentals/1", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		handlerLogs := logs.FilterMessage("internal server error").All()
+		require.Len(t, handlerLogs, 1)
+		assert.EqualValues(t, 7, handlerLogs[0].ContextMap()["user_id"])
+
+		entries := logs.FilterMessage("request completed").All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+	})
+
+	t.Run("it should apply per-route level overrides", func(t *testing.T) {
+		logs.TakeAll()
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+
+		assert.Empty(t, logs.FilterMessage("request completed").All())
+	})
+}
@@ -1,18 +1,31 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"log"
 	"os"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/swaggo/http-swagger/v2"
 	"go.uber.org/zap"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/activity"
 	"github.com/andras-szesztai/dev-rental-api/internal/auth"
+	"github.com/andras-szesztai/dev-rental-api/internal/config"
 	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/dunning"
+	"github.com/andras-szesztai/dev-rental-api/internal/jobs"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/migrate"
+	"github.com/andras-szesztai/dev-rental-api/internal/notification"
+	"github.com/andras-szesztai/dev-rental-api/internal/outbox"
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/tracing"
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
-	"github.com/joho/godotenv"
+	"github.com/andras-szesztai/dev-rental-api/internal/webhook"
+	"github.com/andras-szesztai/dev-rental-api/migrations"
 )
 
 func getVersion() string {
@@ -46,38 +59,20 @@ func getVersion() string {
 //	@type						http
 
 type application struct {
-	logger        *zap.SugaredLogger
-	config        config
-	store         *store.Store
-	authenticator auth.Authenticator
-	errorHandler  *utils.ErrorHandler
-}
-
-type config struct {
-	addr    string
-	env     string
-	db      dbConfig
-	auth    authConfig
-	apiURL  string
-	version string
-}
-
-type dbConfig struct {
-	addr         string
-	maxOpenConns int
-	maxIdleConns int
-	maxIdleTime  string
-}
-
-type authConfig struct {
-	token tokenConfig
-}
-
-type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	aud    string
-	iss    string
+	logger           *zap.SugaredLogger
+	config           config.Config
+	store            *store.Store
+	authenticator    auth.Authenticator
+	identityProvider auth.IdentityProvider
+	errorHandler     *utils.ErrorHandler
+	activity         *activity.Hub
+	jobs             *jobs.Scheduler
+	notifications    *notification.Service
+	// schemaVersion is the latest embedded migration, the schema this binary
+	// is written against.
+	schemaVersion uint
+	workers       workerGroup
+	shuttingDown  atomic.Bool
 }
 
 //	@title			Swagger Examasdasdasdasdasdawdasple API
@@ -105,38 +100,11 @@ type tokenConfig struct {
 // @externalDocs.description	OpenAPI
 // @externalDocs.url			https://swagger.io/resources/open-api/
 func main() {
-	if os.Getenv("ENV") != "prod" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatal("Error loading .env file")
-		}
-	}
-
-	exp, err := time.ParseDuration(os.Getenv("TOKEN_EXP"))
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
 	if err != nil {
-		log.Fatal("Error parsing TOKEN_EXP")
-	}
-
-	cfg := config{
-		addr:    os.Getenv("PORT"),
-		env:     os.Getenv("ENV"),
-		version: getVersion(),
-		db: dbConfig{
-			addr:         os.Getenv("DB_ADDR"),
-			maxOpenConns: 50,
-			maxIdleConns: 25,
-			maxIdleTime:  "15m",
-		},
-		auth: authConfig{
-			token: tokenConfig{
-				secret: os.Getenv("TOKEN_SECRET"),
-				exp:    exp,
-				aud:    os.Getenv("TOKEN_AUD"),
-				iss:    os.Getenv("TOKEN_ISS"),
-			},
-		},
-		apiURL: os.Getenv("API_URL"),
+		log.Fatalf("invalid configuration: %v", err)
 	}
+	cfg.Version = getVersion()
 
 	logger := zap.Must(zap.NewProduction()).Sugar()
 	defer func() {
@@ -145,26 +113,157 @@ func main() {
 		}
 	}()
 
-	db, err := db.NewDB(cfg.db.addr, cfg.db.maxOpenConns, cfg.db.maxIdleConns, cfg.db.maxIdleTime)
+	logger.Infow("effective configuration", "config", cfg.Redacted())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		ServiceName:  "dvd-rental-api",
+		Version:      cfg.Version,
+	})
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Errorw("failed to flush traces", "error", err)
+		}
+	}()
+
+	primary, err := db.Open(cfg.DB.Addr, cfg.DB.MaxOpenConns, cfg.DB.MaxIdleConns, cfg.DB.MaxIdleTime)
 	if err != nil {
 		logger.Fatal(err)
 	}
-	defer db.Close()
 	logger.Info("database connection pool established")
 
-	store := store.NewStore(db)
+	if err := metrics.RegisterDBStats(primary, "dvdrental"); err != nil {
+		logger.Fatal(err)
+	}
 
-	authenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.aud, cfg.auth.token.iss)
+	var replica *sql.DB
+	if cfg.DB.Replica.Addr != "" {
+		replica, err = db.Open(cfg.DB.Replica.Addr, cfg.DB.MaxOpenConns, cfg.DB.MaxIdleConns, cfg.DB.MaxIdleTime)
+		if err != nil {
+			logger.Fatalw("failed to connect to the read replica", "error", err)
+		}
+		if err := metrics.RegisterDBStats(replica, "dvdrental_replica"); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	pools := db.NewDB(primary, replica, cfg.DB.Replica.MaxLag)
+	defer pools.Close()
+	if replica != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.DB.Replica.LagCheckInterval)
+		status := pools.CheckReplica(ctx)
+		cancel()
+		logger.Infow("read replica connection pool established", "in_use", status.InUse, "lag", status.Lag, "error", status.Error)
+	}
+
+	migrator, err := migrate.New(primary, migrations.FS)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := prepareSchema(context.Background(), migrator, cfg.DB.Migrate, logger); err != nil {
+		logger.Fatal(err)
+	}
+
+	store := store.NewStore(pools)
+
+	authenticator := auth.NewJWTAuthenticator(cfg.Auth.Token.Secret, cfg.Auth.Token.Aud, cfg.Auth.Token.Iss)
+
+	var identityProvider auth.IdentityProvider
+	if cfg.Auth.OIDC.DiscoveryURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		identityProvider, err = auth.NewOIDCProvider(ctx, cfg.Auth.OIDC.DiscoveryURL, cfg.Auth.OIDC.ClientID, cfg.Auth.OIDC.ClientSecret, cfg.Auth.OIDC.RedirectURL)
+		cancel()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Info("oidc identity provider configured")
+	}
 
 	errorHandler := utils.NewErrorHandler(logger)
 
+	webhooks := webhook.NewDispatcher(store.Webhooks, webhook.Options{
+		PollInterval: cfg.Webhooks.PollInterval,
+		Timeout:      cfg.Webhooks.Timeout,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		Logger:       logger,
+	})
+
+	relay := outbox.NewRelay(store.Outbox, outbox.Options{
+		PollInterval: cfg.Outbox.PollInterval,
+		Retention:    cfg.Outbox.Retention,
+		Logger:       logger,
+	})
+	relay.Register("webhooks", webhooks)
+	relay.Register("activity", activity.NewBroadcaster(store))
+	if err := relay.Listen(cfg.DB.Addr); err != nil {
+		logger.Fatal(err)
+	}
+
+	hub := activity.NewHub(cfg.Stream.Buffer, logger)
+	if err := hub.Listen(cfg.DB.Addr); err != nil {
+		logger.Fatal(err)
+	}
+
+	templates, err := notification.DefaultTemplates()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	notifications := notification.NewService(store.Notifications, templates, logger)
+	switch cfg.Notifications.Driver {
+	case "smtp":
+		driver, err := notification.NewSMTPDriver(notification.SMTPOptions{
+			Addr:     cfg.Notifications.SMTP.Addr,
+			Username: cfg.Notifications.SMTP.Username,
+			Password: cfg.Notifications.SMTP.Password,
+			From:     cfg.Notifications.From,
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		notifications.Register(notification.ChannelEmail, driver)
+	default:
+		driver, err := notification.NewFileDriver(cfg.Notifications.FileDir, cfg.Notifications.From)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		notifications.Register(notification.ChannelEmail, driver)
+	}
+
 	app := &application{
-		logger:        logger,
-		config:        cfg,
-		store:         store,
-		authenticator: authenticator,
-		errorHandler:  errorHandler,
+		logger:           logger,
+		config:           *cfg,
+		store:            store,
+		authenticator:    authenticator,
+		identityProvider: identityProvider,
+		errorHandler:     errorHandler,
+		activity:         hub,
+		jobs:             jobs.NewScheduler(store.Jobs, logger),
+		notifications:    notifications,
+		schemaVersion:    migrator.Latest(),
+	}
+
+	dunningProcessor := dunning.NewProcessor(store.Dunning, dunning.NotifierFunc(app.notifyDunning), dunning.Policy{
+		ReminderOffsets: cfg.Dunning.ReminderOffsets,
+		BlockAfter:      cfg.Dunning.BlockAfter,
+		LostAfter:       cfg.Dunning.LostAfter,
+	}, logger)
+
+	if err := app.registerJobs(relay, dunningProcessor); err != nil {
+		logger.Fatal(err)
+	}
+	if cfg.Jobs.Enabled {
+		app.workers.Go(app.jobs.Run)
 	}
+	app.workers.Go(relay.Run)
+	app.workers.Go(webhooks.Run)
+	app.workers.Go(hub.Run)
+	app.workers.Go(func(ctx context.Context) {
+		pools.MonitorReplica(ctx, cfg.DB.Replica.LagCheckInterval)
+	})
 
 	err = app.serve(app.mountRoutes())
 	if err != nil {
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{
+			ID: 1,
+			Role: &store.Role{
+				ID: 1,
+			},
+		}, nil
+	}
+	app.store.Rentals.(*store.MockRentalStore).GetRentalFunc = func(ctx context.Context, id int64) (*store.Rental, error) {
+		return &store.Rental{ID: int(id), RentalDate: time.Now()}, nil
+	}
+
+	for _, id := range []string{"1", "2", "3"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/rentals/"+id, nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/rentals/1", nil))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+
+	t.Run("it should label requests by route pattern", func(t *testing.T) {
+		assert.Contains(t, body, `dvdrental_http_requests_total{method="GET",route="/v1/rentals/{id}",status="200"}`)
+		assert.Contains(t, body, `dvdrental_http_request_duration_seconds_bucket{method="GET",route="/v1/rentals/{id}"`)
+		assert.NotContains(t, body, `route="/v1/rentals/2"`)
+	})
+
+	t.Run("it should count auth failures by reason", func(t *testing.T) {
+		assert.Contains(t, body, `dvdrental_auth_failures_total{reason="missing_header"}`)
+	})
+}
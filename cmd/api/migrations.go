@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/migrate"
+	"go.uber.org/zap"
+)
+
+// prepareSchema applies pending migrations when apply is set and checks that
+// the binary can run against the database. It fails if the database is dirty
+// or newer than the embedded migrations; a database that is behind only
+// fails readiness until it is migrated.
+func prepareSchema(ctx context.Context, migrator *migrate.Migrator, apply bool, logger *zap.SugaredLogger) error {
+	if apply {
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			logger.Infow("applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate the database: %w", err)
+		}
+	}
+
+	version, err := migrator.Check(ctx)
+	if err != nil {
+		return fmt.Errorf("database schema does not match this binary: %w", err)
+	}
+	if version < migrator.Latest() {
+		logger.Warnw("database schema is behind, run the migrations or start with DB_MIGRATE=true", "version", version, "latest", migrator.Latest())
+		return nil
+	}
+
+	logger.Infow("database schema is up to date", "version", version)
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/dunning"
+	"github.com/andras-szesztai/dev-rental-api/internal/notification"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 500
+)
+
+// dunningNoticeTemplates maps dunning notices to their notification template.
+var dunningNoticeTemplates = map[string]string{
+	dunning.NoticeReminder: notification.TemplateOverdueReminder,
+	dunning.NoticeLost:     notification.TemplateRentalLost,
+}
+
+// notifyDunning sends a dunning notice through the notification service.
+func (app *application) notifyDunning(ctx context.Context, notice dunning.Notice) error {
+	return app.notifications.Send(ctx, notification.Notification{
+		Template: dunningNoticeTemplates[notice.Kind],
+		To:       notification.Recipient{CustomerID: notice.CustomerID, Email: notice.Email, Name: notice.Name},
+		Data:     notice,
+	})
+}
+
+type notificationPreferencesResponse struct {
+	Data store.NotificationPreferences `json:"data"`
+}
+
+type notificationLogResponse struct {
+	Data []store.NotificationLogEntry `json:"data"`
+}
+
+type updateNotificationPreferencesPayload struct {
+	Channels []string `json:"channels" validate:"required,unique,dive,oneof=email" example:"email"`
+	OptedOut *bool    `json:"opted_out" validate:"required" example:"false"`
+}
+
+// GetNotificationPreferences godoc
+//
+//	@Summary		Get notification preferences
+//	@Description	Get the channels a customer is notified on and whether they opted out. Available to admins and to the customer's own user.
+//	@Tags			3. Customers
+//	@Produce		json
+//	@Param			id	path		int	true	"Customer ID"
+//	@Success		200	{object}	notificationPreferencesResponse
+//	@Failure		400	{object}	utils.Problem
+//	@Failure		401	{object}	utils.Problem
+//	@Failure		403	{object}	utils.Problem
+//	@Failure		404	{object}	utils.Problem
+//	@Failure		500	{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/customers/{id}/notification-preferences [get]
+func (app *application) getNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := app.customerForUser(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := app.store.Notifications.GetNotificationPreferences(r.Context(), customerID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, notificationPreferencesResponse{Data: *prefs}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// UpdateNotificationPreferences godoc
+//
+//	@Summary		Update notification preferences
+//	@Description	Replace the channels a customer is notified on. An opted out customer only gets essential messages, such as charges for lost copies. Available to admins and to the customer's own user.
+//	@Tags			3. Customers
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int										true	"Customer ID"
+//	@Param			request	body		updateNotificationPreferencesPayload	true	"Notification preferences"
+//	@Success		200		{object}	notificationPreferencesResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		404		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/customers/{id}/notification-preferences [put]
+func (app *application) updateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	customerID, ok := app.customerForUser(w, r)
+	if !ok {
+		return
+	}
+
+	var payload updateNotificationPreferencesPayload
+	if err := utils.ReadJSON(w, r, &payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(r, payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	prefs := &store.NotificationPreferences{
+		CustomerID: int(customerID),
+		Channels:   payload.Channels,
+		OptedOut:   *payload.OptedOut,
+	}
+	if err := app.store.Notifications.UpdateNotificationPreferences(r.Context(), prefs); err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, notificationPreferencesResponse{Data: *prefs}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// ListNotifications godoc
+//
+//	@Summary		List sent notifications
+//	@Description	List the notification send log, newest first. Every message has an entry per channel with status sent, failed or skipped.
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Param			customer_id	query		int	false	"Customer ID"
+//	@Param			limit		query		int	false	"Limit"	default(50)
+//	@Success		200			{object}	notificationLogResponse
+//	@Failure		400			{object}	utils.Problem
+//	@Failure		401			{object}	utils.Problem
+//	@Failure		403			{object}	utils.Problem
+//	@Failure		500			{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/notifications [get]
+func (app *application) listNotifications(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var customerID int64
+	if value := query.Get("customer_id"); value != "" {
+		var err error
+		customerID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || customerID < 1 {
+			app.errorHandler.BadRequest(w, r, errors.New("customer_id must be a positive integer"))
+			return
+		}
+	}
+
+	limit := defaultNotificationLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxNotificationLimit {
+			app.errorHandler.BadRequest(w, r, errors.New("limit must be between 1 and 500"))
+			return
+		}
+	}
+
+	entries, err := app.store.Notifications.ListNotifications(r.Context(), customerID, limit)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, notificationLogResponse{Data: entries}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// customerForUser returns the customer ID of the route if the user is an
+// admin or that customer's own user, and writes the error response
+// otherwise.
+func (app *application) customerForUser(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	customerID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return 0, false
+	}
+
+	user := app.getUserContext(r)
+	if user.Role.Name == "admin" {
+		return customerID, true
+	}
+
+	customer, err := app.store.Customers.GetCustomerByID(r.Context(), customerID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return 0, false
+	}
+	if customer.UserID == nil || *customer.UserID != user.ID {
+		app.errorHandler.Forbidden(w, r, errors.New("not allowed to manage this customer"))
+		return 0, false
+	}
+	return customerID, true
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/dunning"
+	"github.com/andras-szesztai/dev-rental-api/internal/notification"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	require.NoError(t, err)
+
+	asRole := func(roleID int) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 5, Role: &store.Role{ID: roleID}}, nil
+		}
+	}
+	ownUserID := 5
+	app.store.Customers.(*store.MockCustomerStore).GetCustomerByIDFunc = func(ctx context.Context, id int64) (*store.Customer, error) {
+		if id == 1 {
+			return &store.Customer{ID: 1, UserID: &ownUserID}, nil
+		}
+		return &store.Customer{ID: int(id)}, nil
+	}
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("admin should be able to get the preferences of a customer", func(t *testing.T) {
+		asRole(1)
+
+		recorder := send(http.MethodGet, "/v1/customers/2/notification-preferences", "")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"customer_id":2,"channels":["email"],"opted_out":false`)
+	})
+
+	t.Run("customer should be able to opt out", func(t *testing.T) {
+		asRole(2)
+		var updated *store.NotificationPreferences
+		app.store.Notifications.(*store.MockNotificationStore).UpdateNotificationPreferencesFunc = func(ctx context.Context, prefs *store.NotificationPreferences) error {
+			updated = prefs
+			return nil
+		}
+
+		recorder := send(http.MethodPut, "/v1/customers/1/notification-preferences", `{"channels":["email"],"opted_out":true}`)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		require.NotNil(t, updated)
+		assert.Equal(t, 1, updated.CustomerID)
+		assert.True(t, updated.OptedOut)
+	})
+
+	t.Run("customer should not be able to change another customer", func(t *testing.T) {
+		asRole(2)
+
+		recorder := send(http.MethodPut, "/v1/customers/2/notification-preferences", `{"channels":["email"],"opted_out":true}`)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("bad request for an unknown channel", func(t *testing.T) {
+		asRole(1)
+
+		recorder := send(http.MethodPut, "/v1/customers/1/notification-preferences", `{"channels":["pigeon"],"opted_out":false}`)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("admin should be able to list sent notifications", func(t *testing.T) {
+		asRole(1)
+		app.store.Notifications.(*store.MockNotificationStore).ListNotificationsFunc = func(ctx context.Context, customerID int64, limit int) ([]store.NotificationLogEntry, error) {
+			assert.Equal(t, int64(1), customerID)
+			assert.Equal(t, 10, limit)
+			return []store.NotificationLogEntry{{ID: 3, Template: notification.TemplateRentalLost, Status: store.NotificationSent}}, nil
+		}
+
+		recorder := send(http.MethodGet, "/v1/admin/notifications?customer_id=1&limit=10", "")
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"template":"rental_lost"`)
+	})
+
+	t.Run("bad request if the limit is out of range", func(t *testing.T) {
+		asRole(1)
+
+		recorder := send(http.MethodGet, "/v1/admin/notifications?limit=0", "")
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("customer should not be able to list sent notifications", func(t *testing.T) {
+		asRole(2)
+
+		recorder := send(http.MethodGet, "/v1/admin/notifications", "")
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
+
+func TestNotifyDunning(t *testing.T) {
+	app := newTestApplication(t)
+
+	var sent []notification.Message
+	app.notifications.Register(notification.ChannelEmail, notification.DriverFunc(func(ctx context.Context, msg notification.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}))
+
+	err := app.notifyDunning(context.Background(), dunning.Notice{
+		Kind:       dunning.NoticeLost,
+		RentalID:   7,
+		CustomerID: 1,
+		Email:      "mary.smith@sakilacustomer.org",
+		Name:       "MARY SMITH",
+		FilmTitle:  "ACADEMY DINOSAUR",
+		DueDate:    time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+		Charge:     20.99,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, sent, 1)
+	assert.Equal(t, notification.TemplateRentalLost, sent[0].Template)
+	assert.Equal(t, "mary.smith@sakilacustomer.org", sent[0].To.Email)
+	assert.Contains(t, sent[0].Text, "replacement cost of 20.99")
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowTTL    = 10 * time.Minute
+	// Provisioned usernames follow the 3 to 20 character rule of
+	// registration. A taken username is retried with a number appended, up
+	// to maxUsernameAttempts times.
+	minUsernameLength   = 3
+	maxUsernameLength   = 20
+	maxUsernameAttempts = 10
+)
+
+type oidcFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// OIDCLogin godoc
+//
+//	@Summary		Start staff single sign-on
+//	@Description	Redirect to the corporate identity provider using the authorization code + PKCE flow
+//	@Tags			2. Auth
+//	@Success		302
+//	@Failure		500	{object}	utils.Problem
+//	@Router			/auth/oidc/login [get]
+func (app *application) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := randomString(32)
+	if err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	flow := oidcFlow{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier()}
+	value, err := json.Marshal(flow)
+	if err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/v1/auth/oidc",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   app.config.Env == "prod",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, app.identityProvider.AuthCodeURL(flow.State, flow.Nonce, flow.Verifier), http.StatusFound)
+}
+
+// OIDCCallback godoc
+//
+//	@Summary		Complete staff single sign-on
+//	@Description	Exchange the authorization code, map the verified email to a staff member and issue a JWT
+//	@Tags			2. Auth
+//	@Produce		json
+//	@Param			code	query		string			true	"Authorization code"
+//	@Param			state	query		string			true	"State"
+//	@Success		200		{object}	signInResponse	"JWT token"
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Router			/auth/oidc/callback [get]
+func (app *application) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	flow, err := readOIDCFlow(r)
+	if err != nil {
+		app.errorHandler.Unauthorized(w, r, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcFlowCookie, Path: "/v1/auth/oidc", MaxAge: -1})
+
+	query := r.URL.Query()
+	if idpErr := query.Get("error"); idpErr != "" {
+		app.errorHandler.Unauthorized(w, r, fmt.Errorf("identity provider returned error: %s", idpErr))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(flow.State)) != 1 {
+		app.errorHandler.Unauthorized(w, r, errors.New("state mismatch"))
+		return
+	}
+
+	identity, err := app.identityProvider.Exchange(r.Context(), query.Get("code"), flow.Nonce, flow.Verifier)
+	if err != nil {
+		app.errorHandler.Unauthorized(w, r, err)
+		return
+	}
+	if !identity.EmailVerified || identity.Email == "" {
+		app.errorHandler.Unauthorized(w, r, errors.New("email is not verified"))
+		return
+	}
+
+	staff, err := app.store.Staff.GetStaffByEmail(r.Context(), identity.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			app.errorHandler.Unauthorized(w, r, errors.New("no staff member for email"))
+			return
+		}
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	var userID int
+	if staff.UserID != nil {
+		userID = *staff.UserID
+	} else {
+		userID, err = app.provisionStaffUser(r, identity.Email)
+		if err != nil {
+			app.errorHandler.Error(w, r, err)
+			return
+		}
+	}
+
+	token, err := app.generateUserToken(userID)
+	if err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, signInResponse{Data: token}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+}
+
+// provisionStaffUser creates the user account for a staff member signing in
+// through the identity provider for the first time. The password is random,
+// so the account can only be used through single sign-on.
+func (app *application) provisionStaffUser(r *http.Request, email string) (int, error) {
+	role, err := app.store.Roles.GetRoleByName(r.Context(), "admin")
+	if err != nil {
+		return 0, err
+	}
+
+	password, err := randomString(32)
+	if err != nil {
+		return 0, err
+	}
+
+	user := &store.User{
+		Email: email,
+		Role:  role,
+	}
+	if err := user.Password.Set(password); err != nil {
+		return 0, err
+	}
+
+	username := usernameFromEmail(email)
+	for attempt := 1; ; attempt++ {
+		user.Username = username
+		if attempt > 1 {
+			user.Username = numberedUsername(username, attempt)
+		}
+		err := app.store.Users.RegisterUser(r.Context(), user)
+		if err == nil {
+			return user.ID, nil
+		}
+		if !errors.Is(err, store.ErrUsernameTaken) || attempt == maxUsernameAttempts {
+			return 0, err
+		}
+	}
+}
+
+// usernameFromEmail derives a username from the local part of email, keeping
+// letters, digits, dots, dashes and underscores and shortening it to the
+// length limit. Local parts that are too short get a staff. prefix.
+func usernameFromEmail(email string) string {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	var b strings.Builder
+	for _, r := range local {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	username := b.String()
+	if len(username) < minUsernameLength {
+		username = "staff." + username
+	}
+	if len(username) > maxUsernameLength {
+		username = username[:maxUsernameLength]
+	}
+	return username
+}
+
+// numberedUsername appends n to username, shortening it to stay within the
+// length limit.
+func numberedUsername(username string, n int) string {
+	suffix := strconv.Itoa(n)
+	if len(username)+len(suffix) > maxUsernameLength {
+		username = username[:maxUsernameLength-len(suffix)]
+	}
+	return username + suffix
+}
+
+func readOIDCFlow(r *http.Request) (*oidcFlow, error) {
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		return nil, errors.New("sign-in flow not found")
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, errors.New("invalid sign-in flow")
+	}
+
+	var flow oidcFlow
+	if err := json.Unmarshal(value, &flow); err != nil || flow.State == "" {
+		return nil, errors.New("invalid sign-in flow")
+	}
+
+	return &flow, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
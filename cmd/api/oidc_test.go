@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/auth"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOIDCClientID    = "dvd-rental"
+	testOIDCRedirectURL = "http://localhost/v1/auth/oidc/callback"
+)
+
+type testAuthRequest struct {
+	challenge string
+	nonce     string
+}
+
+// testIdentityProvider is a minimal stand-in OIDC provider serving discovery,
+// JWKS, authorize and token endpoints.
+type testIdentityProvider struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	email         string
+	emailVerified bool
+
+	mu    sync.Mutex
+	codes map[string]testAuthRequest
+}
+
+func newTestIdentityProvider(t *testing.T) *testIdentityProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &testIdentityProvider{
+		key:           key,
+		email:         "mike.hillyer@sakilastaff.com",
+		emailVerified: true,
+		codes:         map[string]testAuthRequest{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *testIdentityProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeTestJSON(w, map[string]any{
+		"issuer":                                idp.server.URL,
+		"authorization_endpoint":                idp.server.URL + "/authorize",
+		"token_endpoint":                        idp.server.URL + "/token",
+		"jwks_uri":                              idp.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (idp *testIdentityProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeTestJSON(w, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (idp *testIdentityProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != testOIDCClientID {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	code, _ := randomString(16)
+	idp.mu.Lock()
+	idp.codes[code] = testAuthRequest{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	idp.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *testIdentityProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	idp.mu.Lock()
+	request, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != request.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            testOIDCClientID,
+		"sub":            "staff-1",
+		"email":          idp.email,
+		"email_verified": idp.emailVerified,
+		"nonce":          request.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = "test"
+	signed, _ := idToken.SignedString(idp.key)
+
+	writeTestJSON(w, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+func writeTestJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// runOIDCFlow starts the sign-in, follows the provider redirect and returns
+// the callback request including the flow cookie.
+func runOIDCFlow(t *testing.T, mux http.Handler) *http.Request {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, recorder.Code)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/callback?"+callback.RawQuery, nil)
+	req.AddCookie(cookies[0])
+	return req
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+
+	app := newTestApplication(t)
+	provider, err := auth.NewOIDCProvider(context.Background(), idp.server.URL+"/.well-known/openid-configuration", testOIDCClientID, "secret", testOIDCRedirectURL)
+	require.NoError(t, err)
+	app.identityProvider = provider
+	mux := app.mountRoutes()
+
+	t.Run("it should redirect to the identity provider with a PKCE challenge", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil))
+
+		assert.Equal(t, http.StatusFound, recorder.Code)
+		location, err := url.Parse(recorder.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, idp.server.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+		assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+		assert.NotEmpty(t, location.Query().Get("nonce"))
+		assert.NotEmpty(t, location.Query().Get("state"))
+	})
+
+	t.Run("it should issue a token for a registered staff member", func(t *testing.T) {
+		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
+			assert.Equal(t, idp.email, email)
+			return &store.Staff{ID: 1, UserID: &[]int{1}[0]}, nil
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, runOIDCFlow(t, mux))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "data")
+	})
+
+	t.Run("it should provision a user for a staff member signing in for the first time", func(t *testing.T) {
+		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
+			return &store.Staff{ID: 1}, nil
+		}
+		var registered *store.User
+		app.store.Users.(*store.MockUserStore).RegisterUserFunc = func(ctx context.Context, user *store.User) error {
+			registered = user
+			user.ID = 5
+			return nil
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, runOIDCFlow(t, mux))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.NotNil(t, registered)
+		assert.Equal(t, idp.email, registered.Email)
+		assert.Equal(t, "mike.hillyer", registered.Username)
+		assert.Equal(t, "admin", registered.Role.Name)
+	})
+
+	t.Run("it should number the username if it is taken", func(t *testing.T) {
+		var tried []string
+		app.store.Users.(*store.MockUserStore).RegisterUserFunc = func(ctx context.Context, user *store.User) error {
+			tried = append(tried, user.Username)
+			if len(tried) < 3 {
+				return store.ErrUsernameTaken
+			}
+			user.ID = 5
+			return nil
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, runOIDCFlow(t, mux))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, []string{"mike.hillyer", "mike.hillyer2", "mike.hillyer3"}, tried)
+	})
+
+	t.Run("conflict if every numbered username is taken", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).RegisterUserFunc = func(ctx context.Context, user *store.User) error {
+			return store.ErrUsernameTaken
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, runOIDCFlow(t, mux))
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "username_taken")
+	})
+
+	t.Run("unauthorized if no staff member matches the email", func(t *testing.T) {
+		app.store.Staff.(*store.MockStaffStore).GetStaffByEmailFunc = func(ctx context.Context, email string) (*store.Staff, error) {
+			return nil, sql.ErrNoRows
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, runOIDCFlow(t, mux))
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("unauthorized if the email is not verified", func(t *testing.T) {
+		idp.emailVerified = false
+		defer func() { idp.emailVerified = true }()
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, runOIDCFlow(t, mux))
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("unauthorized if the state does not match", func(t *testing.T) {
+		req := runOIDCFlow(t, mux)
+		query := req.URL.Query()
+		query.Set("state", "forged")
+		req.URL.RawQuery = query.Encode()
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("unauthorized without the flow cookie", func(t *testing.T) {
+		req := runOIDCFlow(t, mux)
+		req.Header.Del("Cookie")
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+}
+
+func TestUsernameFromEmail(t *testing.T) {
+	tests := []struct {
+		email    string
+		username string
+	}{
+		{"mike.hillyer@sakilastaff.com", "mike.hillyer"},
+		{"Jon+Stephens@sakilastaff.com", "jonstephens"},
+		{"jo@sakilastaff.com", "staff.jo"},
+		{"a.very.long.name.of.a.staff.member@sakilastaff.com", "a.very.long.name.of."},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.username, usernameFromEmail(tt.email), tt.email)
+	}
+
+	assert.Equal(t, "a.very.long.name.o10", numberedUsername("a.very.long.name.of.", 10))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+)
+
+type paymentResponse struct {
+	Data store.Payment `json:"data"`
+}
+
+type paymentListResponse struct {
+	Data       []store.Payment `json:"data"`
+	NextCursor string          `json:"next_cursor"`
+}
+
+// ListPayments godoc
+//
+//	@Summary		List payments
+//	@Description	List payments, newest first. Filter with filter[field]=value or filter[field][op]=value (ops: eq, ne, lt, lte, gt, gte, in) on id, customer_id, staff_id, rental_id, amount and payment_date. Sort by id, amount or payment_date.
+//	@Tags			4. Rentals
+//	@Produce		json
+//	@Param			filter[customer_id]			query		int		false	"Customer ID"
+//	@Param			filter[payment_date][gte]	query		string	false	"Paid at or after (RFC3339 or YYYY-MM-DD)"
+//	@Param			sort						query		string	false	"Comma separated fields, prefix with - for descending"	default(-payment_date)
+//	@Param			limit						query		int		false	"Limit"													default(50)
+//	@Param			cursor						query		string	false	"next_cursor of the previous page"
+//	@Success		200							{object}	paymentListResponse
+//	@Failure		400							{object}	utils.Problem
+//	@Failure		401							{object}	utils.Problem
+//	@Failure		403							{object}	utils.Problem
+//	@Failure		500							{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/payments [get]
+func (app *application) listPayments(w http.ResponseWriter, r *http.Request) {
+	q, err := listquery.Parse(r.URL.Query(), store.PaymentList)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	payments, cursor, err := app.store.Payments.ListPayments(r.Context(), q)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, paymentListResponse{Data: payments, NextCursor: cursor}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+type createPaymentPayload struct {
+	CustomerID int     `json:"customer_id" validate:"required,min=1" example:"1"`
+	StaffID    int     `json:"staff_id" validate:"required,min=1" example:"1"`
+	RentalID   int     `json:"rental_id" validate:"required,min=1" example:"1"`
+	Amount     float64 `json:"amount" validate:"gt=0,max=999.99" example:"4.99"`
+}
+
+// CreatePayment godoc
+//
+//	@Summary		Create payment
+//	@Description	Record a payment for a rental. Publishes the payment.created webhook event.
+//	@Tags			4. Rentals
+//	@Accept			json
+//	@Produce		json
+//	@Param			request			body		createPaymentPayload	true	"Create payment request"
+//	@Param			Idempotency-Key	header		string					false	"Key that makes retries of this request safe"
+//	@Success		201				{object}	paymentResponse
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		422				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/payments [post]
+func (app *application) createPayment(w http.ResponseWriter, r *http.Request) {
+	var payload createPaymentPayload
+	if err := utils.ReadJSON(w, r, &payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(r, payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	payment := &store.Payment{
+		CustomerID: payload.CustomerID,
+		StaffID:    payload.StaffID,
+		RentalID:   payload.RentalID,
+		Amount:     payload.Amount,
+	}
+	if err := app.store.Payments.CreatePayment(r.Context(), payment); err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusCreated, paymentResponse{Data: *payment}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePayment(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	users := app.store.Users.(*store.MockUserStore)
+	payments := app.store.Payments.(*store.MockPaymentStore)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/v1/payments", strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("admin should be able to record a payment", func(t *testing.T) {
+		users.GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+		}
+		payments.CreatePaymentFunc = func(ctx context.Context, payment *store.Payment) error {
+			assert.Equal(t, 77, payment.RentalID)
+			assert.Equal(t, 4.99, payment.Amount)
+			payment.ID = 12
+			return nil
+		}
+
+		recorder := post(`{"customer_id":2,"staff_id":1,"rental_id":77,"amount":4.99}`)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"id":12`)
+	})
+
+	t.Run("bad request if the rental does not exist", func(t *testing.T) {
+		payments.CreatePaymentFunc = func(ctx context.Context, payment *store.Payment) error {
+			return store.ErrInvalidReference
+		}
+
+		recorder := post(`{"customer_id":2,"staff_id":1,"rental_id":77,"amount":4.99}`)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"invalid_reference"`)
+	})
+
+	t.Run("bad request if the amount is not positive", func(t *testing.T) {
+		recorder := post(`{"customer_id":2,"staff_id":1,"rental_id":77,"amount":0}`)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"field":"amount"`)
+	})
+
+	t.Run("customer should not be able to record a payment", func(t *testing.T) {
+		users.GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 2, Role: &store.Role{ID: 2}}, nil
+		}
+
+		recorder := post(`{"customer_id":2,"staff_id":1,"rental_id":77,"amount":4.99}`)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
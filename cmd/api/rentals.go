@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
 	"github.com/go-chi/chi/v5"
@@ -15,6 +16,47 @@ type rentalResponse struct {
 	Data store.Rental `json:"data"`
 }
 
+type rentalListResponse struct {
+	Data       []store.Rental `json:"data"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+// ListRentals godoc
+//
+//	@Summary		List rentals
+//	@Description	List rentals, newest first. Filter with filter[field]=value or filter[field][op]=value (ops: eq, ne, lt, lte, gt, gte, like, in) on id, rental_date, inventory_id, customer_id, return_date, staff_id and last_update. Sort by id, rental_date or last_update.
+//	@Tags			4. Rentals
+//	@Produce		json
+//	@Param			filter[customer_id]			query		int		false	"Customer ID"
+//	@Param			filter[rental_date][gte]	query		string	false	"Rented at or after (RFC3339 or YYYY-MM-DD)"
+//	@Param			sort						query		string	false	"Comma separated fields, prefix with - for descending"	default(-rental_date)
+//	@Param			limit						query		int		false	"Limit"													default(50)
+//	@Param			cursor						query		string	false	"next_cursor of the previous page"
+//	@Success		200							{object}	rentalListResponse
+//	@Failure		400							{object}	utils.Problem
+//	@Failure		401							{object}	utils.Problem
+//	@Failure		403							{object}	utils.Problem
+//	@Failure		500							{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/rentals [get]
+func (app *application) listRentals(w http.ResponseWriter, r *http.Request) {
+	q, err := listquery.Parse(r.URL.Query(), store.RentalList)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	rentals, cursor, err := app.store.Rentals.ListRentals(r.Context(), q)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, rentalListResponse{Data: rentals, NextCursor: cursor}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
 // GetRentalByID godoc
 //
 //	@Summary		Get rental by ID
@@ -24,10 +66,11 @@ type rentalResponse struct {
 //	@Produce		json
 //	@Param			id	path		string	true	"Rental ID"
 //	@Success		200	{object}	rentalResponse
-//	@Failure		400	{object}	utils.ErrorResponse
-//	@Failure		401	{object}	utils.ErrorResponse
-//	@Failure		404	{object}	utils.ErrorResponse
-//	@Failure		500	{object}	utils.ErrorResponse
+//	@Failure		400	{object}	utils.Problem
+//	@Failure		401	{object}	utils.Problem
+//	@Failure		403	{object}	utils.Problem
+//	@Failure		404	{object}	utils.Problem
+//	@Failure		500	{object}	utils.Problem
 //	@Security		ApiKeyAuth
 //	@Router			/rentals/{id} [get]
 func (app *application) getRentalByID(w http.ResponseWriter, r *http.Request) {
@@ -56,7 +99,92 @@ func (app *application) getRentalByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if user.Role.Name != "admin" {
-		app.errorHandler.Unauthorized(w, r, errors.New("unauthorized"))
+		app.errorHandler.Forbidden(w, r, errors.New("admin role required"))
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, rentalResponse{Data: *rental}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+type createRentalPayload struct {
+	InventoryID int `json:"inventory_id" validate:"required,min=1" example:"1"`
+	CustomerID  int `json:"customer_id" validate:"required,min=1" example:"1"`
+	StaffID     int `json:"staff_id" validate:"required,min=1" example:"1"`
+}
+
+// CreateRental godoc
+//
+//	@Summary		Create rental
+//	@Description	Check out an inventory item to a customer. Fails with customer_checkout_blocked while the customer has an overdue rental past DUNNING_BLOCK_AFTER. Publishes the rental.created webhook event.
+//	@Tags			4. Rentals
+//	@Accept			json
+//	@Produce		json
+//	@Param			request			body		createRentalPayload	true	"Create rental request"
+//	@Param			Idempotency-Key	header		string				false	"Key that makes retries of this request safe"
+//	@Success		201				{object}	rentalResponse
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		409				{object}	utils.Problem
+//	@Failure		422				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/rentals [post]
+func (app *application) createRental(w http.ResponseWriter, r *http.Request) {
+	var payload createRentalPayload
+	if err := utils.ReadJSON(w, r, &payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(r, payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	rental := &store.Rental{
+		InventoryID: payload.InventoryID,
+		CustomerID:  payload.CustomerID,
+		StaffID:     payload.StaffID,
+	}
+	if err := app.store.Rentals.CreateRental(r.Context(), rental); err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusCreated, rentalResponse{Data: *rental}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// ReturnRental godoc
+//
+//	@Summary		Return rental
+//	@Description	Record the return of a rental. Publishes the rental.returned webhook event.
+//	@Tags			4. Rentals
+//	@Produce		json
+//	@Param			id				path		int		true	"Rental ID"
+//	@Param			Idempotency-Key	header		string	false	"Key that makes retries of this request safe"
+//	@Success		200				{object}	rentalResponse
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		404				{object}	utils.Problem
+//	@Failure		409				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/rentals/{id}/return [post]
+func (app *application) returnRental(w http.ResponseWriter, r *http.Request) {
+	rentalID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	rental, err := app.store.Rentals.ReturnRental(r.Context(), rentalID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
 		return
 	}
 
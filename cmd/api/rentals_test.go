@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
@@ -70,8 +72,8 @@ func TestGetRental(t *testing.T) {
 		recorder := httptest.NewRecorder()
 		mux.ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "unauthorized")
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"forbidden"`)
 	})
 
 	t.Run("unauthorized if user is not authenticated (nil)", func(t *testing.T) {
@@ -129,7 +131,7 @@ func TestGetRental(t *testing.T) {
 		mux.ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusNotFound, recorder.Code)
-		assert.Contains(t, recorder.Body.String(), "not found")
+		assert.Contains(t, recorder.Body.String(), `"code":"not_found"`)
 	})
 
 	t.Run("internal server error if database error", func(t *testing.T) {
@@ -157,3 +159,120 @@ func TestGetRental(t *testing.T) {
 		assert.Contains(t, recorder.Body.String(), "the server encountered a problem and could not process your request")
 	})
 }
+
+func TestListRentals(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Rentals.(*store.MockRentalStore).ListRentalsFunc = func(ctx context.Context, q *listquery.Query) ([]store.Rental, string, error) {
+		return []store.Rental{{ID: 1, CustomerID: 130}}, "", nil
+	}
+
+	list := func(role int) *httptest.ResponseRecorder {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: role}}, nil
+		}
+		req := httptest.NewRequest(http.MethodGet, "/v1/rentals?filter[customer_id]=130", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("admin should be able to list rentals", func(t *testing.T) {
+		recorder := list(1)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"customer_id":130`)
+		assert.Contains(t, recorder.Body.String(), `"next_cursor":""`)
+	})
+
+	t.Run("customer should not be able to list rentals", func(t *testing.T) {
+		recorder := list(2)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
+
+func TestCreateAndReturnRental(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+	rentals := app.store.Rentals.(*store.MockRentalStore)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("admin should be able to check out an item", func(t *testing.T) {
+		rentals.CreateRentalFunc = func(ctx context.Context, rental *store.Rental) error {
+			assert.Equal(t, 5, rental.InventoryID)
+			rental.ID = 77
+			return nil
+		}
+
+		recorder := send(http.MethodPost, "/v1/rentals", `{"inventory_id":5,"customer_id":2,"staff_id":1}`)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"id":77`)
+	})
+
+	t.Run("conflict if the item is rented out", func(t *testing.T) {
+		rentals.CreateRentalFunc = func(ctx context.Context, rental *store.Rental) error {
+			return store.ErrInventoryUnavailable
+		}
+
+		recorder := send(http.MethodPost, "/v1/rentals", `{"inventory_id":5,"customer_id":2,"staff_id":1}`)
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "inventory_unavailable")
+	})
+
+	t.Run("conflict if the customer is blocked by an overdue rental", func(t *testing.T) {
+		rentals.CreateRentalFunc = func(ctx context.Context, rental *store.Rental) error {
+			return store.ErrCustomerCheckoutBlocked
+		}
+
+		recorder := send(http.MethodPost, "/v1/rentals", `{"inventory_id":5,"customer_id":2,"staff_id":1}`)
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "customer_checkout_blocked")
+	})
+
+	t.Run("bad request if a field is missing", func(t *testing.T) {
+		recorder := send(http.MethodPost, "/v1/rentals", `{"inventory_id":5}`)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("admin should be able to return a rental", func(t *testing.T) {
+		rentals.ReturnRentalFunc = func(ctx context.Context, id int64) (*store.Rental, error) {
+			returned := time.Now()
+			return &store.Rental{ID: int(id), ReturnDate: &returned}, nil
+		}
+
+		recorder := send(http.MethodPost, "/v1/rentals/77/return", "")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"id":77`)
+	})
+
+	t.Run("conflict if the rental was already returned", func(t *testing.T) {
+		rentals.ReturnRentalFunc = func(ctx context.Context, id int64) (*store.Rental, error) {
+			return nil, store.ErrRentalAlreadyReturned
+		}
+
+		recorder := send(http.MethodPost, "/v1/rentals/77/return", "")
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+)
+
+// lastWriteCookie holds the time of the client's last write in Unix
+// milliseconds.
+const lastWriteCookie = "last_write"
+
+// ReadYourWritesMiddleware starts a database session for the request. Once
+// the request commits a write, its later reads go to the primary instead of
+// a replica that may not have the write yet. The response then sets the
+// last_write cookie, and requests carrying it read from the primary too until
+// the replica is certain to have caught up: it is only used while lagging at
+// most DB_REPLICA_MAX_LAG, measured every DB_REPLICA_LAG_CHECK_INTERVAL.
+func (app *application) ReadYourWritesMiddleware(next http.Handler) http.Handler {
+	if app.config.DB.Replica.Addr == "" {
+		return next
+	}
+	window := app.config.DB.Replica.MaxLag + app.config.DB.Replica.LagCheckInterval
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var primaryUntil time.Time
+		if cookie, err := r.Cookie(lastWriteCookie); err == nil {
+			if millis, err := strconv.ParseInt(cookie.Value, 10, 64); err == nil {
+				primaryUntil = time.UnixMilli(millis).Add(window)
+			}
+		}
+		// A last write in the future can only come from a forged cookie or a
+		// clock step; it must not pin reads for longer than a real one.
+		if limit := time.Now().Add(window); primaryUntil.After(limit) {
+			primaryUntil = limit
+		}
+
+		r = r.WithContext(db.WithSession(r.Context(), primaryUntil))
+		next.ServeHTTP(&lastWriteWriter{ResponseWriter: w, r: r, secure: app.config.Env == "prod", maxAge: window}, r)
+	})
+}
+
+// lastWriteWriter sets the last_write cookie before the response is written
+// if the request wrote by then. Writes after the headers went out, such as
+// those of a streamed response, are not carried over.
+type lastWriteWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	secure      bool
+	maxAge      time.Duration
+	wroteHeader bool
+}
+
+func (w *lastWriteWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if lastWrite, ok := db.LastWrite(w.r.Context()); ok {
+			http.SetCookie(w.ResponseWriter, &http.Cookie{
+				Name:     lastWriteCookie,
+				Value:    strconv.FormatInt(lastWrite.UnixMilli(), 10),
+				Path:     "/",
+				MaxAge:   int(w.maxAge.Seconds()) + 1,
+				HttpOnly: true,
+				Secure:   w.secure,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *lastWriteWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher of the underlying
+// writer.
+func (w *lastWriteWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
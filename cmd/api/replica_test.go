@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadYourWritesMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.DB.Replica.Addr = "postgres://localhost/replica"
+	app.config.DB.Replica.MaxLag = 5 * time.Second
+	app.config.DB.Replica.LagCheckInterval = 5 * time.Second
+
+	handler := app.ReadYourWritesMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			db.MarkWritten(r.Context())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Run("a write should set the last_write cookie", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/customers", nil))
+
+		cookies := recorder.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, lastWriteCookie, cookies[0].Name)
+		assert.Equal(t, 11, cookies[0].MaxAge)
+		assert.True(t, cookies[0].HttpOnly)
+		millis, err := strconv.ParseInt(cookies[0].Value, 10, 64)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), time.UnixMilli(millis), time.Second)
+	})
+
+	t.Run("a read should not set the cookie", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/customers/1", nil)
+		req.AddCookie(&http.Cookie{Name: lastWriteCookie, Value: strconv.FormatInt(time.Now().UnixMilli(), 10)})
+		handler.ServeHTTP(recorder, req)
+
+		assert.Empty(t, recorder.Result().Cookies())
+	})
+
+	t.Run("it should not set the cookie without a replica", func(t *testing.T) {
+		app := newTestApplication(t)
+		recorder := httptest.NewRecorder()
+		app.ReadYourWritesMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			db.MarkWritten(r.Context())
+			w.WriteHeader(http.StatusNoContent)
+		})).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/customers", nil))
+
+		assert.Empty(t, recorder.Result().Cookies())
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type staffResponse struct {
+	Data store.Staff `json:"data"`
+}
+
+// GetStaffByID godoc
+//
+//	@Summary		Get staff member by ID
+//	@Description	Get a staff member by ID. The ETag header carries the staff member's version for If-None-Match.
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Param			id				path		int		true	"Staff ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{object}	staffResponse
+//	@Success		304
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		403				{object}	utils.Problem
+//	@Failure		404				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/staff/{id} [get]
+func (app *application) getStaffByID(w http.ResponseWriter, r *http.Request) {
+	staffID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	staff, err := app.store.Staff.GetStaffByID(r.Context(), staffID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	app.writeVersioned(w, r, staff.LastUpdate, http.StatusOK, staffResponse{Data: *staff})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type storeResponse struct {
+	Data store.RentalPlace `json:"data"`
+}
+
+// GetStoreByID godoc
+//
+//	@Summary		Get store by ID
+//	@Description	Get a rental store by ID. The ETag header carries the store's version for If-None-Match.
+//	@Tags			6. Catalog
+//	@Produce		json
+//	@Param			id				path		int		true	"Store ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{object}	storeResponse
+//	@Success		304
+//	@Failure		400				{object}	utils.Problem
+//	@Failure		401				{object}	utils.Problem
+//	@Failure		404				{object}	utils.Problem
+//	@Failure		500				{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/stores/{id} [get]
+func (app *application) getStoreByID(w http.ResponseWriter, r *http.Request) {
+	storeID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	rentalPlace, err := app.store.RentalPlaces.GetRentalPlaceByID(r.Context(), storeID)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	app.writeVersioned(w, r, rentalPlace.LastUpdate, http.StatusOK, storeResponse{Data: *rentalPlace})
+}
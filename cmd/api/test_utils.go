@@ -3,18 +3,36 @@ package main
 import (
 	"testing"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/activity"
 	"github.com/andras-szesztai/dev-rental-api/internal/auth"
+	"github.com/andras-szesztai/dev-rental-api/internal/jobs"
+	"github.com/andras-szesztai/dev-rental-api/internal/migrate"
+	"github.com/andras-szesztai/dev-rental-api/internal/notification"
 	"github.com/andras-szesztai/dev-rental-api/internal/store"
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/andras-szesztai/dev-rental-api/migrations"
 	"go.uber.org/zap"
 )
 
 func newTestApplication(t *testing.T) *application {
 	t.Helper()
+	mockStore := store.NewMockStore()
+	templates, err := notification.DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(nil, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
 	return &application{
 		logger:        zap.NewNop().Sugar(),
-		store:         store.NewMockStore(),
+		store:         mockStore,
 		authenticator: auth.NewMockAuth(),
 		errorHandler:  utils.NewErrorHandler(zap.NewNop().Sugar()),
+		activity:      activity.NewHub(0, nil),
+		jobs:          jobs.NewScheduler(mockStore.Jobs, nil),
+		notifications: notification.NewService(mockStore.Notifications, templates, nil),
+		schemaVersion: migrator.Latest(),
 	}
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/andras-szesztai/dev-rental-api/internal/webhook"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultDeadLetterLimit = 50
+	maxDeadLetterLimit     = 500
+)
+
+type createWebhookEndpointPayload struct {
+	URL         string   `json:"url" validate:"required,http_url,max=2048" example:"https://example.com/webhooks"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=rental.created rental.returned rental.overdue customer.created customer.updated payment.created" example:"rental.created,rental.returned"`
+	Description string   `json:"description" validate:"max=255" example:"Inventory sync"`
+}
+
+// createdWebhookEndpoint is the only response that includes the signing
+// secret.
+type createdWebhookEndpoint struct {
+	store.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+type webhookEndpointResponse struct {
+	Data createdWebhookEndpoint `json:"data"`
+}
+
+type webhookEndpointListResponse struct {
+	Data []store.WebhookEndpoint `json:"data"`
+}
+
+type webhookDeliveryListResponse struct {
+	Data []store.WebhookDelivery `json:"data"`
+}
+
+// CreateWebhookEndpoint godoc
+//
+//	@Summary		Create webhook endpoint
+//	@Description	Register an endpoint for rental lifecycle events. Deliveries are POSTed as JSON with the Webhook-Id, Webhook-Event, Webhook-Timestamp and Webhook-Signature headers. The signature is "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">" under the secret, which is only returned in this response. Failed deliveries are retried with exponential backoff, then dead-lettered.
+//	@Tags			5. Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		createWebhookEndpointPayload	true	"Create webhook endpoint request"
+//	@Success		201		{object}	webhookEndpointResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks [post]
+func (app *application) createWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	var payload createWebhookEndpointPayload
+	if err := utils.ReadJSON(w, r, &payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+	if err := Validator.Struct(r, payload); err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+		return
+	}
+
+	endpoint := &store.WebhookEndpoint{
+		URL:         payload.URL,
+		Secret:      secret,
+		Events:      payload.Events,
+		Description: payload.Description,
+	}
+	if err := app.store.Webhooks.CreateWebhookEndpoint(r.Context(), endpoint); err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	response := webhookEndpointResponse{Data: createdWebhookEndpoint{WebhookEndpoint: *endpoint, Secret: secret}}
+	if err := utils.WriteJSONResponse(w, http.StatusCreated, response); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// ListWebhookEndpoints godoc
+//
+//	@Summary		List webhook endpoints
+//	@Description	List the registered webhook endpoints
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Success		200	{object}	webhookEndpointListResponse
+//	@Failure		401	{object}	utils.Problem
+//	@Failure		403	{object}	utils.Problem
+//	@Failure		500	{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks [get]
+func (app *application) listWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := app.store.Webhooks.ListWebhookEndpoints(r.Context())
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, webhookEndpointListResponse{Data: endpoints}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// DeleteWebhookEndpoint godoc
+//
+//	@Summary		Delete webhook endpoint
+//	@Description	Delete a webhook endpoint together with its pending and dead deliveries
+//	@Tags			5. Admin
+//	@Param			id	path	int	true	"Webhook endpoint ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.Problem
+//	@Failure		401	{object}	utils.Problem
+//	@Failure		403	{object}	utils.Problem
+//	@Failure		404	{object}	utils.Problem
+//	@Failure		500	{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{id} [delete]
+func (app *application) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Webhooks.DeleteWebhookEndpoint(r.Context(), id); err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeadWebhookDeliveries godoc
+//
+//	@Summary		List dead webhook deliveries
+//	@Description	List the deliveries to an endpoint that ran out of attempts, oldest first
+//	@Tags			5. Admin
+//	@Produce		json
+//	@Param			id		path		int	true	"Webhook endpoint ID"
+//	@Param			limit	query		int	false	"Limit"	default(50)
+//	@Success		200		{object}	webhookDeliveryListResponse
+//	@Failure		400		{object}	utils.Problem
+//	@Failure		401		{object}	utils.Problem
+//	@Failure		403		{object}	utils.Problem
+//	@Failure		500		{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/{id}/dead-letters [get]
+func (app *application) listDeadWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	limit := defaultDeadLetterLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxDeadLetterLimit {
+			app.errorHandler.BadRequest(w, r, errors.New("limit must be between 1 and 500"))
+			return
+		}
+	}
+
+	deliveries, err := app.store.Webhooks.ListDeadWebhookDeliveries(r.Context(), id, limit)
+	if err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	if err := utils.WriteJSONResponse(w, http.StatusOK, webhookDeliveryListResponse{Data: deliveries}); err != nil {
+		app.errorHandler.InternalServerError(w, r, err)
+	}
+}
+
+// ReplayWebhookDelivery godoc
+//
+//	@Summary		Replay dead webhook delivery
+//	@Description	Queue a dead delivery again with a fresh set of attempts
+//	@Tags			5. Admin
+//	@Param			id	path	int	true	"Webhook delivery ID"
+//	@Success		202
+//	@Failure		400	{object}	utils.Problem
+//	@Failure		401	{object}	utils.Problem
+//	@Failure		403	{object}	utils.Problem
+//	@Failure		404	{object}	utils.Problem
+//	@Failure		500	{object}	utils.Problem
+//	@Security		ApiKeyAuth
+//	@Router			/admin/webhooks/deliveries/{id}/replay [post]
+func (app *application) replayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.errorHandler.BadRequest(w, r, err)
+		return
+	}
+
+	if err := app.store.Webhooks.ReplayWebhookDelivery(r.Context(), id); err != nil {
+		app.errorHandler.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/outbox"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/webhook"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookEndpoints(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+
+	webhooks := app.store.Webhooks.(*store.MockWebhookStore)
+
+	t.Run("admin should be able to register an endpoint and receive its secret once", func(t *testing.T) {
+		var created *store.WebhookEndpoint
+		webhooks.CreateWebhookEndpointFunc = func(ctx context.Context, endpoint *store.WebhookEndpoint) error {
+			endpoint.ID = 4
+			endpoint.Active = true
+			created = endpoint
+			return nil
+		}
+
+		body := `{"url":"https://example.com/hooks","events":["rental.created","payment.created"]}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/admin/webhooks", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		require.NotNil(t, created)
+		assert.Equal(t, []string{"rental.created", "payment.created"}, created.Events)
+		assert.Contains(t, created.Secret, "whsec_")
+
+		var response struct {
+			Data struct {
+				ID     int64  `json:"id"`
+				Secret string `json:"secret"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		assert.Equal(t, int64(4), response.Data.ID)
+		assert.Equal(t, created.Secret, response.Data.Secret)
+	})
+
+	t.Run("bad request for an unknown event type", func(t *testing.T) {
+		body := `{"url":"https://example.com/hooks","events":["rental.lost"]}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/admin/webhooks", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("listed endpoints should not expose their secret", func(t *testing.T) {
+		webhooks.ListWebhookEndpointsFunc = func(ctx context.Context) ([]store.WebhookEndpoint, error) {
+			return []store.WebhookEndpoint{{ID: 4, URL: "https://example.com/hooks", Secret: "whsec_hidden", Events: []string{"rental.created"}}}, nil
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/webhooks", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "https://example.com/hooks")
+		assert.NotContains(t, recorder.Body.String(), "whsec_hidden")
+	})
+
+	t.Run("admin should be able to list and replay dead letters", func(t *testing.T) {
+		var endpointID int64
+		webhooks.ListDeadWebhookDeliveriesFunc = func(ctx context.Context, id int64, limit int) ([]store.WebhookDelivery, error) {
+			endpointID = id
+			return []store.WebhookDelivery{{ID: 9, EndpointID: id, EventID: "evt_1", Status: store.WebhookDeliveryDead, Payload: json.RawMessage(`{}`)}}, nil
+		}
+		var replayed int64
+		webhooks.ReplayWebhookDeliveryFunc = func(ctx context.Context, id int64) error {
+			replayed = id
+			return nil
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/webhooks/4/dead-letters", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, int64(4), endpointID)
+		assert.Contains(t, recorder.Body.String(), "evt_1")
+
+		req, err = http.NewRequest(http.MethodPost, "/v1/admin/webhooks/deliveries/9/replay", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder = httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusAccepted, recorder.Code)
+		assert.Equal(t, int64(9), replayed)
+	})
+
+	t.Run("not found when replaying a delivery that is not dead", func(t *testing.T) {
+		webhooks.ReplayWebhookDeliveryFunc = func(ctx context.Context, id int64) error {
+			return sql.ErrNoRows
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/admin/webhooks/deliveries/9/replay", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("forbidden for customers", func(t *testing.T) {
+		app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+			return &store.User{ID: 1, Role: &store.Role{ID: 2}}, nil
+		}
+		defer func() {
+			app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+				return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+			}
+		}()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/webhooks", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
+
+func TestRentalWebhookDelivery(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mountRoutes()
+
+	token, err := app.authenticator.GenerateToken(jwt.MapClaims{})
+	require.NoError(t, err)
+
+	app.store.Users.(*store.MockUserStore).GetUserByIDFunc = func(ctx context.Context, id int64) (*store.User, error) {
+		return &store.User{ID: 1, Role: &store.Role{ID: 1}}, nil
+	}
+
+	// The mocks keep the outbox and the delivery queue in memory, standing in
+	// for outbox_events and webhook_deliveries.
+	var pending []store.OutboxEvent
+	app.store.Rentals.(*store.MockRentalStore).CreateRentalFunc = func(ctx context.Context, rental *store.Rental) error {
+		rental.ID = 77
+		payload, err := json.Marshal(rental)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, store.OutboxEvent{ID: 1, AggregateType: "rental", AggregateID: 77, EventType: store.EventRentalCreated, Payload: payload, CreatedAt: time.Now()})
+		return nil
+	}
+	app.store.Outbox.(*store.MockOutboxStore).RelayOutboxEventsFunc = func(ctx context.Context, limit int, publish func(ctx context.Context, event *store.OutboxEvent) error) (int, error) {
+		relayed := 0
+		for len(pending) > 0 && relayed < limit {
+			if err := publish(ctx, &pending[0]); err != nil {
+				return relayed, err
+			}
+			pending = pending[1:]
+			relayed++
+		}
+		return relayed, nil
+	}
+
+	const secret = "whsec_test"
+	var delivery *http.Request
+	var payload []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivery = r
+		payload, _ = io.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+
+	var queue []store.WebhookDelivery
+	webhooks := app.store.Webhooks.(*store.MockWebhookStore)
+	webhooks.EnqueueWebhookEventFunc = func(ctx context.Context, eventID, eventType string, payload []byte) (int64, error) {
+		queue = append(queue, store.WebhookDelivery{ID: 1, EventID: eventID, EventType: eventType, Payload: payload, URL: receiver.URL, Secret: secret})
+		return 1, nil
+	}
+	webhooks.ClaimWebhookDeliveriesFunc = func(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+		claimed := queue
+		queue = nil
+		return claimed, nil
+	}
+	delivered := false
+	webhooks.CompleteWebhookDeliveryFunc = func(ctx context.Context, id int64, statusCode int) error {
+		delivered = true
+		return nil
+	}
+
+	dispatcher := webhook.NewDispatcher(app.store.Webhooks, webhook.Options{})
+	relay := outbox.NewRelay(app.store.Outbox, outbox.Options{})
+	relay.Register("webhooks", dispatcher)
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/rentals", bytes.NewBufferString(`{"inventory_id":1,"customer_id":2,"staff_id":1}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.Len(t, pending, 1)
+
+	relayed, err := relay.RelayPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+	require.Len(t, queue, 1)
+
+	sent, err := dispatcher.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.True(t, delivered)
+
+	require.NotNil(t, delivery)
+	assert.Equal(t, webhook.EventRentalCreated, delivery.Header.Get(webhook.HeaderEvent))
+	assert.NoError(t, webhook.Verify(secret, delivery.Header, payload, time.Minute, time.Now()))
+
+	var event struct {
+		Type string       `json:"type"`
+		Data store.Rental `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &event))
+	assert.Equal(t, webhook.EventRentalCreated, event.Type)
+	assert.Equal(t, 77, event.Data.ID)
+	assert.Equal(t, 2, event.Data.CustomerID)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// workerGroup runs background goroutines that are cancelled and waited for
+// when the server shuts down.
+type workerGroup struct {
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (g *workerGroup) init() {
+	g.once.Do(func() {
+		g.ctx, g.cancel = context.WithCancel(context.Background())
+	})
+}
+
+// Go starts fn with a context that is cancelled on shutdown.
+func (g *workerGroup) Go(fn func(ctx context.Context)) {
+	g.init()
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn(g.ctx)
+	}()
+}
+
+// Stop cancels all workers and waits for them to return or for ctx to expire.
+func (g *workerGroup) Stop(ctx context.Context) error {
+	g.init()
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
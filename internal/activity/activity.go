@@ -0,0 +1,149 @@
+// Package activity streams live rental activity of a store: checkouts,
+// returns and the inventory availability changes they cause. Events come
+// from the outbox and reach every replica through Postgres notifications,
+// where a Hub fans them out to the connected subscribers.
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+)
+
+// Channel is the Postgres notification channel events are broadcast on.
+const Channel = "store_activity"
+
+// Event types. Rental events carry the rental, availability events an
+// Availability.
+const (
+	TypeRentalCreated         = store.EventRentalCreated
+	TypeRentalReturned        = store.EventRentalReturned
+	TypeInventoryAvailability = "inventory.availability"
+)
+
+var ErrInvalidCursor = errors.New("invalid event id")
+
+// Cursor identifies an event. An outbox event can yield several events,
+// which share its Seq and are told apart by Part.
+type Cursor struct {
+	Seq  int64
+	Part int
+}
+
+func (c Cursor) String() string {
+	return fmt.Sprintf("%d:%d", c.Seq, c.Part)
+}
+
+// After reports whether c comes after other.
+func (c Cursor) After(other Cursor) bool {
+	return c.Seq > other.Seq || (c.Seq == other.Seq && c.Part > other.Part)
+}
+
+// ParseCursor parses an event ID as sent in Last-Event-ID.
+func ParseCursor(value string) (Cursor, error) {
+	seq, part, _ := strings.Cut(value, ":")
+	var c Cursor
+	var err error
+	if c.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil || c.Seq < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if c.Part, err = strconv.Atoi(part); err != nil || c.Part < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (c Cursor) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Cursor) UnmarshalText(text []byte) error {
+	parsed, err := ParseCursor(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// Event is one message of a store's stream.
+type Event struct {
+	ID      Cursor          `json:"id"`
+	StoreID int64           `json:"store_id"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Availability tells whether an inventory item can be rented.
+type Availability struct {
+	InventoryID int  `json:"inventory_id"`
+	FilmID      int  `json:"film_id"`
+	Available   bool `json:"available"`
+}
+
+// IsRentalEvent reports whether an outbox event yields activity events.
+func IsRentalEvent(event *store.OutboxEvent) bool {
+	return event.AggregateType == "rental" && (event.EventType == TypeRentalCreated || event.EventType == TypeRentalReturned)
+}
+
+// FromRentalEvent returns the activity events of a rental event: the event
+// itself followed by the availability change of the rented item.
+func FromRentalEvent(event *store.OutboxEvent, item store.InventoryItem) ([]Event, error) {
+	availability, err := json.Marshal(Availability{
+		InventoryID: item.ID,
+		FilmID:      item.FilmID,
+		Available:   event.EventType == TypeRentalReturned,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	storeID := int64(item.StoreID)
+	return []Event{
+		{ID: Cursor{Seq: event.ID}, StoreID: storeID, Type: event.EventType, Data: event.Payload},
+		{ID: Cursor{Seq: event.ID, Part: 1}, StoreID: storeID, Type: TypeInventoryAvailability, Data: availability},
+	}, nil
+}
+
+// Broadcaster is an outbox publisher that notifies every replica of the
+// activity events of rental events.
+type Broadcaster struct {
+	store *store.Store
+}
+
+func NewBroadcaster(s *store.Store) *Broadcaster {
+	return &Broadcaster{store: s}
+}
+
+func (b *Broadcaster) Publish(ctx context.Context, event *store.OutboxEvent) error {
+	if !IsRentalEvent(event) {
+		return nil
+	}
+
+	var rental store.Rental
+	if err := json.Unmarshal(event.Payload, &rental); err != nil {
+		return err
+	}
+	items, err := b.store.Inventory.GetInventoryItemsByIDs(ctx, []int64{int64(rental.InventoryID)})
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("inventory item %d of rental %d not found", rental.InventoryID, rental.ID)
+	}
+
+	events, err := FromRentalEvent(event, items[0])
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	return b.store.Outbox.Notify(ctx, Channel, string(payload))
+}
@@ -0,0 +1,45 @@
+package activity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("it should round trip", func(t *testing.T) {
+		cursor, err := ParseCursor(Cursor{Seq: 42, Part: 1}.String())
+		require.NoError(t, err)
+		assert.Equal(t, Cursor{Seq: 42, Part: 1}, cursor)
+	})
+
+	t.Run("it should order by sequence, then part", func(t *testing.T) {
+		assert.True(t, Cursor{Seq: 2}.After(Cursor{Seq: 1, Part: 1}))
+		assert.True(t, Cursor{Seq: 2, Part: 1}.After(Cursor{Seq: 2}))
+		assert.False(t, Cursor{Seq: 2}.After(Cursor{Seq: 2}))
+	})
+
+	t.Run("it should reject malformed ids", func(t *testing.T) {
+		for _, value := range []string{"", "42", "a:1", "42:b", "-1:0", "1:-1"} {
+			_, err := ParseCursor(value)
+			assert.ErrorIs(t, err, ErrInvalidCursor, value)
+		}
+	})
+}
+
+func TestFromRentalEvent(t *testing.T) {
+	t.Run("it should follow a rental event with the availability change", func(t *testing.T) {
+		event := &store.OutboxEvent{ID: 9, AggregateType: "rental", EventType: store.EventRentalReturned, Payload: json.RawMessage(`{"id":3}`)}
+		events, err := FromRentalEvent(event, store.InventoryItem{ID: 5, FilmID: 6, StoreID: 2})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+
+		assert.Equal(t, Event{ID: Cursor{Seq: 9}, StoreID: 2, Type: TypeRentalReturned, Data: event.Payload}, events[0])
+		assert.Equal(t, Cursor{Seq: 9, Part: 1}, events[1].ID)
+		assert.Equal(t, TypeInventoryAvailability, events[1].Type)
+		assert.JSONEq(t, `{"inventory_id":5,"film_id":6,"available":true}`, string(events[1].Data))
+	})
+}
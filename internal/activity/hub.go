@@ -0,0 +1,163 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const defaultBuffer = 64
+
+// Subscription receives the events of one store. C is closed when the
+// subscriber fell too far behind, or when the hub closes.
+type Subscription struct {
+	C       <-chan Event
+	c       chan Event
+	storeID int64
+	// Lagged is set before C is closed because the subscriber fell behind.
+	Lagged bool
+}
+
+// Hub fans events out to the subscribers of their store. Publishing never
+// blocks: a subscriber whose buffer is full is dropped, and resumes from its
+// last event once it reconnects.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[*Subscription]struct{}
+	closed      bool
+	buffer      int
+	logger      *zap.SugaredLogger
+	listener    *pq.Listener
+}
+
+// NewHub returns a hub that buffers up to buffer events per subscriber.
+func NewHub(buffer int, logger *zap.SugaredLogger) *Hub {
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	return &Hub{subscribers: map[int64]map[*Subscription]struct{}{}, buffer: buffer, logger: logger}
+}
+
+// Subscribe registers a subscriber to a store's events. Callers must
+// Unsubscribe when done.
+func (h *Hub) Subscribe(storeID int64) *Subscription {
+	c := make(chan Event, h.buffer)
+	sub := &Subscription{C: c, c: c, storeID: storeID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(c)
+		return sub
+	}
+	if h.subscribers[storeID] == nil {
+		h.subscribers[storeID] = map[*Subscription]struct{}{}
+	}
+	h.subscribers[storeID][sub] = struct{}{}
+	metrics.StreamSubscribers.Inc()
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// remove drops sub and closes its channel. h.mu must be held.
+func (h *Hub) remove(sub *Subscription) {
+	subs := h.subscribers[sub.storeID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.storeID)
+	}
+	close(sub.c)
+	metrics.StreamSubscribers.Dec()
+}
+
+// Publish hands event to the subscribers of its store.
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers[event.StoreID] {
+		select {
+		case sub.c <- event:
+		default:
+			sub.Lagged = true
+			h.remove(sub)
+		}
+	}
+}
+
+// Close ends every subscription and refuses new ones, so streams end when
+// the server shuts down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// Listen subscribes the hub to the events broadcast by any replica. dsn is
+// the database connection string.
+func (h *Hub) Listen(dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			h.logger.Warnw("activity listener connection error", "error", err)
+		}
+	})
+	if err := listener.Listen(Channel); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to listen for store activity: %w", err)
+	}
+	h.listener = listener
+	return nil
+}
+
+// Run publishes the broadcast events until ctx is cancelled.
+func (h *Hub) Run(ctx context.Context) {
+	if h.listener == nil {
+		return
+	}
+	defer h.listener.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-h.listener.Notify:
+			// nil after a reconnect: events sent meanwhile were missed,
+			// clients catch up with Last-Event-ID when they reconnect.
+			if notification == nil {
+				continue
+			}
+			var events []Event
+			if err := json.Unmarshal([]byte(notification.Extra), &events); err != nil {
+				h.logger.Errorw("failed to decode store activity", "error", err)
+				continue
+			}
+			for _, event := range events {
+				h.Publish(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package activity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub(t *testing.T) {
+	t.Run("it should only deliver events of the subscribed store", func(t *testing.T) {
+		hub := NewHub(4, nil)
+		first := hub.Subscribe(1)
+		second := hub.Subscribe(2)
+		defer hub.Unsubscribe(first)
+		defer hub.Unsubscribe(second)
+
+		hub.Publish(Event{ID: Cursor{Seq: 1}, StoreID: 1})
+
+		assert.Equal(t, Cursor{Seq: 1}, (<-first.C).ID)
+		assert.Empty(t, second.C)
+	})
+
+	t.Run("it should drop a subscriber that falls behind without blocking", func(t *testing.T) {
+		hub := NewHub(2, nil)
+		slow := hub.Subscribe(1)
+		fast := hub.Subscribe(1)
+		defer hub.Unsubscribe(fast)
+
+		for seq := int64(1); seq <= 3; seq++ {
+			hub.Publish(Event{ID: Cursor{Seq: seq}, StoreID: 1})
+			<-fast.C
+		}
+
+		assert.Equal(t, Cursor{Seq: 1}, (<-slow.C).ID)
+		assert.Equal(t, Cursor{Seq: 2}, (<-slow.C).ID)
+		_, open := <-slow.C
+		assert.False(t, open)
+		assert.True(t, slow.Lagged)
+
+		// Unsubscribing a dropped subscriber is harmless.
+		hub.Unsubscribe(slow)
+	})
+
+	t.Run("it should end every subscription on close", func(t *testing.T) {
+		hub := NewHub(2, nil)
+		sub := hub.Subscribe(1)
+		hub.Close()
+
+		_, open := <-sub.C
+		assert.False(t, open)
+		assert.False(t, sub.Lagged)
+
+		_, open = <-hub.Subscribe(1).C
+		assert.False(t, open)
+	})
+}
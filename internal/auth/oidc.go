@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/tracing"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+var (
+	ErrMissingIDToken = errors.New("token response did not include an id_token")
+	ErrNonceMismatch  = errors.New("id_token nonce does not match")
+)
+
+// Identity is the verified subject returned by an IdentityProvider.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// IdentityProvider drives an authorization code + PKCE sign-in against an
+// external identity provider.
+type IdentityProvider interface {
+	AuthCodeURL(state, nonce, verifier string) string
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error)
+}
+
+type OIDCProvider struct {
+	client   *http.Client
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDCProvider loads the provider metadata from discoveryURL. Both the
+// issuer URL and the full .well-known/openid-configuration URL are accepted.
+func NewOIDCProvider(ctx context.Context, discoveryURL, clientID, clientSecret, redirectURL string) (*OIDCProvider, error) {
+	issuer := strings.TrimSuffix(strings.TrimSuffix(discoveryURL, "/"), discoveryPath)
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, tracing.HTTPClient()), issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider: %w", err)
+	}
+
+	return &OIDCProvider{
+		client: tracing.HTTPClient(),
+		oauth2: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	ctx = oidc.ClientContext(ctx, p.client)
+
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id_token claims: %w", err)
+	}
+
+	return &Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
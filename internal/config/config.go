@@ -0,0 +1,765 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	minTokenSecretLength = 32
+	redacted             = "[REDACTED]"
+	grpcDisabled         = "off"
+)
+
+type Config struct {
+	Addr            string
+	Env             string
+	APIURL          string
+	Version         string
+	ShutdownTimeout time.Duration
+	// ShutdownDrainDelay is how long the server keeps serving after readiness
+	// starts failing, so load balancers stop routing to it before the
+	// listener closes.
+	ShutdownDrainDelay time.Duration
+	DB                 DBConfig
+	Auth               AuthConfig
+	Tracing            TracingConfig
+	Logging            LoggingConfig
+	Idempotency        IdempotencyConfig
+	GraphQL            GraphQLConfig
+	GRPC               GRPCConfig
+	Webhooks           WebhookConfig
+	Outbox             OutboxConfig
+	Stream             StreamConfig
+	Jobs               JobsConfig
+	Dunning            DunningConfig
+	Notifications      NotificationConfig
+}
+
+type DBConfig struct {
+	Addr         string
+	MaxOpenConns int
+	MaxIdleConns int
+	MaxIdleTime  time.Duration
+	// Migrate applies pending migrations at startup. Replicas take turns
+	// through an advisory lock.
+	Migrate bool
+	Replica ReplicaConfig
+}
+
+// ReplicaConfig configures an optional read replica. Without an address
+// every query goes to the primary.
+type ReplicaConfig struct {
+	Addr             string
+	MaxLag           time.Duration
+	LagCheckInterval time.Duration
+}
+
+type AuthConfig struct {
+	Token TokenConfig
+	OIDC  OIDCConfig
+}
+
+type TokenConfig struct {
+	Secret string
+	Exp    time.Duration
+	Aud    string
+	Iss    string
+}
+
+type OIDCConfig struct {
+	DiscoveryURL string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+}
+
+type LoggingConfig struct {
+	// SampleInitial access log lines are written per second and level, then
+	// only every SampleThereafter-th. Zero SampleInitial disables sampling.
+	SampleInitial    int
+	SampleThereafter int
+	// RouteLevels overrides the access log level by chi route pattern.
+	RouteLevels map[string]zapcore.Level
+}
+
+type IdempotencyConfig struct {
+	// Retention is how long responses are kept for replay.
+	Retention time.Duration
+	// InFlightTimeout is how long a key stays locked by a request that never
+	// completed, e.g. because the process crashed.
+	InFlightTimeout time.Duration
+}
+
+type GraphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+type GRPCConfig struct {
+	// Addr is the listen address of the gRPC server. Empty disables it,
+	// which GRPC_ADDR=off selects, since an empty variable counts as unset.
+	Addr string
+}
+
+type WebhookConfig struct {
+	// PollInterval is how often due deliveries are looked for.
+	PollInterval time.Duration
+	// Timeout bounds a single delivery request.
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered.
+	MaxAttempts int
+}
+
+type OutboxConfig struct {
+	// PollInterval is how often the outbox is checked for events whose
+	// notification was missed.
+	PollInterval time.Duration
+	// Retention is how long published events are kept.
+	Retention time.Duration
+}
+
+type StreamConfig struct {
+	// HeartbeatInterval is how often an idle event stream gets a comment, so
+	// proxies and clients keep the connection open.
+	HeartbeatInterval time.Duration
+	// Buffer is how many events a stream may fall behind before it is
+	// dropped.
+	Buffer int
+}
+
+type JobsConfig struct {
+	// Enabled runs the maintenance job scheduler in this process. Jobs can
+	// still be triggered by hand when it is disabled.
+	Enabled bool
+	// RunRetention is how long the history of finished job runs is kept.
+	RunRetention time.Duration
+}
+
+type DunningConfig struct {
+	// ReminderOffsets are the offsets past the due date at which overdue
+	// reminders are sent, in ascending order.
+	ReminderOffsets []time.Duration
+	// BlockAfter is how long past the due date checkout is blocked.
+	BlockAfter time.Duration
+	// LostAfter is how long past the due date a copy is considered lost and
+	// its replacement cost charged.
+	LostAfter time.Duration
+}
+
+type NotificationConfig struct {
+	// Driver delivers email: smtp, or file to write messages to FileDir.
+	Driver  string
+	FileDir string
+	From    string
+	SMTP    SMTPConfig
+}
+
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+// field describes a single setting: the environment (and file) key, the
+// command line flag and its default.
+type field struct {
+	key    string
+	flag   string
+	usage  string
+	def    string
+	redact func(value string) string
+	set    func(c *Config, value string) error
+	get    func(c *Config) string
+}
+
+var fields = []field{
+	{
+		key: "PORT", flag: "addr", usage: "listen address", def: ":8080",
+		set: setString(func(c *Config) *string { return &c.Addr }),
+		get: func(c *Config) string { return c.Addr },
+	},
+	{
+		key: "ENV", flag: "env", usage: "environment name", def: "development",
+		set: setString(func(c *Config) *string { return &c.Env }),
+		get: func(c *Config) string { return c.Env },
+	},
+	{
+		key: "API_URL", flag: "api-url", usage: "public API URL",
+		set: setString(func(c *Config) *string { return &c.APIURL }),
+		get: func(c *Config) string { return c.APIURL },
+	},
+	{
+		key: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "time allowed to drain connections on shutdown", def: "30s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+		get: func(c *Config) string { return c.ShutdownTimeout.String() },
+	},
+	{
+		key: "SHUTDOWN_DRAIN_DELAY", flag: "shutdown-drain-delay", usage: "time to keep serving with a failing readiness probe before draining connections", def: "5s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.ShutdownDrainDelay }),
+		get: func(c *Config) string { return c.ShutdownDrainDelay.String() },
+	},
+	{
+		key: "GRPC_ADDR", flag: "grpc-addr", usage: `gRPC listen address, "off" disables the gRPC server`, def: ":9090",
+		set: func(c *Config, value string) error {
+			if value == grpcDisabled {
+				value = ""
+			}
+			c.GRPC.Addr = value
+			return nil
+		},
+		get: func(c *Config) string {
+			if c.GRPC.Addr == "" {
+				return grpcDisabled
+			}
+			return c.GRPC.Addr
+		},
+	},
+	{
+		key: "DB_ADDR", flag: "db-addr", usage: "database connection string", redact: redactURL,
+		set: setString(func(c *Config) *string { return &c.DB.Addr }),
+		get: func(c *Config) string { return c.DB.Addr },
+	},
+	{
+		key: "DB_MAX_OPEN_CONNS", flag: "db-max-open-conns", usage: "maximum open database connections", def: "50",
+		set: setInt(func(c *Config) *int { return &c.DB.MaxOpenConns }),
+		get: func(c *Config) string { return strconv.Itoa(c.DB.MaxOpenConns) },
+	},
+	{
+		key: "DB_MAX_IDLE_CONNS", flag: "db-max-idle-conns", usage: "maximum idle database connections", def: "25",
+		set: setInt(func(c *Config) *int { return &c.DB.MaxIdleConns }),
+		get: func(c *Config) string { return strconv.Itoa(c.DB.MaxIdleConns) },
+	},
+	{
+		key: "DB_MAX_IDLE_TIME", flag: "db-max-idle-time", usage: "maximum idle time of a database connection", def: "15m",
+		set: setDuration(func(c *Config) *time.Duration { return &c.DB.MaxIdleTime }),
+		get: func(c *Config) string { return c.DB.MaxIdleTime.String() },
+	},
+	{
+		key: "DB_MIGRATE", flag: "db-migrate", usage: "apply pending database migrations at startup", def: "false",
+		set: setBool(func(c *Config) *bool { return &c.DB.Migrate }),
+		get: func(c *Config) string { return strconv.FormatBool(c.DB.Migrate) },
+	},
+	{
+		key: "DB_REPLICA_ADDR", flag: "db-replica-addr", usage: "read replica connection string, empty sends every query to the primary", redact: redactURL,
+		set: setString(func(c *Config) *string { return &c.DB.Replica.Addr }),
+		get: func(c *Config) string { return c.DB.Replica.Addr },
+	},
+	{
+		key: "DB_REPLICA_MAX_LAG", flag: "db-replica-max-lag", usage: "replication lag above which reads fall back to the primary", def: "5s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.DB.Replica.MaxLag }),
+		get: func(c *Config) string { return c.DB.Replica.MaxLag.String() },
+	},
+	{
+		key: "DB_REPLICA_LAG_CHECK_INTERVAL", flag: "db-replica-lag-check-interval", usage: "how often the replication lag is checked", def: "5s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.DB.Replica.LagCheckInterval }),
+		get: func(c *Config) string { return c.DB.Replica.LagCheckInterval.String() },
+	},
+	{
+		key: "TOKEN_SECRET", flag: "token-secret", usage: "JWT signing secret", redact: redactSecret,
+		set: setString(func(c *Config) *string { return &c.Auth.Token.Secret }),
+		get: func(c *Config) string { return c.Auth.Token.Secret },
+	},
+	{
+		key: "TOKEN_EXP", flag: "token-exp", usage: "JWT lifetime", def: "24h",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Auth.Token.Exp }),
+		get: func(c *Config) string { return c.Auth.Token.Exp.String() },
+	},
+	{
+		key: "TOKEN_AUD", flag: "token-aud", usage: "JWT audience",
+		set: setString(func(c *Config) *string { return &c.Auth.Token.Aud }),
+		get: func(c *Config) string { return c.Auth.Token.Aud },
+	},
+	{
+		key: "TOKEN_ISS", flag: "token-iss", usage: "JWT issuer",
+		set: setString(func(c *Config) *string { return &c.Auth.Token.Iss }),
+		get: func(c *Config) string { return c.Auth.Token.Iss },
+	},
+	{
+		key: "OIDC_DISCOVERY_URL", flag: "oidc-discovery-url", usage: "OIDC issuer or discovery URL, enables staff single sign-on",
+		set: setString(func(c *Config) *string { return &c.Auth.OIDC.DiscoveryURL }),
+		get: func(c *Config) string { return c.Auth.OIDC.DiscoveryURL },
+	},
+	{
+		key: "OIDC_CLIENT_ID", flag: "oidc-client-id", usage: "OIDC client ID",
+		set: setString(func(c *Config) *string { return &c.Auth.OIDC.ClientID }),
+		get: func(c *Config) string { return c.Auth.OIDC.ClientID },
+	},
+	{
+		key: "OIDC_CLIENT_SECRET", flag: "oidc-client-secret", usage: "OIDC client secret", redact: redactSecret,
+		set: setString(func(c *Config) *string { return &c.Auth.OIDC.ClientSecret }),
+		get: func(c *Config) string { return c.Auth.OIDC.ClientSecret },
+	},
+	{
+		key: "OIDC_REDIRECT_URL", flag: "oidc-redirect-url", usage: "OIDC redirect URL",
+		set: setString(func(c *Config) *string { return &c.Auth.OIDC.RedirectURL }),
+		get: func(c *Config) string { return c.Auth.OIDC.RedirectURL },
+	},
+	{
+		key: "TRACING_EXPORTER", flag: "tracing-exporter", usage: "trace exporter: none, stdout or otlp", def: "none",
+		set: setString(func(c *Config) *string { return &c.Tracing.Exporter }),
+		get: func(c *Config) string { return c.Tracing.Exporter },
+	},
+	{
+		key: "TRACING_OTLP_ENDPOINT", flag: "tracing-otlp-endpoint", usage: "OTLP/HTTP traces endpoint URL",
+		set: setString(func(c *Config) *string { return &c.Tracing.OTLPEndpoint }),
+		get: func(c *Config) string { return c.Tracing.OTLPEndpoint },
+	},
+	{
+		key: "LOG_SAMPLE_INITIAL", flag: "log-sample-initial", usage: "access log lines per second and level before sampling, 0 disables sampling", def: "100",
+		set: setInt(func(c *Config) *int { return &c.Logging.SampleInitial }),
+		get: func(c *Config) string { return strconv.Itoa(c.Logging.SampleInitial) },
+	},
+	{
+		key: "LOG_SAMPLE_THEREAFTER", flag: "log-sample-thereafter", usage: "log every n-th access log line once sampling kicks in", def: "100",
+		set: setInt(func(c *Config) *int { return &c.Logging.SampleThereafter }),
+		get: func(c *Config) string { return strconv.Itoa(c.Logging.SampleThereafter) },
+	},
+	{
+		key: "LOG_ROUTE_LEVELS", flag: "log-route-levels", usage: "access log level overrides as route=level pairs",
+		def: "/v1/health=debug,/v1/health/live=debug,/v1/health/ready=debug,/metrics=debug",
+		set: setRouteLevels,
+		get: func(c *Config) string { return formatRouteLevels(c.Logging.RouteLevels) },
+	},
+	{
+		key: "IDEMPOTENCY_RETENTION", flag: "idempotency-retention", usage: "how long Idempotency-Key responses are kept for replay", def: "24h",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Idempotency.Retention }),
+		get: func(c *Config) string { return c.Idempotency.Retention.String() },
+	},
+	{
+		key: "IDEMPOTENCY_IN_FLIGHT_TIMEOUT", flag: "idempotency-in-flight-timeout", usage: "how long an unfinished Idempotency-Key request keeps the key locked", def: "5m",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Idempotency.InFlightTimeout }),
+		get: func(c *Config) string { return c.Idempotency.InFlightTimeout.String() },
+	},
+	{
+		key: "GRAPHQL_MAX_DEPTH", flag: "graphql-max-depth", usage: "how deeply fields may be nested in a GraphQL query", def: "10",
+		set: setInt(func(c *Config) *int { return &c.GraphQL.MaxDepth }),
+		get: func(c *Config) string { return strconv.Itoa(c.GraphQL.MaxDepth) },
+	},
+	{
+		key: "GRAPHQL_MAX_COMPLEXITY", flag: "graphql-max-complexity", usage: "most fields a GraphQL query may resolve, lists count once per element", def: "1000",
+		set: setInt(func(c *Config) *int { return &c.GraphQL.MaxComplexity }),
+		get: func(c *Config) string { return strconv.Itoa(c.GraphQL.MaxComplexity) },
+	},
+	{
+		key: "WEBHOOK_POLL_INTERVAL", flag: "webhook-poll-interval", usage: "how often due webhook deliveries are sent", def: "5s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Webhooks.PollInterval }),
+		get: func(c *Config) string { return c.Webhooks.PollInterval.String() },
+	},
+	{
+		key: "WEBHOOK_TIMEOUT", flag: "webhook-timeout", usage: "timeout of a single webhook delivery", def: "10s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Webhooks.Timeout }),
+		get: func(c *Config) string { return c.Webhooks.Timeout.String() },
+	},
+	{
+		key: "WEBHOOK_MAX_ATTEMPTS", flag: "webhook-max-attempts", usage: "attempts before a webhook delivery is dead-lettered", def: "8",
+		set: setInt(func(c *Config) *int { return &c.Webhooks.MaxAttempts }),
+		get: func(c *Config) string { return strconv.Itoa(c.Webhooks.MaxAttempts) },
+	},
+	{
+		key: "OUTBOX_POLL_INTERVAL", flag: "outbox-poll-interval", usage: "how often the outbox is checked for unpublished events", def: "5s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Outbox.PollInterval }),
+		get: func(c *Config) string { return c.Outbox.PollInterval.String() },
+	},
+	{
+		key: "OUTBOX_RETENTION", flag: "outbox-retention", usage: "how long published outbox events are kept", def: "168h",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Outbox.Retention }),
+		get: func(c *Config) string { return c.Outbox.Retention.String() },
+	},
+	{
+		key: "STREAM_HEARTBEAT_INTERVAL", flag: "stream-heartbeat-interval", usage: "how often idle event streams get a heartbeat", def: "15s",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Stream.HeartbeatInterval }),
+		get: func(c *Config) string { return c.Stream.HeartbeatInterval.String() },
+	},
+	{
+		key: "STREAM_BUFFER", flag: "stream-buffer", usage: "events an event stream may fall behind before it is dropped", def: "64",
+		set: setInt(func(c *Config) *int { return &c.Stream.Buffer }),
+		get: func(c *Config) string { return strconv.Itoa(c.Stream.Buffer) },
+	},
+	{
+		key: "JOBS_ENABLED", flag: "jobs-enabled", usage: "run scheduled maintenance jobs in this process", def: "true",
+		set: setBool(func(c *Config) *bool { return &c.Jobs.Enabled }),
+		get: func(c *Config) string { return strconv.FormatBool(c.Jobs.Enabled) },
+	},
+	{
+		key: "JOBS_RUN_RETENTION", flag: "jobs-run-retention", usage: "how long the history of finished job runs is kept", def: "720h",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Jobs.RunRetention }),
+		get: func(c *Config) string { return c.Jobs.RunRetention.String() },
+	},
+	{
+		key: "DUNNING_REMINDER_OFFSETS", flag: "dunning-reminder-offsets", usage: "comma separated offsets past the due date at which overdue reminders are sent", def: "24h,72h,168h",
+		set: setDurations(func(c *Config) *[]time.Duration { return &c.Dunning.ReminderOffsets }),
+		get: func(c *Config) string { return joinDurations(c.Dunning.ReminderOffsets) },
+	},
+	{
+		key: "DUNNING_BLOCK_AFTER", flag: "dunning-block-after", usage: "how long past the due date checkout is blocked", def: "72h",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Dunning.BlockAfter }),
+		get: func(c *Config) string { return c.Dunning.BlockAfter.String() },
+	},
+	{
+		key: "DUNNING_LOST_AFTER", flag: "dunning-lost-after", usage: "how long past the due date a copy is considered lost and charged", def: "720h",
+		set: setDuration(func(c *Config) *time.Duration { return &c.Dunning.LostAfter }),
+		get: func(c *Config) string { return c.Dunning.LostAfter.String() },
+	},
+	{
+		key: "NOTIFY_DRIVER", flag: "notify-driver", usage: "email driver: smtp, or file for development", def: "file",
+		set: setString(func(c *Config) *string { return &c.Notifications.Driver }),
+		get: func(c *Config) string { return c.Notifications.Driver },
+	},
+	{
+		key: "NOTIFY_FILE_DIR", flag: "notify-file-dir", usage: "directory the file driver writes messages to", def: "var/notifications",
+		set: setString(func(c *Config) *string { return &c.Notifications.FileDir }),
+		get: func(c *Config) string { return c.Notifications.FileDir },
+	},
+	{
+		key: "NOTIFY_FROM", flag: "notify-from", usage: "sender address of notifications", def: "DVD Rental <no-reply@dvdrental.local>",
+		set: setString(func(c *Config) *string { return &c.Notifications.From }),
+		get: func(c *Config) string { return c.Notifications.From },
+	},
+	{
+		key: "SMTP_ADDR", flag: "smtp-addr", usage: "host:port of the mail server",
+		set: setString(func(c *Config) *string { return &c.Notifications.SMTP.Addr }),
+		get: func(c *Config) string { return c.Notifications.SMTP.Addr },
+	},
+	{
+		key: "SMTP_USERNAME", flag: "smtp-username", usage: "mail server username",
+		set: setString(func(c *Config) *string { return &c.Notifications.SMTP.Username }),
+		get: func(c *Config) string { return c.Notifications.SMTP.Username },
+	},
+	{
+		key: "SMTP_PASSWORD", flag: "smtp-password", usage: "mail server password", redact: redactSecret,
+		set: setString(func(c *Config) *string { return &c.Notifications.SMTP.Password }),
+		get: func(c *Config) string { return c.Notifications.SMTP.Password },
+	},
+}
+
+// Load builds the configuration from defaults, an optional env file, the
+// environment and command line flags, in increasing order of precedence.
+// getenv is usually os.Getenv; tests pass their own lookup.
+//
+// The env file is taken from the -config-file flag or CONFIG_FILE. Without
+// either, .env is read outside prod when it exists.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg, _, err := Parse(args, getenv)
+	return cfg, err
+}
+
+// Parse is Load for commands that take arguments after the flags, like the
+// admin CLI. It also returns the arguments that are not flags.
+func Parse(args []string, getenv func(string) string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	configFile := fs.String("config-file", "", "path to an env file with configuration")
+	flagValues := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flagValues[f.key] = fs.String(f.flag, "", fmt.Sprintf("%s (%s)", f.usage, f.key))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		values[f.key] = f.def
+	}
+
+	fileValues, err := readFile(*configFile, getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range fields {
+		if value, ok := fileValues[f.key]; ok {
+			values[f.key] = value
+		}
+		if value := getenv(f.key); value != "" {
+			values[f.key] = value
+		}
+	}
+
+	setFlags := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { setFlags[fl.Name] = true })
+	for _, f := range fields {
+		if setFlags[f.flag] {
+			values[f.key] = *flagValues[f.key]
+		}
+	}
+
+	cfg := &Config{}
+	var errs []error
+	for _, f := range fields {
+		if values[f.key] == "" {
+			continue
+		}
+		if err := f.set(cfg, values[f.key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+func readFile(path string, getenv func(string) string) (map[string]string, error) {
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		values, err := godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		return values, nil
+	}
+
+	if getenv("ENV") == "prod" {
+		return nil, nil
+	}
+	values, err := godotenv.Read(".env")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return values, err
+}
+
+// Validate reports every invalid or missing setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("PORT is required"))
+	}
+	if c.DB.Addr == "" {
+		errs = append(errs, errors.New("DB_ADDR is required"))
+	}
+	if c.DB.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS must be positive"))
+	}
+	if c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS"))
+	}
+	if c.DB.Replica.Addr != "" {
+		if c.DB.Replica.MaxLag <= 0 {
+			errs = append(errs, errors.New("DB_REPLICA_MAX_LAG must be positive"))
+		}
+		if c.DB.Replica.LagCheckInterval <= 0 {
+			errs = append(errs, errors.New("DB_REPLICA_LAG_CHECK_INTERVAL must be positive"))
+		}
+	}
+	if len(c.Auth.Token.Secret) < minTokenSecretLength {
+		errs = append(errs, fmt.Errorf("TOKEN_SECRET must be at least %d characters", minTokenSecretLength))
+	}
+	if c.Auth.Token.Exp <= 0 {
+		errs = append(errs, errors.New("TOKEN_EXP must be positive"))
+	}
+	if c.Auth.Token.Aud == "" {
+		errs = append(errs, errors.New("TOKEN_AUD is required"))
+	}
+	if c.Auth.Token.Iss == "" {
+		errs = append(errs, errors.New("TOKEN_ISS is required"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.ShutdownDrainDelay < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_DELAY must not be negative"))
+	}
+	if c.Auth.OIDC.DiscoveryURL != "" && (c.Auth.OIDC.ClientID == "" || c.Auth.OIDC.RedirectURL == "") {
+		errs = append(errs, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_DISCOVERY_URL is set"))
+	}
+	if c.Logging.SampleInitial < 0 || c.Logging.SampleThereafter < 0 {
+		errs = append(errs, errors.New("LOG_SAMPLE_INITIAL and LOG_SAMPLE_THEREAFTER must not be negative"))
+	}
+	if c.Idempotency.Retention <= 0 || c.Idempotency.InFlightTimeout <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_RETENTION and IDEMPOTENCY_IN_FLIGHT_TIMEOUT must be positive"))
+	}
+	if c.GraphQL.MaxDepth < 1 || c.GraphQL.MaxComplexity < 1 {
+		errs = append(errs, errors.New("GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY must be positive"))
+	}
+	if c.Webhooks.PollInterval <= 0 || c.Webhooks.Timeout <= 0 || c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, errors.New("WEBHOOK_POLL_INTERVAL, WEBHOOK_TIMEOUT and WEBHOOK_MAX_ATTEMPTS must be positive"))
+	}
+	if c.Jobs.RunRetention <= 0 {
+		errs = append(errs, errors.New("JOBS_RUN_RETENTION must be positive"))
+	}
+	if c.Outbox.PollInterval <= 0 || c.Outbox.Retention <= 0 {
+		errs = append(errs, errors.New("OUTBOX_POLL_INTERVAL and OUTBOX_RETENTION must be positive"))
+	}
+	if c.Stream.HeartbeatInterval <= 0 || c.Stream.Buffer < 1 {
+		errs = append(errs, errors.New("STREAM_HEARTBEAT_INTERVAL and STREAM_BUFFER must be positive"))
+	}
+	if len(c.Dunning.ReminderOffsets) == 0 || !sort.SliceIsSorted(c.Dunning.ReminderOffsets, func(i, j int) bool { return c.Dunning.ReminderOffsets[i] < c.Dunning.ReminderOffsets[j] }) || c.Dunning.ReminderOffsets[0] <= 0 {
+		errs = append(errs, errors.New("DUNNING_REMINDER_OFFSETS must be positive and in ascending order"))
+	}
+	if c.Dunning.BlockAfter <= 0 || c.Dunning.LostAfter < c.Dunning.BlockAfter {
+		errs = append(errs, errors.New("DUNNING_BLOCK_AFTER must be positive and DUNNING_LOST_AFTER at least as long"))
+	}
+	switch c.Notifications.Driver {
+	case "file":
+		if c.Notifications.FileDir == "" {
+			errs = append(errs, errors.New("NOTIFY_FILE_DIR is required for the file driver"))
+		}
+	case "smtp":
+		if c.Notifications.SMTP.Addr == "" {
+			errs = append(errs, errors.New("SMTP_ADDR is required for the smtp driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("NOTIFY_DRIVER must be one of file or smtp, got %q", c.Notifications.Driver))
+	}
+	if _, err := mail.ParseAddress(c.Notifications.From); err != nil {
+		errs = append(errs, fmt.Errorf("NOTIFY_FROM must be an email address: %w", err))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER must be one of none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns the effective settings keyed by environment variable, with
+// secrets masked, for logging at startup.
+func (c *Config) Redacted() map[string]string {
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		value := f.get(c)
+		if f.redact != nil {
+			value = f.redact(value)
+		}
+		values[f.key] = value
+	}
+	return values
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
+
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return redactSecret(value)
+	}
+	return u.Redacted()
+}
+
+func setString(target func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*target(c) = value
+		return nil
+	}
+}
+
+func setInt(target func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*target(c) = n
+		return nil
+	}
+}
+
+func setBool(target func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*target(c) = b
+		return nil
+	}
+}
+
+func setDuration(target func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*target(c) = d
+		return nil
+	}
+}
+
+// setDurations parses a comma separated list of durations.
+func setDurations(target func(c *Config) *[]time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		var durations []time.Duration
+		for _, item := range strings.Split(value, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(item))
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", item)
+			}
+			durations = append(durations, d)
+		}
+		*target(c) = durations
+		return nil
+	}
+}
+
+func joinDurations(durations []time.Duration) string {
+	values := make([]string, len(durations))
+	for i, d := range durations {
+		values[i] = d.String()
+	}
+	return strings.Join(values, ",")
+}
+
+// setRouteLevels parses a comma separated list of route=level pairs, e.g.
+// "/v1/health=debug,/metrics=debug".
+func setRouteLevels(c *Config, value string) error {
+	levels := map[string]zapcore.Level{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		route, name, ok := strings.Cut(pair, "=")
+		if !ok || route == "" {
+			return fmt.Errorf("invalid route level %q", pair)
+		}
+		level, err := zapcore.ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("invalid level for route %s: %q", route, name)
+		}
+		levels[route] = level
+	}
+	c.Logging.RouteLevels = levels
+	return nil
+}
+
+func formatRouteLevels(levels map[string]zapcore.Level) string {
+	pairs := make([]string, 0, len(levels))
+	for route, level := range levels {
+		pairs = append(pairs, route+"="+level.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -3,23 +3,26 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
-)
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
-func NewDB(addr string, maxOpenConns int, maxIdleConns int, maxIdleTime string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", addr)
-	if err != nil {
-		return nil, err
-	}
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+)
 
-	duration, err := time.ParseDuration(maxIdleTime)
+// Open opens a connection pool and checks that the database is reachable.
+func Open(addr string, maxOpenConns int, maxIdleConns int, maxIdleTime time.Duration) (*sql.DB, error) {
+	db, err := otelsql.Open("postgres", addr, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetConnMaxIdleTime(duration)
+	db.SetConnMaxIdleTime(maxIdleTime)
 	db.SetMaxOpenConns(maxOpenConns)
 	db.SetMaxIdleConns(maxIdleConns)
 
@@ -27,8 +30,189 @@ func NewDB(addr string, maxOpenConns int, maxIdleConns int, maxIdleTime string)
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// DB is the primary pool, which it embeds for writes and transactions, with
+// an optional read replica. Read-only store methods get their pool from
+// Reader, which only returns the replica while its lag is checked and below
+// the limit.
+type DB struct {
+	*sql.DB
+	replica *sql.DB
+	maxLag  time.Duration
+
+	// useReplica is set by CheckReplica.
+	useReplica atomic.Bool
+	mu         sync.Mutex
+	status     ReplicaStatus
+}
+
+// ReplicaStatus is the outcome of the last replica check.
+type ReplicaStatus struct {
+	Configured bool
+	// InUse is false while reads fall back to the primary.
+	InUse     bool
+	Lag       time.Duration
+	Error     string
+	CheckedAt time.Time
+}
+
+// NewDB combines a primary and an optional replica pool. Reads go to the
+// primary until CheckReplica has found the replica within maxLag.
+func NewDB(primary, replica *sql.DB, maxLag time.Duration) *DB {
+	return &DB{
+		DB:      primary,
+		replica: replica,
+		maxLag:  maxLag,
+		status:  ReplicaStatus{Configured: replica != nil},
+	}
+}
+
+// Replica returns the replica pool, or nil without one.
+func (d *DB) Replica() *sql.DB {
+	return d.replica
+}
+
+// Reader returns the pool for a read-only query: the replica, unless there is
+// none, it is lagging, or ctx has to read its own writes.
+func (d *DB) Reader(ctx context.Context) *sql.DB {
+	if d.replica == nil || !d.useReplica.Load() || readsPrimary(ctx) {
+		return d.DB
+	}
+	return d.replica
+}
+
+// replicaLagQuery returns the replay lag in seconds. A replica that has
+// replayed everything it received is not lagging, even if the primary has
+// been idle since the last replayed transaction.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END
+`
+
+var errReplicaLagging = errors.New("replica lag exceeds the limit")
+
+// CheckReplica measures the replication lag. Reads fall back to the primary
+// while the replica is unreachable or further behind than the limit, and
+// return to it once it has caught up.
+func (d *DB) CheckReplica(ctx context.Context) ReplicaStatus {
+	if d.replica == nil {
+		return d.ReplicaStatus()
+	}
+
+	var seconds float64
+	err := d.replica.QueryRowContext(ctx, replicaLagQuery).Scan(&seconds)
+	lag := time.Duration(seconds * float64(time.Second))
+	if err == nil && lag > d.maxLag {
+		err = errReplicaLagging
+	}
+
+	status := ReplicaStatus{Configured: true, InUse: err == nil, Lag: lag, CheckedAt: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	d.mu.Lock()
+	d.status = status
+	d.mu.Unlock()
+	d.useReplica.Store(status.InUse)
+
+	metrics.ReplicaLag.Set(lag.Seconds())
+	if status.InUse {
+		metrics.ReplicaFallback.Set(0)
+	} else {
+		metrics.ReplicaFallback.Set(1)
+	}
+
+	return status
+}
+
+// MonitorReplica checks the replica every interval until ctx is done.
+func (d *DB) MonitorReplica(ctx context.Context, interval time.Duration) {
+	if d.replica == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			d.CheckReplica(checkCtx)
+			cancel()
+		}
+	}
+}
+
+// ReplicaStatus returns the outcome of the last replica check.
+func (d *DB) ReplicaStatus() ReplicaStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// Close closes both pools.
+func (d *DB) Close() error {
+	err := d.DB.Close()
+	if d.replica != nil {
+		err = errors.Join(err, d.replica.Close())
+	}
+	return err
+}
+
+type sessionContextKey struct{}
+
+// session records whether a request, or the client before it, wrote to the
+// primary.
+type session struct {
+	primaryUntil time.Time
+	// lastWrite is the time of the request's last committed write in Unix
+	// nanoseconds, zero before the first.
+	lastWrite atomic.Int64
+}
+
+// WithSession starts a session for a request. Its reads go to the primary
+// until primaryUntil, which carries an earlier request's write over to this
+// one, and for the rest of the request once MarkWritten is called with it, so
+// a client reads its own writes even if the replica has not caught up.
+func WithSession(ctx context.Context, primaryUntil time.Time) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, &session{primaryUntil: primaryUntil})
+}
+
+// MarkWritten records a committed write in the session of ctx, if it has
+// one.
+func MarkWritten(ctx context.Context) {
+	if s, ok := ctx.Value(sessionContextKey{}).(*session); ok {
+		s.lastWrite.Store(time.Now().UnixNano())
+	}
+}
+
+// LastWrite returns the time of the last write recorded in the session of
+// ctx, and false if the request has not written.
+func LastWrite(ctx context.Context) (time.Time, bool) {
+	s, ok := ctx.Value(sessionContextKey{}).(*session)
+	if !ok {
+		return time.Time{}, false
+	}
+	nanos := s.lastWrite.Load()
+	if nanos == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+func readsPrimary(ctx context.Context) bool {
+	s, ok := ctx.Value(sessionContextKey{}).(*session)
+	return ok && (s.lastWrite.Load() != 0 || time.Now().Before(s.primaryUntil))
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDB returns pools that are never connected; Reader only compares
+// them.
+func newTestDB(t *testing.T, withReplica bool) (*DB, *sql.DB, *sql.DB) {
+	primary, err := sql.Open("postgres", "postgres://localhost/primary")
+	require.NoError(t, err)
+	var replica *sql.DB
+	if withReplica {
+		replica, err = sql.Open("postgres", "postgres://localhost/replica")
+		require.NoError(t, err)
+	}
+	d := NewDB(primary, replica, 0)
+	t.Cleanup(func() { d.Close() })
+	return d, primary, replica
+}
+
+func TestReader(t *testing.T) {
+	t.Run("it should read from the primary without a replica", func(t *testing.T) {
+		d, primary, _ := newTestDB(t, false)
+		d.useReplica.Store(true)
+
+		assert.Same(t, primary, d.Reader(context.Background()))
+		assert.False(t, d.ReplicaStatus().Configured)
+	})
+
+	t.Run("it should read from the primary until the replica was checked", func(t *testing.T) {
+		d, primary, _ := newTestDB(t, true)
+
+		assert.Same(t, primary, d.Reader(context.Background()))
+		assert.True(t, d.ReplicaStatus().Configured)
+	})
+
+	t.Run("it should read from a replica in use", func(t *testing.T) {
+		d, _, replica := newTestDB(t, true)
+		d.useReplica.Store(true)
+
+		assert.Same(t, replica, d.Reader(context.Background()))
+	})
+
+	t.Run("a session should read its own writes from the primary", func(t *testing.T) {
+		d, primary, replica := newTestDB(t, true)
+		d.useReplica.Store(true)
+
+		ctx := WithSession(context.Background(), time.Time{})
+		assert.Same(t, replica, d.Reader(ctx))
+		_, wrote := LastWrite(ctx)
+		assert.False(t, wrote)
+
+		MarkWritten(ctx)
+		assert.Same(t, primary, d.Reader(ctx))
+		lastWrite, wrote := LastWrite(ctx)
+		assert.True(t, wrote)
+		assert.WithinDuration(t, time.Now(), lastWrite, time.Second)
+		assert.Same(t, replica, d.Reader(WithSession(context.Background(), time.Time{})))
+	})
+
+	t.Run("a session should read from the primary until primaryUntil", func(t *testing.T) {
+		d, primary, replica := newTestDB(t, true)
+		d.useReplica.Store(true)
+
+		assert.Same(t, primary, d.Reader(WithSession(context.Background(), time.Now().Add(time.Minute))))
+		assert.Same(t, replica, d.Reader(WithSession(context.Background(), time.Now().Add(-time.Second))))
+	})
+
+	t.Run("writes outside a session should not pin reads", func(t *testing.T) {
+		d, _, replica := newTestDB(t, true)
+		d.useReplica.Store(true)
+
+		ctx := context.Background()
+		MarkWritten(ctx)
+		assert.Same(t, replica, d.Reader(ctx))
+	})
+
+}
+
+func TestCheckReplicaFallback(t *testing.T) {
+	d, primary, _ := newTestDB(t, true)
+	d.useReplica.Store(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	status := d.CheckReplica(ctx)
+	assert.False(t, status.InUse)
+	assert.NotEmpty(t, status.Error)
+	assert.Equal(t, status, d.ReplicaStatus())
+	assert.Same(t, primary, d.Reader(context.Background()))
+}
@@ -0,0 +1,162 @@
+// Package dunning chases overdue rentals. Once a rental is overdue, its
+// customer gets reminders at growing offsets past the due date, is blocked
+// from checking out, and is finally charged the replacement cost of a copy
+// that is considered lost. Each step is recorded on the rental's dunning
+// case, which admins can pause or waive.
+package dunning
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"go.uber.org/zap"
+)
+
+const batchSize = 200
+
+// Store is the part of store.Store the processor needs.
+type Store interface {
+	OpenDunningCases(ctx context.Context) (int64, error)
+	ListOpenDunningCases(ctx context.Context, afterRentalID int64, limit int) ([]store.DunningCase, error)
+	RecordDunningReminder(ctx context.Context, rentalID int64, reminder int) error
+	BlockDunningCustomer(ctx context.Context, rentalID int64) error
+	MarkDunningRentalLost(ctx context.Context, rentalID int64) (*store.CustomerCharge, error)
+}
+
+// Policy sets when each step is taken, measured from the due date.
+type Policy struct {
+	// ReminderOffsets are the ascending offsets at which reminders are
+	// sent. Reminders missed while a case was paused are not caught up;
+	// only the latest due one is sent.
+	ReminderOffsets []time.Duration
+	// BlockAfter is when checkout is blocked.
+	BlockAfter time.Duration
+	// LostAfter is when the copy is considered lost and charged.
+	LostAfter time.Duration
+}
+
+// Processor takes the due dunning steps of every open case.
+type Processor struct {
+	store    Store
+	notifier Notifier
+	policy   Policy
+	logger   *zap.SugaredLogger
+}
+
+func NewProcessor(s Store, notifier Notifier, policy Policy, logger *zap.SugaredLogger) *Processor {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	return &Processor{store: s, notifier: notifier, policy: policy, logger: logger}
+}
+
+// Run opens cases for newly overdue rentals and takes the due steps of all
+// open cases. A failing case does not stop the others; their errors are
+// returned together.
+func (p *Processor) Run(ctx context.Context) error {
+	opened, err := p.store.OpenDunningCases(ctx)
+	if err != nil {
+		return err
+	}
+	if opened > 0 {
+		p.logger.Infow("opened dunning cases", "count", opened)
+	}
+
+	var errs []error
+	var after int64
+	for {
+		cases, err := p.store.ListOpenDunningCases(ctx, after, batchSize)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		for i := range cases {
+			if err := p.process(ctx, &cases[i]); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				errs = append(errs, fmt.Errorf("rental %d: %w", cases[i].RentalID, err))
+			}
+		}
+		if len(cases) < batchSize {
+			return errors.Join(errs...)
+		}
+		after = int64(cases[len(cases)-1].RentalID)
+	}
+}
+
+func (p *Processor) process(ctx context.Context, c *store.DunningCase) error {
+	rentalID := int64(c.RentalID)
+
+	if p.policy.LostAfter > 0 && c.OverdueFor >= p.policy.LostAfter {
+		charge, err := p.store.MarkDunningRentalLost(ctx, rentalID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		notice := p.notice(c, NoticeLost)
+		notice.Charge = charge.Amount
+		return p.notifier.Notify(ctx, notice)
+	}
+
+	if c.BlockedAt == nil && p.policy.BlockAfter > 0 && c.OverdueFor >= p.policy.BlockAfter {
+		err := p.store.BlockDunningCustomer(ctx, rentalID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		c.BlockedAt = &now
+	}
+
+	reminder := p.dueReminder(c.OverdueFor)
+	if reminder <= c.RemindersSent {
+		return nil
+	}
+
+	// The reminder is recorded once it was sent, so a failure in between
+	// sends it again on the next run rather than never.
+	notice := p.notice(c, NoticeReminder)
+	notice.Reminder = reminder
+	if err := p.notifier.Notify(ctx, notice); err != nil {
+		return err
+	}
+	err := p.store.RecordDunningReminder(ctx, rentalID, reminder)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// dueReminder returns the number of the latest reminder due after being
+// overdue for overdueFor, or 0.
+func (p *Processor) dueReminder(overdueFor time.Duration) int {
+	due := 0
+	for i, offset := range p.policy.ReminderOffsets {
+		if overdueFor >= offset {
+			due = i + 1
+		}
+	}
+	return due
+}
+
+func (p *Processor) notice(c *store.DunningCase, kind string) Notice {
+	return Notice{
+		Kind:            kind,
+		RentalID:        c.RentalID,
+		CustomerID:      c.CustomerID,
+		Email:           c.CustomerEmail,
+		Name:            c.CustomerName,
+		FilmTitle:       c.FilmTitle,
+		DueDate:         c.DueDate,
+		DaysOverdue:     int(c.OverdueFor / (24 * time.Hour)),
+		CheckoutBlocked: c.BlockedAt != nil,
+	}
+}
@@ -0,0 +1,171 @@
+package dunning
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const day = 24 * time.Hour
+
+var testPolicy = Policy{
+	ReminderOffsets: []time.Duration{1 * day, 3 * day, 7 * day},
+	BlockAfter:      3 * day,
+	LostAfter:       30 * day,
+}
+
+type recorder struct {
+	notices   []Notice
+	reminders map[int]int
+	blocked   []int64
+	lost      []int64
+}
+
+func newProcessor(t *testing.T, cases []store.DunningCase) (*Processor, *store.MockDunningStore, *recorder) {
+	t.Helper()
+	rec := &recorder{reminders: map[int]int{}}
+	s := &store.MockDunningStore{
+		ListOpenDunningCasesFunc: func(ctx context.Context, afterRentalID int64, limit int) ([]store.DunningCase, error) {
+			var page []store.DunningCase
+			for _, c := range cases {
+				if int64(c.RentalID) > afterRentalID && len(page) < limit {
+					page = append(page, c)
+				}
+			}
+			return page, nil
+		},
+		RecordDunningReminderFunc: func(ctx context.Context, rentalID int64, reminder int) error {
+			rec.reminders[int(rentalID)] = reminder
+			return nil
+		},
+		BlockDunningCustomerFunc: func(ctx context.Context, rentalID int64) error {
+			rec.blocked = append(rec.blocked, rentalID)
+			return nil
+		},
+		MarkDunningRentalLostFunc: func(ctx context.Context, rentalID int64) (*store.CustomerCharge, error) {
+			rec.lost = append(rec.lost, rentalID)
+			return &store.CustomerCharge{RentalID: int(rentalID), Reason: store.ChargeReasonReplacement, Amount: 19.99}, nil
+		},
+	}
+	notifier := NotifierFunc(func(ctx context.Context, notice Notice) error {
+		rec.notices = append(rec.notices, notice)
+		return nil
+	})
+	return NewProcessor(s, notifier, testPolicy, nil), s, rec
+}
+
+func TestProcessor(t *testing.T) {
+	t.Run("it should send the first reminder once a rental is overdue", func(t *testing.T) {
+		p, _, rec := newProcessor(t, []store.DunningCase{{RentalID: 1, FilmTitle: "ACADEMY DINOSAUR", OverdueFor: 30 * time.Hour}})
+
+		require.NoError(t, p.Run(context.Background()))
+
+		assert.Equal(t, map[int]int{1: 1}, rec.reminders)
+		assert.Empty(t, rec.blocked)
+		require.Len(t, rec.notices, 1)
+		assert.Equal(t, NoticeReminder, rec.notices[0].Kind)
+		assert.Equal(t, 1, rec.notices[0].Reminder)
+		assert.Equal(t, 1, rec.notices[0].DaysOverdue)
+		assert.False(t, rec.notices[0].CheckoutBlocked)
+	})
+
+	t.Run("it should not repeat a reminder that was sent", func(t *testing.T) {
+		p, _, rec := newProcessor(t, []store.DunningCase{{RentalID: 1, OverdueFor: 2 * day, RemindersSent: 1}})
+
+		require.NoError(t, p.Run(context.Background()))
+
+		assert.Empty(t, rec.reminders)
+		assert.Empty(t, rec.notices)
+	})
+
+	t.Run("it should block checkout and say so in the reminder", func(t *testing.T) {
+		p, _, rec := newProcessor(t, []store.DunningCase{{RentalID: 1, OverdueFor: 4 * day, RemindersSent: 1}})
+
+		require.NoError(t, p.Run(context.Background()))
+
+		assert.Equal(t, []int64{1}, rec.blocked)
+		assert.Equal(t, map[int]int{1: 2}, rec.reminders)
+		require.Len(t, rec.notices, 1)
+		assert.True(t, rec.notices[0].CheckoutBlocked)
+	})
+
+	t.Run("it should only send the latest due reminder", func(t *testing.T) {
+		blockedAt := time.Now()
+		p, _, rec := newProcessor(t, []store.DunningCase{{RentalID: 1, OverdueFor: 10 * day, BlockedAt: &blockedAt}})
+
+		require.NoError(t, p.Run(context.Background()))
+
+		assert.Empty(t, rec.blocked)
+		assert.Equal(t, map[int]int{1: 3}, rec.reminders)
+		assert.Len(t, rec.notices, 1)
+	})
+
+	t.Run("it should mark the copy lost and notify the charge", func(t *testing.T) {
+		p, _, rec := newProcessor(t, []store.DunningCase{{RentalID: 1, OverdueFor: 31 * day, RemindersSent: 3}})
+
+		require.NoError(t, p.Run(context.Background()))
+
+		assert.Equal(t, []int64{1}, rec.lost)
+		assert.Empty(t, rec.reminders)
+		require.Len(t, rec.notices, 1)
+		assert.Equal(t, NoticeLost, rec.notices[0].Kind)
+		assert.Equal(t, 19.99, rec.notices[0].Charge)
+	})
+
+	t.Run("it should skip a case that changed since it was listed", func(t *testing.T) {
+		p, s, rec := newProcessor(t, []store.DunningCase{{RentalID: 1, OverdueFor: 31 * day}})
+		s.MarkDunningRentalLostFunc = func(ctx context.Context, rentalID int64) (*store.CustomerCharge, error) {
+			return nil, sql.ErrNoRows
+		}
+
+		require.NoError(t, p.Run(context.Background()))
+
+		assert.Empty(t, rec.notices)
+	})
+
+	t.Run("it should not record a reminder that could not be sent", func(t *testing.T) {
+		p, _, rec := newProcessor(t, []store.DunningCase{
+			{RentalID: 1, OverdueFor: 2 * day},
+			{RentalID: 2, OverdueFor: 2 * day},
+		})
+		p.notifier = NotifierFunc(func(ctx context.Context, notice Notice) error {
+			if notice.RentalID == 1 {
+				return errors.New("mail server down")
+			}
+			return nil
+		})
+
+		err := p.Run(context.Background())
+
+		assert.ErrorContains(t, err, "rental 1: mail server down")
+		assert.Equal(t, map[int]int{2: 1}, rec.reminders)
+	})
+
+	t.Run("it should page through all open cases", func(t *testing.T) {
+		cases := make([]store.DunningCase, batchSize+1)
+		for i := range cases {
+			cases[i] = store.DunningCase{RentalID: i + 1, OverdueFor: 2 * day}
+		}
+		p, _, rec := newProcessor(t, cases)
+
+		require.NoError(t, p.Run(context.Background()))
+
+		assert.Len(t, rec.reminders, batchSize+1)
+	})
+
+	t.Run("it should stop if cases cannot be opened", func(t *testing.T) {
+		p, s, rec := newProcessor(t, []store.DunningCase{{RentalID: 1, OverdueFor: 2 * day}})
+		s.OpenDunningCasesFunc = func(ctx context.Context) (int64, error) {
+			return 0, errors.New("database error")
+		}
+
+		assert.Error(t, p.Run(context.Background()))
+		assert.Empty(t, rec.notices)
+	})
+}
@@ -0,0 +1,42 @@
+package dunning
+
+import (
+	"context"
+	"time"
+)
+
+// Notice kinds.
+const (
+	NoticeReminder = "reminder"
+	NoticeLost     = "lost"
+)
+
+// Notice is a message to a customer about an overdue rental.
+type Notice struct {
+	Kind string
+	// Reminder is the number of the reminder, starting at 1.
+	Reminder        int
+	RentalID        int
+	CustomerID      int
+	Email           string
+	Name            string
+	FilmTitle       string
+	DueDate         time.Time
+	DaysOverdue     int
+	CheckoutBlocked bool
+	// Charge is the replacement cost charged for a lost copy.
+	Charge float64
+}
+
+// Notifier delivers notices to customers, e.g. through the notification
+// service.
+type Notifier interface {
+	Notify(ctx context.Context, notice Notice) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, notice Notice) error
+
+func (f NotifierFunc) Notify(ctx context.Context, notice Notice) error {
+	return f(ctx, notice)
+}
@@ -0,0 +1,189 @@
+// Package graph serves a GraphQL API over the store layer.
+package graph
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"go.uber.org/zap"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// DefaultMaxDepth is used when Options leaves MaxDepth unset.
+const DefaultMaxDepth = 10
+
+const (
+	// maxBodySize limits the size of a POSTed query document.
+	maxBodySize        = 1 << 20
+	internalErrMessage = "the server encountered a problem and could not process your request"
+)
+
+type Options struct {
+	// MaxDepth is how deeply fields may be nested in a query.
+	MaxDepth int
+	// MaxComplexity is the most fields a query may resolve, counting every
+	// element of a list as one resolution of its fields.
+	MaxComplexity int
+	Logger        *zap.SugaredLogger
+}
+
+// Handler executes GraphQL queries over HTTP. The caller is expected to have
+// authenticated the request and stored the user with WithUser.
+type Handler struct {
+	store  *store.Store
+	schema *graphql.Schema
+	limits *limits
+	logger *zap.SugaredLogger
+}
+
+func NewHandler(s *store.Store, opts Options) *Handler {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+	h := &Handler{
+		store:  s,
+		limits: newLimits(schema, opts.MaxComplexity),
+		logger: opts.Logger,
+	}
+	h.schema = graphql.MustParseSchema(schema, &Resolver{store: s},
+		graphql.MaxDepth(opts.MaxDepth),
+		graphql.PanicHandler(h),
+		graphql.Logger(h),
+	)
+	return h
+}
+
+type userContextKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user that role
+// checks of the resolvers run against.
+func WithUser(ctx context.Context, user *store.User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+func UserFromContext(ctx context.Context) *store.User {
+	user, _ := ctx.Value(userContextKey{}).(*store.User)
+	return user
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				h.writeErrors(w, http.StatusBadRequest, "invalid_request", "variables must be a JSON object")
+				return
+			}
+		}
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.writeErrors(w, http.StatusBadRequest, "invalid_request", "body must be a JSON object with a query")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		h.writeErrors(w, http.StatusMethodNotAllowed, "method_not_allowed", "use GET or POST")
+		return
+	}
+	if req.Query == "" {
+		h.writeErrors(w, http.StatusBadRequest, "invalid_request", "query is required")
+		return
+	}
+
+	if err := h.limits.check(req.Query, req.OperationName, req.Variables); err != nil {
+		h.writeErrors(w, http.StatusOK, err.Code, err.Message)
+		return
+	}
+
+	ctx := withLoaders(r.Context(), newLoaders(h.store))
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, err := range response.Errors {
+		h.translate(ctx, err)
+	}
+
+	h.write(w, http.StatusOK, response)
+}
+
+// translate replaces the message of a resolver error with one that is safe
+// to show to clients, the same way ErrorHandler does for REST responses.
+// Fields nested deeper than MaxDepth get the query_too_deep code.
+func (h *Handler) translate(ctx context.Context, qerr *gqlerrors.QueryError) {
+	if qerr.Rule == "MaxDepthExceeded" {
+		qerr.Extensions = map[string]any{"code": "query_too_deep"}
+		return
+	}
+	if qerr.ResolverError == nil {
+		return
+	}
+
+	var appErr *utils.Error
+	switch {
+	case errors.As(qerr.ResolverError, &appErr):
+		qerr.Message = appErr.Message
+		qerr.Extensions = map[string]any{"code": appErr.Code}
+	case errors.Is(qerr.ResolverError, sql.ErrNoRows):
+		qerr.Message = "the requested resource could not be found"
+		qerr.Extensions = map[string]any{"code": "not_found"}
+	default:
+		h.loggerFrom(ctx).Errorw("graphql resolver error", "error", qerr.ResolverError, "path", qerr.Path)
+		qerr.Message = internalErrMessage
+		qerr.Extensions = map[string]any{"code": "internal_error"}
+	}
+}
+
+// MakePanicError implements errors.PanicHandler so panics are reported
+// without their value.
+func (h *Handler) MakePanicError(ctx context.Context, value any) *gqlerrors.QueryError {
+	return &gqlerrors.QueryError{
+		Message:    internalErrMessage,
+		Extensions: map[string]any{"code": "internal_error"},
+	}
+}
+
+// LogPanic implements log.Logger.
+func (h *Handler) LogPanic(ctx context.Context, value any) {
+	h.loggerFrom(ctx).Errorw("graphql resolver panic", "panic", value, zap.StackSkip("stack", 1))
+}
+
+func (h *Handler) loggerFrom(ctx context.Context) *zap.SugaredLogger {
+	if logger, ok := utils.LoggerFromContext(ctx); ok {
+		return logger
+	}
+	if h.logger != nil {
+		return h.logger
+	}
+	return zap.NewNop().Sugar()
+}
+
+func (h *Handler) writeErrors(w http.ResponseWriter, status int, code, message string) {
+	h.write(w, status, &graphql.Response{Errors: []*gqlerrors.QueryError{{
+		Message:    message,
+		Extensions: map[string]any{"code": code},
+	}}})
+}
+
+func (h *Handler) write(w http.ResponseWriter, status int, response *graphql.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
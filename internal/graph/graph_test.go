@@ -0,0 +1,152 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2"
+)
+
+var (
+	admin    = &store.User{ID: 1, Role: &store.Role{ID: 1, Name: "admin"}}
+	customer = &store.User{ID: 2, Role: &store.Role{ID: 2, Name: "customer"}}
+)
+
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func execute(t *testing.T, h *Handler, user *store.User, query string) (int, response) {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]any{"query": query})
+	req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader(string(body)))
+	req = req.WithContext(WithUser(req.Context(), user))
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+
+	var res response
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	return recorder.Code, res
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("it should batch nested lists into one query per field", func(t *testing.T) {
+		s := store.NewMockStore()
+		s.Films.(*store.MockFilmStore).ListFilmsFunc = func(ctx context.Context, q *listquery.Query) ([]store.Film, string, error) {
+			return []store.Film{{ID: 1, Title: "Academy Dinosaur"}, {ID: 2, Title: "Ace Goldfinger"}}, "", nil
+		}
+		var calls atomic.Int32
+		var requested []int64
+		s.Actors.(*store.MockActorStore).GetActorsByFilmIDsFunc = func(ctx context.Context, filmIDs []int64) (map[int64][]store.Actor, error) {
+			calls.Add(1)
+			requested = filmIDs
+			return map[int64][]store.Actor{
+				1: {{ID: 1, FirstName: "Penelope"}},
+				2: {{ID: 2, FirstName: "Nick"}},
+			}, nil
+		}
+
+		status, res := execute(t, NewHandler(s, Options{}), customer, `{ films(first: 2) { nodes { title actors { firstName } } } }`)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, res.Errors)
+		assert.Equal(t, int32(1), calls.Load())
+		assert.ElementsMatch(t, []int64{1, 2}, requested)
+		assert.Contains(t, mustJSON(t, res.Data), `"firstName":"Nick"`)
+	})
+
+	t.Run("it should enforce the admin role on customer data", func(t *testing.T) {
+		s := store.NewMockStore()
+		s.Customers.(*store.MockCustomerStore).GetCustomersByIDsFunc = func(ctx context.Context, ids []int64) ([]store.Customer, error) {
+			return []store.Customer{{ID: 1, FirstName: "Mary"}}, nil
+		}
+		h := NewHandler(s, Options{})
+
+		_, res := execute(t, h, customer, `{ customer(id: 1) { firstName } }`)
+		if assert.Len(t, res.Errors, 1) {
+			assert.Equal(t, "forbidden", res.Errors[0].Extensions["code"])
+		}
+
+		_, res = execute(t, h, admin, `{ customer(id: 1) { firstName } }`)
+		assert.Empty(t, res.Errors)
+		assert.Contains(t, mustJSON(t, res.Data), `"firstName":"Mary"`)
+	})
+
+	t.Run("it should return null for a missing row", func(t *testing.T) {
+		_, res := execute(t, NewHandler(store.NewMockStore(), Options{}), customer, `{ film(id: 1) { title } }`)
+
+		assert.Empty(t, res.Errors)
+		assert.Nil(t, res.Data["film"])
+	})
+
+	t.Run("it should reject queries that are too deep", func(t *testing.T) {
+		h := NewHandler(store.NewMockStore(), Options{MaxDepth: 2})
+
+		status, res := execute(t, h, customer, `{ actor(id: 1) { films { title } } }`)
+
+		assert.Equal(t, http.StatusOK, status)
+		assert.Nil(t, res.Data)
+		if assert.Len(t, res.Errors, 1) {
+			assert.Equal(t, "query_too_deep", res.Errors[0].Extensions["code"])
+		}
+	})
+
+	t.Run("it should reject queries that are too complex", func(t *testing.T) {
+		h := NewHandler(store.NewMockStore(), Options{})
+
+		_, res := execute(t, h, admin, `{ films(first: 100) { nodes { actors { films { title } } } } }`)
+
+		if assert.Len(t, res.Errors, 1) {
+			assert.Equal(t, "query_too_complex", res.Errors[0].Extensions["code"])
+		}
+	})
+
+	t.Run("it should reject a malformed body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/graphql", strings.NewReader("{"))
+		recorder := httptest.NewRecorder()
+		NewHandler(store.NewMockStore(), Options{}).ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
+
+func TestLimits(t *testing.T) {
+	l := newLimits(schema, 0)
+
+	tests := []struct {
+		query      string
+		complexity int
+	}{
+		{`{ film(id: 1) { title } }`, 2},
+		{`{ films(first: 5) { nodes { title } } }`, 1 + 1 + 5},
+		{`{ films { nextCursor nodes { title actors { firstName } } } }`, 1 + 1 + 1 + 20*(1+1+10)},
+		{`{ customer(id: 1) { rentals(first: 3) { id } } }`, 1 + 1 + 3},
+		{`query F { film(id: 1) { ...f } } fragment f on Film { title __typename }`, 2},
+	}
+	for _, tt := range tests {
+		doc, errs := gqlparser.LoadQuery(l.schema, tt.query)
+		if assert.Empty(t, errs, tt.query) {
+			assert.Equal(t, tt.complexity, l.measure(doc.Operations[0].SelectionSet, nil, 0), tt.query)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const (
+	DefaultMaxComplexity = 1000
+
+	// defaultListSize is the assumed length of a list without a first
+	// argument, e.g. the actors of a film.
+	defaultListSize = 10
+)
+
+// limits rejects queries that would resolve too many fields before they are
+// executed. A list multiplies the cost of its fields by its first argument, so
+// asking for 100 customers with 100 rentals each costs about 10000 rather
+// than 2. The depth is limited by graphql-go itself, but it does not expose
+// its query AST, so the query is parsed here with gqlparser.
+type limits struct {
+	schema        *ast.Schema
+	maxComplexity int
+}
+
+func newLimits(sdl string, maxComplexity int) *limits {
+	if maxComplexity <= 0 {
+		maxComplexity = DefaultMaxComplexity
+	}
+
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: "schema.graphql", Input: sdl})
+	if err != nil {
+		panic(err)
+	}
+	return &limits{schema: schema, maxComplexity: maxComplexity}
+}
+
+func (l *limits) check(query, operationName string, variables map[string]any) *utils.Error {
+	doc, errs := gqlparser.LoadQuery(l.schema, query)
+	if len(errs) > 0 {
+		return utils.NewError(utils.KindInvalid, "invalid_query", errs[0].Message)
+	}
+
+	operation := doc.Operations.ForName(operationName)
+	if operation == nil {
+		return utils.NewError(utils.KindInvalid, "invalid_query", "the operation to execute is ambiguous or unknown")
+	}
+
+	if complexity := l.measure(operation.SelectionSet, variables, 0); complexity > l.maxComplexity {
+		return utils.NewError(utils.KindInvalid, "query_too_complex",
+			fmt.Sprintf("query has complexity of more than %d", l.maxComplexity))
+	}
+	return nil
+}
+
+// measure returns the complexity of a selection set. carried is the first
+// argument of a parent connection, which sizes the lists inside it. The
+// complexity stops growing once it exceeds the limit.
+func (l *limits) measure(set ast.SelectionSet, variables map[string]any, carried int) (complexity int) {
+	for _, field := range collectFields(set) {
+		if strings.HasPrefix(field.Name, "__") || field.Definition == nil {
+			continue
+		}
+
+		first := firstArgument(field, variables)
+		multiplier, carry := 1, 0
+		switch {
+		case field.Definition.Type.Elem != nil && first > 0:
+			multiplier = first
+		case field.Definition.Type.Elem != nil && carried > 0:
+			multiplier = carried
+		case field.Definition.Type.Elem != nil:
+			multiplier = defaultListSize
+		case first > 0:
+			carry = first
+		}
+		multiplier = min(multiplier, l.maxComplexity+1)
+
+		complexity += 1 + multiplier*l.measure(field.SelectionSet, variables, carry)
+		if complexity > l.maxComplexity {
+			return complexity
+		}
+	}
+	return complexity
+}
+
+// collectFields flattens the fragments of a selection set into its fields.
+func collectFields(set ast.SelectionSet) []*ast.Field {
+	var fields []*ast.Field
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			fields = append(fields, s)
+		case *ast.InlineFragment:
+			fields = append(fields, collectFields(s.SelectionSet)...)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				fields = append(fields, collectFields(s.Definition.SelectionSet)...)
+			}
+		}
+	}
+	return fields
+}
+
+// firstArgument returns the first argument of a field, or 0 if it has none.
+func firstArgument(field *ast.Field, variables map[string]any) int {
+	var value any
+	if arg := field.Arguments.ForName("first"); arg != nil {
+		value, _ = arg.Value.Value(variables)
+	} else if def := field.Definition.Arguments.ForName("first"); def != nil && def.DefaultValue != nil {
+		value, _ = def.DefaultValue.Value(nil)
+	}
+
+	switch n := value.(type) {
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
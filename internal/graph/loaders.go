@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// loaderWait is how long a loader collects keys before it runs a batch.
+// Sibling fields resolve concurrently, so a short wait is enough.
+const loaderWait = 2 * time.Millisecond
+
+// pageKey identifies the first Limit items of a nested list, e.g. the most
+// recent rentals of a customer.
+type pageKey struct {
+	ID    int64
+	Limit int
+}
+
+// loaders batch the lookups of a single request, so resolving a field on
+// every element of a list costs one query instead of one per element.
+type loaders struct {
+	films            *dataloader.Loader[int64, *store.Film]
+	actors           *dataloader.Loader[int64, *store.Actor]
+	customers        *dataloader.Loader[int64, *store.Customer]
+	rentals          *dataloader.Loader[int64, *store.Rental]
+	staff            *dataloader.Loader[int64, *store.Staff]
+	stores           *dataloader.Loader[int64, *store.RentalPlace]
+	inventory        *dataloader.Loader[int64, *store.InventoryItem]
+	filmActors       *dataloader.Loader[int64, []store.Actor]
+	filmCategories   *dataloader.Loader[int64, []store.Category]
+	actorFilms       *dataloader.Loader[int64, []store.Film]
+	rentalPayments   *dataloader.Loader[int64, []store.Payment]
+	customerRentals  *dataloader.Loader[pageKey, []store.Rental]
+	customerPayments *dataloader.Loader[pageKey, []store.Payment]
+}
+
+func newLoaders(s *store.Store) *loaders {
+	return &loaders{
+		films:            newLoader(byID(s.Films.GetFilmsByIDs, func(f *store.Film) int { return f.ID })),
+		actors:           newLoader(byID(s.Actors.GetActorsByIDs, func(a *store.Actor) int { return a.ID })),
+		customers:        newLoader(byID(s.Customers.GetCustomersByIDs, func(c *store.Customer) int { return c.ID })),
+		rentals:          newLoader(byID(s.Rentals.GetRentalsByIDs, func(r *store.Rental) int { return r.ID })),
+		staff:            newLoader(byID(s.Staff.GetStaffByIDs, func(s *store.Staff) int { return s.ID })),
+		stores:           newLoader(byID(s.RentalPlaces.GetRentalPlacesByIDs, func(p *store.RentalPlace) int { return p.ID })),
+		inventory:        newLoader(byID(s.Inventory.GetInventoryItemsByIDs, func(i *store.InventoryItem) int { return i.ID })),
+		filmActors:       newLoader(grouped(s.Actors.GetActorsByFilmIDs)),
+		filmCategories:   newLoader(grouped(s.Categories.GetCategoriesByFilmIDs)),
+		actorFilms:       newLoader(grouped(s.Films.GetFilmsByActorIDs)),
+		rentalPayments:   newLoader(grouped(s.Payments.GetPaymentsByRentalIDs)),
+		customerRentals:  newLoader(groupedPages(s.Rentals.GetRentalsByCustomerIDs)),
+		customerPayments: newLoader(groupedPages(s.Payments.GetPaymentsByCustomerIDs)),
+	}
+}
+
+func newLoader[K comparable, V any](batch dataloader.BatchFunc[K, V]) *dataloader.Loader[K, V] {
+	return dataloader.NewBatchedLoader(batch, dataloader.WithWait[K, V](loaderWait))
+}
+
+// byID batches lookups by primary key. Keys without a row fail with
+// sql.ErrNoRows, like the single row getters of the store.
+func byID[V any](fetch func(ctx context.Context, ids []int64) ([]V, error), id func(item *V) int) dataloader.BatchFunc[int64, *V] {
+	return func(ctx context.Context, keys []int64) []*dataloader.Result[*V] {
+		results := make([]*dataloader.Result[*V], len(keys))
+
+		items, err := fetch(ctx, keys)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*V]{Error: err}
+			}
+			return results
+		}
+
+		byKey := make(map[int64]*V, len(items))
+		for i := range items {
+			byKey[int64(id(&items[i]))] = &items[i]
+		}
+		for i, key := range keys {
+			if item, ok := byKey[key]; ok {
+				results[i] = &dataloader.Result[*V]{Data: item}
+			} else {
+				results[i] = &dataloader.Result[*V]{Error: sql.ErrNoRows}
+			}
+		}
+		return results
+	}
+}
+
+// grouped batches lookups of the items that belong to a parent, e.g. the
+// actors of a film.
+func grouped[V any](fetch func(ctx context.Context, ids []int64) (map[int64][]V, error)) dataloader.BatchFunc[int64, []V] {
+	return func(ctx context.Context, keys []int64) []*dataloader.Result[[]V] {
+		results := make([]*dataloader.Result[[]V], len(keys))
+
+		groups, err := fetch(ctx, keys)
+		for i, key := range keys {
+			results[i] = &dataloader.Result[[]V]{Data: groups[key], Error: err}
+		}
+		return results
+	}
+}
+
+// groupedPages is grouped for limited lists. Keys are batched per limit, as
+// one query can only apply a single limit.
+func groupedPages[V any](fetch func(ctx context.Context, ids []int64, limit int) (map[int64][]V, error)) dataloader.BatchFunc[pageKey, []V] {
+	return func(ctx context.Context, keys []pageKey) []*dataloader.Result[[]V] {
+		idsByLimit := map[int][]int64{}
+		for _, key := range keys {
+			idsByLimit[key.Limit] = append(idsByLimit[key.Limit], key.ID)
+		}
+
+		type page struct {
+			groups map[int64][]V
+			err    error
+		}
+		pages := make(map[int]page, len(idsByLimit))
+		for limit, ids := range idsByLimit {
+			groups, err := fetch(ctx, ids, limit)
+			pages[limit] = page{groups: groups, err: err}
+		}
+
+		results := make([]*dataloader.Result[[]V], len(keys))
+		for i, key := range keys {
+			p := pages[key.Limit]
+			results[i] = &dataloader.Result[[]V]{Data: p.groups[key.ID], Error: p.err}
+		}
+		return results
+	}
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
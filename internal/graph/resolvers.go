@@ -0,0 +1,467 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// maxListSize caps the first argument of nested lists.
+const maxListSize = 100
+
+var (
+	ErrUnauthorized = utils.NewError(utils.KindUnauthorized, "unauthorized", "unauthorized")
+	ErrForbidden    = utils.NewError(utils.KindForbidden, "forbidden", "you do not have permission to access this resource")
+	ErrInvalidID    = utils.NewError(utils.KindInvalid, "invalid_id", "id must be an integer")
+	ErrInvalidFirst = utils.NewError(utils.KindInvalid, "invalid_first", "first must be between 1 and 100")
+)
+
+// requireAdmin applies the same role check as CheckAdminMiddleware does for
+// the REST endpoints.
+func requireAdmin(ctx context.Context) error {
+	user := UserFromContext(ctx)
+	if user == nil {
+		return ErrUnauthorized
+	}
+	if user.Role == nil || user.Role.Name != "admin" {
+		metrics.AuthFailures.WithLabelValues("insufficient_role").Inc()
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Resolver is the root resolver of the schema.
+type Resolver struct {
+	store *store.Store
+}
+
+func (r *Resolver) Film(ctx context.Context, args struct{ ID graphql.ID }) (*filmResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return optional(filmByID(ctx, id))
+}
+
+func (r *Resolver) Films(ctx context.Context, args struct {
+	First  int32
+	After  *string
+	Rating *string
+}) (*filmConnectionResolver, error) {
+	values := url.Values{"limit": {strconv.Itoa(int(args.First))}}
+	if args.After != nil {
+		values.Set("cursor", *args.After)
+	}
+	if args.Rating != nil {
+		values.Set("filter[rating]", *args.Rating)
+	}
+
+	q, err := listquery.Parse(values, store.FilmList)
+	if err != nil {
+		return nil, err
+	}
+
+	films, cursor, err := r.store.Films.ListFilms(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return &filmConnectionResolver{films: films, cursor: cursor}, nil
+}
+
+func (r *Resolver) Actor(ctx context.Context, args struct{ ID graphql.ID }) (*actorResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return optional(actorByID(ctx, id))
+}
+
+func (r *Resolver) Categories(ctx context.Context) ([]*categoryResolver, error) {
+	categories, err := r.store.Categories.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wrap(categories, newCategoryResolver), nil
+}
+
+func (r *Resolver) Store(ctx context.Context, args struct{ ID graphql.ID }) (*storeResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return optional(storeByID(ctx, id))
+}
+
+func (r *Resolver) Customer(ctx context.Context, args struct{ ID graphql.ID }) (*customerResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return optional(customerByID(ctx, id))
+}
+
+func (r *Resolver) Rental(ctx context.Context, args struct{ ID graphql.ID }) (*rentalResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return optional(rentalByID(ctx, id))
+}
+
+func (r *Resolver) Staff(ctx context.Context, args struct{ ID graphql.ID }) (*staffResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return optional(staffByID(ctx, id))
+}
+
+type filmConnectionResolver struct {
+	films  []store.Film
+	cursor string
+}
+
+func (r *filmConnectionResolver) Nodes() []*filmResolver {
+	return wrap(r.films, newFilmResolver)
+}
+
+func (r *filmConnectionResolver) NextCursor() *string {
+	if r.cursor == "" {
+		return nil
+	}
+	return &r.cursor
+}
+
+type filmResolver struct {
+	film *store.Film
+}
+
+func newFilmResolver(film *store.Film) *filmResolver {
+	return &filmResolver{film: film}
+}
+
+func (r *filmResolver) ID() graphql.ID           { return toID(r.film.ID) }
+func (r *filmResolver) Title() string            { return r.film.Title }
+func (r *filmResolver) Description() string      { return r.film.Description }
+func (r *filmResolver) ReleaseYear() *int32      { return toInt32(r.film.ReleaseYear) }
+func (r *filmResolver) RentalDuration() int32    { return int32(r.film.RentalDuration) }
+func (r *filmResolver) RentalRate() float64      { return r.film.RentalRate }
+func (r *filmResolver) Length() *int32           { return toInt32(r.film.Length) }
+func (r *filmResolver) ReplacementCost() float64 { return r.film.ReplacementCost }
+func (r *filmResolver) Rating() string           { return r.film.Rating }
+func (r *filmResolver) SpecialFeatures() []string {
+	if r.film.SpecialFeatures == nil {
+		return []string{}
+	}
+	return r.film.SpecialFeatures
+}
+func (r *filmResolver) LastUpdate() graphql.Time { return graphql.Time{Time: r.film.LastUpdate} }
+
+func (r *filmResolver) Actors(ctx context.Context) ([]*actorResolver, error) {
+	actors, err := loadersFromContext(ctx).filmActors.Load(ctx, int64(r.film.ID))()
+	if err != nil {
+		return nil, err
+	}
+	return wrap(actors, newActorResolver), nil
+}
+
+func (r *filmResolver) Categories(ctx context.Context) ([]*categoryResolver, error) {
+	categories, err := loadersFromContext(ctx).filmCategories.Load(ctx, int64(r.film.ID))()
+	if err != nil {
+		return nil, err
+	}
+	return wrap(categories, newCategoryResolver), nil
+}
+
+type actorResolver struct {
+	actor *store.Actor
+}
+
+func newActorResolver(actor *store.Actor) *actorResolver {
+	return &actorResolver{actor: actor}
+}
+
+func (r *actorResolver) ID() graphql.ID    { return toID(r.actor.ID) }
+func (r *actorResolver) FirstName() string { return r.actor.FirstName }
+func (r *actorResolver) LastName() string  { return r.actor.LastName }
+
+func (r *actorResolver) Films(ctx context.Context) ([]*filmResolver, error) {
+	films, err := loadersFromContext(ctx).actorFilms.Load(ctx, int64(r.actor.ID))()
+	if err != nil {
+		return nil, err
+	}
+	return wrap(films, newFilmResolver), nil
+}
+
+type categoryResolver struct {
+	category *store.Category
+}
+
+func newCategoryResolver(category *store.Category) *categoryResolver {
+	return &categoryResolver{category: category}
+}
+
+func (r *categoryResolver) ID() graphql.ID { return toID(r.category.ID) }
+func (r *categoryResolver) Name() string   { return r.category.Name }
+
+type storeResolver struct {
+	store *store.RentalPlace
+}
+
+func (r *storeResolver) ID() graphql.ID { return toID(r.store.ID) }
+
+func (r *storeResolver) Manager(ctx context.Context) (*staffResolver, error) {
+	return staffByID(ctx, int64(r.store.ManagerStaffID))
+}
+
+type inventoryResolver struct {
+	item *store.InventoryItem
+}
+
+func (r *inventoryResolver) ID() graphql.ID { return toID(r.item.ID) }
+
+func (r *inventoryResolver) Film(ctx context.Context) (*filmResolver, error) {
+	return filmByID(ctx, int64(r.item.FilmID))
+}
+
+func (r *inventoryResolver) Store(ctx context.Context) (*storeResolver, error) {
+	return storeByID(ctx, int64(r.item.StoreID))
+}
+
+type customerResolver struct {
+	customer *store.Customer
+}
+
+func (r *customerResolver) ID() graphql.ID    { return toID(r.customer.ID) }
+func (r *customerResolver) FirstName() string { return r.customer.FirstName }
+func (r *customerResolver) LastName() string  { return r.customer.LastName }
+func (r *customerResolver) Email() string     { return r.customer.Email }
+func (r *customerResolver) Active() bool      { return r.customer.Active }
+func (r *customerResolver) LastUpdate() graphql.Time {
+	return graphql.Time{Time: r.customer.LastUpdate}
+}
+
+func (r *customerResolver) Store(ctx context.Context) (*storeResolver, error) {
+	return storeByID(ctx, r.customer.StoreID)
+}
+
+func (r *customerResolver) Rentals(ctx context.Context, args struct{ First int32 }) ([]*rentalResolver, error) {
+	key, err := page(ctx, r.customer.ID, args.First)
+	if err != nil {
+		return nil, err
+	}
+	rentals, err := loadersFromContext(ctx).customerRentals.Load(ctx, key)()
+	if err != nil {
+		return nil, err
+	}
+	return wrap(rentals, newRentalResolver), nil
+}
+
+func (r *customerResolver) Payments(ctx context.Context, args struct{ First int32 }) ([]*paymentResolver, error) {
+	key, err := page(ctx, r.customer.ID, args.First)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := loadersFromContext(ctx).customerPayments.Load(ctx, key)()
+	if err != nil {
+		return nil, err
+	}
+	return wrap(payments, newPaymentResolver), nil
+}
+
+type rentalResolver struct {
+	rental *store.Rental
+}
+
+func newRentalResolver(rental *store.Rental) *rentalResolver {
+	return &rentalResolver{rental: rental}
+}
+
+func (r *rentalResolver) ID() graphql.ID           { return toID(r.rental.ID) }
+func (r *rentalResolver) RentalDate() graphql.Time { return graphql.Time{Time: r.rental.RentalDate} }
+
+func (r *rentalResolver) ReturnDate() *graphql.Time {
+	if r.rental.ReturnDate == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.rental.ReturnDate}
+}
+
+func (r *rentalResolver) Customer(ctx context.Context) (*customerResolver, error) {
+	return customerByID(ctx, int64(r.rental.CustomerID))
+}
+
+func (r *rentalResolver) Inventory(ctx context.Context) (*inventoryResolver, error) {
+	item, err := loadersFromContext(ctx).inventory.Load(ctx, int64(r.rental.InventoryID))()
+	if err != nil {
+		return nil, err
+	}
+	return &inventoryResolver{item: item}, nil
+}
+
+func (r *rentalResolver) Staff(ctx context.Context) (*staffResolver, error) {
+	return staffByID(ctx, int64(r.rental.StaffID))
+}
+
+func (r *rentalResolver) Payments(ctx context.Context) ([]*paymentResolver, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	payments, err := loadersFromContext(ctx).rentalPayments.Load(ctx, int64(r.rental.ID))()
+	if err != nil {
+		return nil, err
+	}
+	return wrap(payments, newPaymentResolver), nil
+}
+
+type paymentResolver struct {
+	payment *store.Payment
+}
+
+func newPaymentResolver(payment *store.Payment) *paymentResolver {
+	return &paymentResolver{payment: payment}
+}
+
+func (r *paymentResolver) ID() graphql.ID  { return toID(r.payment.ID) }
+func (r *paymentResolver) Amount() float64 { return r.payment.Amount }
+func (r *paymentResolver) PaymentDate() graphql.Time {
+	return graphql.Time{Time: r.payment.PaymentDate}
+}
+
+func (r *paymentResolver) Customer(ctx context.Context) (*customerResolver, error) {
+	return customerByID(ctx, int64(r.payment.CustomerID))
+}
+
+func (r *paymentResolver) Rental(ctx context.Context) (*rentalResolver, error) {
+	return rentalByID(ctx, int64(r.payment.RentalID))
+}
+
+func (r *paymentResolver) Staff(ctx context.Context) (*staffResolver, error) {
+	return staffByID(ctx, int64(r.payment.StaffID))
+}
+
+type staffResolver struct {
+	staff *store.Staff
+}
+
+func (r *staffResolver) ID() graphql.ID    { return toID(r.staff.ID) }
+func (r *staffResolver) FirstName() string { return r.staff.FirstName }
+func (r *staffResolver) LastName() string  { return r.staff.LastName }
+func (r *staffResolver) Email() string     { return r.staff.Email }
+func (r *staffResolver) Active() bool      { return r.staff.Active }
+
+func (r *staffResolver) Store(ctx context.Context) (*storeResolver, error) {
+	return storeByID(ctx, r.staff.StoreID)
+}
+
+func filmByID(ctx context.Context, id int64) (*filmResolver, error) {
+	film, err := loadersFromContext(ctx).films.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	return newFilmResolver(film), nil
+}
+
+func actorByID(ctx context.Context, id int64) (*actorResolver, error) {
+	actor, err := loadersFromContext(ctx).actors.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	return newActorResolver(actor), nil
+}
+
+func storeByID(ctx context.Context, id int64) (*storeResolver, error) {
+	rentalPlace, err := loadersFromContext(ctx).stores.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	return &storeResolver{store: rentalPlace}, nil
+}
+
+func customerByID(ctx context.Context, id int64) (*customerResolver, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	customer, err := loadersFromContext(ctx).customers.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	return &customerResolver{customer: customer}, nil
+}
+
+func rentalByID(ctx context.Context, id int64) (*rentalResolver, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	rental, err := loadersFromContext(ctx).rentals.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	return newRentalResolver(rental), nil
+}
+
+func staffByID(ctx context.Context, id int64) (*staffResolver, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	staff, err := loadersFromContext(ctx).staff.Load(ctx, id)()
+	if err != nil {
+		return nil, err
+	}
+	return &staffResolver{staff: staff}, nil
+}
+
+// page checks access to, and the size of, a nested list of a customer.
+func page(ctx context.Context, id int, first int32) (pageKey, error) {
+	if err := requireAdmin(ctx); err != nil {
+		return pageKey{}, err
+	}
+	if first < 1 || first > maxListSize {
+		return pageKey{}, ErrInvalidFirst
+	}
+	return pageKey{ID: int64(id), Limit: int(first)}, nil
+}
+
+// optional resolves a missing row of a nullable root field to null.
+func optional[R any](resolver *R, err error) (*R, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return resolver, err
+}
+
+func wrap[V any, R any](items []V, newResolver func(item *V) R) []R {
+	resolvers := make([]R, len(items))
+	for i := range items {
+		resolvers[i] = newResolver(&items[i])
+	}
+	return resolvers
+}
+
+func parseID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidID.Wrap(err)
+	}
+	return n, nil
+}
+
+func toID(id int) graphql.ID {
+	return graphql.ID(strconv.Itoa(id))
+}
+
+func toInt32(n *int) *int32 {
+	if n == nil {
+		return nil
+	}
+	v := int32(*n)
+	return &v
+}
@@ -0,0 +1,274 @@
+// Package jobs runs recurring maintenance tasks inside the API process. Every
+// replica runs a scheduler, and a Postgres advisory lock per job makes sure a
+// job runs on only one of them at a time. Runs are recorded in the job_runs
+// table.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"go.uber.org/zap"
+)
+
+const defaultTimeout = 10 * time.Minute
+
+var (
+	ErrUnknownJob = utils.NewError(utils.KindNotFound, "job_not_found", "no job with this name")
+	ErrStopped    = errors.New("the scheduler is stopped")
+)
+
+// Store is the part of store.Store the scheduler needs.
+type Store interface {
+	StartJobRun(ctx context.Context, name, trigger string, triggeredBy *int) (*store.JobRun, error)
+	FinishJobRun(ctx context.Context, run *store.JobRun, jobErr error) error
+}
+
+// Job is a task run on a schedule.
+type Job struct {
+	Name string
+	// Schedule is a cron expression, see ParseSchedule.
+	Schedule string
+	// Timeout bounds a run. The job's context is cancelled when it expires.
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// Info describes a registered job.
+type Info struct {
+	Name      string     `json:"name"`
+	Schedule  string     `json:"schedule"`
+	Timeout   string     `json:"timeout"`
+	NextRunAt *time.Time `json:"next_run_at"`
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	next     time.Time
+}
+
+// Scheduler runs registered jobs when they are due and on demand.
+type Scheduler struct {
+	store  Store
+	logger *zap.SugaredLogger
+
+	mu      sync.Mutex
+	entries []*entry
+	stopped bool
+
+	// ctx is the parent of every run, cancelled when Run returns.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler(s Store, logger *zap.SugaredLogger) *Scheduler {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{store: s, logger: logger, ctx: ctx, cancel: cancel}
+}
+
+// Register adds a job. It must be called before Run.
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = defaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lookup(job.Name) != nil {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries = append(s.entries, &entry{job: job, schedule: schedule})
+	return nil
+}
+
+func (s *Scheduler) lookup(name string) *entry {
+	for _, e := range s.entries {
+		if e.job.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Jobs describes the registered jobs in the order they were registered.
+func (s *Scheduler) Jobs() []Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]Info, 0, len(s.entries))
+	for _, e := range s.entries {
+		info := Info{Name: e.job.Name, Schedule: e.job.Schedule, Timeout: e.job.Timeout.String()}
+		if !e.next.IsZero() {
+			next := e.next
+			info.NextRunAt = &next
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Trigger runs the named job now, outside of its schedule, and returns the
+// started run. It fails with store.ErrJobRunning while the job is running on
+// any replica.
+func (s *Scheduler) Trigger(ctx context.Context, name string, triggeredBy *int) (*store.JobRun, error) {
+	s.mu.Lock()
+	e := s.lookup(name)
+	s.mu.Unlock()
+	if e == nil {
+		return nil, ErrUnknownJob
+	}
+	return s.start(ctx, e.job, store.JobTriggerManual, triggeredBy)
+}
+
+// Run starts the jobs when they are due until ctx is cancelled. It then
+// cancels the running jobs and waits for them to record their outcome.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.stop()
+
+	s.mu.Lock()
+	now := time.Now()
+	for _, e := range s.entries {
+		e.next = e.schedule.Next(now)
+	}
+	s.mu.Unlock()
+
+	for {
+		timer := time.NewTimer(time.Until(s.nextDue()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, job := range s.due(time.Now()) {
+			_, err := s.start(ctx, job, store.JobTriggerSchedule, nil)
+			switch {
+			case errors.Is(err, store.ErrJobRunning), errors.Is(err, ErrStopped), errors.Is(err, context.Canceled):
+			case err != nil:
+				s.logger.Errorw("failed to start job", "job", job.Name, "error", err)
+			}
+		}
+	}
+}
+
+// nextDue returns when the next job is due. Without any, it returns a time
+// far enough ahead to just wait for cancellation.
+func (s *Scheduler) nextDue() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := time.Now().Add(24 * time.Hour)
+	for _, e := range s.entries {
+		if !e.next.IsZero() && e.next.Before(next) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+// due returns the jobs due at now and moves them to their next run time.
+func (s *Scheduler) due(now time.Time) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []Job
+	for _, e := range s.entries {
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		jobs = append(jobs, e.job)
+		e.next = e.schedule.Next(now)
+	}
+	return jobs
+}
+
+func (s *Scheduler) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.wg.Wait()
+}
+
+// start records a run of job and runs it in the background. The run does not
+// depend on ctx, which only bounds taking the lock.
+func (s *Scheduler) start(ctx context.Context, job Job, trigger string, triggeredBy *int) (*store.JobRun, error) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil, ErrStopped
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	run, err := s.store.StartJobRun(ctx, job.Name, trigger, triggeredBy)
+	if err != nil {
+		s.wg.Done()
+		return nil, err
+	}
+	started := *run
+
+	go func() {
+		defer s.wg.Done()
+		s.execute(job, run)
+	}()
+
+	return &started, nil
+}
+
+func (s *Scheduler) execute(job Job, run *store.JobRun) {
+	logger := s.logger.With("job", job.Name, "run_id", run.ID, "trigger", run.Trigger)
+	logger.Infow("job started")
+
+	ctx, cancel := context.WithTimeout(s.ctx, job.Timeout)
+	start := time.Now()
+	err := runJob(ctx, job)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", job.Timeout, err)
+	}
+	cancel()
+	duration := time.Since(start)
+
+	// The outcome is recorded even when the run was cancelled by shutdown.
+	if finishErr := s.store.FinishJobRun(context.WithoutCancel(s.ctx), run, err); finishErr != nil {
+		logger.Errorw("failed to record job run", "error", finishErr)
+	}
+
+	status := store.JobRunSucceeded
+	if err != nil {
+		status = store.JobRunFailed
+		logger.Errorw("job failed", "duration", duration, "error", err)
+	} else {
+		logger.Infow("job succeeded", "duration", duration)
+	}
+	metrics.JobRuns.WithLabelValues(job.Name, status).Inc()
+	metrics.JobDuration.WithLabelValues(job.Name).Observe(duration.Seconds())
+}
+
+// runJob turns a panic of the job into an error, so it is recorded like any
+// other failure.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("job panicked: %v", v)
+		}
+	}()
+	return job.Run(ctx)
+}
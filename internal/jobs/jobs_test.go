@@ -0,0 +1,154 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStore records the outcome of every finished run.
+type recordingStore struct {
+	store.MockJobStore
+	mu       sync.Mutex
+	finished map[string]error
+	done     chan string
+}
+
+func newRecordingStore() *recordingStore {
+	s := &recordingStore{finished: map[string]error{}, done: make(chan string, 10)}
+	s.FinishJobRunFunc = func(ctx context.Context, run *store.JobRun, jobErr error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.mu.Lock()
+		s.finished[run.JobName] = jobErr
+		s.mu.Unlock()
+		s.done <- run.JobName
+		return nil
+	}
+	return s
+}
+
+func (s *recordingStore) wait(t *testing.T) error {
+	t.Helper()
+	select {
+	case name := <-s.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.finished[name]
+	case <-time.After(5 * time.Second):
+		t.Fatal("no run finished")
+		return nil
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("it should run a triggered job and record its outcome", func(t *testing.T) {
+		s := newRecordingStore()
+		scheduler := NewScheduler(s, nil)
+		require.NoError(t, scheduler.Register(Job{Name: "cleanup", Schedule: "@daily", Run: func(ctx context.Context) error {
+			return errors.New("database error")
+		}}))
+
+		userID := 7
+		run, err := scheduler.Trigger(context.Background(), "cleanup", &userID)
+		require.NoError(t, err)
+		assert.Equal(t, store.JobTriggerManual, run.Trigger)
+		assert.Equal(t, &userID, run.TriggeredBy)
+		assert.EqualError(t, s.wait(t), "database error")
+	})
+
+	t.Run("it should fail jobs that exceed their timeout", func(t *testing.T) {
+		s := newRecordingStore()
+		scheduler := NewScheduler(s, nil)
+		require.NoError(t, scheduler.Register(Job{Name: "slow", Schedule: "@daily", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}}))
+
+		_, err := scheduler.Trigger(context.Background(), "slow", nil)
+		require.NoError(t, err)
+		err = s.wait(t)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "timed out after 10ms")
+	})
+
+	t.Run("it should record a panic as a failure", func(t *testing.T) {
+		s := newRecordingStore()
+		scheduler := NewScheduler(s, nil)
+		require.NoError(t, scheduler.Register(Job{Name: "broken", Schedule: "@daily", Run: func(ctx context.Context) error {
+			panic("boom")
+		}}))
+
+		_, err := scheduler.Trigger(context.Background(), "broken", nil)
+		require.NoError(t, err)
+		assert.EqualError(t, s.wait(t), "job panicked: boom")
+	})
+
+	t.Run("it should not trigger a job that is running elsewhere", func(t *testing.T) {
+		s := newRecordingStore()
+		s.StartJobRunFunc = func(ctx context.Context, name, trigger string, triggeredBy *int) (*store.JobRun, error) {
+			return nil, store.ErrJobRunning
+		}
+		scheduler := NewScheduler(s, nil)
+		require.NoError(t, scheduler.Register(Job{Name: "cleanup", Schedule: "@daily", Run: func(ctx context.Context) error { return nil }}))
+
+		_, err := scheduler.Trigger(context.Background(), "cleanup", nil)
+		assert.ErrorIs(t, err, store.ErrJobRunning)
+	})
+
+	t.Run("it should not trigger unknown jobs", func(t *testing.T) {
+		scheduler := NewScheduler(newRecordingStore(), nil)
+
+		_, err := scheduler.Trigger(context.Background(), "unknown", nil)
+		assert.ErrorIs(t, err, ErrUnknownJob)
+	})
+
+	t.Run("it should reject invalid and duplicate jobs", func(t *testing.T) {
+		scheduler := NewScheduler(newRecordingStore(), nil)
+		run := func(ctx context.Context) error { return nil }
+
+		assert.Error(t, scheduler.Register(Job{Name: "cleanup", Schedule: "never", Run: run}))
+		require.NoError(t, scheduler.Register(Job{Name: "cleanup", Schedule: "@daily", Run: run}))
+		assert.Error(t, scheduler.Register(Job{Name: "cleanup", Schedule: "@hourly", Run: run}))
+	})
+
+	t.Run("it should run due jobs and cancel them on shutdown", func(t *testing.T) {
+		s := newRecordingStore()
+		scheduler := NewScheduler(s, nil)
+		started := make(chan struct{})
+		require.NoError(t, scheduler.Register(Job{Name: "periodic", Schedule: "@every 1s", Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			scheduler.Run(ctx)
+			close(stopped)
+		}()
+
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not run")
+		}
+		require.NotNil(t, scheduler.Jobs()[0].NextRunAt)
+
+		cancel()
+		<-stopped
+		// Run returns only after the cancelled run was recorded.
+		assert.ErrorIs(t, s.wait(t), context.Canceled)
+
+		_, err := scheduler.Trigger(context.Background(), "periodic", nil)
+		assert.ErrorIs(t, err, ErrStopped)
+	})
+}
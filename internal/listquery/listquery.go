@@ -0,0 +1,426 @@
+// Package listquery parses the filtering, sorting and pagination parameters
+// of list endpoints and turns them into parameterised SQL for the store.
+//
+// Supported parameters:
+//
+//	filter[field]=value         equality
+//	filter[field][op]=value     op is one of ne, lt, lte, gt, gte, like, in
+//	sort=-rental_date,title     comma separated, "-" sorts descending
+//	limit=50
+//	cursor=...                  next_cursor of the previous page
+//
+// Only the fields a Resource whitelists can be filtered or sorted on, and
+// column names never come from the request, so the generated SQL is safe.
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/lib/pq"
+)
+
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Time
+	Bool
+)
+
+type Operator string
+
+const (
+	Eq   Operator = "eq"
+	Ne   Operator = "ne"
+	Lt   Operator = "lt"
+	Lte  Operator = "lte"
+	Gt   Operator = "gt"
+	Gte  Operator = "gte"
+	Like Operator = "like"
+	In   Operator = "in"
+)
+
+var sqlOperators = map[Operator]string{
+	Eq:  "=",
+	Ne:  "<>",
+	Lt:  "<",
+	Lte: "<=",
+	Gt:  ">",
+	Gte: ">=",
+}
+
+// Field is a column that a list endpoint exposes under an API name.
+type Field struct {
+	// Column is the SQL expression for the field. Sortable fields must not be
+	// NULL, wrap nullable columns in COALESCE.
+	Column string
+	Type   Type
+	Filter bool
+	Sort   bool
+}
+
+// Resource is the whitelist of fields a list endpoint accepts.
+type Resource struct {
+	Fields map[string]Field
+	// Key is a unique, sortable field used to break ties between rows, so
+	// that the keyset cursor is stable.
+	Key          string
+	DefaultSort  string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+type Filter struct {
+	Field    string
+	Operator Operator
+	Value    any
+}
+
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// Query is a parsed and validated list request.
+type Query struct {
+	resource *Resource
+	Filters  []Filter
+	Sort     []Sort
+	Limit    int
+	// After holds the sort values of the last row of the previous page.
+	After []any
+}
+
+var (
+	ErrInvalidQuery  = utils.NewError(utils.KindInvalid, "invalid_list_query", "invalid list query")
+	ErrInvalidCursor = utils.NewError(utils.KindInvalid, "invalid_cursor", "cursor is invalid or does not match the sort order")
+)
+
+func invalid(format string, args ...any) error {
+	return utils.NewError(ErrInvalidQuery.Kind, ErrInvalidQuery.Code, fmt.Sprintf(format, args...))
+}
+
+var filterParam = regexp.MustCompile(`^filter\[([a-z0-9_]+)\](?:\[([a-z]+)\])?$`)
+
+// Parse validates the list parameters in values against resource.
+func Parse(values url.Values, resource *Resource) (*Query, error) {
+	q := &Query{resource: resource, Limit: resource.DefaultLimit}
+
+	for param, paramValues := range values {
+		if !strings.HasPrefix(param, "filter") {
+			continue
+		}
+		match := filterParam.FindStringSubmatch(param)
+		if match == nil {
+			return nil, invalid("malformed filter parameter %q", param)
+		}
+		filter, err := resource.parseFilter(match[1], Operator(match[2]), paramValues[len(paramValues)-1])
+		if err != nil {
+			return nil, err
+		}
+		q.Filters = append(q.Filters, filter)
+	}
+	// Order filters by field, so the generated SQL does not depend on map
+	// iteration order.
+	slices.SortFunc(q.Filters, func(a, b Filter) int { return strings.Compare(a.Field, b.Field) })
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = resource.DefaultSort
+	}
+	sorts, err := resource.parseSort(sortParam)
+	if err != nil {
+		return nil, err
+	}
+	q.Sort = sorts
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > resource.MaxLimit {
+			return nil, invalid("limit must be between 1 and %d", resource.MaxLimit)
+		}
+		q.Limit = limit
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		after, err := q.decodeCursor(value)
+		if err != nil {
+			return nil, err
+		}
+		q.After = after
+	}
+
+	return q, nil
+}
+
+func (resource *Resource) parseFilter(name string, op Operator, raw string) (Filter, error) {
+	field, ok := resource.Fields[name]
+	if !ok || !field.Filter {
+		return Filter{}, invalid("filtering on %q is not supported", name)
+	}
+	if op == "" {
+		op = Eq
+	}
+
+	switch {
+	case op == Like && field.Type != String,
+		op == In && (field.Type == Time || field.Type == Bool),
+		field.Type == Bool && op != Eq && op != Ne:
+		return Filter{}, invalid("operator %q is not supported for %q", op, name)
+	case op != Like && op != In && sqlOperators[op] == "":
+		return Filter{}, invalid("unknown filter operator %q", op)
+	}
+
+	if op == In {
+		parts := strings.Split(raw, ",")
+		values := make([]any, 0, len(parts))
+		for _, part := range parts {
+			value, err := parseValue(field.Type, part)
+			if err != nil {
+				return Filter{}, invalid("invalid value for %q: %v", name, err)
+			}
+			values = append(values, value)
+		}
+		return Filter{Field: name, Operator: op, Value: values}, nil
+	}
+
+	value, err := parseValue(field.Type, raw)
+	if err != nil {
+		return Filter{}, invalid("invalid value for %q: %v", name, err)
+	}
+	return Filter{Field: name, Operator: op, Value: value}, nil
+}
+
+func (resource *Resource) parseSort(param string) ([]Sort, error) {
+	var sorts []Sort
+	seen := map[string]bool{}
+	for _, part := range strings.Split(param, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, descending := strings.CutPrefix(part, "-")
+		field, ok := resource.Fields[name]
+		if !ok || !field.Sort {
+			return nil, invalid("sorting by %q is not supported", name)
+		}
+		if seen[name] {
+			return nil, invalid("duplicate sort field %q", name)
+		}
+		seen[name] = true
+		sorts = append(sorts, Sort{Field: name, Descending: descending})
+	}
+	if !seen[resource.Key] {
+		sorts = append(sorts, Sort{Field: resource.Key})
+	}
+	return sorts, nil
+}
+
+func parseValue(fieldType Type, raw string) (any, error) {
+	raw = strings.TrimSpace(raw)
+	switch fieldType {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Float:
+		return strconv.ParseFloat(raw, 64)
+	case Time:
+		if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.DateOnly, raw)
+	case Bool:
+		return strconv.ParseBool(raw)
+	default:
+		return raw, nil
+	}
+}
+
+// Fragments are the SQL clauses for a Query. Where, OrderBy and Limit start
+// with a space, so they can be appended to a SELECT ... FROM statement.
+type Fragments struct {
+	Where   string
+	OrderBy string
+	Limit   string
+	Args    []any
+}
+
+// Build renders the query as SQL. args are the positional arguments the
+// statement already uses, new placeholders are numbered after them.
+//
+// Limit fetches one row more than requested, so Page can tell whether there
+// is a next page.
+func (q *Query) Build(args ...any) Fragments {
+	var conditions []string
+	addArg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	for _, filter := range q.Filters {
+		column := q.resource.Fields[filter.Field].Column
+		switch filter.Operator {
+		case Like:
+			conditions = append(conditions, fmt.Sprintf("%s ILIKE %s", column, addArg("%"+escapeLike(filter.Value.(string))+"%")))
+		case In:
+			conditions = append(conditions, fmt.Sprintf("%s = ANY(%s)", column, addArg(arrayArg(q.resource.Fields[filter.Field].Type, filter.Value.([]any)))))
+		default:
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", column, sqlOperators[filter.Operator], addArg(filter.Value)))
+		}
+	}
+
+	if q.After != nil {
+		var alternatives []string
+		for i, sort := range q.Sort {
+			var terms []string
+			for j := 0; j < i; j++ {
+				terms = append(terms, fmt.Sprintf("%s = %s", q.resource.Fields[q.Sort[j].Field].Column, addArg(q.After[j])))
+			}
+			op := ">"
+			if sort.Descending {
+				op = "<"
+			}
+			terms = append(terms, fmt.Sprintf("%s %s %s", q.resource.Fields[sort.Field].Column, op, addArg(q.After[i])))
+			alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		}
+		conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
+	}
+
+	var fragments Fragments
+	if len(conditions) > 0 {
+		fragments.Where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	orderBy := make([]string, 0, len(q.Sort))
+	for _, sort := range q.Sort {
+		direction := "ASC"
+		if sort.Descending {
+			direction = "DESC"
+		}
+		orderBy = append(orderBy, q.resource.Fields[sort.Field].Column+" "+direction)
+	}
+	fragments.OrderBy = " ORDER BY " + strings.Join(orderBy, ", ")
+	fragments.Limit = " LIMIT " + addArg(q.Limit+1)
+	fragments.Args = args
+
+	return fragments
+}
+
+// Page trims the extra row fetched by Build and returns the cursor for the
+// next page, or "" on the last page. value returns an item's value for a
+// sortable field.
+func Page[T any](q *Query, items []T, value func(item T, field string) any) ([]T, string, error) {
+	if len(items) <= q.Limit {
+		return items, "", nil
+	}
+	items = items[:q.Limit]
+
+	last := items[len(items)-1]
+	values := make([]any, 0, len(q.Sort))
+	for _, sort := range q.Sort {
+		values = append(values, value(last, sort.Field))
+	}
+
+	cursor, err := q.encodeCursor(values)
+	if err != nil {
+		return nil, "", err
+	}
+	return items, cursor, nil
+}
+
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+func (q *Query) sortKey() string {
+	parts := make([]string, 0, len(q.Sort))
+	for _, sort := range q.Sort {
+		if sort.Descending {
+			parts = append(parts, "-"+sort.Field)
+		} else {
+			parts = append(parts, sort.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (q *Query) encodeCursor(values []any) (string, error) {
+	data, err := json.Marshal(cursor{Sort: q.sortKey(), Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func (q *Query) decodeCursor(value string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var c cursor
+	if err := decoder.Decode(&c); err != nil || c.Sort != q.sortKey() || len(c.Values) != len(q.Sort) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, len(c.Values))
+	for i, sort := range q.Sort {
+		raw, ok := c.Values[i].(json.Number)
+		text := string(raw)
+		if !ok {
+			if s, isString := c.Values[i].(string); isString {
+				text = s
+			} else if b, isBool := c.Values[i].(bool); isBool {
+				text = strconv.FormatBool(b)
+			} else {
+				return nil, ErrInvalidCursor
+			}
+		}
+		parsed, err := parseValue(q.resource.Fields[sort.Field].Type, text)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = parsed
+	}
+	return values, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func arrayArg(fieldType Type, values []any) any {
+	switch fieldType {
+	case Int:
+		ints := make(pq.Int64Array, len(values))
+		for i, v := range values {
+			ints[i] = v.(int64)
+		}
+		return ints
+	case Float:
+		floats := make(pq.Float64Array, len(values))
+		for i, v := range values {
+			floats[i] = v.(float64)
+		}
+		return floats
+	default:
+		strs := make(pq.StringArray, len(values))
+		for i, v := range values {
+			strs[i] = v.(string)
+		}
+		return strs
+	}
+}
@@ -0,0 +1,156 @@
+package listquery
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var rentals = &Resource{
+	Fields: map[string]Field{
+		"id":          {Column: "rental_id", Type: Int, Filter: true, Sort: true},
+		"rental_date": {Column: "rental_date", Type: Time, Filter: true, Sort: true},
+		"customer_id": {Column: "customer_id", Type: Int, Filter: true},
+		"title":       {Column: "title", Type: String, Filter: true, Sort: true},
+		"returned":    {Column: "(return_date IS NOT NULL)", Type: Bool, Filter: true},
+	},
+	Key:          "id",
+	DefaultSort:  "-rental_date",
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+func TestParse(t *testing.T) {
+	t.Run("it should apply the defaults", func(t *testing.T) {
+		q, err := Parse(url.Values{}, rentals)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 20, q.Limit)
+		assert.Equal(t, []Sort{{Field: "rental_date", Descending: true}, {Field: "id"}}, q.Sort)
+		assert.Empty(t, q.Filters)
+	})
+
+	t.Run("it should parse filters, sort and limit", func(t *testing.T) {
+		q, err := Parse(url.Values{
+			"filter[customer_id][in]": {"1,2"},
+			"filter[title]":           {"Academy"},
+			"sort":                    {"title,-id"},
+			"limit":                   {"5"},
+		}, rentals)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5, q.Limit)
+		assert.Equal(t, []Sort{{Field: "title"}, {Field: "id", Descending: true}}, q.Sort)
+		assert.Equal(t, []Filter{
+			{Field: "customer_id", Operator: In, Value: []any{int64(1), int64(2)}},
+			{Field: "title", Operator: Eq, Value: "Academy"},
+		}, q.Filters)
+	})
+
+	t.Run("it should reject parameters outside the whitelist", func(t *testing.T) {
+		for name, values := range map[string]url.Values{
+			"unknown filter":      {"filter[password]": {"x"}},
+			"malformed filter":    {"filter[title": {"x"}},
+			"unknown operator":    {"filter[id][regex]": {"1"}},
+			"like on a number":    {"filter[id][like]": {"1"}},
+			"invalid value":       {"filter[id]": {"one"}},
+			"unsortable field":    {"sort": {"customer_id"}},
+			"duplicate sort":      {"sort": {"title,-title"}},
+			"limit above maximum": {"limit": {"101"}},
+			"invalid limit":       {"limit": {"0"}},
+			"invalid cursor":      {"cursor": {"not-a-cursor"}},
+		} {
+			_, err := Parse(values, rentals)
+
+			var e *utils.Error
+			if assert.ErrorAs(t, err, &e, name) {
+				assert.Equal(t, utils.KindInvalid, e.Kind, name)
+			}
+		}
+	})
+}
+
+func TestBuild(t *testing.T) {
+	t.Run("it should render parameterised SQL after the existing arguments", func(t *testing.T) {
+		q, err := Parse(url.Values{
+			"filter[title][like]":     {"50%_off"},
+			"filter[customer_id][in]": {"1,2"},
+			"filter[returned]":        {"true"},
+		}, rentals)
+		assert.NoError(t, err)
+
+		fragments := q.Build("existing")
+
+		assert.Equal(t, " WHERE customer_id = ANY($2) AND (return_date IS NOT NULL) = $3 AND title ILIKE $4", fragments.Where)
+		assert.Equal(t, " ORDER BY rental_date DESC, rental_id ASC", fragments.OrderBy)
+		assert.Equal(t, " LIMIT $5", fragments.Limit)
+		assert.Equal(t, []any{"existing", pq.Int64Array{1, 2}, true, `%50\%\_off%`, 21}, fragments.Args)
+	})
+
+	t.Run("it should render a keyset predicate for mixed sort directions", func(t *testing.T) {
+		q, err := Parse(url.Values{"sort": {"-rental_date,title"}}, rentals)
+		assert.NoError(t, err)
+		rentalDate := time.Date(2005, 5, 24, 22, 53, 30, 0, time.UTC)
+		q.After = []any{rentalDate, "Academy", int64(7)}
+
+		fragments := q.Build()
+
+		assert.Equal(t, " WHERE ((rental_date < $1) OR (rental_date = $2 AND title > $3) OR (rental_date = $4 AND title = $5 AND rental_id > $6))", fragments.Where)
+		assert.Equal(t, []any{rentalDate, rentalDate, "Academy", rentalDate, "Academy", int64(7), 21}, fragments.Args)
+	})
+}
+
+type rental struct {
+	ID         int
+	RentalDate time.Time
+}
+
+func TestPage(t *testing.T) {
+	value := func(r rental, field string) any {
+		if field == "rental_date" {
+			return r.RentalDate
+		}
+		return r.ID
+	}
+	start := time.Date(2005, 5, 24, 22, 53, 30, 123456000, time.UTC)
+
+	t.Run("it should return a cursor that resumes after the last row", func(t *testing.T) {
+		q, err := Parse(url.Values{"limit": {"2"}}, rentals)
+		assert.NoError(t, err)
+
+		items, cursor, err := Page(q, []rental{{1, start}, {2, start}, {3, start}}, value)
+		assert.NoError(t, err)
+		assert.Len(t, items, 2)
+		assert.NotEmpty(t, cursor)
+
+		next, err := Parse(url.Values{"limit": {"2"}, "cursor": {cursor}}, rentals)
+		assert.NoError(t, err)
+		assert.Len(t, next.After, 2)
+		assert.True(t, start.Equal(next.After[0].(time.Time)))
+		assert.Equal(t, int64(2), next.After[1])
+	})
+
+	t.Run("it should not return a cursor on the last page", func(t *testing.T) {
+		q, err := Parse(url.Values{"limit": {"2"}}, rentals)
+		assert.NoError(t, err)
+
+		items, cursor, err := Page(q, []rental{{1, start}, {2, start}}, value)
+		assert.NoError(t, err)
+		assert.Len(t, items, 2)
+		assert.Empty(t, cursor)
+	})
+
+	t.Run("it should reject a cursor issued for another sort order", func(t *testing.T) {
+		q, err := Parse(url.Values{"limit": {"1"}}, rentals)
+		assert.NoError(t, err)
+		_, cursor, err := Page(q, []rental{{1, start}, {2, start}}, value)
+		assert.NoError(t, err)
+
+		_, err = Parse(url.Values{"sort": {"title"}, "cursor": {cursor}}, rentals)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
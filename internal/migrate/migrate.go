@@ -0,0 +1,312 @@
+// Package migrate applies the SQL migrations in /migrations. It keeps its
+// state in the schema_migrations table the way golang-migrate does, so a
+// database can be migrated with either this package or the migrate CLI.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// lockID is the advisory lock held while migrating, so that only one process
+// changes the schema at a time.
+const lockID int64 = 4265728190
+
+// nilVersion is recorded by golang-migrate while the first migration is
+// being rolled back. It reads as version 0.
+const nilVersion int64 = -1
+
+var (
+	ErrDirty = errors.New("database is dirty, fix it and force the version")
+	ErrAhead = errors.New("database is ahead of the migrations")
+)
+
+var fileName = regexp.MustCompile(`^([0-9]+)_(.*)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New reads the migrations from the root of fsys. Every version needs an up
+// file; the down file is optional.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func readMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 0)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files named %s and %s", version, migration.Name, match[2])
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the version of the newest migration, or 0 if there are
+// none.
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the applied version and whether the last migration failed
+// halfway. A database that was never migrated reports version 0.
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	return readVersion(ctx, m.db)
+}
+
+// Up applies every migration newer than the applied version and returns the
+// ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+			if err := run(ctx, conn, int64(migration.Version), migration.Up); err != nil {
+				return fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the given number of migrations, newest first, and returns
+// the ones it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := m.index(current); i >= 0 && len(rolledBack) < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d %s has no down file", migration.Version, migration.Name)
+			}
+
+			target := nilVersion
+			if i > 0 {
+				target = int64(m.migrations[i-1].Version)
+			}
+			if err := run(ctx, conn, target, migration.Down); err != nil {
+				return fmt.Errorf("rollback of migration %d %s failed: %w", migration.Version, migration.Name, err)
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Force records version as applied and clean without running anything. It
+// is used after a failed migration has been fixed by hand. Version 0 marks
+// the database as never migrated.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		target := int64(version)
+		if version == 0 {
+			target = nilVersion
+		}
+		return setVersion(ctx, conn, target, false)
+	})
+}
+
+// index returns the position of version in the migrations, or -1.
+func (m *Migrator) index(version uint) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// Check returns the applied version. It fails with ErrDirty or ErrAhead if
+// the database is not at a clean version these migrations know; a database
+// that is behind passes.
+func (m *Migrator) Check(ctx context.Context) (uint, error) {
+	return m.cleanVersion(ctx, m.db)
+}
+
+// cleanVersion returns the applied version, failing if the database is dirty
+// or at a version this binary does not know.
+func (m *Migrator) cleanVersion(ctx context.Context, db execQueryer) (uint, error) {
+	current, dirty, err := readVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("version %d: %w", current, ErrDirty)
+	}
+	if current > m.Latest() {
+		return 0, fmt.Errorf("%w: database is at version %d, the latest migration is %d", ErrAhead, current, m.Latest())
+	}
+	if current != 0 && m.index(current) < 0 {
+		return 0, fmt.Errorf("database is at unknown migration version %d, the latest is %d", current, m.Latest())
+	}
+	return current, nil
+}
+
+// locked runs fn on a single connection that holds the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to acquire the migration lock: %w", err)
+	}
+	defer func() {
+		// The context may be done already, the lock must be released anyway
+		_, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+		err = errors.Join(err, unlockErr)
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// run executes a migration file, marking the database dirty at version until
+// it succeeds. Like golang-migrate, the file is not wrapped in a transaction.
+func run(ctx context.Context, conn *sql.Conn, version int64, body string) error {
+	if err := setVersion(ctx, conn, version, true); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	return setVersion(ctx, conn, version, false)
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func ensureTable(ctx context.Context, db execQueryer) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	return err
+}
+
+func readVersion(ctx context.Context, db execQueryer) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	var pqErr *pq.Error
+	if errors.Is(err, sql.ErrNoRows) || (errors.As(err, &pqErr) && pqErr.Code == "42P01") {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if version < 0 {
+		return 0, dirty, nil
+	}
+	return uint(version), dirty, nil
+}
+
+func setVersion(ctx context.Context, conn *sql.Conn, version int64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+	if version != nilVersion || dirty {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,170 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/migrate"
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/andras-szesztai/dev-rental-api/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func readMigrations(fsys fstest.MapFS) ([]migrate.Migration, error) {
+	migrator, err := migrate.New(nil, fsys)
+	if err != nil {
+		return nil, err
+	}
+	return migrator.Migrations(), nil
+}
+
+func TestNew(t *testing.T) {
+	t.Run("it should read the embedded migrations in order", func(t *testing.T) {
+		migrator, err := migrate.New(nil, migrations.FS)
+		require.NoError(t, err)
+		all := migrator.Migrations()
+		require.NotEmpty(t, all)
+		assert.Equal(t, all[len(all)-1].Version, migrator.Latest())
+
+		for i, migration := range all {
+			assert.Equal(t, uint(i+1), migration.Version)
+			assert.NotEmpty(t, migration.Down, "migration %d has no down file", migration.Version)
+		}
+		assert.Equal(t, "create_users_table", all[0].Name)
+	})
+
+	t.Run("it should ignore other files", func(t *testing.T) {
+		read, err := readMigrations(fstest.MapFS{
+			"2_b.up.sql": {Data: []byte("SELECT 2")},
+			"1_a.up.sql": {Data: []byte("SELECT 1")},
+			"README.md":  {Data: []byte("docs")},
+		})
+		require.NoError(t, err)
+		require.Len(t, read, 2)
+		assert.Equal(t, migrate.Migration{Version: 1, Name: "a", Up: "SELECT 1"}, read[0])
+	})
+
+	t.Run("it should require an up file", func(t *testing.T) {
+		_, err := readMigrations(fstest.MapFS{"1_a.down.sql": {Data: []byte("SELECT 1")}})
+		assert.ErrorContains(t, err, "migration 1 has no up file")
+	})
+
+	t.Run("it should reject mismatched names", func(t *testing.T) {
+		_, err := readMigrations(fstest.MapFS{
+			"1_a.up.sql":   {Data: []byte("SELECT 1")},
+			"1_b.down.sql": {Data: []byte("SELECT 1")},
+		})
+		assert.ErrorContains(t, err, "migration 1 has files named")
+	})
+}
+
+type MigrateTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	ctx         context.Context
+}
+
+func (suite *MigrateTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+
+	// Start from a database that was never migrated, the container applied
+	// the real migrations already
+	_, err = pgContainer.DB.ExecContext(suite.ctx, "DROP TABLE schema_migrations")
+	suite.Require().NoError(err)
+}
+
+func TestMigrateTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
+
+func (suite *MigrateTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *MigrateTestSuite) TestMigrator() {
+	files := fstest.MapFS{
+		"1_create_widgets.up.sql":     {Data: []byte("CREATE TABLE widgets (id SERIAL PRIMARY KEY);")},
+		"1_create_widgets.down.sql":   {Data: []byte("DROP TABLE widgets;")},
+		"2_add_widget_color.up.sql":   {Data: []byte("ALTER TABLE widgets ADD COLUMN color TEXT;")},
+		"2_add_widget_color.down.sql": {Data: []byte("ALTER TABLE widgets DROP COLUMN color;")},
+	}
+	migrator, err := migrate.New(suite.pgContainer.DB.DB, files)
+	suite.Require().NoError(err)
+
+	suite.T().Run("it should apply every migration", func(t *testing.T) {
+		applied, err := migrator.Up(suite.ctx)
+		suite.Require().NoError(err)
+		suite.Len(applied, 2)
+
+		version, dirty, err := migrator.Version(suite.ctx)
+		suite.NoError(err)
+		suite.Equal(uint(2), version)
+		suite.False(dirty)
+
+		applied, err = migrator.Up(suite.ctx)
+		suite.NoError(err)
+		suite.Empty(applied)
+	})
+
+	suite.T().Run("it should roll back migrations", func(t *testing.T) {
+		rolledBack, err := migrator.Down(suite.ctx, 5)
+		suite.Require().NoError(err)
+		suite.Len(rolledBack, 2)
+
+		version, dirty, err := migrator.Version(suite.ctx)
+		suite.NoError(err)
+		suite.Zero(version)
+		suite.False(dirty)
+	})
+
+	suite.T().Run("a failed migration should leave the database dirty", func(t *testing.T) {
+		files["3_broken.up.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE missing ADD COLUMN x INT;")}
+		broken, err := migrate.New(suite.pgContainer.DB.DB, files)
+		suite.Require().NoError(err)
+
+		_, err = broken.Up(suite.ctx)
+		suite.ErrorContains(err, "migration 3 broken failed")
+
+		version, dirty, err := broken.Version(suite.ctx)
+		suite.NoError(err)
+		suite.Equal(uint(3), version)
+		suite.True(dirty)
+
+		_, err = broken.Up(suite.ctx)
+		suite.ErrorIs(err, migrate.ErrDirty)
+
+		suite.Require().NoError(broken.Force(suite.ctx, 2))
+		rolledBack, err := broken.Down(suite.ctx, 2)
+		suite.NoError(err)
+		suite.Len(rolledBack, 2)
+	})
+
+	suite.T().Run("it should refuse a database newer than its migrations", func(t *testing.T) {
+		_, err := migrator.Up(suite.ctx)
+		suite.Require().NoError(err)
+		defer migrator.Down(suite.ctx, 2)
+
+		older, err := migrate.New(suite.pgContainer.DB.DB, fstest.MapFS{"1_create_widgets.up.sql": files["1_create_widgets.up.sql"]})
+		suite.Require().NoError(err)
+
+		_, err = older.Up(suite.ctx)
+		suite.ErrorIs(err, migrate.ErrAhead)
+
+		_, err = older.Check(suite.ctx)
+		suite.ErrorIs(err, migrate.ErrAhead)
+
+		version, err := migrator.Check(suite.ctx)
+		suite.NoError(err)
+		suite.Equal(uint(2), version)
+	})
+}
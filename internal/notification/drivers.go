@@ -0,0 +1,136 @@
+package notification
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileDriver writes every message as an .eml file to a directory instead of
+// sending it, for development, tests and deployments without mail delivery.
+type FileDriver struct {
+	dir  string
+	from *mail.Address
+}
+
+// NewFileDriver creates dir if it does not exist.
+func NewFileDriver(dir, from string) (*FileDriver, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create notification directory: %w", err)
+	}
+	return &FileDriver{dir: dir, from: sender}, nil
+}
+
+func (d *FileDriver) Send(ctx context.Context, msg Message) error {
+	now := time.Now().UTC()
+	body, err := composeEmail(d.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s-%s", now.Format("20060102T150405.000000000"), msg.Template, msg.Channel)
+
+	// Writing to a temporary file first means readers never see a partial
+	// message.
+	path := filepath.Join(d.dir, name+".eml")
+	tmp := filepath.Join(d.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type SMTPOptions struct {
+	// Addr is the host:port of the mail server.
+	Addr string
+	// Username and Password authenticate with PLAIN auth if Username is set.
+	// The server must then offer STARTTLS.
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPDriver sends email through a mail server. STARTTLS is used whenever
+// the server offers it.
+type SMTPDriver struct {
+	opts   SMTPOptions
+	from   *mail.Address
+	dialer net.Dialer
+}
+
+func NewSMTPDriver(opts SMTPOptions) (*SMTPDriver, error) {
+	sender, err := mail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	if _, _, err := net.SplitHostPort(opts.Addr); err != nil {
+		return nil, fmt.Errorf("invalid SMTP address: %w", err)
+	}
+	return &SMTPDriver{opts: opts, from: sender}, nil
+}
+
+func (d *SMTPDriver) Send(ctx context.Context, msg Message) error {
+	body, err := composeEmail(d.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.opts.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	host, _, _ := net.SplitHostPort(d.opts.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if d.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", d.opts.Username, d.opts.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(d.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To.Email); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return errors.Join(err, w.Close())
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
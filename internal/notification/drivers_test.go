@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage(t *testing.T) Message {
+	t.Helper()
+	templates, err := DefaultTemplates()
+	require.NoError(t, err)
+	content, err := templates.Render(TemplateOverdueReminder, testData)
+	require.NoError(t, err)
+
+	return Message{
+		Channel:  ChannelEmail,
+		Template: TemplateOverdueReminder,
+		To:       Recipient{CustomerID: 1, Email: "mary.smith@sakilacustomer.org", Name: "MARY SMITH"},
+		Content:  *content,
+	}
+}
+
+func TestFileDriver(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "notifications")
+	driver, err := NewFileDriver(dir, "DVD Rental <no-reply@dvdrental.local>")
+	require.NoError(t, err)
+
+	require.NoError(t, driver.Send(context.Background(), testMessage(t)))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Regexp(t, `-overdue_reminder-email\.eml$`, files[0])
+
+	file, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer file.Close()
+
+	msg, err := mail.ReadMessage(file)
+	require.NoError(t, err)
+	assert.Equal(t, `"MARY SMITH" <mary.smith@sakilacustomer.org>`, msg.Header.Get("To"))
+	assert.Equal(t, `"DVD Rental" <no-reply@dvdrental.local>`, msg.Header.Get("From"))
+	assert.Equal(t, "Reminder 2: ACADEMY DINOSAUR is 3 days overdue", msg.Header.Get("Subject"))
+	assert.Contains(t, msg.Header.Get("Content-Type"), "multipart/alternative")
+}
+
+func TestSMTPDriver(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go serveSMTP(t, listener, received)
+
+	driver, err := NewSMTPDriver(SMTPOptions{Addr: listener.Addr().String(), From: "no-reply@dvdrental.local"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, driver.Send(ctx, testMessage(t)))
+
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "MAIL FROM:<no-reply@dvdrental.local>")
+		assert.Contains(t, data, "RCPT TO:<mary.smith@sakilacustomer.org>")
+		assert.Contains(t, data, "Subject: Reminder 2: ACADEMY DINOSAUR is 3 days overdue")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+// serveSMTP accepts one connection and speaks just enough SMTP to receive a
+// message, without STARTTLS or auth. It sends the whole conversation from
+// the client on received.
+func serveSMTP(t *testing.T, listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	var transcript strings.Builder
+	reply := func(response string) {
+		if err := text.PrintfLine("%s", response); err != nil {
+			t.Error(err)
+		}
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		transcript.WriteString(line + "\n")
+
+		switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL", "RCPT":
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			transcript.Write(data)
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			received <- transcript.String()
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package notification sends templated messages to customers and staff. A
+// message is rendered from a named template, sent on every channel the
+// customer prefers through the driver registered for it, and recorded in the
+// send log. Customers who opted out only get essential messages.
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"go.uber.org/zap"
+)
+
+// Channels a message can be sent on.
+const (
+	ChannelEmail = "email"
+)
+
+// Template names.
+const (
+	TemplateOverdueReminder = "overdue_reminder"
+	TemplateRentalLost      = "rental_lost"
+)
+
+// essential templates are sent to customers who opted out, because they
+// concern money owed.
+var essential = map[string]bool{
+	TemplateRentalLost: true,
+}
+
+// Store is the part of store.Store the service needs.
+type Store interface {
+	GetNotificationPreferences(ctx context.Context, customerID int64) (*store.NotificationPreferences, error)
+	RecordNotification(ctx context.Context, entry *store.NotificationLogEntry) error
+}
+
+// Recipient is who a message goes to. CustomerID is 0 for staff, whose
+// messages go to their email regardless of preferences.
+type Recipient struct {
+	CustomerID int
+	Email      string
+	Name       string
+}
+
+// Notification asks for the template to be rendered with Data and sent to
+// the recipient.
+type Notification struct {
+	Template string
+	To       Recipient
+	Data     any
+}
+
+// Message is a rendered notification on its way to one channel.
+type Message struct {
+	Channel  string
+	Template string
+	To       Recipient
+	Content
+}
+
+// Driver delivers messages on a channel.
+type Driver interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// DriverFunc adapts a function to a Driver.
+type DriverFunc func(ctx context.Context, msg Message) error
+
+func (f DriverFunc) Send(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// Service renders and sends notifications. It is safe for concurrent use
+// once its drivers are registered.
+type Service struct {
+	store     Store
+	templates *Templates
+	drivers   map[string]Driver
+	logger    *zap.SugaredLogger
+}
+
+func NewService(s Store, templates *Templates, logger *zap.SugaredLogger) *Service {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	return &Service{store: s, templates: templates, drivers: map[string]Driver{}, logger: logger}
+}
+
+// Register sets the driver of a channel. Messages for channels without a
+// driver are logged as skipped.
+func (s *Service) Register(channel string, driver Driver) {
+	s.drivers[channel] = driver
+}
+
+// Send renders the notification and sends it on each channel of the
+// recipient. It returns the errors of the channels that failed; a message
+// that was skipped, e.g. because the customer opted out, is not an error.
+func (s *Service) Send(ctx context.Context, n Notification) error {
+	content, err := s.templates.Render(n.Template, n.Data)
+	if err != nil {
+		return err
+	}
+
+	channels := []string{ChannelEmail}
+	optedOut := false
+	if n.To.CustomerID != 0 {
+		prefs, err := s.store.GetNotificationPreferences(ctx, int64(n.To.CustomerID))
+		if err != nil {
+			return fmt.Errorf("failed to get notification preferences: %w", err)
+		}
+		optedOut = prefs.OptedOut && !essential[n.Template]
+		channels = prefs.Channels
+		if len(channels) == 0 && essential[n.Template] {
+			channels = []string{ChannelEmail}
+		}
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		msg := Message{Channel: channel, Template: n.Template, To: n.To, Content: *content}
+
+		driver, ok := s.drivers[channel]
+		switch {
+		case optedOut:
+			s.record(ctx, msg, store.NotificationSkipped, errors.New("recipient opted out"))
+		case !ok:
+			s.record(ctx, msg, store.NotificationSkipped, errors.New("no driver for channel"))
+		default:
+			if err := driver.Send(ctx, msg); err != nil {
+				s.record(ctx, msg, store.NotificationFailed, err)
+				errs = append(errs, fmt.Errorf("%s: %w", channel, err))
+				continue
+			}
+			s.record(ctx, msg, store.NotificationSent, nil)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// record adds the outcome to the send log. A message that was sent is not
+// failed because the log could not be written, so that error is only logged.
+func (s *Service) record(ctx context.Context, msg Message, status string, sendErr error) {
+	metrics.Notifications.WithLabelValues(msg.Template, msg.Channel, status).Inc()
+
+	entry := &store.NotificationLogEntry{
+		Template:  msg.Template,
+		Channel:   msg.Channel,
+		Recipient: msg.To.Email,
+		Subject:   msg.Subject,
+		Status:    status,
+	}
+	if msg.To.CustomerID != 0 {
+		entry.CustomerID = &msg.To.CustomerID
+	}
+	if sendErr != nil {
+		message := sendErr.Error()
+		entry.Error = &message
+	}
+
+	if err := s.store.RecordNotification(context.WithoutCancel(ctx), entry); err != nil {
+		s.logger.Errorw("failed to record notification", "template", msg.Template, "channel", msg.Channel, "status", status, "error", err)
+	}
+}
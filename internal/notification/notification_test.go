@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type overdue struct {
+	Reminder        int
+	Name            string
+	FilmTitle       string
+	DueDate         time.Time
+	DaysOverdue     int
+	CheckoutBlocked bool
+	Charge          float64
+}
+
+var testData = overdue{
+	Reminder:    2,
+	Name:        "MARY SMITH",
+	FilmTitle:   "ACADEMY DINOSAUR",
+	DueDate:     time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+	DaysOverdue: 3,
+	Charge:      20.99,
+}
+
+func newService(t *testing.T, prefs *store.NotificationPreferences) (*Service, *[]store.NotificationLogEntry, *[]Message) {
+	t.Helper()
+	templates, err := DefaultTemplates()
+	require.NoError(t, err)
+
+	var log []store.NotificationLogEntry
+	s := &store.MockNotificationStore{
+		GetNotificationPreferencesFunc: func(ctx context.Context, customerID int64) (*store.NotificationPreferences, error) {
+			return prefs, nil
+		},
+		RecordNotificationFunc: func(ctx context.Context, entry *store.NotificationLogEntry) error {
+			log = append(log, *entry)
+			return nil
+		},
+	}
+
+	var sent []Message
+	service := NewService(s, templates, nil)
+	service.Register(ChannelEmail, DriverFunc(func(ctx context.Context, msg Message) error {
+		sent = append(sent, msg)
+		return nil
+	}))
+	return service, &log, &sent
+}
+
+func TestService(t *testing.T) {
+	customer := Recipient{CustomerID: 1, Email: "mary.smith@sakilacustomer.org", Name: "MARY SMITH"}
+
+	t.Run("it should send on the preferred channels and log it", func(t *testing.T) {
+		service, log, sent := newService(t, &store.NotificationPreferences{Channels: []string{ChannelEmail}})
+
+		err := service.Send(context.Background(), Notification{Template: TemplateOverdueReminder, To: customer, Data: testData})
+		require.NoError(t, err)
+
+		require.Len(t, *sent, 1)
+		assert.Equal(t, "Reminder 2: ACADEMY DINOSAUR is 3 days overdue", (*sent)[0].Subject)
+		require.Len(t, *log, 1)
+		assert.Equal(t, store.NotificationSent, (*log)[0].Status)
+		assert.Equal(t, 1, *(*log)[0].CustomerID)
+		assert.Equal(t, "mary.smith@sakilacustomer.org", (*log)[0].Recipient)
+	})
+
+	t.Run("it should skip customers who opted out", func(t *testing.T) {
+		service, log, sent := newService(t, &store.NotificationPreferences{Channels: []string{ChannelEmail}, OptedOut: true})
+
+		err := service.Send(context.Background(), Notification{Template: TemplateOverdueReminder, To: customer, Data: testData})
+		require.NoError(t, err)
+
+		assert.Empty(t, *sent)
+		require.Len(t, *log, 1)
+		assert.Equal(t, store.NotificationSkipped, (*log)[0].Status)
+		assert.Equal(t, "recipient opted out", *(*log)[0].Error)
+	})
+
+	t.Run("it should send essential messages to customers who opted out", func(t *testing.T) {
+		service, _, sent := newService(t, &store.NotificationPreferences{Channels: []string{}, OptedOut: true})
+
+		err := service.Send(context.Background(), Notification{Template: TemplateRentalLost, To: customer, Data: testData})
+		require.NoError(t, err)
+
+		require.Len(t, *sent, 1)
+		assert.Equal(t, ChannelEmail, (*sent)[0].Channel)
+	})
+
+	t.Run("it should skip channels without a driver", func(t *testing.T) {
+		service, log, _ := newService(t, &store.NotificationPreferences{Channels: []string{"sms"}})
+
+		err := service.Send(context.Background(), Notification{Template: TemplateOverdueReminder, To: customer, Data: testData})
+		require.NoError(t, err)
+
+		require.Len(t, *log, 1)
+		assert.Equal(t, store.NotificationSkipped, (*log)[0].Status)
+		assert.Equal(t, "sms", (*log)[0].Channel)
+	})
+
+	t.Run("it should send to staff without preferences", func(t *testing.T) {
+		service, log, sent := newService(t, nil)
+
+		err := service.Send(context.Background(), Notification{Template: TemplateOverdueReminder, To: Recipient{Email: "mike@sakilastaff.com"}, Data: testData})
+		require.NoError(t, err)
+
+		assert.Len(t, *sent, 1)
+		assert.Nil(t, (*log)[0].CustomerID)
+	})
+
+	t.Run("it should return and log failed sends", func(t *testing.T) {
+		service, log, _ := newService(t, &store.NotificationPreferences{Channels: []string{ChannelEmail}})
+		service.Register(ChannelEmail, DriverFunc(func(ctx context.Context, msg Message) error {
+			return errors.New("connection refused")
+		}))
+
+		err := service.Send(context.Background(), Notification{Template: TemplateOverdueReminder, To: customer, Data: testData})
+		assert.ErrorContains(t, err, "email: connection refused")
+
+		require.Len(t, *log, 1)
+		assert.Equal(t, store.NotificationFailed, (*log)[0].Status)
+	})
+
+	t.Run("it should fail on an unknown template", func(t *testing.T) {
+		service, _, sent := newService(t, &store.NotificationPreferences{Channels: []string{ChannelEmail}})
+
+		err := service.Send(context.Background(), Notification{Template: "reservation_ready", To: customer})
+		assert.ErrorContains(t, err, `unknown notification template "reservation_ready"`)
+		assert.Empty(t, *sent)
+	})
+}
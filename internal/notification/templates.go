@@ -0,0 +1,102 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Content is a rendered message.
+type Content struct {
+	Subject string
+	Text    string
+	// HTML is empty for templates without an HTML version.
+	HTML string
+}
+
+type template struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates are the named message templates. Each name has a text template,
+// <name>.txt.tmpl, that defines the "subject" template next to the body, and
+// optionally an HTML body, <name>.html.tmpl.
+type Templates struct {
+	templates map[string]template
+}
+
+// DefaultTemplates returns the templates built into the binary.
+func DefaultTemplates() (*Templates, error) {
+	return ParseTemplates(defaultTemplates, "templates")
+}
+
+// ParseTemplates parses the templates in dir of fsys.
+func ParseTemplates(fsys fs.FS, dir string) (*Templates, error) {
+	textFiles, err := fs.Glob(fsys, path.Join(dir, "*.txt.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{templates: map[string]template{}}
+	for _, file := range textFiles {
+		name := strings.TrimSuffix(path.Base(file), ".txt.tmpl")
+
+		text, err := texttemplate.New(name).Option("missingkey=error").ParseFS(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		text = text.Lookup(path.Base(file))
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("template %s does not define a subject", name)
+		}
+
+		tmpl := template{text: text}
+		htmlFile := path.Join(dir, name+".html.tmpl")
+		if _, err := fs.Stat(fsys, htmlFile); err == nil {
+			tmpl.html, err = htmltemplate.New(name).Option("missingkey=error").ParseFS(fsys, htmlFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+			}
+			tmpl.html = tmpl.html.Lookup(path.Base(htmlFile))
+		}
+		t.templates[name] = tmpl
+	}
+	return t, nil
+}
+
+// Render executes the template name with data.
+func (t *Templates) Render(name string, data any) (*Content, error) {
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown notification template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	if tmpl.html != nil {
+		if err := tmpl.html.Execute(&html, data); err != nil {
+			return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+		}
+	}
+
+	return &Content{
+		// Subjects are a single header line.
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    strings.TrimSpace(html.String()),
+	}, nil
+}
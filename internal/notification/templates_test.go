@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := DefaultTemplates()
+	require.NoError(t, err)
+
+	t.Run("it should render the subject, text and html", func(t *testing.T) {
+		data := testData
+		data.CheckoutBlocked = true
+		data.FilmTitle = "ALIEN & CENTER"
+
+		content, err := templates.Render(TemplateOverdueReminder, data)
+		require.NoError(t, err)
+
+		assert.Equal(t, "Reminder 2: ALIEN & CENTER is 3 days overdue", content.Subject)
+		assert.Contains(t, content.Text, "Hello MARY SMITH,\n\nALIEN & CENTER was due back on May 1, 2025")
+		assert.Contains(t, content.Text, "you cannot rent other films.\n")
+		assert.Contains(t, content.HTML, "<strong>ALIEN &amp; CENTER</strong>")
+	})
+
+	t.Run("the first reminder should have a short subject", func(t *testing.T) {
+		data := testData
+		data.Reminder = 1
+
+		content, err := templates.Render(TemplateOverdueReminder, data)
+		require.NoError(t, err)
+		assert.Equal(t, "ACADEMY DINOSAUR is overdue", content.Subject)
+		assert.NotContains(t, content.Text, "cannot rent")
+	})
+
+	t.Run("it should render the lost copy charge", func(t *testing.T) {
+		content, err := templates.Render(TemplateRentalLost, testData)
+		require.NoError(t, err)
+		assert.Equal(t, "ACADEMY DINOSAUR has been marked as lost", content.Subject)
+		assert.Contains(t, content.Text, "replacement cost of 20.99")
+	})
+
+	t.Run("it should fail on missing data", func(t *testing.T) {
+		_, err := templates.Render(TemplateRentalLost, map[string]any{"Name": "MARY SMITH"})
+		assert.Error(t, err)
+	})
+
+	t.Run("templates without html should only have text", func(t *testing.T) {
+		templates, err := ParseTemplates(fstest.MapFS{
+			"templates/welcome.txt.tmpl": {Data: []byte(`{{define "subject"}}Welcome {{.}}{{end}}Hello {{.}}`)},
+		}, "templates")
+		require.NoError(t, err)
+
+		content, err := templates.Render("welcome", "Mary")
+		require.NoError(t, err)
+		assert.Equal(t, "Welcome Mary", content.Subject)
+		assert.Equal(t, "Hello Mary\n", content.Text)
+		assert.Empty(t, content.HTML)
+	})
+
+	t.Run("templates must define a subject", func(t *testing.T) {
+		_, err := ParseTemplates(fstest.MapFS{
+			"templates/welcome.txt.tmpl": {Data: []byte(`Hello {{.}}`)},
+		}, "templates")
+		assert.ErrorContains(t, err, "template welcome does not define a subject")
+	})
+}
@@ -0,0 +1,169 @@
+// Package outbox relays the domain events that store methods record in the
+// outbox table to the registered publishers, such as webhooks. An event is
+// only ever relayed once its transaction has committed.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultRetention    = 7 * 24 * time.Hour
+	defaultBatchSize    = 100
+)
+
+// Store is the part of store.Store the relay needs.
+type Store interface {
+	RelayOutboxEvents(ctx context.Context, limit int, publish func(ctx context.Context, event *store.OutboxEvent) error) (int, error)
+	DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Publisher receives every relayed event. Events are delivered at least
+// once, so Publish must tolerate seeing an event again, e.g. after another
+// publisher failed or the process restarted.
+type Publisher interface {
+	Publish(ctx context.Context, event *store.OutboxEvent) error
+}
+
+// PublisherFunc adapts a function to a Publisher.
+type PublisherFunc func(ctx context.Context, event *store.OutboxEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event *store.OutboxEvent) error {
+	return f(ctx, event)
+}
+
+type Options struct {
+	// PollInterval is how often the outbox is checked without a
+	// notification.
+	PollInterval time.Duration
+	// Retention is how long published events are kept.
+	Retention time.Duration
+	// BatchSize is the most events relayed per transaction.
+	BatchSize int
+	Logger    *zap.SugaredLogger
+}
+
+type namedPublisher struct {
+	name      string
+	publisher Publisher
+}
+
+// Relay hands outbox events to its publishers in order of their aggregate.
+type Relay struct {
+	store      Store
+	opts       Options
+	publishers []namedPublisher
+	listener   *pq.Listener
+}
+
+func NewRelay(s Store, opts Options) *Relay {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop().Sugar()
+	}
+	return &Relay{store: s, opts: opts}
+}
+
+// Register adds a publisher. It must be called before Run.
+func (r *Relay) Register(name string, publisher Publisher) {
+	r.publishers = append(r.publishers, namedPublisher{name: name, publisher: publisher})
+}
+
+// Listen makes the relay wake up as soon as a transaction that wrote events
+// commits, instead of on the next poll. dsn is the database connection
+// string.
+func (r *Relay) Listen(dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			r.opts.Logger.Warnw("outbox listener connection error", "error", err)
+		}
+	})
+	if err := listener.Listen(store.OutboxChannel); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to listen for outbox events: %w", err)
+	}
+	r.listener = listener
+	return nil
+}
+
+// Run relays events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	var notify <-chan *pq.Notification
+	if r.listener != nil {
+		defer r.listener.Close()
+		notify = r.listener.Notify
+	}
+
+	for {
+		r.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-notify:
+			// A nil notification after a reconnect may stand for missed
+			// ones, so it triggers a relay just the same.
+		}
+	}
+}
+
+// drain relays batches until the outbox has no more events to relay.
+func (r *Relay) drain(ctx context.Context) {
+	for {
+		relayed, err := r.RelayPending(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				r.opts.Logger.Errorw("failed to relay outbox events", "error", err)
+			}
+			return
+		}
+		if relayed < r.opts.BatchSize {
+			return
+		}
+	}
+}
+
+// RelayPending relays one batch of unpublished events and returns how many
+// every publisher accepted.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	return r.store.RelayOutboxEvents(ctx, r.opts.BatchSize, r.publish)
+}
+
+// DeletePublished removes published events past their retention and returns
+// how many it deleted. It is run as a scheduled job.
+func (r *Relay) DeletePublished(ctx context.Context) (int64, error) {
+	return r.store.DeletePublishedOutboxEvents(ctx, time.Now().Add(-r.opts.Retention))
+}
+
+func (r *Relay) publish(ctx context.Context, event *store.OutboxEvent) error {
+	for _, p := range r.publishers {
+		if err := p.publisher.Publish(ctx, event); err != nil {
+			metrics.OutboxPublishFailures.WithLabelValues(p.name).Inc()
+			r.opts.Logger.Warnw("failed to publish outbox event",
+				"publisher", p.name, "event_id", event.ID, "event_type", event.EventType, "error", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay(t *testing.T) {
+	events := []store.OutboxEvent{
+		{ID: 1, AggregateType: "rental", AggregateID: 7, EventType: store.EventRentalCreated},
+		{ID: 2, AggregateType: "customer", AggregateID: 3, EventType: store.EventCustomerCreated},
+	}
+
+	// relayAll stands in for the store: it accepts the events every
+	// publisher took.
+	relayAll := func(accepted *[]int64) func(ctx context.Context, limit int, publish func(ctx context.Context, event *store.OutboxEvent) error) (int, error) {
+		return func(ctx context.Context, limit int, publish func(ctx context.Context, event *store.OutboxEvent) error) (int, error) {
+			for i := range events {
+				if err := publish(ctx, &events[i]); err == nil {
+					*accepted = append(*accepted, events[i].ID)
+				}
+			}
+			return len(*accepted), nil
+		}
+	}
+
+	t.Run("it should hand every event to every publisher", func(t *testing.T) {
+		var accepted []int64
+		relay := NewRelay(&store.MockOutboxStore{RelayOutboxEventsFunc: relayAll(&accepted)}, Options{})
+
+		var first, second []int64
+		relay.Register("first", PublisherFunc(func(ctx context.Context, event *store.OutboxEvent) error {
+			first = append(first, event.ID)
+			return nil
+		}))
+		relay.Register("second", PublisherFunc(func(ctx context.Context, event *store.OutboxEvent) error {
+			second = append(second, event.ID)
+			return nil
+		}))
+
+		relayed, err := relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, relayed)
+		assert.Equal(t, []int64{1, 2}, first)
+		assert.Equal(t, []int64{1, 2}, second)
+		assert.Equal(t, []int64{1, 2}, accepted)
+	})
+
+	t.Run("it should not accept an event a publisher failed", func(t *testing.T) {
+		var accepted []int64
+		relay := NewRelay(&store.MockOutboxStore{RelayOutboxEventsFunc: relayAll(&accepted)}, Options{})
+
+		var later []int64
+		relay.Register("flaky", PublisherFunc(func(ctx context.Context, event *store.OutboxEvent) error {
+			if event.AggregateType == "rental" {
+				return errors.New("unavailable")
+			}
+			return nil
+		}))
+		relay.Register("later", PublisherFunc(func(ctx context.Context, event *store.OutboxEvent) error {
+			later = append(later, event.ID)
+			return nil
+		}))
+
+		_, err := relay.RelayPending(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2}, accepted)
+		assert.Equal(t, []int64{2}, later)
+	})
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+type ActorStore struct {
+	db *db.DB
+}
+
+func NewActorStore(db *db.DB) *ActorStore {
+	return &ActorStore{db: db}
+}
+
+type Actor struct {
+	ID         int       `json:"id"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+const actorColumns = `actor.actor_id, first_name, last_name, actor.last_update`
+
+// GetActorsByIDs returns the actors with the given IDs in no particular order.
+func (s *ActorStore) GetActorsByIDs(ctx context.Context, ids []int64) ([]Actor, error) {
+	defer metrics.ObserveQuery("ActorStore.GetActorsByIDs")()
+
+	query := `SELECT ` + actorColumns + ` FROM actor WHERE actor_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(ids)}, scanActor)
+}
+
+// GetActorsByFilmIDs returns the cast of each film, ordered by name.
+func (s *ActorStore) GetActorsByFilmIDs(ctx context.Context, filmIDs []int64) (map[int64][]Actor, error) {
+	defer metrics.ObserveQuery("ActorStore.GetActorsByFilmIDs")()
+
+	query := `
+		SELECT film_actor.film_id, ` + actorColumns + `
+		FROM actor
+		JOIN film_actor USING (actor_id)
+		WHERE film_actor.film_id = ANY($1)
+		ORDER BY last_name, first_name, actor_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return groupRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(filmIDs)}, scanActor)
+}
+
+func scanActor(row rowScanner) (*Actor, error) {
+	var actor Actor
+	err := row.Scan(&actor.ID, &actor.FirstName, &actor.LastName, &actor.LastUpdate)
+	if err != nil {
+		return nil, err
+	}
+	return &actor, nil
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+)
+
+type auditContextKey struct{}
+
+// AuditActor describes who performed a mutation. It travels in the request
+// context so store methods can record it alongside the change.
+type AuditActor struct {
+	UserID    *int
+	Role      string
+	RequestID string
+	IP        string
+}
+
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, actor)
+}
+
+func AuditActorFromContext(ctx context.Context) AuditActor {
+	actor, _ := ctx.Value(auditContextKey{}).(AuditActor)
+	return actor
+}
+
+type AuditStore struct {
+	db *db.DB
+}
+
+func NewAuditStore(db *db.DB) *AuditStore {
+	return &AuditStore{db: db}
+}
+
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type AuditEntry struct {
+	ID          int64                  `json:"id"`
+	ActorUserID *int                   `json:"actor_user_id"`
+	ActorRole   string                 `json:"actor_role"`
+	RequestID   string                 `json:"request_id"`
+	Action      string                 `json:"action"`
+	EntityType  string                 `json:"entity_type"`
+	EntityID    int64                  `json:"entity_id"`
+	Diff        map[string]AuditChange `json:"diff"`
+	IP          string                 `json:"ip"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+type AuditFilter struct {
+	ActorUserID *int64
+	Action      string
+	EntityType  string
+	EntityID    *int64
+	From        *time.Time
+	To          *time.Time
+	Limit       int
+	Offset      int
+}
+
+func (s *AuditStore) ListAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	defer metrics.ObserveQuery("AuditStore.ListAuditEntries")()
+
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorUserID != nil {
+		addCondition("actor_user_id = $%d", *filter.ActorUserID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		addCondition("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		addCondition("entity_id = $%d", *filter.EntityID)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	query := `
+		SELECT id, actor_user_id, actor_role, request_id, action, entity_type, entity_id, diff, ip, created_at
+		FROM audit_log
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var actorUserID sql.NullInt64
+		var diff []byte
+		err := rows.Scan(&entry.ID, &actorUserID, &entry.ActorRole, &entry.RequestID, &entry.Action, &entry.EntityType, &entry.EntityID, &diff, &entry.IP, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if actorUserID.Valid {
+			actorUserIDInt := int(actorUserID.Int64)
+			entry.ActorUserID = &actorUserIDInt
+		}
+		if err := json.Unmarshal(diff, &entry.Diff); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// recordAudit writes an audit entry for a mutation inside the transaction that
+// performs it, so the entry is only kept when the change is committed.
+func recordAudit(ctx context.Context, tx *sql.Tx, action, entityType string, entityID int64, before, after any) error {
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	actor := AuditActorFromContext(ctx)
+	query := `
+		INSERT INTO audit_log (actor_user_id, actor_role, request_id, action, entity_type, entity_id, diff, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err = tx.ExecContext(ctx, query, actor.UserID, actor.Role, actor.RequestID, action, entityType, entityID, diffJSON, actor.IP)
+	return err
+}
+
+// auditDiff returns the JSON fields that differ between before and after.
+// Either side may be nil for creations and deletions.
+func auditDiff(before, after any) (map[string]AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]AuditChange{}
+	for field, value := range afterFields {
+		if previous, ok := beforeFields[field]; !ok || !reflect.DeepEqual(previous, value) {
+			diff[field] = AuditChange{Before: beforeFields[field], After: value}
+		}
+	}
+	for field, value := range beforeFields {
+		if _, ok := afterFields[field]; !ok {
+			diff[field] = AuditChange{Before: value}
+		}
+	}
+
+	return diff, nil
+}
+
+func auditFields(value any) (map[string]any, error) {
+	fields := map[string]any{}
+	if value == nil {
+		return fields, nil
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
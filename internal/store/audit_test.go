@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *AuditStore
+	customers   *CustomerStore
+	ctx         context.Context
+}
+
+func (suite *AuditTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+	suite.repository = NewAuditStore(suite.pgContainer.DB)
+	suite.customers = NewCustomerStore(suite.pgContainer.DB)
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (suite *AuditTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *AuditTestSuite) TestRecordAudit() {
+	suite.T().Run("it should record the actor and diff of a created customer", func(t *testing.T) {
+		ctx := WithAuditActor(suite.ctx, AuditActor{
+			Role:      "admin",
+			RequestID: "request-1",
+			IP:        "203.0.113.7",
+		})
+
+		customer := &Customer{
+			StoreID:   1,
+			FirstName: "Audit",
+			LastName:  "Trail",
+			Email:     "audit@example.com",
+		}
+		err := suite.customers.CreateCustomer(ctx, customer)
+		suite.NoError(err)
+
+		entityID := int64(customer.ID)
+		entries, err := suite.repository.ListAuditEntries(suite.ctx, AuditFilter{EntityType: "customer", EntityID: &entityID, Limit: 10})
+		suite.NoError(err)
+		suite.Len(entries, 1)
+		suite.Equal("create", entries[0].Action)
+		suite.Equal("admin", entries[0].ActorRole)
+		suite.Equal("request-1", entries[0].RequestID)
+		suite.Equal("203.0.113.7", entries[0].IP)
+		suite.Nil(entries[0].Diff["email"].Before)
+		suite.Equal("audit@example.com", entries[0].Diff["email"].After)
+	})
+
+	suite.T().Run("it should not record an entry when the change is rolled back", func(t *testing.T) {
+		err := suite.customers.CreateCustomer(suite.ctx, &Customer{
+			StoreID:   1,
+			FirstName: "Audit",
+			LastName:  "Trail",
+			Email:     "audit@example.com",
+		})
+		suite.Error(err)
+
+		entries, err := suite.repository.ListAuditEntries(suite.ctx, AuditFilter{EntityType: "customer", Limit: 10})
+		suite.NoError(err)
+		suite.Len(entries, 1)
+	})
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := &Customer{ID: 1, FirstName: "John", LastName: "Doe"}
+	after := &Customer{ID: 1, FirstName: "Jane", LastName: "Doe"}
+
+	diff, err := auditDiff(before, after)
+	assert.NoError(t, err)
+	assert.Len(t, diff, 1)
+	assert.Equal(t, AuditChange{Before: "John", After: "Jane"}, diff["first_name"])
+
+	diff, err = auditDiff(nil, after)
+	assert.NoError(t, err)
+	assert.Nil(t, diff["last_name"].Before)
+	assert.Equal(t, "Doe", diff["last_name"].After)
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+type CategoryStore struct {
+	db *db.DB
+}
+
+func NewCategoryStore(db *db.DB) *CategoryStore {
+	return &CategoryStore{db: db}
+}
+
+type Category struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+const categoryColumns = `category.category_id, name, category.last_update`
+
+// ListCategories returns all categories ordered by name.
+func (s *CategoryStore) ListCategories(ctx context.Context) ([]Category, error) {
+	defer metrics.ObserveQuery("CategoryStore.ListCategories")()
+
+	query := `SELECT ` + categoryColumns + ` FROM category ORDER BY name`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, nil, scanCategory)
+}
+
+// GetCategoriesByFilmIDs returns the categories of each film, ordered by
+// name.
+func (s *CategoryStore) GetCategoriesByFilmIDs(ctx context.Context, filmIDs []int64) (map[int64][]Category, error) {
+	defer metrics.ObserveQuery("CategoryStore.GetCategoriesByFilmIDs")()
+
+	query := `
+		SELECT film_category.film_id, ` + categoryColumns + `
+		FROM category
+		JOIN film_category USING (category_id)
+		WHERE film_category.film_id = ANY($1)
+		ORDER BY name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return groupRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(filmIDs)}, scanCategory)
+}
+
+func scanCategory(row rowScanner) (*Category, error) {
+	var category Category
+	err := row.Scan(&category.ID, &category.Name, &category.LastUpdate)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
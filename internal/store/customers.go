@@ -4,28 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
 )
 
 type CustomerStore struct {
-	db *sql.DB
+	db *db.DB
 }
 
-func NewCustomerStore(db *sql.DB) *CustomerStore {
+func NewCustomerStore(db *db.DB) *CustomerStore {
 	return &CustomerStore{db: db}
 }
 
 type Customer struct {
-	ID        int    `json:"id"`
-	UserID    *int   `json:"user_id"`
-	StoreID   int64  `json:"store_id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	Phone     string `json:"phone"`
-	Address   string `json:"address"`
+	ID         int       `json:"id"`
+	UserID     *int      `json:"user_id"`
+	StoreID    int64     `json:"store_id"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	Address    string    `json:"address"`
+	Active     bool      `json:"active"`
+	LastUpdate time.Time `json:"last_update"`
 }
 
 func (s *CustomerStore) GetCustomerByEmail(ctx context.Context, email string) (*Customer, error) {
+	defer metrics.ObserveQuery("CustomerStore.GetCustomerByEmail")()
+
 	query := `
 		SELECT customer_id, user_id
 		FROM customer
@@ -53,15 +62,188 @@ func (s *CustomerStore) GetCustomerByEmail(ctx context.Context, email string) (*
 }
 
 func (s *CustomerStore) CreateCustomer(ctx context.Context, customer *Customer) error {
-	query := `
-		INSERT INTO customer (store_id, first_name, last_name, email)
-		VALUES ($1, $2, $3, $4)
-	`
+	defer metrics.ObserveQuery("CustomerStore.CreateCustomer")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			INSERT INTO customer (store_id, first_name, last_name, email)
+			VALUES ($1, $2, $3, $4)
+			RETURNING customer_id
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, customer.StoreID, customer.FirstName, customer.LastName, customer.Email).Scan(&customer.ID)
+		if err != nil {
+			return translateError(err)
+		}
+
+		if err := recordAudit(ctx, tx, "create", "customer", int64(customer.ID), nil, customer); err != nil {
+			return err
+		}
 
-	_, err := s.db.ExecContext(ctx, query, customer.StoreID, customer.FirstName, customer.LastName, customer.Email)
+		return recordEvent(ctx, tx, EventCustomerCreated, "customer", int64(customer.ID), customer)
+	})
+}
+
+func (s *CustomerStore) GetCustomerByID(ctx context.Context, id int64) (*Customer, error) {
+	defer metrics.ObserveQuery("CustomerStore.GetCustomerByID")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return scanCustomer(s.db.Reader(ctx).QueryRowContext(ctx, customerByIDQuery, id))
+}
+
+// UpdateCustomer saves customer if its last_update still matches
+// expectedVersion, and returns ErrVersionMismatch otherwise. A nil
+// expectedVersion updates unconditionally. On success customer holds the new
+// last_update.
+func (s *CustomerStore) UpdateCustomer(ctx context.Context, customer *Customer, expectedVersion *time.Time) error {
+	defer metrics.ObserveQuery("CustomerStore.UpdateCustomer")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		before, err := scanCustomer(tx.QueryRowContext(ctx, customerByIDQuery+" FOR UPDATE", customer.ID))
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && !SameVersion(before.LastUpdate, *expectedVersion) {
+			return ErrVersionMismatch
+		}
+
+		query := `
+			UPDATE customer
+			SET store_id = $2, first_name = $3, last_name = $4, email = $5, active = $6, activebool = $7, last_update = now()
+			WHERE customer_id = $1
+			RETURNING user_id, last_update
+		`
+
+		active := 0
+		if customer.Active {
+			active = 1
+		}
+
+		var userID sql.NullInt64
+		err = tx.QueryRowContext(ctx, query, customer.ID, customer.StoreID, customer.FirstName, customer.LastName, customer.Email, active, customer.Active).
+			Scan(&userID, &customer.LastUpdate)
+		if err != nil {
+			return translateError(err)
+		}
+		if userID.Valid {
+			userIDInt := int(userID.Int64)
+			customer.UserID = &userIDInt
+		}
+
+		if err := recordAudit(ctx, tx, "update", "customer", int64(customer.ID), before, customer); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, EventCustomerUpdated, "customer", int64(customer.ID), customer)
+	})
+}
+
+const customerSelect = `
+	SELECT customer_id, user_id, store_id, first_name, last_name, COALESCE(email, ''), COALESCE(active, 0) = 1, COALESCE(last_update, create_date)
+	FROM customer
+`
+
+const customerByIDQuery = customerSelect + `WHERE customer_id = $1`
+
+// GetCustomersByIDs returns the customers with the given IDs in no particular
+// order.
+func (s *CustomerStore) GetCustomersByIDs(ctx context.Context, ids []int64) ([]Customer, error) {
+	defer metrics.ObserveQuery("CustomerStore.GetCustomersByIDs")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), customerSelect+`WHERE customer_id = ANY($1)`, []any{pq.Int64Array(ids)}, scanCustomer)
+}
+
+// CustomerList is the list query whitelist for customers.
+var CustomerList = &listquery.Resource{
+	Fields: map[string]listquery.Field{
+		"id":          {Column: "customer_id", Type: listquery.Int, Filter: true, Sort: true},
+		"store_id":    {Column: "store_id", Type: listquery.Int, Filter: true},
+		"first_name":  {Column: "first_name", Type: listquery.String, Filter: true, Sort: true},
+		"last_name":   {Column: "last_name", Type: listquery.String, Filter: true, Sort: true},
+		"email":       {Column: "email", Type: listquery.String, Filter: true},
+		"active":      {Column: "(COALESCE(active, 0) = 1)", Type: listquery.Bool, Filter: true},
+		"last_update": {Column: "COALESCE(last_update, create_date)", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Key:          "id",
+	DefaultSort:  "last_name,first_name",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// ListCustomers returns a page of customers and the cursor of the next page.
+func (s *CustomerStore) ListCustomers(ctx context.Context, q *listquery.Query) ([]Customer, string, error) {
+	defer metrics.ObserveQuery("CustomerStore.ListCustomers")()
+
+	fragments := q.Build()
+	query := customerSelect + fragments.Where + fragments.OrderBy + fragments.Limit
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Reader(ctx).QueryContext(ctx, query, fragments.Args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	customers := []Customer{}
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		customers = append(customers, *customer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return listquery.Page(q, customers, func(customer Customer, field string) any {
+		switch field {
+		case "first_name":
+			return customer.FirstName
+		case "last_name":
+			return customer.LastName
+		case "last_update":
+			return customer.LastUpdate
+		default:
+			return customer.ID
+		}
+	})
+}
+
+func scanCustomer(row rowScanner) (*Customer, error) {
+	var customer Customer
+	var userID sql.NullInt64
+	err := row.Scan(
+		&customer.ID,
+		&userID,
+		&customer.StoreID,
+		&customer.FirstName,
+		&customer.LastName,
+		&customer.Email,
+		&customer.Active,
+		&customer.LastUpdate,
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if userID.Valid {
+		userIDInt := int(userID.Int64)
+		customer.UserID = &userIDInt
+	}
+
+	return &customer, nil
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
 	_ "github.com/lib/pq"
@@ -90,3 +91,41 @@ func (suite *CustomersTestSuite) TestCreatCustomer() {
 		suite.Nil(createdCustomer.UserID)
 	})
 }
+
+func (suite *CustomersTestSuite) TestUpdateCustomer() {
+	suite.T().Run("it should update a customer when the version matches", func(t *testing.T) {
+		customer, err := suite.repository.GetCustomerByID(suite.ctx, 11)
+		suite.NoError(err)
+		suite.Equal("Lisa", customer.FirstName)
+
+		version := customer.LastUpdate
+		customer.FirstName = "Elisabeth"
+		err = suite.repository.UpdateCustomer(suite.ctx, customer, &version)
+		suite.NoError(err)
+		suite.False(SameVersion(version, customer.LastUpdate))
+
+		updated, err := suite.repository.GetCustomerByID(suite.ctx, 11)
+		suite.NoError(err)
+		suite.Equal("Elisabeth", updated.FirstName)
+		suite.True(SameVersion(customer.LastUpdate, updated.LastUpdate))
+	})
+
+	suite.T().Run("it should reject an update based on a stale version", func(t *testing.T) {
+		customer, err := suite.repository.GetCustomerByID(suite.ctx, 12)
+		suite.NoError(err)
+
+		stale := customer.LastUpdate.Add(-time.Second)
+		customer.FirstName = "Stale"
+		err = suite.repository.UpdateCustomer(suite.ctx, customer, &stale)
+		suite.ErrorIs(err, ErrVersionMismatch)
+
+		unchanged, err := suite.repository.GetCustomerByID(suite.ctx, 12)
+		suite.NoError(err)
+		suite.NotEqual("Stale", unchanged.FirstName)
+	})
+
+	suite.T().Run("it should return sql.ErrNoRows for a missing customer", func(t *testing.T) {
+		err := suite.repository.UpdateCustomer(suite.ctx, &Customer{ID: 100000}, nil)
+		suite.ErrorIs(err, sql.ErrNoRows)
+	})
+}
@@ -0,0 +1,462 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+)
+
+// Dunning case statuses. An open case escalates until the rental is
+// returned, the copy is declared lost or an admin pauses or waives it.
+const (
+	DunningOpen     = "open"
+	DunningPaused   = "paused"
+	DunningLost     = "lost"
+	DunningWaived   = "waived"
+	DunningReturned = "returned"
+)
+
+// Dunning steps recorded for a rental.
+const (
+	DunningStepOpened          = "opened"
+	DunningStepReminder        = "reminder"
+	DunningStepCheckoutBlocked = "checkout_blocked"
+	DunningStepLost            = "lost"
+	DunningStepPaused          = "paused"
+	DunningStepResumed         = "resumed"
+	DunningStepWaived          = "waived"
+	DunningStepReturned        = "returned"
+)
+
+// ChargeReasonReplacement is the reason of the charge for a lost copy.
+const ChargeReasonReplacement = "replacement"
+
+// dunningTransitions lists the statuses an admin step applies to and the
+// status it leads to.
+var dunningTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	DunningStepPaused:  {from: []string{DunningOpen}, to: DunningPaused},
+	DunningStepResumed: {from: []string{DunningPaused}, to: DunningOpen},
+	DunningStepWaived:  {from: []string{DunningOpen, DunningPaused, DunningLost}, to: DunningWaived},
+}
+
+type DunningStore struct {
+	db *db.DB
+}
+
+func NewDunningStore(db *db.DB) *DunningStore {
+	return &DunningStore{db: db}
+}
+
+// DunningCase tracks the reminders and escalation of an overdue rental. A
+// blocked case keeps the customer from checking out while it is open, paused
+// or lost.
+type DunningCase struct {
+	RentalID        int           `json:"rental_id"`
+	CustomerID      int           `json:"customer_id"`
+	CustomerEmail   string        `json:"customer_email"`
+	CustomerName    string        `json:"customer_name"`
+	FilmTitle       string        `json:"film_title"`
+	ReplacementCost float64       `json:"replacement_cost"`
+	DueDate         time.Time     `json:"due_date"`
+	OverdueFor      time.Duration `json:"-"`
+	Status          string        `json:"status"`
+	RemindersSent   int           `json:"reminders_sent"`
+	BlockedAt       *time.Time    `json:"blocked_at"`
+	LostAt          *time.Time    `json:"lost_at"`
+	OpenedAt        time.Time     `json:"opened_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	Steps           []DunningStep `json:"steps,omitempty"`
+}
+
+// DunningStep is an entry in the history of a dunning case. ActorUserID is
+// set for steps taken by an admin.
+type DunningStep struct {
+	ID          int64     `json:"id"`
+	Step        string    `json:"step"`
+	Reminder    *int      `json:"reminder"`
+	Note        *string   `json:"note"`
+	ActorUserID *int      `json:"actor_user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CustomerCharge is an amount a customer owes on top of their payments.
+type CustomerCharge struct {
+	ID         int64      `json:"id"`
+	CustomerID int        `json:"customer_id"`
+	RentalID   int        `json:"rental_id"`
+	Reason     string     `json:"reason"`
+	Amount     float64    `json:"amount"`
+	CreatedAt  time.Time  `json:"created_at"`
+	VoidedAt   *time.Time `json:"voided_at"`
+}
+
+// dunningCaseSelect reads cases with what reminders need to know about the
+// rental. OverdueFor is computed by the database, so it does not depend on
+// the clock of the replica.
+const dunningCaseSelect = `
+	SELECT d.rental_id, d.customer_id, c.email, c.first_name || ' ' || c.last_name, f.title, f.replacement_cost,
+		r.rental_date + f.rental_duration * INTERVAL '1 day',
+		EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - (r.rental_date + f.rental_duration * INTERVAL '1 day'))::bigint,
+		d.status, d.reminders_sent, d.blocked_at, d.lost_at, d.opened_at, d.updated_at
+	FROM dunning_cases d
+	JOIN rental r ON r.rental_id = d.rental_id
+	JOIN inventory i ON i.inventory_id = r.inventory_id
+	JOIN film f ON f.film_id = i.film_id
+	JOIN customer c ON c.customer_id = d.customer_id
+`
+
+const dunningStepColumns = `id, step, reminder, note, actor_user_id, created_at`
+
+// OpenDunningCases opens a case for every overdue rental that has none and
+// returns how many it opened.
+func (s *DunningStore) OpenDunningCases(ctx context.Context) (int64, error) {
+	defer metrics.ObserveQuery("DunningStore.OpenDunningCases")()
+
+	query := `
+		WITH opened AS (
+			INSERT INTO dunning_cases (rental_id, customer_id)
+			SELECT rental_id, customer_id
+			FROM rental
+			WHERE overdue_at IS NOT NULL AND return_date IS NULL
+			ON CONFLICT (rental_id) DO NOTHING
+			RETURNING rental_id
+		)
+		INSERT INTO dunning_steps (rental_id, step)
+		SELECT rental_id, $1 FROM opened
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, DunningStepOpened)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListOpenDunningCases returns up to limit open cases with a rental ID above
+// afterRentalID, in rental ID order.
+func (s *DunningStore) ListOpenDunningCases(ctx context.Context, afterRentalID int64, limit int) ([]DunningCase, error) {
+	defer metrics.ObserveQuery("DunningStore.ListOpenDunningCases")()
+
+	query := dunningCaseSelect + `WHERE d.status = $1 AND d.rental_id > $2 ORDER BY d.rental_id LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db, query, []any{DunningOpen, afterRentalID, limit}, scanDunningCase)
+}
+
+// GetDunningCase returns the case of a rental with its steps, oldest first.
+func (s *DunningStore) GetDunningCase(ctx context.Context, rentalID int64) (*DunningCase, error) {
+	defer metrics.ObserveQuery("DunningStore.GetDunningCase")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	dunningCase, err := scanDunningCase(s.db.QueryRowContext(ctx, dunningCaseSelect+`WHERE d.rental_id = $1`, rentalID))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + dunningStepColumns + ` FROM dunning_steps WHERE rental_id = $1 ORDER BY id`
+	dunningCase.Steps, err = collectRows(ctx, s.db, query, []any{rentalID}, scanDunningStep)
+	if err != nil {
+		return nil, err
+	}
+	return dunningCase, nil
+}
+
+// RecordDunningReminder records that reminder number reminder was sent for
+// an open case. It returns sql.ErrNoRows if the case is no longer open or
+// the reminder was already recorded.
+func (s *DunningStore) RecordDunningReminder(ctx context.Context, rentalID int64, reminder int) error {
+	defer metrics.ObserveQuery("DunningStore.RecordDunningReminder")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `
+			UPDATE dunning_cases
+			SET reminders_sent = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE rental_id = $1 AND status = $3 AND reminders_sent < $2
+			RETURNING rental_id
+		`
+		if err := tx.QueryRowContext(ctx, query, rentalID, reminder, DunningOpen).Scan(new(int64)); err != nil {
+			return err
+		}
+
+		return recordDunningStep(ctx, tx, rentalID, DunningStepReminder, &reminder, "")
+	})
+}
+
+// BlockDunningCustomer blocks checkout for the customer of an open case. It
+// returns sql.ErrNoRows if the case is no longer open or already blocking.
+func (s *DunningStore) BlockDunningCustomer(ctx context.Context, rentalID int64) error {
+	defer metrics.ObserveQuery("DunningStore.BlockDunningCustomer")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `
+			UPDATE dunning_cases
+			SET blocked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE rental_id = $1 AND status = $2 AND blocked_at IS NULL
+			RETURNING rental_id
+		`
+		if err := tx.QueryRowContext(ctx, query, rentalID, DunningOpen).Scan(new(int64)); err != nil {
+			return err
+		}
+
+		return recordDunningStep(ctx, tx, rentalID, DunningStepCheckoutBlocked, nil, "")
+	})
+}
+
+// MarkDunningRentalLost declares the copy of an open case lost, keeps the
+// customer blocked and charges them the film's replacement cost. It returns
+// sql.ErrNoRows if the case is no longer open.
+func (s *DunningStore) MarkDunningRentalLost(ctx context.Context, rentalID int64) (*CustomerCharge, error) {
+	defer metrics.ObserveQuery("DunningStore.MarkDunningRentalLost")()
+
+	var charge *CustomerCharge
+	err := withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `
+			UPDATE dunning_cases
+			SET status = $2, lost_at = CURRENT_TIMESTAMP, blocked_at = COALESCE(blocked_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP
+			WHERE rental_id = $1 AND status = $3
+			RETURNING rental_id
+		`
+		if err := tx.QueryRowContext(ctx, query, rentalID, DunningLost, DunningOpen).Scan(new(int64)); err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE inventory i
+			SET lost_at = CURRENT_TIMESTAMP
+			FROM rental r
+			WHERE r.rental_id = $1 AND i.inventory_id = r.inventory_id
+		`
+		if _, err := tx.ExecContext(ctx, query, rentalID); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO customer_charges (customer_id, rental_id, reason, amount)
+			SELECT r.customer_id, r.rental_id, $2, f.replacement_cost
+			FROM rental r
+			JOIN inventory i ON i.inventory_id = r.inventory_id
+			JOIN film f ON f.film_id = i.film_id
+			WHERE r.rental_id = $1
+			RETURNING id, customer_id, rental_id, reason, amount, created_at, voided_at
+		`
+		var err error
+		charge, err = scanCustomerCharge(tx.QueryRowContext(ctx, query, rentalID, ChargeReasonReplacement))
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, "create", "customer_charge", charge.ID, nil, charge); err != nil {
+			return err
+		}
+
+		return recordDunningStep(ctx, tx, rentalID, DunningStepLost, nil, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return charge, nil
+}
+
+// UpdateDunningCase applies an admin step, DunningStepPaused,
+// DunningStepResumed or DunningStepWaived, to the case of a rental. A paused
+// case stops escalating but keeps blocking checkout. Waiving ends the case,
+// lifts the block and voids the replacement charge.
+func (s *DunningStore) UpdateDunningCase(ctx context.Context, rentalID int64, step, note string) (*DunningCase, error) {
+	defer metrics.ObserveQuery("DunningStore.UpdateDunningCase")()
+
+	transition, ok := dunningTransitions[step]
+	if !ok {
+		return nil, ErrDunningStepInvalid
+	}
+
+	err := withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		var before string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM dunning_cases WHERE rental_id = $1 FOR UPDATE`, rentalID).Scan(&before)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(transition.from, before) {
+			return ErrDunningStepInvalid
+		}
+
+		query := `UPDATE dunning_cases SET status = $2, updated_at = CURRENT_TIMESTAMP WHERE rental_id = $1`
+		if _, err := tx.ExecContext(ctx, query, rentalID, transition.to); err != nil {
+			return err
+		}
+		if step == DunningStepWaived {
+			if err := voidRentalCharges(ctx, tx, rentalID); err != nil {
+				return err
+			}
+		}
+
+		if err := recordAudit(ctx, tx, step, "dunning_case", rentalID, map[string]string{"status": before}, map[string]string{"status": transition.to}); err != nil {
+			return err
+		}
+
+		return recordDunningStep(ctx, tx, rentalID, step, nil, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetDunningCase(ctx, rentalID)
+}
+
+// closeDunningCase ends the case of a rental that was returned. A copy
+// declared lost is back in stock and its replacement charge is voided.
+func closeDunningCase(ctx context.Context, tx *sql.Tx, rentalID int64) error {
+	query := `
+		UPDATE dunning_cases
+		SET status = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE rental_id = $1 AND status IN ($3, $4, $5)
+		RETURNING lost_at
+	`
+	var lostAt sql.NullTime
+	err := tx.QueryRowContext(ctx, query, rentalID, DunningReturned, DunningOpen, DunningPaused, DunningLost).Scan(&lostAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if lostAt.Valid {
+		query := `
+			UPDATE inventory i
+			SET lost_at = NULL
+			FROM rental r
+			WHERE r.rental_id = $1 AND i.inventory_id = r.inventory_id
+		`
+		if _, err := tx.ExecContext(ctx, query, rentalID); err != nil {
+			return err
+		}
+		if err := voidRentalCharges(ctx, tx, rentalID); err != nil {
+			return err
+		}
+	}
+
+	return recordDunningStep(ctx, tx, rentalID, DunningStepReturned, nil, "")
+}
+
+// customerCheckoutBlocked reports whether a dunning case blocks the customer
+// from renting.
+func customerCheckoutBlocked(ctx context.Context, tx *sql.Tx, customerID int) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM dunning_cases
+			WHERE customer_id = $1 AND blocked_at IS NOT NULL AND status IN ($2, $3, $4)
+		)
+	`
+	var blocked bool
+	err := tx.QueryRowContext(ctx, query, customerID, DunningOpen, DunningPaused, DunningLost).Scan(&blocked)
+	return blocked, err
+}
+
+func voidRentalCharges(ctx context.Context, tx *sql.Tx, rentalID int64) error {
+	query := `
+		UPDATE customer_charges
+		SET voided_at = CURRENT_TIMESTAMP
+		WHERE rental_id = $1 AND reason = $2 AND voided_at IS NULL
+	`
+	_, err := tx.ExecContext(ctx, query, rentalID, ChargeReasonReplacement)
+	return err
+}
+
+func recordDunningStep(ctx context.Context, tx *sql.Tx, rentalID int64, step string, reminder *int, note string) error {
+	var notePtr *string
+	if note != "" {
+		notePtr = &note
+	}
+
+	query := `
+		INSERT INTO dunning_steps (rental_id, step, reminder, note, actor_user_id)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := tx.ExecContext(ctx, query, rentalID, step, reminder, notePtr, AuditActorFromContext(ctx).UserID)
+	return err
+}
+
+func scanDunningCase(row rowScanner) (*DunningCase, error) {
+	var c DunningCase
+	var overdueSeconds int64
+	err := row.Scan(
+		&c.RentalID,
+		&c.CustomerID,
+		&c.CustomerEmail,
+		&c.CustomerName,
+		&c.FilmTitle,
+		&c.ReplacementCost,
+		&c.DueDate,
+		&overdueSeconds,
+		&c.Status,
+		&c.RemindersSent,
+		&c.BlockedAt,
+		&c.LostAt,
+		&c.OpenedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c.OverdueFor = time.Duration(overdueSeconds) * time.Second
+	return &c, nil
+}
+
+func scanDunningStep(row rowScanner) (*DunningStep, error) {
+	var step DunningStep
+	err := row.Scan(
+		&step.ID,
+		&step.Step,
+		&step.Reminder,
+		&step.Note,
+		&step.ActorUserID,
+		&step.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &step, nil
+}
+
+func scanCustomerCharge(row rowScanner) (*CustomerCharge, error) {
+	var charge CustomerCharge
+	err := row.Scan(
+		&charge.ID,
+		&charge.CustomerID,
+		&charge.RentalID,
+		&charge.Reason,
+		&charge.Amount,
+		&charge.CreatedAt,
+		&charge.VoidedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
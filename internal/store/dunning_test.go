@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type DunningTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *DunningStore
+	rentals     *RentalStore
+	ctx         context.Context
+}
+
+func (suite *DunningTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+	suite.repository = NewDunningStore(suite.pgContainer.DB)
+	suite.rentals = NewRentalStore(suite.pgContainer.DB)
+}
+
+func TestDunningTestSuite(t *testing.T) {
+	suite.Run(t, new(DunningTestSuite))
+}
+
+func (suite *DunningTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+// availableInventory returns an item that is in stock.
+func (suite *DunningTestSuite) availableInventory() int {
+	var inventoryID int
+	err := suite.pgContainer.DB.QueryRowContext(suite.ctx, `
+		SELECT i.inventory_id FROM inventory i
+		WHERE NOT EXISTS (SELECT 1 FROM rental r WHERE r.inventory_id = i.inventory_id AND r.return_date IS NULL)
+		ORDER BY i.inventory_id DESC LIMIT 1
+	`).Scan(&inventoryID)
+	suite.Require().NoError(err)
+	return inventoryID
+}
+
+func (suite *DunningTestSuite) TestDunningCases() {
+	_, err := suite.rentals.MarkOverdueRentals(suite.ctx)
+	suite.Require().NoError(err)
+
+	opened, err := suite.repository.OpenDunningCases(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Require().NotZero(opened)
+
+	cases, err := suite.repository.ListOpenDunningCases(suite.ctx, 0, 2)
+	suite.Require().NoError(err)
+	suite.Require().Len(cases, 2)
+	first, second := cases[0], cases[1]
+
+	suite.T().Run("it should open a case once per overdue rental", func(t *testing.T) {
+		opened, err := suite.repository.OpenDunningCases(suite.ctx)
+		suite.NoError(err)
+		suite.Zero(opened)
+
+		suite.Less(first.RentalID, second.RentalID)
+		suite.Equal(DunningOpen, first.Status)
+		suite.Positive(first.OverdueFor)
+		suite.NotEmpty(first.CustomerEmail)
+
+		next, err := suite.repository.ListOpenDunningCases(suite.ctx, int64(first.RentalID), 1)
+		suite.NoError(err)
+		suite.Equal(second.RentalID, next[0].RentalID)
+	})
+
+	suite.T().Run("it should record each reminder once", func(t *testing.T) {
+		suite.NoError(suite.repository.RecordDunningReminder(suite.ctx, int64(first.RentalID), 1))
+		suite.ErrorIs(suite.repository.RecordDunningReminder(suite.ctx, int64(first.RentalID), 1), sql.ErrNoRows)
+
+		dunningCase, err := suite.repository.GetDunningCase(suite.ctx, int64(first.RentalID))
+		suite.NoError(err)
+		suite.Equal(1, dunningCase.RemindersSent)
+		suite.Len(dunningCase.Steps, 2)
+		suite.Equal(DunningStepOpened, dunningCase.Steps[0].Step)
+		suite.Equal(DunningStepReminder, dunningCase.Steps[1].Step)
+		suite.Equal(1, *dunningCase.Steps[1].Reminder)
+	})
+
+	suite.T().Run("it should block checkout for the customer", func(t *testing.T) {
+		suite.NoError(suite.repository.BlockDunningCustomer(suite.ctx, int64(first.RentalID)))
+		suite.ErrorIs(suite.repository.BlockDunningCustomer(suite.ctx, int64(first.RentalID)), sql.ErrNoRows)
+
+		err := suite.rentals.CreateRental(suite.ctx, &Rental{InventoryID: suite.availableInventory(), CustomerID: first.CustomerID, StaffID: 1})
+		suite.ErrorIs(err, ErrCustomerCheckoutBlocked)
+	})
+
+	suite.T().Run("it should pause and resume a case", func(t *testing.T) {
+		dunningCase, err := suite.repository.UpdateDunningCase(suite.ctx, int64(first.RentalID), DunningStepPaused, "Customer called")
+		suite.NoError(err)
+		suite.Equal(DunningPaused, dunningCase.Status)
+		suite.Equal("Customer called", *dunningCase.Steps[len(dunningCase.Steps)-1].Note)
+
+		suite.ErrorIs(suite.repository.RecordDunningReminder(suite.ctx, int64(first.RentalID), 2), sql.ErrNoRows)
+		_, err = suite.repository.UpdateDunningCase(suite.ctx, int64(first.RentalID), DunningStepPaused, "")
+		suite.ErrorIs(err, ErrDunningStepInvalid)
+
+		dunningCase, err = suite.repository.UpdateDunningCase(suite.ctx, int64(first.RentalID), DunningStepResumed, "")
+		suite.NoError(err)
+		suite.Equal(DunningOpen, dunningCase.Status)
+	})
+
+	suite.T().Run("it should charge a lost copy and void the charge on waive", func(t *testing.T) {
+		charge, err := suite.repository.MarkDunningRentalLost(suite.ctx, int64(first.RentalID))
+		suite.NoError(err)
+		suite.Equal(first.ReplacementCost, charge.Amount)
+		suite.Equal(ChargeReasonReplacement, charge.Reason)
+
+		_, err = suite.repository.MarkDunningRentalLost(suite.ctx, int64(first.RentalID))
+		suite.ErrorIs(err, sql.ErrNoRows)
+
+		dunningCase, err := suite.repository.UpdateDunningCase(suite.ctx, int64(first.RentalID), DunningStepWaived, "")
+		suite.NoError(err)
+		suite.Equal(DunningWaived, dunningCase.Status)
+
+		var voided bool
+		err = suite.pgContainer.DB.QueryRowContext(suite.ctx, `SELECT voided_at IS NOT NULL FROM customer_charges WHERE id = $1`, charge.ID).Scan(&voided)
+		suite.NoError(err)
+		suite.True(voided)
+	})
+
+	suite.T().Run("it should close the case when the lost copy is returned", func(t *testing.T) {
+		charge, err := suite.repository.MarkDunningRentalLost(suite.ctx, int64(second.RentalID))
+		suite.Require().NoError(err)
+
+		_, err = suite.rentals.ReturnRental(suite.ctx, int64(second.RentalID))
+		suite.Require().NoError(err)
+
+		dunningCase, err := suite.repository.GetDunningCase(suite.ctx, int64(second.RentalID))
+		suite.NoError(err)
+		suite.Equal(DunningReturned, dunningCase.Status)
+		suite.Equal(DunningStepReturned, dunningCase.Steps[len(dunningCase.Steps)-1].Step)
+
+		var voided, lost bool
+		err = suite.pgContainer.DB.QueryRowContext(suite.ctx, `
+			SELECT c.voided_at IS NOT NULL, i.lost_at IS NOT NULL
+			FROM customer_charges c
+			JOIN rental r ON r.rental_id = c.rental_id
+			JOIN inventory i ON i.inventory_id = r.inventory_id
+			WHERE c.id = $1
+		`, charge.ID).Scan(&voided, &lost)
+		suite.NoError(err)
+		suite.True(voided)
+		suite.False(lost)
+	})
+
+	suite.T().Run("it should not find a rental without a case", func(t *testing.T) {
+		_, err := suite.repository.GetDunningCase(suite.ctx, 1<<30)
+		suite.ErrorIs(err, sql.ErrNoRows)
+	})
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/lib/pq"
+)
+
+// Domain errors returned by the store and handlers. Their codes are part of
+// the API and must not change.
+var (
+	ErrCustomerAlreadyRegistered = utils.NewError(utils.KindConflict, "customer_already_registered", "customer already registered")
+	ErrStaffAlreadyRegistered    = utils.NewError(utils.KindConflict, "staff_already_registered", "staff member already registered")
+	ErrNoAccountForEmail         = utils.NewError(utils.KindInvalid, "no_account_for_email", "email does not belong to a customer or staff member")
+	ErrInvalidCredentials        = utils.NewError(utils.KindUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrCustomerEmailTaken        = utils.NewError(utils.KindConflict, "customer_email_taken", "a customer with this email already exists")
+	ErrUsernameTaken             = utils.NewError(utils.KindConflict, "username_taken", "username is already taken")
+	ErrInvalidReference          = utils.NewError(utils.KindInvalid, "invalid_reference", "a referenced resource does not exist")
+	ErrVersionMismatch           = utils.NewError(utils.KindPreconditionFailed, "version_mismatch", "the resource was modified since it was read, fetch it again and retry")
+	ErrPreconditionRequired      = utils.NewError(utils.KindPreconditionRequired, "if_match_required", "the If-Match header is required to modify this resource")
+	ErrIdempotencyKeyInvalid     = utils.NewError(utils.KindInvalid, "idempotency_key_invalid", "Idempotency-Key must be between 1 and 255 characters")
+	ErrIdempotencyKeyInFlight    = utils.NewError(utils.KindConflict, "idempotency_key_in_flight", "a request with this Idempotency-Key is still being processed")
+	ErrIdempotencyKeyReused      = utils.NewError(utils.KindUnprocessable, "idempotency_key_reused", "Idempotency-Key was already used with a different request")
+	ErrInventoryUnavailable      = utils.NewError(utils.KindConflict, "inventory_unavailable", "the item is rented out")
+	ErrRentalAlreadyReturned     = utils.NewError(utils.KindConflict, "rental_already_returned", "the rental was already returned")
+	ErrJobRunning                = utils.NewError(utils.KindConflict, "job_running", "the job is already running")
+	ErrCustomerCheckoutBlocked   = utils.NewError(utils.KindConflict, "customer_checkout_blocked", "the customer has overdue rentals and cannot check out until they are returned")
+	ErrDunningStepInvalid        = utils.NewError(utils.KindConflict, "dunning_step_invalid", "the dunning case does not allow this step in its current status")
+)
+
+// constraintErrors maps unique constraints to the domain error reported when
+// they are violated.
+var constraintErrors = map[string]*utils.Error{
+	"customer_email_unique": ErrCustomerEmailTaken,
+	"users_username_key":    ErrUsernameTaken,
+}
+
+// translateError turns constraint violations into domain errors and returns
+// any other error unchanged.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case "23505":
+		if domainErr, ok := constraintErrors[pqErr.Constraint]; ok {
+			return domainErr.Wrap(err)
+		}
+	case "23503":
+		return ErrInvalidReference.Wrap(err)
+	}
+	return err
+}
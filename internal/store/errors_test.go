@@ -0,0 +1,33 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	t.Run("it should map known unique constraints to domain errors", func(t *testing.T) {
+		err := translateError(&pq.Error{Code: "23505", Constraint: "customer_email_unique"})
+		assert.ErrorIs(t, err, ErrCustomerEmailTaken)
+
+		var pqErr *pq.Error
+		assert.ErrorAs(t, err, &pqErr)
+	})
+
+	t.Run("it should map foreign key violations to invalid reference", func(t *testing.T) {
+		err := translateError(fmt.Errorf("insert: %w", &pq.Error{Code: "23503"}))
+		assert.ErrorIs(t, err, ErrInvalidReference)
+	})
+
+	t.Run("it should leave other errors unchanged", func(t *testing.T) {
+		original := errors.New("connection refused")
+		assert.Equal(t, original, translateError(original))
+
+		unknown := &pq.Error{Code: "23505", Constraint: "some_other_key"}
+		assert.Equal(t, error(unknown), translateError(unknown))
+	})
+}
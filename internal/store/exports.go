@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+)
+
+// exportBatchSize is the number of rows fetched from a server-side cursor at
+// a time.
+const exportBatchSize = 1000
+
+// ExportFilter selects the rows of an export by date, From inclusive and To
+// exclusive.
+type ExportFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+// streamQuery runs query through a server-side cursor in a read-only
+// transaction and calls fn for every row, so an export never holds more than
+// one batch in memory. It stops at the first error from fn or when ctx is
+// cancelled.
+//
+// Each DECLARE and FETCH round trip is recorded as a query of method. The
+// time spent in fn is not, because it depends on how fast the client reads
+// the export.
+func streamQuery[T any](ctx context.Context, db *sql.DB, method, query string, args []any, scan func(row rowScanner) (*T, error), fn func(item *T) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	observe := metrics.ObserveQuery(method)
+	_, err = tx.ExecContext(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...)
+	observe()
+	if err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM export_cursor", exportBatchSize)
+	for {
+		observe := metrics.ObserveQuery(method)
+		batch, err := collectRows(ctx, tx, fetch, nil, scan)
+		observe()
+		if err != nil {
+			return err
+		}
+
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+type FilmStore struct {
+	db *db.DB
+}
+
+func NewFilmStore(db *db.DB) *FilmStore {
+	return &FilmStore{db: db}
+}
+
+type Film struct {
+	ID              int       `json:"id"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	ReleaseYear     *int      `json:"release_year"`
+	LanguageID      int       `json:"language_id"`
+	RentalDuration  int       `json:"rental_duration"`
+	RentalRate      float64   `json:"rental_rate"`
+	Length          *int      `json:"length"`
+	ReplacementCost float64   `json:"replacement_cost"`
+	Rating          string    `json:"rating"`
+	SpecialFeatures []string  `json:"special_features"`
+	LastUpdate      time.Time `json:"last_update"`
+}
+
+// FilmList is the list query whitelist for films.
+var FilmList = &listquery.Resource{
+	Fields: map[string]listquery.Field{
+		"id":               {Column: "film_id", Type: listquery.Int, Filter: true, Sort: true},
+		"title":            {Column: "title", Type: listquery.String, Filter: true, Sort: true},
+		"release_year":     {Column: "release_year", Type: listquery.Int, Filter: true},
+		"language_id":      {Column: "language_id", Type: listquery.Int, Filter: true},
+		"rental_rate":      {Column: "rental_rate", Type: listquery.Float, Filter: true, Sort: true},
+		"length":           {Column: "length", Type: listquery.Int, Filter: true},
+		"replacement_cost": {Column: "replacement_cost", Type: listquery.Float, Filter: true, Sort: true},
+		"rating":           {Column: "rating", Type: listquery.String, Filter: true},
+		"last_update":      {Column: "last_update", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Key:          "id",
+	DefaultSort:  "title",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+const filmColumns = `
+	film.film_id, title, COALESCE(description, ''), release_year, language_id, rental_duration,
+	rental_rate, length, replacement_cost, COALESCE(rating, ''), COALESCE(special_features, '{}'), film.last_update
+`
+
+func (s *FilmStore) GetFilmByID(ctx context.Context, id int64) (*Film, error) {
+	defer metrics.ObserveQuery("FilmStore.GetFilmByID")()
+
+	query := `SELECT ` + filmColumns + ` FROM film WHERE film_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return scanFilm(s.db.Reader(ctx).QueryRowContext(ctx, query, id))
+}
+
+// ListFilms returns a page of films and the cursor of the next page.
+func (s *FilmStore) ListFilms(ctx context.Context, q *listquery.Query) ([]Film, string, error) {
+	defer metrics.ObserveQuery("FilmStore.ListFilms")()
+
+	fragments := q.Build()
+	query := `SELECT ` + filmColumns + ` FROM film` + fragments.Where + fragments.OrderBy + fragments.Limit
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Reader(ctx).QueryContext(ctx, query, fragments.Args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	films := []Film{}
+	for rows.Next() {
+		film, err := scanFilm(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		films = append(films, *film)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return listquery.Page(q, films, func(film Film, field string) any {
+		switch field {
+		case "title":
+			return film.Title
+		case "rental_rate":
+			return film.RentalRate
+		case "replacement_cost":
+			return film.ReplacementCost
+		case "last_update":
+			return film.LastUpdate
+		default:
+			return film.ID
+		}
+	})
+}
+
+// GetFilmsByIDs returns the films with the given IDs in no particular order.
+func (s *FilmStore) GetFilmsByIDs(ctx context.Context, ids []int64) ([]Film, error) {
+	defer metrics.ObserveQuery("FilmStore.GetFilmsByIDs")()
+
+	query := `SELECT ` + filmColumns + ` FROM film WHERE film_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(ids)}, scanFilm)
+}
+
+// GetFilmsByActorIDs returns the films of each actor, ordered by title.
+func (s *FilmStore) GetFilmsByActorIDs(ctx context.Context, actorIDs []int64) (map[int64][]Film, error) {
+	defer metrics.ObserveQuery("FilmStore.GetFilmsByActorIDs")()
+
+	query := `
+		SELECT film_actor.actor_id, ` + filmColumns + `
+		FROM film
+		JOIN film_actor USING (film_id)
+		WHERE film_actor.actor_id = ANY($1)
+		ORDER BY title, film_id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return groupRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(actorIDs)}, scanFilm)
+}
+
+func scanFilm(row rowScanner) (*Film, error) {
+	var film Film
+	var releaseYear, length sql.NullInt64
+	err := row.Scan(
+		&film.ID,
+		&film.Title,
+		&film.Description,
+		&releaseYear,
+		&film.LanguageID,
+		&film.RentalDuration,
+		&film.RentalRate,
+		&length,
+		&film.ReplacementCost,
+		&film.Rating,
+		(*pq.StringArray)(&film.SpecialFeatures),
+		&film.LastUpdate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if releaseYear.Valid {
+		year := int(releaseYear.Int64)
+		film.ReleaseYear = &year
+	}
+	if length.Valid {
+		minutes := int(length.Int64)
+		film.Length = &minutes
+	}
+
+	return &film, nil
+}
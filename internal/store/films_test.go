@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type FilmsTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *FilmStore
+	ctx         context.Context
+}
+
+func (suite *FilmsTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.pgContainer = pgContainer
+	suite.repository = NewFilmStore(suite.pgContainer.DB)
+}
+
+func TestFilmsTestSuite(t *testing.T) {
+	suite.Run(t, new(FilmsTestSuite))
+}
+
+func (suite *FilmsTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *FilmsTestSuite) TestGetFilmByID() {
+	suite.T().Run("it should return an existing film", func(t *testing.T) {
+		film, err := suite.repository.GetFilmByID(suite.ctx, 1)
+		suite.NoError(err)
+		suite.Equal("Academy Dinosaur", film.Title)
+		suite.Equal("PG", film.Rating)
+		suite.NotEmpty(film.SpecialFeatures)
+		suite.False(film.LastUpdate.IsZero())
+	})
+
+	suite.T().Run("it should return sql.ErrNoRows if the film does not exist", func(t *testing.T) {
+		film, err := suite.repository.GetFilmByID(suite.ctx, 100000)
+		suite.True(errors.Is(err, sql.ErrNoRows))
+		suite.Nil(film)
+	})
+}
+
+func (suite *FilmsTestSuite) TestListFilms() {
+	suite.T().Run("it should page through filtered films without gaps", func(t *testing.T) {
+		values := url.Values{"filter[rating]": {"PG"}, "sort": {"-rental_rate,title"}, "limit": {"25"}}
+		q, err := listquery.Parse(values, FilmList)
+		suite.Require().NoError(err)
+
+		seen := map[int]bool{}
+		for {
+			films, cursor, err := suite.repository.ListFilms(suite.ctx, q)
+			suite.Require().NoError(err)
+			for _, film := range films {
+				suite.Equal("PG", film.Rating)
+				suite.False(seen[film.ID])
+				seen[film.ID] = true
+			}
+			if cursor == "" {
+				break
+			}
+			values.Set("cursor", cursor)
+			q, err = listquery.Parse(values, FilmList)
+			suite.Require().NoError(err)
+		}
+
+		var count int
+		err = suite.pgContainer.DB.QueryRow("SELECT count(*) FROM film WHERE rating = 'PG'").Scan(&count)
+		suite.NoError(err)
+		suite.Len(seen, count)
+	})
+}
+
+func (suite *FilmsTestSuite) TestGetFilmsByActorIDs() {
+	suite.T().Run("it should group the films of several actors in one query", func(t *testing.T) {
+		films, err := suite.repository.GetFilmsByActorIDs(suite.ctx, []int64{1, 2, 100000})
+		suite.NoError(err)
+		suite.NotEmpty(films[1])
+		suite.NotEmpty(films[2])
+		suite.Empty(films[100000])
+	})
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+)
+
+type HealthStore struct {
+	db *db.DB
+}
+
+func NewHealthStore(db *db.DB) *HealthStore {
+	return &HealthStore{db: db}
+}
+
+func (s *HealthStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// MigrationVersion returns the version recorded by golang-migrate. A database
+// that was never migrated reports version 0.
+func (s *HealthStore) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	query := `
+		SELECT version, dirty
+		FROM schema_migrations
+		LIMIT 1
+	`
+
+	var version uint
+	var dirty bool
+	err := s.db.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.Is(err, sql.ErrNoRows) || (errors.As(err, &pqErr) && pqErr.Code == "42P01") {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return version, dirty, nil
+}
+
+func (s *HealthStore) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// Replica returns the outcome of the last read replica check.
+func (s *HealthStore) Replica() db.ReplicaStatus {
+	return s.db.ReplicaStatus()
+}
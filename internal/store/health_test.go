@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *HealthStore
+	ctx         context.Context
+}
+
+func (suite *HealthTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+	suite.repository = NewHealthStore(suite.pgContainer.DB)
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func (suite *HealthTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *HealthTestSuite) TestPing() {
+	suite.NoError(suite.repository.Ping(suite.ctx))
+	suite.GreaterOrEqual(suite.repository.Stats().OpenConnections, 1)
+}
+
+func (suite *HealthTestSuite) TestMigrationVersion() {
+	suite.T().Run("it should report version 0 without a migrations table", func(t *testing.T) {
+		version, dirty, err := suite.repository.MigrationVersion(suite.ctx)
+		suite.NoError(err)
+		suite.Equal(uint(0), version)
+		suite.False(dirty)
+	})
+
+	suite.T().Run("it should report the recorded version", func(t *testing.T) {
+		_, err := suite.pgContainer.DB.ExecContext(suite.ctx, `
+			CREATE TABLE schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL);
+			INSERT INTO schema_migrations (version, dirty) VALUES (6, false);
+		`)
+		suite.NoError(err)
+
+		version, dirty, err := suite.repository.MigrationVersion(suite.ctx)
+		suite.NoError(err)
+		suite.Equal(uint(6), version)
+		suite.False(dirty)
+	})
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+)
+
+type IdempotencyStore struct {
+	db *db.DB
+}
+
+func NewIdempotencyStore(db *db.DB) *IdempotencyStore {
+	return &IdempotencyStore{db: db}
+}
+
+// IdempotencyRecord is a request made with an Idempotency-Key. Status is nil
+// while the first request with the key is still being handled.
+type IdempotencyRecord struct {
+	UserID          int
+	Key             string
+	Method          string
+	Path            string
+	Fingerprint     string
+	Status          *int
+	ResponseHeaders map[string]string
+	ResponseBody    []byte
+	CreatedAt       time.Time
+}
+
+// BeginIdempotentRequest claims key for the user. It returns the new record
+// and true when the key was unused, or the existing record and false.
+func (s *IdempotencyStore) BeginIdempotentRequest(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, bool, error) {
+	defer metrics.ObserveQuery("IdempotencyStore.BeginIdempotentRequest")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO idempotency_keys (user_id, key, method, path, fingerprint)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING created_at
+	`
+
+	// The request holding the key may release it between the insert and the
+	// select, so the claim is retried once when the key is gone.
+	for attempt := 1; ; attempt++ {
+		err := s.db.QueryRowContext(ctx, query, record.UserID, record.Key, record.Method, record.Path, record.Fingerprint).Scan(&record.CreatedAt)
+		if err == nil {
+			return record, true, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, false, err
+		}
+
+		existing, err := s.getIdempotencyRecord(ctx, record.UserID, record.Key)
+		if err == sql.ErrNoRows && attempt == 1 {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return existing, false, nil
+	}
+}
+
+func (s *IdempotencyStore) getIdempotencyRecord(ctx context.Context, userID int, key string) (*IdempotencyRecord, error) {
+	query := `
+		SELECT user_id, key, method, path, fingerprint, status, response_headers, response_body, created_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+
+	var record IdempotencyRecord
+	var status sql.NullInt64
+	var headers []byte
+	err := s.db.QueryRowContext(ctx, query, userID, key).Scan(
+		&record.UserID,
+		&record.Key,
+		&record.Method,
+		&record.Path,
+		&record.Fingerprint,
+		&status,
+		&headers,
+		&record.ResponseBody,
+		&record.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.Valid {
+		statusInt := int(status.Int64)
+		record.Status = &statusInt
+	}
+	if err := json.Unmarshal(headers, &record.ResponseHeaders); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// CompleteIdempotentRequest stores the response to replay for retries.
+func (s *IdempotencyStore) CompleteIdempotentRequest(ctx context.Context, userID int, key string, status int, headers map[string]string, body []byte) error {
+	defer metrics.ObserveQuery("IdempotencyStore.CompleteIdempotentRequest")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status = $3, response_headers = $4, response_body = $5, completed_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND key = $2
+	`
+
+	_, err = s.db.ExecContext(ctx, query, userID, key, status, encodedHeaders, body)
+	return err
+}
+
+// ReleaseIdempotentRequest forgets a key whose request failed, so the client
+// can retry it.
+func (s *IdempotencyStore) ReleaseIdempotentRequest(ctx context.Context, userID int, key string) error {
+	defer metrics.ObserveQuery("IdempotencyStore.ReleaseIdempotentRequest")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND status IS NULL
+	`
+
+	_, err := s.db.ExecContext(ctx, query, userID, key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes completed keys created before
+// completedBefore and in-flight keys, left behind by a crashed request,
+// created before inFlightBefore.
+func (s *IdempotencyStore) DeleteExpiredIdempotencyKeys(ctx context.Context, completedBefore, inFlightBefore time.Time) (int64, error) {
+	defer metrics.ObserveQuery("IdempotencyStore.DeleteExpiredIdempotencyKeys")()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE (status IS NOT NULL AND created_at < $1)
+		   OR (status IS NULL AND created_at < $2)
+	`
+
+	result, err := s.db.ExecContext(ctx, query, completedBefore, inFlightBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type IdempotencyTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *IdempotencyStore
+	userID      int
+	ctx         context.Context
+}
+
+func (suite *IdempotencyTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+	suite.repository = NewIdempotencyStore(suite.pgContainer.DB)
+
+	err = suite.pgContainer.DB.QueryRowContext(suite.ctx, `
+		INSERT INTO users (username, role_id, password) VALUES ('idempotency', 1, '\x00') RETURNING id
+	`).Scan(&suite.userID)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func TestIdempotencyTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotencyTestSuite))
+}
+
+func (suite *IdempotencyTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *IdempotencyTestSuite) record(key string) *IdempotencyRecord {
+	return &IdempotencyRecord{
+		UserID:      suite.userID,
+		Key:         key,
+		Method:      http.MethodPost,
+		Path:        "/v1/customers",
+		Fingerprint: "fingerprint",
+	}
+}
+
+func (suite *IdempotencyTestSuite) TestIdempotentRequest() {
+	suite.T().Run("it should claim an unused key once", func(t *testing.T) {
+		record, created, err := suite.repository.BeginIdempotentRequest(suite.ctx, suite.record("key-1"))
+		suite.NoError(err)
+		suite.True(created)
+		suite.Nil(record.Status)
+
+		existing, created, err := suite.repository.BeginIdempotentRequest(suite.ctx, suite.record("key-1"))
+		suite.NoError(err)
+		suite.False(created)
+		suite.Nil(existing.Status)
+		suite.Equal("fingerprint", existing.Fingerprint)
+	})
+
+	suite.T().Run("it should return the stored response once completed", func(t *testing.T) {
+		err := suite.repository.CompleteIdempotentRequest(suite.ctx, suite.userID, "key-1", http.StatusCreated, map[string]string{"Content-Type": "application/json"}, []byte(`{}`))
+		suite.NoError(err)
+
+		existing, created, err := suite.repository.BeginIdempotentRequest(suite.ctx, suite.record("key-1"))
+		suite.NoError(err)
+		suite.False(created)
+		suite.Equal(http.StatusCreated, *existing.Status)
+		suite.Equal("application/json", existing.ResponseHeaders["Content-Type"])
+		suite.Equal([]byte(`{}`), existing.ResponseBody)
+	})
+
+	suite.T().Run("it should only release keys that are in flight", func(t *testing.T) {
+		suite.NoError(suite.repository.ReleaseIdempotentRequest(suite.ctx, suite.userID, "key-1"))
+
+		_, created, err := suite.repository.BeginIdempotentRequest(suite.ctx, suite.record("key-1"))
+		suite.NoError(err)
+		suite.False(created)
+
+		_, _, err = suite.repository.BeginIdempotentRequest(suite.ctx, suite.record("key-2"))
+		suite.NoError(err)
+		suite.NoError(suite.repository.ReleaseIdempotentRequest(suite.ctx, suite.userID, "key-2"))
+
+		_, created, err = suite.repository.BeginIdempotentRequest(suite.ctx, suite.record("key-2"))
+		suite.NoError(err)
+		suite.True(created)
+	})
+
+	suite.T().Run("it should delete expired keys", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+
+		deleted, err := suite.repository.DeleteExpiredIdempotencyKeys(suite.ctx, future, time.Now().Add(-time.Hour))
+		suite.NoError(err)
+		suite.Equal(int64(1), deleted)
+
+		deleted, err = suite.repository.DeleteExpiredIdempotencyKeys(suite.ctx, future, future)
+		suite.NoError(err)
+		suite.Equal(int64(1), deleted)
+	})
+}
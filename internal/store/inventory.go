@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+type InventoryStore struct {
+	db *db.DB
+}
+
+func NewInventoryStore(db *db.DB) *InventoryStore {
+	return &InventoryStore{db: db}
+}
+
+// InventoryItem is a copy of a film held by a store.
+type InventoryItem struct {
+	ID         int       `json:"id"`
+	FilmID     int       `json:"film_id"`
+	StoreID    int       `json:"store_id"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// GetInventoryItemsByIDs returns the inventory items with the given IDs in no
+// particular order.
+func (s *InventoryStore) GetInventoryItemsByIDs(ctx context.Context, ids []int64) ([]InventoryItem, error) {
+	defer metrics.ObserveQuery("InventoryStore.GetInventoryItemsByIDs")()
+
+	query := `
+		SELECT inventory_id, film_id, store_id, last_update
+		FROM inventory
+		WHERE inventory_id = ANY($1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(ids)}, func(row rowScanner) (*InventoryItem, error) {
+		var item InventoryItem
+		err := row.Scan(&item.ID, &item.FilmID, &item.StoreID, &item.LastUpdate)
+		if err != nil {
+			return nil, err
+		}
+		return &item, nil
+	})
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+)
+
+// Job run triggers and statuses. A run is running until its job returns. A
+// run left running by a process that died is marked interrupted by the next
+// run of the job.
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+
+	JobRunRunning     = "running"
+	JobRunSucceeded   = "succeeded"
+	JobRunFailed      = "failed"
+	JobRunInterrupted = "interrupted"
+)
+
+// jobLockClassID is the first key of the advisory locks held by running
+// jobs. The second is the hash of the job name.
+const jobLockClassID = 7_384_002
+
+type JobStore struct {
+	db *db.DB
+}
+
+func NewJobStore(db *db.DB) *JobStore {
+	return &JobStore{db: db}
+}
+
+// JobRun is one execution of a scheduled job. While it runs, it holds the
+// connection that owns the job's advisory lock.
+type JobRun struct {
+	ID          int64      `json:"id"`
+	JobName     string     `json:"job_name"`
+	Trigger     string     `json:"trigger"`
+	TriggeredBy *int       `json:"triggered_by"`
+	Status      string     `json:"status"`
+	Error       *string    `json:"error"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	conn        *sql.Conn
+}
+
+const jobRunColumns = `id, job_name, trigger, triggered_by, status, error, started_at, finished_at`
+
+// StartJobRun takes the advisory lock of the job and records a run. It fails
+// with ErrJobRunning while the job runs elsewhere, on any replica. The lock
+// is held until FinishJobRun.
+func (s *JobStore) StartJobRun(ctx context.Context, name, trigger string, triggeredBy *int) (*JobRun, error) {
+	defer metrics.ObserveQuery("JobStore.StartJobRun")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Session advisory locks belong to a connection, so the run keeps one
+	// out of the pool until it finishes.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1, hashtext($2))`, jobLockClassID, name).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrJobRunning
+	}
+
+	run, err := startJobRun(ctx, conn, name, trigger, triggeredBy)
+	if err != nil {
+		releaseJobLock(ctx, conn, name)
+		return nil, err
+	}
+	run.conn = conn
+	return run, nil
+}
+
+func startJobRun(ctx context.Context, conn *sql.Conn, name, trigger string, triggeredBy *int) (*JobRun, error) {
+	// Holding the lock means no other run of the job is alive.
+	query := `
+		UPDATE job_runs
+		SET status = $2, error = 'the process running the job stopped', finished_at = CURRENT_TIMESTAMP
+		WHERE job_name = $1 AND status = $3
+	`
+	if _, err := conn.ExecContext(ctx, query, name, JobRunInterrupted, JobRunRunning); err != nil {
+		return nil, err
+	}
+
+	query = `
+		INSERT INTO job_runs (job_name, trigger, triggered_by, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + jobRunColumns
+	return scanJobRun(conn.QueryRowContext(ctx, query, name, trigger, triggeredBy, JobRunRunning))
+}
+
+// FinishJobRun records the outcome of a run and releases the job's lock.
+// jobErr is the error the job returned, if any.
+func (s *JobStore) FinishJobRun(ctx context.Context, run *JobRun, jobErr error) error {
+	defer metrics.ObserveQuery("JobStore.FinishJobRun")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if run.conn != nil {
+		defer releaseJobLock(ctx, run.conn, run.JobName)
+		run.conn = nil
+	}
+
+	run.Status = JobRunSucceeded
+	run.Error = nil
+	if jobErr != nil {
+		message := jobErr.Error()
+		run.Status = JobRunFailed
+		run.Error = &message
+	}
+
+	query := `
+		UPDATE job_runs
+		SET status = $2, error = $3, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING finished_at
+	`
+	return s.db.QueryRowContext(ctx, query, run.ID, run.Status, run.Error).Scan(&run.FinishedAt)
+}
+
+// releaseJobLock unlocks the job and returns the connection to the pool. A
+// connection that cannot be unlocked is discarded, which ends its session
+// and so releases the lock as well.
+func releaseJobLock(ctx context.Context, conn *sql.Conn, name string) {
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1, hashtext($2))`, jobLockClassID, name); err != nil {
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+}
+
+// ListJobRuns returns up to limit runs, newest first, of the named job or of
+// all jobs if name is empty.
+func (s *JobStore) ListJobRuns(ctx context.Context, name string, limit int) ([]JobRun, error) {
+	defer metrics.ObserveQuery("JobStore.ListJobRuns")()
+
+	query := `
+		SELECT ` + jobRunColumns + `
+		FROM job_runs
+		WHERE $1 = '' OR job_name = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, []any{name, limit}, scanJobRun)
+}
+
+// DeleteJobRuns removes runs that finished before before. Running jobs are
+// kept.
+func (s *JobStore) DeleteJobRuns(ctx context.Context, before time.Time) (int64, error) {
+	defer metrics.ObserveQuery("JobStore.DeleteJobRuns")()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM job_runs WHERE finished_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanJobRun(row rowScanner) (*JobRun, error) {
+	var run JobRun
+	err := row.Scan(
+		&run.ID,
+		&run.JobName,
+		&run.Trigger,
+		&run.TriggeredBy,
+		&run.Status,
+		&run.Error,
+		&run.StartedAt,
+		&run.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
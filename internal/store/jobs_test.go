@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobsTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *JobStore
+	ctx         context.Context
+}
+
+func (suite *JobsTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+	suite.repository = NewJobStore(suite.pgContainer.DB)
+}
+
+func TestJobsTestSuite(t *testing.T) {
+	suite.Run(t, new(JobsTestSuite))
+}
+
+func (suite *JobsTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *JobsTestSuite) TestJobRuns() {
+	suite.T().Run("it should run a job once at a time", func(t *testing.T) {
+		run, err := suite.repository.StartJobRun(suite.ctx, "cleanup", JobTriggerSchedule, nil)
+		suite.Require().NoError(err)
+		suite.Equal(JobRunRunning, run.Status)
+
+		_, err = suite.repository.StartJobRun(suite.ctx, "cleanup", JobTriggerManual, nil)
+		suite.ErrorIs(err, ErrJobRunning)
+
+		other, err := suite.repository.StartJobRun(suite.ctx, "report", JobTriggerSchedule, nil)
+		suite.Require().NoError(err)
+		suite.NoError(suite.repository.FinishJobRun(suite.ctx, other, nil))
+
+		suite.NoError(suite.repository.FinishJobRun(suite.ctx, run, errors.New("database error")))
+		suite.Equal(JobRunFailed, run.Status)
+		suite.NotNil(run.FinishedAt)
+
+		run, err = suite.repository.StartJobRun(suite.ctx, "cleanup", JobTriggerManual, nil)
+		suite.Require().NoError(err)
+		suite.NoError(suite.repository.FinishJobRun(suite.ctx, run, nil))
+	})
+
+	suite.T().Run("it should list the runs of a job, newest first", func(t *testing.T) {
+		runs, err := suite.repository.ListJobRuns(suite.ctx, "cleanup", 10)
+		suite.NoError(err)
+		suite.Require().Len(runs, 2)
+		suite.Equal(JobRunSucceeded, runs[0].Status)
+		suite.Equal(JobTriggerManual, runs[0].Trigger)
+		suite.Equal(JobRunFailed, runs[1].Status)
+		suite.Equal("database error", *runs[1].Error)
+
+		runs, err = suite.repository.ListJobRuns(suite.ctx, "", 10)
+		suite.NoError(err)
+		suite.Len(runs, 3)
+	})
+
+	suite.T().Run("it should mark runs of a dead process as interrupted", func(t *testing.T) {
+		_, err := suite.pgContainer.DB.ExecContext(suite.ctx, `
+			INSERT INTO job_runs (job_name, trigger, status) VALUES ('crashed', 'schedule', 'running')
+		`)
+		suite.Require().NoError(err)
+
+		run, err := suite.repository.StartJobRun(suite.ctx, "crashed", JobTriggerSchedule, nil)
+		suite.Require().NoError(err)
+		suite.NoError(suite.repository.FinishJobRun(suite.ctx, run, nil))
+
+		runs, err := suite.repository.ListJobRuns(suite.ctx, "crashed", 10)
+		suite.NoError(err)
+		suite.Require().Len(runs, 2)
+		suite.Equal(JobRunInterrupted, runs[1].Status)
+	})
+
+	suite.T().Run("it should delete finished runs past their retention", func(t *testing.T) {
+		running, err := suite.repository.StartJobRun(suite.ctx, "long", JobTriggerSchedule, nil)
+		suite.Require().NoError(err)
+
+		deleted, err := suite.repository.DeleteJobRuns(suite.ctx, time.Now().Add(time.Minute))
+		suite.NoError(err)
+		suite.Equal(int64(5), deleted)
+
+		runs, err := suite.repository.ListJobRuns(suite.ctx, "", 10)
+		suite.NoError(err)
+		suite.Require().Len(runs, 1)
+		suite.Equal(running.ID, runs[0].ID)
+		suite.NoError(suite.repository.FinishJobRun(suite.ctx, running, nil))
+	})
+}
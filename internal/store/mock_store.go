@@ -2,11 +2,23 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
 )
 
 type MockUserStore struct {
-	RegisterUserFunc func(ctx context.Context, user *User) error
-	GetUserByIDFunc  func(ctx context.Context, id int64) (*User, error)
+	RegisterUserFunc       func(ctx context.Context, user *User) error
+	GetUserByIDFunc        func(ctx context.Context, id int64) (*User, error)
+	GetUserByEmailFunc     func(ctx context.Context, email string) (*User, error)
+	GetUserByUsernameFunc  func(ctx context.Context, username string) (*User, error)
+	ListUsersFunc          func(ctx context.Context) ([]User, error)
+	UpdateUserRoleFunc     func(ctx context.Context, id int64, role *Role) error
+	UpdateUserPasswordFunc func(ctx context.Context, id int64, password utils.Password) (time.Time, error)
+	RevokeUserTokensFunc   func(ctx context.Context, id int64) (time.Time, error)
 }
 
 func (m *MockUserStore) RegisterUser(ctx context.Context, user *User) error {
@@ -23,8 +35,53 @@ func (m *MockUserStore) GetUserByID(ctx context.Context, id int64) (*User, error
 	return nil, nil
 }
 
+func (m *MockUserStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if m.GetUserByEmailFunc != nil {
+		return m.GetUserByEmailFunc(ctx, email)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	if m.GetUserByUsernameFunc != nil {
+		return m.GetUserByUsernameFunc(ctx, username)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockUserStore) ListUsers(ctx context.Context) ([]User, error) {
+	if m.ListUsersFunc != nil {
+		return m.ListUsersFunc(ctx)
+	}
+	return []User{}, nil
+}
+
+func (m *MockUserStore) UpdateUserRole(ctx context.Context, id int64, role *Role) error {
+	if m.UpdateUserRoleFunc != nil {
+		return m.UpdateUserRoleFunc(ctx, id, role)
+	}
+	return nil
+}
+
+func (m *MockUserStore) UpdateUserPassword(ctx context.Context, id int64, password utils.Password) (time.Time, error) {
+	if m.UpdateUserPasswordFunc != nil {
+		return m.UpdateUserPasswordFunc(ctx, id, password)
+	}
+	return time.Now(), nil
+}
+
+func (m *MockUserStore) RevokeUserTokens(ctx context.Context, id int64) (time.Time, error) {
+	if m.RevokeUserTokensFunc != nil {
+		return m.RevokeUserTokensFunc(ctx, id)
+	}
+	return time.Now(), nil
+}
+
 type MockStaffStore struct {
-	GetStaffByEmailFunc func(ctx context.Context, email string) (*Staff, error)
+	GetStaffByEmailFunc  func(ctx context.Context, email string) (*Staff, error)
+	GetStaffByIDFunc     func(ctx context.Context, id int64) (*Staff, error)
+	GetStaffByIDsFunc    func(ctx context.Context, ids []int64) ([]Staff, error)
+	GetStaffByUserIDFunc func(ctx context.Context, userID int64) (*Staff, error)
 }
 
 func (m *MockStaffStore) GetStaffByEmail(ctx context.Context, email string) (*Staff, error) {
@@ -34,9 +91,34 @@ func (m *MockStaffStore) GetStaffByEmail(ctx context.Context, email string) (*St
 	return nil, nil
 }
 
+func (m *MockStaffStore) GetStaffByID(ctx context.Context, id int64) (*Staff, error) {
+	if m.GetStaffByIDFunc != nil {
+		return m.GetStaffByIDFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockStaffStore) GetStaffByIDs(ctx context.Context, ids []int64) ([]Staff, error) {
+	if m.GetStaffByIDsFunc != nil {
+		return m.GetStaffByIDsFunc(ctx, ids)
+	}
+	return []Staff{}, nil
+}
+
+func (m *MockStaffStore) GetStaffByUserID(ctx context.Context, userID int64) (*Staff, error) {
+	if m.GetStaffByUserIDFunc != nil {
+		return m.GetStaffByUserIDFunc(ctx, userID)
+	}
+	return nil, sql.ErrNoRows
+}
+
 type MockCustomerStore struct {
 	CreateCustomerFunc     func(ctx context.Context, customer *Customer) error
 	GetCustomerByEmailFunc func(ctx context.Context, email string) (*Customer, error)
+	GetCustomerByIDFunc    func(ctx context.Context, id int64) (*Customer, error)
+	UpdateCustomerFunc     func(ctx context.Context, customer *Customer, expectedVersion *time.Time) error
+	ListCustomersFunc      func(ctx context.Context, q *listquery.Query) ([]Customer, string, error)
+	GetCustomersByIDsFunc  func(ctx context.Context, ids []int64) ([]Customer, error)
 }
 
 func (m *MockCustomerStore) CreateCustomer(ctx context.Context, customer *Customer) error {
@@ -53,9 +135,141 @@ func (m *MockCustomerStore) GetCustomerByEmail(ctx context.Context, email string
 	return nil, nil
 }
 
+func (m *MockCustomerStore) GetCustomerByID(ctx context.Context, id int64) (*Customer, error) {
+	if m.GetCustomerByIDFunc != nil {
+		return m.GetCustomerByIDFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockCustomerStore) UpdateCustomer(ctx context.Context, customer *Customer, expectedVersion *time.Time) error {
+	if m.UpdateCustomerFunc != nil {
+		return m.UpdateCustomerFunc(ctx, customer, expectedVersion)
+	}
+	return nil
+}
+
+func (m *MockCustomerStore) ListCustomers(ctx context.Context, q *listquery.Query) ([]Customer, string, error) {
+	if m.ListCustomersFunc != nil {
+		return m.ListCustomersFunc(ctx, q)
+	}
+	return []Customer{}, "", nil
+}
+
+func (m *MockCustomerStore) GetCustomersByIDs(ctx context.Context, ids []int64) ([]Customer, error) {
+	if m.GetCustomersByIDsFunc != nil {
+		return m.GetCustomersByIDsFunc(ctx, ids)
+	}
+	return []Customer{}, nil
+}
+
+type MockFilmStore struct {
+	GetFilmByIDFunc        func(ctx context.Context, id int64) (*Film, error)
+	ListFilmsFunc          func(ctx context.Context, q *listquery.Query) ([]Film, string, error)
+	GetFilmsByIDsFunc      func(ctx context.Context, ids []int64) ([]Film, error)
+	GetFilmsByActorIDsFunc func(ctx context.Context, actorIDs []int64) (map[int64][]Film, error)
+}
+
+func (m *MockFilmStore) GetFilmByID(ctx context.Context, id int64) (*Film, error) {
+	if m.GetFilmByIDFunc != nil {
+		return m.GetFilmByIDFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockFilmStore) ListFilms(ctx context.Context, q *listquery.Query) ([]Film, string, error) {
+	if m.ListFilmsFunc != nil {
+		return m.ListFilmsFunc(ctx, q)
+	}
+	return []Film{}, "", nil
+}
+
+func (m *MockFilmStore) GetFilmsByIDs(ctx context.Context, ids []int64) ([]Film, error) {
+	if m.GetFilmsByIDsFunc != nil {
+		return m.GetFilmsByIDsFunc(ctx, ids)
+	}
+	return []Film{}, nil
+}
+
+func (m *MockFilmStore) GetFilmsByActorIDs(ctx context.Context, actorIDs []int64) (map[int64][]Film, error) {
+	if m.GetFilmsByActorIDsFunc != nil {
+		return m.GetFilmsByActorIDsFunc(ctx, actorIDs)
+	}
+	return map[int64][]Film{}, nil
+}
+
+type MockActorStore struct {
+	GetActorsByIDsFunc     func(ctx context.Context, ids []int64) ([]Actor, error)
+	GetActorsByFilmIDsFunc func(ctx context.Context, filmIDs []int64) (map[int64][]Actor, error)
+}
+
+func (m *MockActorStore) GetActorsByIDs(ctx context.Context, ids []int64) ([]Actor, error) {
+	if m.GetActorsByIDsFunc != nil {
+		return m.GetActorsByIDsFunc(ctx, ids)
+	}
+	return []Actor{}, nil
+}
+
+func (m *MockActorStore) GetActorsByFilmIDs(ctx context.Context, filmIDs []int64) (map[int64][]Actor, error) {
+	if m.GetActorsByFilmIDsFunc != nil {
+		return m.GetActorsByFilmIDsFunc(ctx, filmIDs)
+	}
+	return map[int64][]Actor{}, nil
+}
+
+type MockCategoryStore struct {
+	ListCategoriesFunc         func(ctx context.Context) ([]Category, error)
+	GetCategoriesByFilmIDsFunc func(ctx context.Context, filmIDs []int64) (map[int64][]Category, error)
+}
+
+func (m *MockCategoryStore) ListCategories(ctx context.Context) ([]Category, error) {
+	if m.ListCategoriesFunc != nil {
+		return m.ListCategoriesFunc(ctx)
+	}
+	return []Category{}, nil
+}
+
+func (m *MockCategoryStore) GetCategoriesByFilmIDs(ctx context.Context, filmIDs []int64) (map[int64][]Category, error) {
+	if m.GetCategoriesByFilmIDsFunc != nil {
+		return m.GetCategoriesByFilmIDsFunc(ctx, filmIDs)
+	}
+	return map[int64][]Category{}, nil
+}
+
+type MockInventoryStore struct {
+	GetInventoryItemsByIDsFunc func(ctx context.Context, ids []int64) ([]InventoryItem, error)
+}
+
+func (m *MockInventoryStore) GetInventoryItemsByIDs(ctx context.Context, ids []int64) ([]InventoryItem, error) {
+	if m.GetInventoryItemsByIDsFunc != nil {
+		return m.GetInventoryItemsByIDsFunc(ctx, ids)
+	}
+	return []InventoryItem{}, nil
+}
+
+type MockRentalPlaceStore struct {
+	GetRentalPlaceByIDFunc   func(ctx context.Context, id int64) (*RentalPlace, error)
+	GetRentalPlacesByIDsFunc func(ctx context.Context, ids []int64) ([]RentalPlace, error)
+}
+
+func (m *MockRentalPlaceStore) GetRentalPlaceByID(ctx context.Context, id int64) (*RentalPlace, error) {
+	if m.GetRentalPlaceByIDFunc != nil {
+		return m.GetRentalPlaceByIDFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *MockRentalPlaceStore) GetRentalPlacesByIDs(ctx context.Context, ids []int64) ([]RentalPlace, error) {
+	if m.GetRentalPlacesByIDsFunc != nil {
+		return m.GetRentalPlacesByIDsFunc(ctx, ids)
+	}
+	return []RentalPlace{}, nil
+}
+
 type MockRoleStore struct {
 	GetRoleByNameFunc func(ctx context.Context, name string) (*Role, error)
 	GetRoleByIDFunc   func(ctx context.Context, id int64) (*Role, error)
+	ListRolesFunc     func(ctx context.Context) ([]Role, error)
 }
 
 func (m *MockRoleStore) GetRoleByName(ctx context.Context, name string) (*Role, error) {
@@ -95,8 +309,22 @@ func (m *MockRoleStore) GetRoleByID(ctx context.Context, id int64) (*Role, error
 	return nil, nil
 }
 
+func (m *MockRoleStore) ListRoles(ctx context.Context) ([]Role, error) {
+	if m.ListRolesFunc != nil {
+		return m.ListRolesFunc(ctx)
+	}
+	return []Role{{ID: 1, Name: "admin", Level: 10}, {ID: 2, Name: "customer", Level: 1}}, nil
+}
+
 type MockRentalStore struct {
-	GetRentalFunc func(ctx context.Context, id int64) (*Rental, error)
+	GetRentalFunc               func(ctx context.Context, id int64) (*Rental, error)
+	ListRentalsFunc             func(ctx context.Context, q *listquery.Query) ([]Rental, string, error)
+	StreamRentalsFunc           func(ctx context.Context, filter ExportFilter, fn func(rental *Rental) error) error
+	GetRentalsByIDsFunc         func(ctx context.Context, ids []int64) ([]Rental, error)
+	GetRentalsByCustomerIDsFunc func(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Rental, error)
+	CreateRentalFunc            func(ctx context.Context, rental *Rental) error
+	ReturnRentalFunc            func(ctx context.Context, id int64) (*Rental, error)
+	MarkOverdueRentalsFunc      func(ctx context.Context) ([]OverdueRental, error)
 }
 
 func (m *MockRentalStore) GetRental(ctx context.Context, id int64) (*Rental, error) {
@@ -106,12 +334,438 @@ func (m *MockRentalStore) GetRental(ctx context.Context, id int64) (*Rental, err
 	return nil, nil
 }
 
+func (m *MockRentalStore) ListRentals(ctx context.Context, q *listquery.Query) ([]Rental, string, error) {
+	if m.ListRentalsFunc != nil {
+		return m.ListRentalsFunc(ctx, q)
+	}
+	return []Rental{}, "", nil
+}
+
+func (m *MockRentalStore) StreamRentals(ctx context.Context, filter ExportFilter, fn func(rental *Rental) error) error {
+	if m.StreamRentalsFunc != nil {
+		return m.StreamRentalsFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+func (m *MockRentalStore) GetRentalsByIDs(ctx context.Context, ids []int64) ([]Rental, error) {
+	if m.GetRentalsByIDsFunc != nil {
+		return m.GetRentalsByIDsFunc(ctx, ids)
+	}
+	return []Rental{}, nil
+}
+
+func (m *MockRentalStore) GetRentalsByCustomerIDs(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Rental, error) {
+	if m.GetRentalsByCustomerIDsFunc != nil {
+		return m.GetRentalsByCustomerIDsFunc(ctx, customerIDs, limit)
+	}
+	return map[int64][]Rental{}, nil
+}
+
+func (m *MockRentalStore) CreateRental(ctx context.Context, rental *Rental) error {
+	if m.CreateRentalFunc != nil {
+		return m.CreateRentalFunc(ctx, rental)
+	}
+	return nil
+}
+
+func (m *MockRentalStore) ReturnRental(ctx context.Context, id int64) (*Rental, error) {
+	if m.ReturnRentalFunc != nil {
+		return m.ReturnRentalFunc(ctx, id)
+	}
+	return &Rental{ID: int(id)}, nil
+}
+
+func (m *MockRentalStore) MarkOverdueRentals(ctx context.Context) ([]OverdueRental, error) {
+	if m.MarkOverdueRentalsFunc != nil {
+		return m.MarkOverdueRentalsFunc(ctx)
+	}
+	return []OverdueRental{}, nil
+}
+
+type MockPaymentStore struct {
+	CreatePaymentFunc            func(ctx context.Context, payment *Payment) error
+	ListPaymentsFunc             func(ctx context.Context, q *listquery.Query) ([]Payment, string, error)
+	StreamPaymentsFunc           func(ctx context.Context, filter ExportFilter, fn func(payment *Payment) error) error
+	GetPaymentsByCustomerIDsFunc func(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Payment, error)
+	GetPaymentsByRentalIDsFunc   func(ctx context.Context, rentalIDs []int64) (map[int64][]Payment, error)
+}
+
+func (m *MockPaymentStore) CreatePayment(ctx context.Context, payment *Payment) error {
+	if m.CreatePaymentFunc != nil {
+		return m.CreatePaymentFunc(ctx, payment)
+	}
+	return nil
+}
+
+func (m *MockPaymentStore) ListPayments(ctx context.Context, q *listquery.Query) ([]Payment, string, error) {
+	if m.ListPaymentsFunc != nil {
+		return m.ListPaymentsFunc(ctx, q)
+	}
+	return []Payment{}, "", nil
+}
+
+func (m *MockPaymentStore) StreamPayments(ctx context.Context, filter ExportFilter, fn func(payment *Payment) error) error {
+	if m.StreamPaymentsFunc != nil {
+		return m.StreamPaymentsFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+func (m *MockPaymentStore) GetPaymentsByCustomerIDs(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Payment, error) {
+	if m.GetPaymentsByCustomerIDsFunc != nil {
+		return m.GetPaymentsByCustomerIDsFunc(ctx, customerIDs, limit)
+	}
+	return map[int64][]Payment{}, nil
+}
+
+func (m *MockPaymentStore) GetPaymentsByRentalIDs(ctx context.Context, rentalIDs []int64) (map[int64][]Payment, error) {
+	if m.GetPaymentsByRentalIDsFunc != nil {
+		return m.GetPaymentsByRentalIDsFunc(ctx, rentalIDs)
+	}
+	return map[int64][]Payment{}, nil
+}
+
+type MockOutboxStore struct {
+	RelayOutboxEventsFunc           func(ctx context.Context, limit int, publish func(ctx context.Context, event *OutboxEvent) error) (int, error)
+	DeletePublishedOutboxEventsFunc func(ctx context.Context, before time.Time) (int64, error)
+	ListStoreRentalEventsFunc       func(ctx context.Context, storeID, afterID int64, limit int) ([]StoreRentalEvent, error)
+	NotifyFunc                      func(ctx context.Context, channel, payload string) error
+}
+
+func (m *MockOutboxStore) RelayOutboxEvents(ctx context.Context, limit int, publish func(ctx context.Context, event *OutboxEvent) error) (int, error) {
+	if m.RelayOutboxEventsFunc != nil {
+		return m.RelayOutboxEventsFunc(ctx, limit, publish)
+	}
+	return 0, nil
+}
+
+func (m *MockOutboxStore) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error) {
+	if m.DeletePublishedOutboxEventsFunc != nil {
+		return m.DeletePublishedOutboxEventsFunc(ctx, before)
+	}
+	return 0, nil
+}
+
+func (m *MockOutboxStore) ListStoreRentalEvents(ctx context.Context, storeID, afterID int64, limit int) ([]StoreRentalEvent, error) {
+	if m.ListStoreRentalEventsFunc != nil {
+		return m.ListStoreRentalEventsFunc(ctx, storeID, afterID, limit)
+	}
+	return []StoreRentalEvent{}, nil
+}
+
+func (m *MockOutboxStore) Notify(ctx context.Context, channel, payload string) error {
+	if m.NotifyFunc != nil {
+		return m.NotifyFunc(ctx, channel, payload)
+	}
+	return nil
+}
+
+type MockAuditStore struct {
+	ListAuditEntriesFunc func(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+func (m *MockAuditStore) ListAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	if m.ListAuditEntriesFunc != nil {
+		return m.ListAuditEntriesFunc(ctx, filter)
+	}
+	return []AuditEntry{}, nil
+}
+
+type MockHealthStore struct {
+	PingFunc             func(ctx context.Context) error
+	MigrationVersionFunc func(ctx context.Context) (uint, bool, error)
+	StatsFunc            func() sql.DBStats
+	ReplicaFunc          func() db.ReplicaStatus
+}
+
+func (m *MockHealthStore) Ping(ctx context.Context) error {
+	if m.PingFunc != nil {
+		return m.PingFunc(ctx)
+	}
+	return nil
+}
+
+func (m *MockHealthStore) MigrationVersion(ctx context.Context) (uint, bool, error) {
+	if m.MigrationVersionFunc != nil {
+		return m.MigrationVersionFunc(ctx)
+	}
+	return 0, false, nil
+}
+
+func (m *MockHealthStore) Stats() sql.DBStats {
+	if m.StatsFunc != nil {
+		return m.StatsFunc()
+	}
+	return sql.DBStats{}
+}
+
+func (m *MockHealthStore) Replica() db.ReplicaStatus {
+	if m.ReplicaFunc != nil {
+		return m.ReplicaFunc()
+	}
+	return db.ReplicaStatus{}
+}
+
+type MockIdempotencyStore struct {
+	BeginIdempotentRequestFunc       func(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, bool, error)
+	CompleteIdempotentRequestFunc    func(ctx context.Context, userID int, key string, status int, headers map[string]string, body []byte) error
+	ReleaseIdempotentRequestFunc     func(ctx context.Context, userID int, key string) error
+	DeleteExpiredIdempotencyKeysFunc func(ctx context.Context, completedBefore, inFlightBefore time.Time) (int64, error)
+}
+
+func (m *MockIdempotencyStore) BeginIdempotentRequest(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, bool, error) {
+	if m.BeginIdempotentRequestFunc != nil {
+		return m.BeginIdempotentRequestFunc(ctx, record)
+	}
+	return record, true, nil
+}
+
+func (m *MockIdempotencyStore) CompleteIdempotentRequest(ctx context.Context, userID int, key string, status int, headers map[string]string, body []byte) error {
+	if m.CompleteIdempotentRequestFunc != nil {
+		return m.CompleteIdempotentRequestFunc(ctx, userID, key, status, headers, body)
+	}
+	return nil
+}
+
+func (m *MockIdempotencyStore) ReleaseIdempotentRequest(ctx context.Context, userID int, key string) error {
+	if m.ReleaseIdempotentRequestFunc != nil {
+		return m.ReleaseIdempotentRequestFunc(ctx, userID, key)
+	}
+	return nil
+}
+
+func (m *MockIdempotencyStore) DeleteExpiredIdempotencyKeys(ctx context.Context, completedBefore, inFlightBefore time.Time) (int64, error) {
+	if m.DeleteExpiredIdempotencyKeysFunc != nil {
+		return m.DeleteExpiredIdempotencyKeysFunc(ctx, completedBefore, inFlightBefore)
+	}
+	return 0, nil
+}
+
 func NewMockStore() *Store {
 	return &Store{
-		Users:     &MockUserStore{},
-		Staff:     &MockStaffStore{},
-		Customers: &MockCustomerStore{},
-		Roles:     &MockRoleStore{},
-		Rentals:   &MockRentalStore{},
+		Users:         &MockUserStore{},
+		Staff:         &MockStaffStore{},
+		Customers:     &MockCustomerStore{},
+		Films:         &MockFilmStore{},
+		Actors:        &MockActorStore{},
+		Categories:    &MockCategoryStore{},
+		Inventory:     &MockInventoryStore{},
+		RentalPlaces:  &MockRentalPlaceStore{},
+		Roles:         &MockRoleStore{},
+		Rentals:       &MockRentalStore{},
+		Payments:      &MockPaymentStore{},
+		Webhooks:      &MockWebhookStore{},
+		Outbox:        &MockOutboxStore{},
+		Audit:         &MockAuditStore{},
+		Idempotency:   &MockIdempotencyStore{},
+		Jobs:          &MockJobStore{},
+		Dunning:       &MockDunningStore{},
+		Notifications: &MockNotificationStore{},
+		Health:        &MockHealthStore{},
+	}
+}
+
+type MockJobStore struct {
+	StartJobRunFunc   func(ctx context.Context, name, trigger string, triggeredBy *int) (*JobRun, error)
+	FinishJobRunFunc  func(ctx context.Context, run *JobRun, jobErr error) error
+	ListJobRunsFunc   func(ctx context.Context, name string, limit int) ([]JobRun, error)
+	DeleteJobRunsFunc func(ctx context.Context, before time.Time) (int64, error)
+}
+
+func (m *MockJobStore) StartJobRun(ctx context.Context, name, trigger string, triggeredBy *int) (*JobRun, error) {
+	if m.StartJobRunFunc != nil {
+		return m.StartJobRunFunc(ctx, name, trigger, triggeredBy)
+	}
+	return &JobRun{ID: 1, JobName: name, Trigger: trigger, TriggeredBy: triggeredBy, Status: JobRunRunning, StartedAt: time.Now()}, nil
+}
+
+func (m *MockJobStore) FinishJobRun(ctx context.Context, run *JobRun, jobErr error) error {
+	if m.FinishJobRunFunc != nil {
+		return m.FinishJobRunFunc(ctx, run, jobErr)
+	}
+	return nil
+}
+
+func (m *MockJobStore) ListJobRuns(ctx context.Context, name string, limit int) ([]JobRun, error) {
+	if m.ListJobRunsFunc != nil {
+		return m.ListJobRunsFunc(ctx, name, limit)
+	}
+	return []JobRun{}, nil
+}
+
+func (m *MockJobStore) DeleteJobRuns(ctx context.Context, before time.Time) (int64, error) {
+	if m.DeleteJobRunsFunc != nil {
+		return m.DeleteJobRunsFunc(ctx, before)
+	}
+	return 0, nil
+}
+
+type MockWebhookStore struct {
+	CreateWebhookEndpointFunc     func(ctx context.Context, endpoint *WebhookEndpoint) error
+	ListWebhookEndpointsFunc      func(ctx context.Context) ([]WebhookEndpoint, error)
+	DeleteWebhookEndpointFunc     func(ctx context.Context, id int64) error
+	EnqueueWebhookEventFunc       func(ctx context.Context, eventID, eventType string, payload []byte) (int64, error)
+	ClaimWebhookDeliveriesFunc    func(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	CompleteWebhookDeliveryFunc   func(ctx context.Context, id int64, statusCode int) error
+	FailWebhookDeliveryFunc       func(ctx context.Context, id int64, result WebhookFailure) error
+	ListDeadWebhookDeliveriesFunc func(ctx context.Context, endpointID int64, limit int) ([]WebhookDelivery, error)
+	ReplayWebhookDeliveryFunc     func(ctx context.Context, id int64) error
+}
+
+func (m *MockWebhookStore) CreateWebhookEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	if m.CreateWebhookEndpointFunc != nil {
+		return m.CreateWebhookEndpointFunc(ctx, endpoint)
+	}
+	return nil
+}
+
+func (m *MockWebhookStore) ListWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error) {
+	if m.ListWebhookEndpointsFunc != nil {
+		return m.ListWebhookEndpointsFunc(ctx)
+	}
+	return []WebhookEndpoint{}, nil
+}
+
+func (m *MockWebhookStore) DeleteWebhookEndpoint(ctx context.Context, id int64) error {
+	if m.DeleteWebhookEndpointFunc != nil {
+		return m.DeleteWebhookEndpointFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *MockWebhookStore) EnqueueWebhookEvent(ctx context.Context, eventID, eventType string, payload []byte) (int64, error) {
+	if m.EnqueueWebhookEventFunc != nil {
+		return m.EnqueueWebhookEventFunc(ctx, eventID, eventType, payload)
+	}
+	return 0, nil
+}
+
+func (m *MockWebhookStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	if m.ClaimWebhookDeliveriesFunc != nil {
+		return m.ClaimWebhookDeliveriesFunc(ctx, limit, lease)
+	}
+	return []WebhookDelivery{}, nil
+}
+
+func (m *MockWebhookStore) CompleteWebhookDelivery(ctx context.Context, id int64, statusCode int) error {
+	if m.CompleteWebhookDeliveryFunc != nil {
+		return m.CompleteWebhookDeliveryFunc(ctx, id, statusCode)
+	}
+	return nil
+}
+
+func (m *MockWebhookStore) FailWebhookDelivery(ctx context.Context, id int64, result WebhookFailure) error {
+	if m.FailWebhookDeliveryFunc != nil {
+		return m.FailWebhookDeliveryFunc(ctx, id, result)
+	}
+	return nil
+}
+
+func (m *MockWebhookStore) ListDeadWebhookDeliveries(ctx context.Context, endpointID int64, limit int) ([]WebhookDelivery, error) {
+	if m.ListDeadWebhookDeliveriesFunc != nil {
+		return m.ListDeadWebhookDeliveriesFunc(ctx, endpointID, limit)
+	}
+	return []WebhookDelivery{}, nil
+}
+
+func (m *MockWebhookStore) ReplayWebhookDelivery(ctx context.Context, id int64) error {
+	if m.ReplayWebhookDeliveryFunc != nil {
+		return m.ReplayWebhookDeliveryFunc(ctx, id)
+	}
+	return nil
+}
+
+type MockDunningStore struct {
+	OpenDunningCasesFunc      func(ctx context.Context) (int64, error)
+	ListOpenDunningCasesFunc  func(ctx context.Context, afterRentalID int64, limit int) ([]DunningCase, error)
+	GetDunningCaseFunc        func(ctx context.Context, rentalID int64) (*DunningCase, error)
+	RecordDunningReminderFunc func(ctx context.Context, rentalID int64, reminder int) error
+	BlockDunningCustomerFunc  func(ctx context.Context, rentalID int64) error
+	MarkDunningRentalLostFunc func(ctx context.Context, rentalID int64) (*CustomerCharge, error)
+	UpdateDunningCaseFunc     func(ctx context.Context, rentalID int64, step, note string) (*DunningCase, error)
+}
+
+func (m *MockDunningStore) OpenDunningCases(ctx context.Context) (int64, error) {
+	if m.OpenDunningCasesFunc != nil {
+		return m.OpenDunningCasesFunc(ctx)
+	}
+	return 0, nil
+}
+
+func (m *MockDunningStore) ListOpenDunningCases(ctx context.Context, afterRentalID int64, limit int) ([]DunningCase, error) {
+	if m.ListOpenDunningCasesFunc != nil {
+		return m.ListOpenDunningCasesFunc(ctx, afterRentalID, limit)
+	}
+	return []DunningCase{}, nil
+}
+
+func (m *MockDunningStore) GetDunningCase(ctx context.Context, rentalID int64) (*DunningCase, error) {
+	if m.GetDunningCaseFunc != nil {
+		return m.GetDunningCaseFunc(ctx, rentalID)
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockDunningStore) RecordDunningReminder(ctx context.Context, rentalID int64, reminder int) error {
+	if m.RecordDunningReminderFunc != nil {
+		return m.RecordDunningReminderFunc(ctx, rentalID, reminder)
+	}
+	return nil
+}
+
+func (m *MockDunningStore) BlockDunningCustomer(ctx context.Context, rentalID int64) error {
+	if m.BlockDunningCustomerFunc != nil {
+		return m.BlockDunningCustomerFunc(ctx, rentalID)
+	}
+	return nil
+}
+
+func (m *MockDunningStore) MarkDunningRentalLost(ctx context.Context, rentalID int64) (*CustomerCharge, error) {
+	if m.MarkDunningRentalLostFunc != nil {
+		return m.MarkDunningRentalLostFunc(ctx, rentalID)
+	}
+	return &CustomerCharge{RentalID: int(rentalID), Reason: ChargeReasonReplacement}, nil
+}
+
+func (m *MockDunningStore) UpdateDunningCase(ctx context.Context, rentalID int64, step, note string) (*DunningCase, error) {
+	if m.UpdateDunningCaseFunc != nil {
+		return m.UpdateDunningCaseFunc(ctx, rentalID, step, note)
+	}
+	return &DunningCase{RentalID: int(rentalID)}, nil
+}
+
+type MockNotificationStore struct {
+	GetNotificationPreferencesFunc    func(ctx context.Context, customerID int64) (*NotificationPreferences, error)
+	UpdateNotificationPreferencesFunc func(ctx context.Context, prefs *NotificationPreferences) error
+	RecordNotificationFunc            func(ctx context.Context, entry *NotificationLogEntry) error
+	ListNotificationsFunc             func(ctx context.Context, customerID int64, limit int) ([]NotificationLogEntry, error)
+}
+
+func (m *MockNotificationStore) GetNotificationPreferences(ctx context.Context, customerID int64) (*NotificationPreferences, error) {
+	if m.GetNotificationPreferencesFunc != nil {
+		return m.GetNotificationPreferencesFunc(ctx, customerID)
+	}
+	return &NotificationPreferences{CustomerID: int(customerID), Channels: []string{"email"}}, nil
+}
+
+func (m *MockNotificationStore) UpdateNotificationPreferences(ctx context.Context, prefs *NotificationPreferences) error {
+	if m.UpdateNotificationPreferencesFunc != nil {
+		return m.UpdateNotificationPreferencesFunc(ctx, prefs)
+	}
+	return nil
+}
+
+func (m *MockNotificationStore) RecordNotification(ctx context.Context, entry *NotificationLogEntry) error {
+	if m.RecordNotificationFunc != nil {
+		return m.RecordNotificationFunc(ctx, entry)
+	}
+	return nil
+}
+
+func (m *MockNotificationStore) ListNotifications(ctx context.Context, customerID int64, limit int) ([]NotificationLogEntry, error) {
+	if m.ListNotificationsFunc != nil {
+		return m.ListNotificationsFunc(ctx, customerID, limit)
 	}
+	return []NotificationLogEntry{}, nil
 }
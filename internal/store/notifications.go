@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+// Notification send statuses.
+const (
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	NotificationSkipped = "skipped"
+)
+
+type NotificationStore struct {
+	db *db.DB
+}
+
+func NewNotificationStore(db *db.DB) *NotificationStore {
+	return &NotificationStore{db: db}
+}
+
+// NotificationPreferences are the channels a customer is notified on. An
+// opted out customer only gets messages that cannot be opted out of, such
+// as charges.
+type NotificationPreferences struct {
+	CustomerID int        `json:"customer_id"`
+	Channels   []string   `json:"channels"`
+	OptedOut   bool       `json:"opted_out"`
+	OptedOutAt *time.Time `json:"opted_out_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+}
+
+// NotificationLogEntry records a message sent, or not sent, to a recipient
+// on one channel. CustomerID is nil for recipients who are not customers.
+type NotificationLogEntry struct {
+	ID         int64     `json:"id"`
+	Template   string    `json:"template"`
+	Channel    string    `json:"channel"`
+	CustomerID *int      `json:"customer_id"`
+	Recipient  string    `json:"recipient"`
+	Subject    string    `json:"subject"`
+	Status     string    `json:"status"`
+	Error      *string   `json:"error"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// GetNotificationPreferences returns the preferences of a customer, or the
+// defaults if they never changed them. It returns sql.ErrNoRows if the
+// customer does not exist.
+func (s *NotificationStore) GetNotificationPreferences(ctx context.Context, customerID int64) (*NotificationPreferences, error) {
+	defer metrics.ObserveQuery("NotificationStore.GetNotificationPreferences")()
+
+	query := `
+		SELECT c.customer_id, COALESCE(p.channels, '{email}'), p.opted_out_at, p.updated_at
+		FROM customer c
+		LEFT JOIN notification_preferences p ON p.customer_id = c.customer_id
+		WHERE c.customer_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return scanNotificationPreferences(s.db.QueryRowContext(ctx, query, customerID))
+}
+
+// UpdateNotificationPreferences replaces the preferences of a customer. The
+// opt-out time is kept while they stay opted out.
+func (s *NotificationStore) UpdateNotificationPreferences(ctx context.Context, prefs *NotificationPreferences) error {
+	defer metrics.ObserveQuery("NotificationStore.UpdateNotificationPreferences")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `
+			SELECT c.customer_id, COALESCE(p.channels, '{email}'), p.opted_out_at, p.updated_at
+			FROM customer c
+			LEFT JOIN notification_preferences p ON p.customer_id = c.customer_id
+			WHERE c.customer_id = $1
+			FOR UPDATE OF c
+		`
+		before, err := scanNotificationPreferences(tx.QueryRowContext(ctx, query, prefs.CustomerID))
+		if err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO notification_preferences (customer_id, channels, opted_out_at)
+			VALUES ($1, $2, CASE WHEN $3 THEN CURRENT_TIMESTAMP END)
+			ON CONFLICT (customer_id) DO UPDATE
+			SET channels = EXCLUDED.channels,
+				opted_out_at = CASE WHEN $3 THEN COALESCE(notification_preferences.opted_out_at, CURRENT_TIMESTAMP) END,
+				updated_at = CURRENT_TIMESTAMP
+			RETURNING opted_out_at, updated_at
+		`
+		err = tx.QueryRowContext(ctx, query, prefs.CustomerID, pq.StringArray(prefs.Channels), prefs.OptedOut).Scan(&prefs.OptedOutAt, &prefs.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, "update", "notification_preferences", int64(prefs.CustomerID), before, prefs)
+	})
+}
+
+// RecordNotification adds an entry to the send log.
+func (s *NotificationStore) RecordNotification(ctx context.Context, entry *NotificationLogEntry) error {
+	defer metrics.ObserveQuery("NotificationStore.RecordNotification")()
+
+	query := `
+		INSERT INTO notification_log (template, channel, customer_id, recipient, subject, status, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query,
+		entry.Template,
+		entry.Channel,
+		entry.CustomerID,
+		entry.Recipient,
+		entry.Subject,
+		entry.Status,
+		entry.Error,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// ListNotifications returns the latest send log entries, of one customer if
+// customerID is not 0, newest first.
+func (s *NotificationStore) ListNotifications(ctx context.Context, customerID int64, limit int) ([]NotificationLogEntry, error) {
+	defer metrics.ObserveQuery("NotificationStore.ListNotifications")()
+
+	query := `
+		SELECT id, template, channel, customer_id, recipient, subject, status, error, created_at
+		FROM notification_log
+		WHERE $1 = 0 OR customer_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, []any{customerID, limit}, scanNotificationLogEntry)
+}
+
+func scanNotificationPreferences(row rowScanner) (*NotificationPreferences, error) {
+	var prefs NotificationPreferences
+	var channels pq.StringArray
+	err := row.Scan(
+		&prefs.CustomerID,
+		&channels,
+		&prefs.OptedOutAt,
+		&prefs.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	prefs.Channels = []string(channels)
+	prefs.OptedOut = prefs.OptedOutAt != nil
+	return &prefs, nil
+}
+
+func scanNotificationLogEntry(row rowScanner) (*NotificationLogEntry, error) {
+	var entry NotificationLogEntry
+	err := row.Scan(
+		&entry.ID,
+		&entry.Template,
+		&entry.Channel,
+		&entry.CustomerID,
+		&entry.Recipient,
+		&entry.Subject,
+		&entry.Status,
+		&entry.Error,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
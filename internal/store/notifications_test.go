@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotificationsTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *NotificationStore
+	ctx         context.Context
+}
+
+func (suite *NotificationsTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+	suite.repository = NewNotificationStore(suite.pgContainer.DB)
+}
+
+func TestNotificationsTestSuite(t *testing.T) {
+	suite.Run(t, new(NotificationsTestSuite))
+}
+
+func (suite *NotificationsTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *NotificationsTestSuite) TestNotificationPreferences() {
+	suite.T().Run("it should return the defaults", func(t *testing.T) {
+		prefs, err := suite.repository.GetNotificationPreferences(suite.ctx, 1)
+		suite.NoError(err)
+		suite.Equal([]string{"email"}, prefs.Channels)
+		suite.False(prefs.OptedOut)
+		suite.Nil(prefs.UpdatedAt)
+	})
+
+	suite.T().Run("it should keep the opt-out time while opted out", func(t *testing.T) {
+		prefs := &NotificationPreferences{CustomerID: 1, Channels: []string{"email"}, OptedOut: true}
+		suite.Require().NoError(suite.repository.UpdateNotificationPreferences(suite.ctx, prefs))
+		suite.Require().NotNil(prefs.OptedOutAt)
+		optedOutAt := *prefs.OptedOutAt
+
+		prefs.Channels = []string{}
+		suite.Require().NoError(suite.repository.UpdateNotificationPreferences(suite.ctx, prefs))
+		suite.Equal(optedOutAt, *prefs.OptedOutAt)
+
+		stored, err := suite.repository.GetNotificationPreferences(suite.ctx, 1)
+		suite.NoError(err)
+		suite.True(stored.OptedOut)
+		suite.Empty(stored.Channels)
+
+		prefs.OptedOut = false
+		suite.Require().NoError(suite.repository.UpdateNotificationPreferences(suite.ctx, prefs))
+		suite.Nil(prefs.OptedOutAt)
+	})
+
+	suite.T().Run("it should not find an unknown customer", func(t *testing.T) {
+		_, err := suite.repository.GetNotificationPreferences(suite.ctx, 1<<30)
+		suite.ErrorIs(err, sql.ErrNoRows)
+
+		err = suite.repository.UpdateNotificationPreferences(suite.ctx, &NotificationPreferences{CustomerID: 1 << 30, Channels: []string{"email"}})
+		suite.ErrorIs(err, sql.ErrNoRows)
+	})
+}
+
+func (suite *NotificationsTestSuite) TestNotificationLog() {
+	customerID := 2
+	failure := "connection refused"
+	entries := []*NotificationLogEntry{
+		{Template: "overdue_reminder", Channel: "email", CustomerID: &customerID, Recipient: "patricia.johnson@sakilacustomer.org", Subject: "overdue", Status: NotificationFailed, Error: &failure},
+		{Template: "overdue_reminder", Channel: "email", CustomerID: &customerID, Recipient: "patricia.johnson@sakilacustomer.org", Subject: "overdue", Status: NotificationSent},
+		{Template: "rental_lost", Channel: "email", Recipient: "mike.hillyer@sakilastaff.com", Subject: "lost", Status: NotificationSent},
+	}
+	for _, entry := range entries {
+		suite.Require().NoError(suite.repository.RecordNotification(suite.ctx, entry))
+		suite.NotZero(entry.ID)
+	}
+
+	suite.T().Run("it should list the entries of a customer, newest first", func(t *testing.T) {
+		listed, err := suite.repository.ListNotifications(suite.ctx, int64(customerID), 10)
+		suite.NoError(err)
+		suite.Require().Len(listed, 2)
+		suite.Equal(NotificationSent, listed[0].Status)
+		suite.Equal(failure, *listed[1].Error)
+	})
+
+	suite.T().Run("it should list all entries", func(t *testing.T) {
+		listed, err := suite.repository.ListNotifications(suite.ctx, 0, 2)
+		suite.NoError(err)
+		suite.Require().Len(listed, 2)
+		suite.Nil(listed[0].CustomerID)
+	})
+}
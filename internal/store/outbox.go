@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+// Domain event types written to the outbox.
+const (
+	EventRentalCreated   = "rental.created"
+	EventRentalReturned  = "rental.returned"
+	EventRentalOverdue   = "rental.overdue"
+	EventCustomerCreated = "customer.created"
+	EventCustomerUpdated = "customer.updated"
+	EventPaymentCreated  = "payment.created"
+)
+
+// OutboxChannel is notified when a transaction that wrote outbox events
+// commits.
+const OutboxChannel = "outbox_events"
+
+// outboxRelayLockID is the advisory lock that keeps a single relay reading
+// the outbox at a time, which is what keeps events of an aggregate in order.
+const outboxRelayLockID = 7_384_001
+
+type OutboxStore struct {
+	db *db.DB
+}
+
+func NewOutboxStore(db *db.DB) *OutboxStore {
+	return &OutboxStore{db: db}
+}
+
+// OutboxEvent is a domain event recorded in the transaction that caused it.
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// StoreRentalEvent is a rental event together with the inventory item, and
+// so the store, it concerns.
+type StoreRentalEvent struct {
+	Event OutboxEvent
+	Item  InventoryItem
+}
+
+const outboxEventColumns = `id, aggregate_type, aggregate_id, event_type, payload, created_at`
+
+// recordEvent writes an event to the outbox within tx, so it is published if
+// and only if tx commits.
+func recordEvent(ctx context.Context, tx *sql.Tx, eventType, aggregateType string, aggregateID int64, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+	`
+	if _, err := tx.ExecContext(ctx, query, aggregateType, aggregateID, eventType, payload); err != nil {
+		return err
+	}
+
+	// Postgres holds notifications back until commit and folds duplicates,
+	// so the relay wakes up once per transaction.
+	_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, OutboxChannel)
+	return err
+}
+
+// RelayOutboxEvents passes up to limit unpublished events, oldest first, to
+// publish and marks those it accepted as published. Once publish fails for
+// an event, later events of the same aggregate are held back until it
+// succeeds, so every aggregate's events are published in order. Events can be
+// published more than once if the process dies before marking them.
+//
+// Only one caller relays at a time; the others return 0 straight away.
+func (s *OutboxStore) RelayOutboxEvents(ctx context.Context, limit int, publish func(ctx context.Context, event *OutboxEvent) error) (int, error) {
+	defer metrics.ObserveQuery("OutboxStore.RelayOutboxEvents")()
+
+	published := []int64{}
+	err := withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		var locked bool
+		if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxRelayLockID).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		query := `SELECT ` + outboxEventColumns + ` FROM outbox_events WHERE published_at IS NULL ORDER BY id LIMIT $1`
+		events, err := collectTxRows(ctx, tx, query, []any{limit}, scanOutboxEvent)
+		if err != nil {
+			return err
+		}
+
+		blocked := map[string]bool{}
+		for i := range events {
+			event := &events[i]
+			aggregate := fmt.Sprintf("%s:%d", event.AggregateType, event.AggregateID)
+			if blocked[aggregate] {
+				continue
+			}
+			if err := publish(ctx, event); err != nil {
+				blocked[aggregate] = true
+				continue
+			}
+			published = append(published, event.ID)
+		}
+
+		if len(published) == 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE outbox_events SET published_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`, pq.Int64Array(published))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(published), nil
+}
+
+// DeletePublishedOutboxEvents removes events published before before.
+func (s *OutboxStore) DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error) {
+	defer metrics.ObserveQuery("OutboxStore.DeletePublishedOutboxEvents")()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM outbox_events WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListStoreRentalEvents returns up to limit rental.created and
+// rental.returned events of a store's inventory with an ID above afterID,
+// oldest first.
+func (s *OutboxStore) ListStoreRentalEvents(ctx context.Context, storeID, afterID int64, limit int) ([]StoreRentalEvent, error) {
+	defer metrics.ObserveQuery("OutboxStore.ListStoreRentalEvents")()
+
+	query := `
+		SELECT o.id, o.aggregate_type, o.aggregate_id, o.event_type, o.payload, o.created_at,
+			i.inventory_id, i.film_id, i.store_id, i.last_update
+		FROM outbox_events o
+		JOIN inventory i ON i.inventory_id = (o.payload->>'inventory_id')::int
+		WHERE o.aggregate_type = 'rental'
+			AND o.event_type IN ('rental.created', 'rental.returned')
+			AND i.store_id = $1
+			AND o.id > $2
+		ORDER BY o.id
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db, query, []any{storeID, afterID, limit}, func(row rowScanner) (*StoreRentalEvent, error) {
+		var e StoreRentalEvent
+		err := row.Scan(
+			&e.Event.ID,
+			&e.Event.AggregateType,
+			&e.Event.AggregateID,
+			&e.Event.EventType,
+			&e.Event.Payload,
+			&e.Event.CreatedAt,
+			&e.Item.ID,
+			&e.Item.FilmID,
+			&e.Item.StoreID,
+			&e.Item.LastUpdate,
+		)
+		return &e, err
+	})
+}
+
+// Notify sends payload to the listeners of channel, on every replica.
+func (s *OutboxStore) Notify(ctx context.Context, channel, payload string) error {
+	defer metrics.ObserveQuery("OutboxStore.Notify")()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+	return err
+}
+
+// collectTxRows is collectRows for a query run within a transaction.
+func collectTxRows[T any](ctx context.Context, tx *sql.Tx, query string, args []any, scan func(row rowScanner) (*T, error)) ([]T, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+func scanOutboxEvent(row rowScanner) (*OutboxEvent, error) {
+	var event OutboxEvent
+	err := row.Scan(
+		&event.ID,
+		&event.AggregateType,
+		&event.AggregateID,
+		&event.EventType,
+		&event.Payload,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type OutboxTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *OutboxStore
+	customers   *CustomerStore
+	ctx         context.Context
+}
+
+func (suite *OutboxTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.pgContainer = pgContainer
+	suite.repository = NewOutboxStore(suite.pgContainer.DB)
+	suite.customers = NewCustomerStore(suite.pgContainer.DB)
+}
+
+func TestOutboxTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboxTestSuite))
+}
+
+func (suite *OutboxTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *OutboxTestSuite) TestRelayOutboxEvents() {
+	customer := &Customer{StoreID: 1, FirstName: "Outbox", LastName: "Tester", Email: "outbox@example.com"}
+	suite.Require().NoError(suite.customers.CreateCustomer(suite.ctx, customer))
+
+	customer.FirstName = "Outboxed"
+	suite.Require().NoError(suite.customers.UpdateCustomer(suite.ctx, customer, nil))
+
+	suite.T().Run("it should not record events of a rolled back transaction", func(t *testing.T) {
+		err := suite.customers.CreateCustomer(suite.ctx, &Customer{StoreID: 1, FirstName: "Outbox", LastName: "Tester", Email: "outbox@example.com"})
+		suite.Error(err)
+	})
+
+	suite.T().Run("it should hold back later events of an aggregate whose event failed", func(t *testing.T) {
+		var seen []string
+		relayed, err := suite.repository.RelayOutboxEvents(suite.ctx, 10, func(ctx context.Context, event *OutboxEvent) error {
+			seen = append(seen, event.EventType)
+			return errors.New("unavailable")
+		})
+		suite.NoError(err)
+		suite.Zero(relayed)
+		suite.Equal([]string{EventCustomerCreated}, seen)
+	})
+
+	suite.T().Run("it should relay events in order and only once", func(t *testing.T) {
+		var seen []*OutboxEvent
+		relayed, err := suite.repository.RelayOutboxEvents(suite.ctx, 10, func(ctx context.Context, event *OutboxEvent) error {
+			seen = append(seen, event)
+			return nil
+		})
+		suite.NoError(err)
+		suite.Equal(2, relayed)
+		suite.Require().Len(seen, 2)
+		suite.Equal(EventCustomerCreated, seen[0].EventType)
+		suite.Equal(EventCustomerUpdated, seen[1].EventType)
+		suite.Equal("customer", seen[1].AggregateType)
+		suite.Equal(int64(customer.ID), seen[1].AggregateID)
+		suite.Contains(string(seen[1].Payload), "Outboxed")
+
+		relayed, err = suite.repository.RelayOutboxEvents(suite.ctx, 10, func(ctx context.Context, event *OutboxEvent) error {
+			suite.Fail("event relayed twice", event.EventType)
+			return nil
+		})
+		suite.NoError(err)
+		suite.Zero(relayed)
+	})
+
+	suite.T().Run("it should delete published events past their retention", func(t *testing.T) {
+		deleted, err := suite.repository.DeletePublishedOutboxEvents(suite.ctx, time.Now().Add(time.Hour))
+		suite.NoError(err)
+		suite.Equal(int64(2), deleted)
+	})
+}
+
+func (suite *OutboxTestSuite) TestListStoreRentalEvents() {
+	rentals := NewRentalStore(suite.pgContainer.DB)
+
+	var inventoryID, storeID int64
+	err := suite.pgContainer.DB.QueryRowContext(suite.ctx, `
+		SELECT i.inventory_id, i.store_id FROM inventory i
+		WHERE NOT EXISTS (SELECT 1 FROM rental r WHERE r.inventory_id = i.inventory_id AND r.return_date IS NULL)
+		ORDER BY i.inventory_id DESC LIMIT 1
+	`).Scan(&inventoryID, &storeID)
+	suite.Require().NoError(err)
+
+	rental := &Rental{InventoryID: int(inventoryID), CustomerID: 1, StaffID: 1}
+	suite.Require().NoError(rentals.CreateRental(suite.ctx, rental))
+	_, err = rentals.ReturnRental(suite.ctx, int64(rental.ID))
+	suite.Require().NoError(err)
+
+	suite.T().Run("it should list the rental events of the store's inventory", func(t *testing.T) {
+		events, err := suite.repository.ListStoreRentalEvents(suite.ctx, storeID, 0, 10)
+		suite.NoError(err)
+		suite.Require().Len(events, 2)
+		suite.Equal(EventRentalCreated, events[0].Event.EventType)
+		suite.Equal(EventRentalReturned, events[1].Event.EventType)
+		suite.Equal(int(inventoryID), events[1].Item.ID)
+
+		events, err = suite.repository.ListStoreRentalEvents(suite.ctx, storeID, events[0].Event.ID, 10)
+		suite.NoError(err)
+		suite.Len(events, 1)
+	})
+
+	suite.T().Run("it should not list events of other stores", func(t *testing.T) {
+		events, err := suite.repository.ListStoreRentalEvents(suite.ctx, 3-storeID, 0, 10)
+		suite.NoError(err)
+		suite.Empty(events)
+	})
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+type PaymentStore struct {
+	db *db.DB
+}
+
+func NewPaymentStore(db *db.DB) *PaymentStore {
+	return &PaymentStore{db: db}
+}
+
+type Payment struct {
+	ID          int       `json:"id"`
+	CustomerID  int       `json:"customer_id"`
+	StaffID     int       `json:"staff_id"`
+	RentalID    int       `json:"rental_id"`
+	Amount      float64   `json:"amount"`
+	PaymentDate time.Time `json:"payment_date"`
+}
+
+// PaymentList is the list query whitelist for payments.
+var PaymentList = &listquery.Resource{
+	Fields: map[string]listquery.Field{
+		"id":           {Column: "payment_id", Type: listquery.Int, Filter: true, Sort: true},
+		"customer_id":  {Column: "customer_id", Type: listquery.Int, Filter: true},
+		"staff_id":     {Column: "staff_id", Type: listquery.Int, Filter: true},
+		"rental_id":    {Column: "rental_id", Type: listquery.Int, Filter: true},
+		"amount":       {Column: "amount", Type: listquery.Float, Filter: true, Sort: true},
+		"payment_date": {Column: "payment_date", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Key:          "id",
+	DefaultSort:  "-payment_date",
+	DefaultLimit: 50,
+	MaxLimit:     500,
+}
+
+const paymentColumns = `payment_id, customer_id, staff_id, rental_id, amount, payment_date`
+
+const paymentSelect = `SELECT ` + paymentColumns + ` FROM payment `
+
+// CreatePayment records a payment made now.
+func (s *PaymentStore) CreatePayment(ctx context.Context, payment *Payment) error {
+	defer metrics.ObserveQuery("PaymentStore.CreatePayment")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `
+			INSERT INTO payment (customer_id, staff_id, rental_id, amount, payment_date)
+			VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+			RETURNING ` + paymentColumns
+
+		created, err := scanPayment(tx.QueryRowContext(ctx, query, payment.CustomerID, payment.StaffID, payment.RentalID, payment.Amount))
+		if err != nil {
+			return translateError(err)
+		}
+		*payment = *created
+
+		if err := recordAudit(ctx, tx, "create", "payment", int64(payment.ID), nil, payment); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, EventPaymentCreated, "payment", int64(payment.ID), payment)
+	})
+}
+
+// ListPayments returns a page of payments and the cursor of the next page.
+func (s *PaymentStore) ListPayments(ctx context.Context, q *listquery.Query) ([]Payment, string, error) {
+	defer metrics.ObserveQuery("PaymentStore.ListPayments")()
+
+	fragments := q.Build()
+	query := paymentSelect + fragments.Where + fragments.OrderBy + fragments.Limit
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Reader(ctx).QueryContext(ctx, query, fragments.Args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	payments := []Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		payments = append(payments, *payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return listquery.Page(q, payments, func(payment Payment, field string) any {
+		switch field {
+		case "amount":
+			return payment.Amount
+		case "payment_date":
+			return payment.PaymentDate
+		default:
+			return payment.ID
+		}
+	})
+}
+
+// StreamPayments calls fn for every payment made in the filter's date range,
+// oldest first.
+func (s *PaymentStore) StreamPayments(ctx context.Context, filter ExportFilter, fn func(payment *Payment) error) error {
+	query := paymentSelect + `WHERE payment_date >= $1 AND payment_date < $2 ORDER BY payment_date, payment_id`
+
+	return streamQuery(ctx, s.db.Reader(ctx), "PaymentStore.StreamPayments", query, []any{filter.From, filter.To}, scanPayment, fn)
+}
+
+// GetPaymentsByCustomerIDs returns up to limit of the most recent payments of
+// each customer, newest first.
+func (s *PaymentStore) GetPaymentsByCustomerIDs(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Payment, error) {
+	defer metrics.ObserveQuery("PaymentStore.GetPaymentsByCustomerIDs")()
+
+	query := `
+		SELECT customer_id, ` + paymentColumns + `
+		FROM (
+			SELECT *, row_number() OVER (PARTITION BY customer_id ORDER BY payment_date DESC, payment_id DESC) AS position
+			FROM payment
+			WHERE customer_id = ANY($1)
+		) AS recent
+		WHERE position <= $2
+		ORDER BY customer_id, position
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return groupRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(customerIDs), limit}, scanPayment)
+}
+
+// GetPaymentsByRentalIDs returns the payments of each rental, oldest first.
+func (s *PaymentStore) GetPaymentsByRentalIDs(ctx context.Context, rentalIDs []int64) (map[int64][]Payment, error) {
+	defer metrics.ObserveQuery("PaymentStore.GetPaymentsByRentalIDs")()
+
+	query := `SELECT rental_id, ` + paymentColumns + ` FROM payment WHERE rental_id = ANY($1) ORDER BY payment_date, payment_id`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return groupRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(rentalIDs)}, scanPayment)
+}
+
+func scanPayment(row rowScanner) (*Payment, error) {
+	var payment Payment
+	err := row.Scan(
+		&payment.ID,
+		&payment.CustomerID,
+		&payment.StaffID,
+		&payment.RentalID,
+		&payment.Amount,
+		&payment.PaymentDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
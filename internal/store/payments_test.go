@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type PaymentsTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *PaymentStore
+	ctx         context.Context
+}
+
+func (suite *PaymentsTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.pgContainer = pgContainer
+	suite.repository = NewPaymentStore(suite.pgContainer.DB)
+}
+
+func TestPaymentsTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentsTestSuite))
+}
+
+func (suite *PaymentsTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *PaymentsTestSuite) TestListPayments() {
+	suite.T().Run("it should filter payments by amount", func(t *testing.T) {
+		q, err := listquery.Parse(url.Values{"filter[amount][gte]": {"10"}, "sort": {"-amount"}, "limit": {"10"}}, PaymentList)
+		suite.Require().NoError(err)
+
+		payments, cursor, err := suite.repository.ListPayments(suite.ctx, q)
+		suite.NoError(err)
+		suite.Len(payments, 10)
+		suite.NotEmpty(cursor)
+		for i, payment := range payments {
+			suite.GreaterOrEqual(payment.Amount, 10.0)
+			if i > 0 {
+				suite.LessOrEqual(payment.Amount, payments[i-1].Amount)
+			}
+		}
+	})
+}
+
+func (suite *PaymentsTestSuite) TestCreatePayment() {
+	suite.T().Run("it should record a payment for a rental", func(t *testing.T) {
+		var rentalID, customerID int
+		err := suite.pgContainer.DB.QueryRowContext(suite.ctx, `SELECT rental_id, customer_id FROM rental ORDER BY rental_id LIMIT 1`).Scan(&rentalID, &customerID)
+		suite.Require().NoError(err)
+
+		payment := &Payment{CustomerID: customerID, StaffID: 1, RentalID: rentalID, Amount: 2.99}
+		suite.NoError(suite.repository.CreatePayment(suite.ctx, payment))
+		suite.NotZero(payment.ID)
+		suite.Equal(2.99, payment.Amount)
+		suite.False(payment.PaymentDate.IsZero())
+	})
+
+	suite.T().Run("it should refuse an unknown rental", func(t *testing.T) {
+		err := suite.repository.CreatePayment(suite.ctx, &Payment{CustomerID: 1, StaffID: 1, RentalID: 1 << 30, Amount: 2.99})
+		suite.ErrorIs(err, ErrInvalidReference)
+	})
+}
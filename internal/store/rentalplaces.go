@@ -2,36 +2,57 @@ package store
 
 import (
 	"context"
-	"database/sql"
 	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
 )
 
 type RentalPlaceStore struct {
-	db *sql.DB
+	db *db.DB
 }
 
-func NewRentalPlaceStore(db *sql.DB) *RentalPlaceStore {
+func NewRentalPlaceStore(db *db.DB) *RentalPlaceStore {
 	return &RentalPlaceStore{db: db}
 }
 
+// RentalPlace is a row of the store table.
 type RentalPlace struct {
-	ID int `json:"id"`
+	ID             int       `json:"id"`
+	ManagerStaffID int       `json:"manager_staff_id"`
+	AddressID      int       `json:"address_id"`
+	LastUpdate     time.Time `json:"last_update"`
 }
 
+const rentalPlaceSelect = `
+	SELECT store_id, manager_staff_id, address_id, last_update
+	FROM store
+`
+
 func (s *RentalPlaceStore) GetRentalPlaceByID(ctx context.Context, id int64) (*RentalPlace, error) {
-	query := `
-		SELECT store_id
-		FROM store
-		WHERE store_id = $1
-	`
+	defer metrics.ObserveQuery("RentalPlaceStore.GetRentalPlaceByID")()
 
 	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	row := s.db.QueryRowContext(ctx, query, id)
+	return scanRentalPlace(s.db.Reader(ctx).QueryRowContext(ctx, rentalPlaceSelect+`WHERE store_id = $1`, id))
+}
+
+// GetRentalPlacesByIDs returns the stores with the given IDs in no particular
+// order.
+func (s *RentalPlaceStore) GetRentalPlacesByIDs(ctx context.Context, ids []int64) ([]RentalPlace, error) {
+	defer metrics.ObserveQuery("RentalPlaceStore.GetRentalPlacesByIDs")()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), rentalPlaceSelect+`WHERE store_id = ANY($1)`, []any{pq.Int64Array(ids)}, scanRentalPlace)
+}
 
+func scanRentalPlace(row rowScanner) (*RentalPlace, error) {
 	var rentalPlace RentalPlace
-	err := row.Scan(&rentalPlace.ID)
+	err := row.Scan(&rentalPlace.ID, &rentalPlace.ManagerStaffID, &rentalPlace.AddressID, &rentalPlace.LastUpdate)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +45,8 @@ func (suite *RentalPlacesTestSuite) TestGetRentalPlaceByID() {
 		suite.NoError(err)
 		suite.NotNil(rentalPlace)
 		suite.Equal(rentalPlace.ID, 1)
+		suite.Equal(1, rentalPlace.ManagerStaffID)
+		suite.False(rentalPlace.LastUpdate.IsZero())
 	})
 
 	suite.T().Run("it should return nil if the rental place does not exist", func(t *testing.T) {
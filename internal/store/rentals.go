@@ -4,38 +4,334 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
 )
 
 type RentalStore struct {
-	db *sql.DB
+	db *db.DB
 }
 
-func NewRentalStore(db *sql.DB) *RentalStore {
+func NewRentalStore(db *db.DB) *RentalStore {
 	return &RentalStore{db: db}
 }
 
 type Rental struct {
-	ID         int       `json:"id"`
-	RentalDate time.Time `json:"rental_date"`
+	ID          int        `json:"id"`
+	RentalDate  time.Time  `json:"rental_date"`
+	InventoryID int        `json:"inventory_id"`
+	CustomerID  int        `json:"customer_id"`
+	ReturnDate  *time.Time `json:"return_date"`
+	StaffID     int        `json:"staff_id"`
+	LastUpdate  time.Time  `json:"last_update"`
+}
+
+// RentalList is the list query whitelist for rentals.
+var RentalList = &listquery.Resource{
+	Fields: map[string]listquery.Field{
+		"id":           {Column: "rental_id", Type: listquery.Int, Filter: true, Sort: true},
+		"rental_date":  {Column: "rental_date", Type: listquery.Time, Filter: true, Sort: true},
+		"inventory_id": {Column: "inventory_id", Type: listquery.Int, Filter: true},
+		"customer_id":  {Column: "customer_id", Type: listquery.Int, Filter: true},
+		"return_date":  {Column: "return_date", Type: listquery.Time, Filter: true},
+		"staff_id":     {Column: "staff_id", Type: listquery.Int, Filter: true},
+		"last_update":  {Column: "last_update", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Key:          "id",
+	DefaultSort:  "-rental_date",
+	DefaultLimit: 50,
+	MaxLimit:     500,
 }
 
+const rentalColumns = `rental_id, rental_date, inventory_id, customer_id, return_date, staff_id, last_update`
+
+const rentalSelect = `SELECT ` + rentalColumns + ` FROM rental `
+
 func (s *RentalStore) GetRental(ctx context.Context, id int64) (*Rental, error) {
+	defer metrics.ObserveQuery("RentalStore.GetRental")()
+
+	query := rentalSelect + `WHERE rental_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return scanRental(s.db.QueryRowContext(ctx, query, id))
+}
+
+// ListRentals returns a page of rentals and the cursor of the next page.
+func (s *RentalStore) ListRentals(ctx context.Context, q *listquery.Query) ([]Rental, string, error) {
+	defer metrics.ObserveQuery("RentalStore.ListRentals")()
+
+	fragments := q.Build()
+	query := rentalSelect + fragments.Where + fragments.OrderBy + fragments.Limit
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Reader(ctx).QueryContext(ctx, query, fragments.Args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	rentals := []Rental{}
+	for rows.Next() {
+		rental, err := scanRental(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		rentals = append(rentals, *rental)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return listquery.Page(q, rentals, func(rental Rental, field string) any {
+		switch field {
+		case "rental_date":
+			return rental.RentalDate
+		case "last_update":
+			return rental.LastUpdate
+		default:
+			return rental.ID
+		}
+	})
+}
+
+// StreamRentals calls fn for every rental made in the filter's date range,
+// oldest first.
+func (s *RentalStore) StreamRentals(ctx context.Context, filter ExportFilter, fn func(rental *Rental) error) error {
+	query := rentalSelect + `WHERE rental_date >= $1 AND rental_date < $2 ORDER BY rental_date, rental_id`
+
+	return streamQuery(ctx, s.db.Reader(ctx), "RentalStore.StreamRentals", query, []any{filter.From, filter.To}, scanRental, fn)
+}
+
+// GetRentalsByIDs returns the rentals with the given IDs in no particular
+// order.
+func (s *RentalStore) GetRentalsByIDs(ctx context.Context, ids []int64) ([]Rental, error) {
+	defer metrics.ObserveQuery("RentalStore.GetRentalsByIDs")()
+
+	query := rentalSelect + `WHERE rental_id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(ids)}, scanRental)
+}
+
+// GetRentalsByCustomerIDs returns up to limit of the most recent rentals of
+// each customer, newest first.
+func (s *RentalStore) GetRentalsByCustomerIDs(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Rental, error) {
+	defer metrics.ObserveQuery("RentalStore.GetRentalsByCustomerIDs")()
+
 	query := `
-		SELECT rental_id,rental_date
-		FROM rental
-		WHERE rental_id = $1
+		SELECT customer_id, ` + rentalColumns + `
+		FROM (
+			SELECT *, row_number() OVER (PARTITION BY customer_id ORDER BY rental_date DESC, rental_id DESC) AS position
+			FROM rental
+			WHERE customer_id = ANY($1)
+		) AS recent
+		WHERE position <= $2
+		ORDER BY customer_id, position
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	row := s.db.QueryRowContext(ctx, query, id)
+	return groupRows(ctx, s.db.Reader(ctx), query, []any{pq.Int64Array(customerIDs), limit}, scanRental)
+}
+
+// CreateRental checks out an inventory item to a customer. It fails with
+// ErrInventoryUnavailable while the item is rented out and with
+// ErrCustomerCheckoutBlocked while dunning blocks the customer.
+func (s *RentalStore) CreateRental(ctx context.Context, rental *Rental) error {
+	defer metrics.ObserveQuery("RentalStore.CreateRental")()
 
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		// Locking the item serializes concurrent checkouts of the same copy.
+		var inventoryID int
+		err := tx.QueryRowContext(ctx, `SELECT inventory_id FROM inventory WHERE inventory_id = $1 FOR UPDATE`, rental.InventoryID).Scan(&inventoryID)
+		if err == sql.ErrNoRows {
+			return ErrInvalidReference
+		}
+		if err != nil {
+			return err
+		}
+
+		blocked, err := customerCheckoutBlocked(ctx, tx, rental.CustomerID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrCustomerCheckoutBlocked
+		}
+
+		var rentedOut bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM rental WHERE inventory_id = $1 AND return_date IS NULL)`, rental.InventoryID).Scan(&rentedOut)
+		if err != nil {
+			return err
+		}
+		if rentedOut {
+			return ErrInventoryUnavailable
+		}
+
+		query := `
+			INSERT INTO rental (rental_date, inventory_id, customer_id, staff_id)
+			VALUES (CURRENT_TIMESTAMP, $1, $2, $3)
+			RETURNING ` + rentalColumns
+
+		created, err := scanRental(tx.QueryRowContext(ctx, query, rental.InventoryID, rental.CustomerID, rental.StaffID))
+		if err != nil {
+			return translateError(err)
+		}
+		*rental = *created
+
+		if err := recordAudit(ctx, tx, "create", "rental", int64(rental.ID), nil, rental); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, EventRentalCreated, "rental", int64(rental.ID), rental)
+	})
+}
+
+// ReturnRental records the return of a rental and returns it, which ends its
+// dunning case. It fails with ErrRentalAlreadyReturned if it was returned
+// before.
+func (s *RentalStore) ReturnRental(ctx context.Context, id int64) (*Rental, error) {
+	defer metrics.ObserveQuery("RentalStore.ReturnRental")()
+
+	var rental *Rental
+	err := withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		before, err := scanRental(tx.QueryRowContext(ctx, rentalSelect+`WHERE rental_id = $1 FOR UPDATE`, id))
+		if err != nil {
+			return err
+		}
+		if before.ReturnDate != nil {
+			return ErrRentalAlreadyReturned
+		}
+
+		query := `
+			UPDATE rental
+			SET return_date = CURRENT_TIMESTAMP, last_update = CURRENT_TIMESTAMP
+			WHERE rental_id = $1
+			RETURNING ` + rentalColumns
+
+		rental, err = scanRental(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(ctx, tx, "return", "rental", id, before, rental); err != nil {
+			return err
+		}
+
+		if err := closeDunningCase(ctx, tx, id); err != nil {
+			return err
+		}
+
+		return recordEvent(ctx, tx, EventRentalReturned, "rental", id, rental)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rental, nil
+}
+
+// OverdueRental is an unreturned rental past the rental duration of its
+// film.
+type OverdueRental struct {
+	Rental
+	DueDate time.Time `json:"due_date"`
+}
+
+// MarkOverdueRentals flags the unreturned rentals that became overdue since
+// the last call and records a rental.overdue event for each, so every rental
+// is reported once.
+func (s *RentalStore) MarkOverdueRentals(ctx context.Context) ([]OverdueRental, error) {
+	defer metrics.ObserveQuery("RentalStore.MarkOverdueRentals")()
+
+	overdue := []OverdueRental{}
+	err := withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		query := `
+			UPDATE rental r
+			SET overdue_at = CURRENT_TIMESTAMP
+			FROM inventory i
+			JOIN film f ON f.film_id = i.film_id
+			WHERE i.inventory_id = r.inventory_id
+				AND r.return_date IS NULL
+				AND r.overdue_at IS NULL
+				AND r.rental_date + f.rental_duration * INTERVAL '1 day' < CURRENT_TIMESTAMP
+			RETURNING r.rental_id, r.rental_date, r.inventory_id, r.customer_id, r.return_date, r.staff_id, r.last_update,
+				r.rental_date + f.rental_duration * INTERVAL '1 day'
+		`
+		rentals, err := collectTxRows(ctx, tx, query, nil, func(row rowScanner) (*OverdueRental, error) {
+			var o OverdueRental
+			rental, err := scanRental(dueDateRow{row: row, dueDate: &o.DueDate})
+			if err != nil {
+				return nil, err
+			}
+			o.Rental = *rental
+			return &o, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for i := range rentals {
+			if err := recordEvent(ctx, tx, EventRentalOverdue, "rental", int64(rentals[i].ID), rentals[i]); err != nil {
+				return err
+			}
+		}
+		overdue = rentals
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return overdue, nil
+}
+
+// dueDateRow scans a trailing due date column after the rental columns.
+type dueDateRow struct {
+	row     rowScanner
+	dueDate *time.Time
+}
+
+func (d dueDateRow) Scan(dest ...any) error {
+	return d.row.Scan(append(dest, d.dueDate)...)
+}
+
+func scanRental(row rowScanner) (*Rental, error) {
 	var rental Rental
-	err := row.Scan(&rental.ID, &rental.RentalDate)
+	var returnDate sql.NullTime
+	err := row.Scan(
+		&rental.ID,
+		&rental.RentalDate,
+		&rental.InventoryID,
+		&rental.CustomerID,
+		&returnDate,
+		&rental.StaffID,
+		&rental.LastUpdate,
+	)
 	if err != nil {
 		return nil, err
 	}
 
+	if returnDate.Valid {
+		rental.ReturnDate = &returnDate.Time
+	}
+
 	return &rental, nil
 }
@@ -4,8 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
 	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/suite"
@@ -62,3 +65,147 @@ func (suite *RentalsTestSuite) TestGetRental() {
 		suite.Nil(rental)
 	})
 }
+
+func (suite *RentalsTestSuite) TestListRentals() {
+	suite.T().Run("it should return the most recent rentals of a customer first", func(t *testing.T) {
+		q, err := listquery.Parse(url.Values{"filter[customer_id]": {"1"}, "limit": {"5"}}, RentalList)
+		suite.Require().NoError(err)
+
+		rentals, cursor, err := suite.repository.ListRentals(suite.ctx, q)
+		suite.NoError(err)
+		suite.Len(rentals, 5)
+		suite.NotEmpty(cursor)
+		for i, rental := range rentals {
+			suite.Equal(1, rental.CustomerID)
+			if i > 0 {
+				suite.False(rental.RentalDate.After(rentals[i-1].RentalDate))
+			}
+		}
+
+		q, err = listquery.Parse(url.Values{"filter[customer_id]": {"1"}, "limit": {"5"}, "cursor": {cursor}}, RentalList)
+		suite.Require().NoError(err)
+
+		next, _, err := suite.repository.ListRentals(suite.ctx, q)
+		suite.NoError(err)
+		suite.NotEmpty(next)
+		suite.False(next[0].RentalDate.After(rentals[4].RentalDate))
+		suite.NotEqual(rentals[4].ID, next[0].ID)
+	})
+}
+
+func (suite *RentalsTestSuite) TestStreamRentals() {
+	suite.T().Run("it should stream every rental in the date range in order", func(t *testing.T) {
+		filter := ExportFilter{
+			From: time.Date(2005, 6, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2005, 7, 1, 0, 0, 0, 0, time.UTC),
+		}
+
+		var streamed []Rental
+		err := suite.repository.StreamRentals(suite.ctx, filter, func(rental *Rental) error {
+			streamed = append(streamed, *rental)
+			return nil
+		})
+		suite.NoError(err)
+
+		var count int
+		err = suite.pgContainer.DB.QueryRow("SELECT count(*) FROM rental WHERE rental_date >= $1 AND rental_date < $2", filter.From, filter.To).Scan(&count)
+		suite.NoError(err)
+		suite.Greater(count, exportBatchSize)
+		suite.Len(streamed, count)
+		for i := 1; i < len(streamed); i++ {
+			suite.False(streamed[i].RentalDate.Before(streamed[i-1].RentalDate))
+		}
+	})
+
+	suite.T().Run("it should stop at the first callback error", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		err := suite.repository.StreamRentals(suite.ctx, ExportFilter{From: time.Time{}, To: time.Now()}, func(rental *Rental) error {
+			calls++
+			return stop
+		})
+		suite.ErrorIs(err, stop)
+		suite.Equal(1, calls)
+	})
+}
+
+func (suite *RentalsTestSuite) TestGetRentalsByCustomerIDs() {
+	suite.T().Run("it should return the most recent rentals of each customer up to the limit", func(t *testing.T) {
+		rentals, err := suite.repository.GetRentalsByCustomerIDs(suite.ctx, []int64{1, 2}, 3)
+		suite.NoError(err)
+		for _, customerID := range []int64{1, 2} {
+			suite.Len(rentals[customerID], 3)
+			for i, rental := range rentals[customerID] {
+				suite.Equal(int(customerID), rental.CustomerID)
+				if i > 0 {
+					suite.False(rental.RentalDate.After(rentals[customerID][i-1].RentalDate))
+				}
+			}
+		}
+	})
+}
+
+func (suite *RentalsTestSuite) TestCreateAndReturnRental() {
+	var inventoryID int
+	err := suite.pgContainer.DB.QueryRowContext(suite.ctx, `
+		SELECT i.inventory_id FROM inventory i
+		WHERE NOT EXISTS (SELECT 1 FROM rental r WHERE r.inventory_id = i.inventory_id AND r.return_date IS NULL)
+		ORDER BY i.inventory_id LIMIT 1
+	`).Scan(&inventoryID)
+	suite.Require().NoError(err)
+
+	rental := &Rental{InventoryID: inventoryID, CustomerID: 1, StaffID: 1}
+
+	suite.T().Run("it should check out an available item", func(t *testing.T) {
+		err := suite.repository.CreateRental(suite.ctx, rental)
+		suite.NoError(err)
+		suite.NotZero(rental.ID)
+		suite.Nil(rental.ReturnDate)
+	})
+
+	suite.T().Run("it should refuse an item that is rented out", func(t *testing.T) {
+		err := suite.repository.CreateRental(suite.ctx, &Rental{InventoryID: inventoryID, CustomerID: 2, StaffID: 1})
+		suite.ErrorIs(err, ErrInventoryUnavailable)
+	})
+
+	suite.T().Run("it should refuse an unknown item", func(t *testing.T) {
+		err := suite.repository.CreateRental(suite.ctx, &Rental{InventoryID: 1 << 30, CustomerID: 1, StaffID: 1})
+		suite.ErrorIs(err, ErrInvalidReference)
+	})
+
+	suite.T().Run("it should return the rental once", func(t *testing.T) {
+		returned, err := suite.repository.ReturnRental(suite.ctx, int64(rental.ID))
+		suite.NoError(err)
+		suite.NotNil(returned.ReturnDate)
+
+		_, err = suite.repository.ReturnRental(suite.ctx, int64(rental.ID))
+		suite.ErrorIs(err, ErrRentalAlreadyReturned)
+
+		_, err = suite.repository.ReturnRental(suite.ctx, 1<<30)
+		suite.ErrorIs(err, sql.ErrNoRows)
+	})
+}
+
+func (suite *RentalsTestSuite) TestMarkOverdueRentals() {
+	suite.T().Run("it should report every overdue rental once", func(t *testing.T) {
+		overdue, err := suite.repository.MarkOverdueRentals(suite.ctx)
+		suite.NoError(err)
+		suite.NotEmpty(overdue)
+		for _, rental := range overdue {
+			suite.Nil(rental.ReturnDate)
+			suite.True(rental.DueDate.After(rental.RentalDate))
+			suite.True(rental.DueDate.Before(time.Now()))
+		}
+
+		var events int
+		err = suite.pgContainer.DB.QueryRowContext(suite.ctx, `
+			SELECT count(*) FROM outbox_events WHERE event_type = $1 AND aggregate_id = $2
+		`, EventRentalOverdue, overdue[0].ID).Scan(&events)
+		suite.NoError(err)
+		suite.Equal(1, events)
+
+		overdue, err = suite.repository.MarkOverdueRentals(suite.ctx)
+		suite.NoError(err)
+		suite.Empty(overdue)
+	})
+}
@@ -2,15 +2,17 @@ package store
 
 import (
 	"context"
-	"database/sql"
 	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
 )
 
 type RoleStore struct {
-	db *sql.DB
+	db *db.DB
 }
 
-func NewRoleStore(db *sql.DB) *RoleStore {
+func NewRoleStore(db *db.DB) *RoleStore {
 	return &RoleStore{db: db}
 }
 
@@ -21,6 +23,8 @@ type Role struct {
 }
 
 func (s *RoleStore) GetRoleByName(ctx context.Context, name string) (*Role, error) {
+	defer metrics.ObserveQuery("RoleStore.GetRoleByName")()
+
 	query := `
 		SELECT id, name, level
 		FROM roles
@@ -42,6 +46,8 @@ func (s *RoleStore) GetRoleByName(ctx context.Context, name string) (*Role, erro
 }
 
 func (s *RoleStore) GetRoleByID(ctx context.Context, id int64) (*Role, error) {
+	defer metrics.ObserveQuery("RoleStore.GetRoleByID")()
+
 	query := `
 		SELECT id, name, level
 		FROM roles
@@ -58,3 +64,24 @@ func (s *RoleStore) GetRoleByID(ctx context.Context, id int64) (*Role, error) {
 
 	return &role, nil
 }
+
+func (s *RoleStore) ListRoles(ctx context.Context) ([]Role, error) {
+	defer metrics.ObserveQuery("RoleStore.ListRoles")()
+
+	query := `
+		SELECT id, name, level
+		FROM roles
+		ORDER BY level DESC, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db, query, nil, func(row rowScanner) (*Role, error) {
+		var role Role
+		if err := row.Scan(&role.ID, &role.Name, &role.Level); err != nil {
+			return nil, err
+		}
+		return &role, nil
+	})
+}
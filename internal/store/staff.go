@@ -4,22 +4,35 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
 )
 
 type StaffStore struct {
-	db *sql.DB
+	db *db.DB
 }
 
-func NewStaffStore(db *sql.DB) *StaffStore {
+func NewStaffStore(db *db.DB) *StaffStore {
 	return &StaffStore{db: db}
 }
 
 type Staff struct {
-	ID     int  `json:"id"`
-	UserID *int `json:"user_id"`
+	ID         int       `json:"id"`
+	UserID     *int      `json:"user_id"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Email      string    `json:"email"`
+	StoreID    int64     `json:"store_id"`
+	Active     bool      `json:"active"`
+	Username   string    `json:"username"`
+	LastUpdate time.Time `json:"last_update"`
 }
 
 func (s *StaffStore) GetStaffByEmail(ctx context.Context, email string) (*Staff, error) {
+	defer metrics.ObserveQuery("StaffStore.GetStaffByEmail")()
+
 	query := `
 		SELECT staff_id, user_id
 		FROM staff
@@ -45,3 +58,64 @@ func (s *StaffStore) GetStaffByEmail(ctx context.Context, email string) (*Staff,
 
 	return &staff, nil
 }
+
+const staffByIDSelect = `
+	SELECT staff_id, user_id, first_name, last_name, COALESCE(email, ''), store_id, active, username, last_update
+	FROM staff
+`
+
+func (s *StaffStore) GetStaffByID(ctx context.Context, id int64) (*Staff, error) {
+	defer metrics.ObserveQuery("StaffStore.GetStaffByID")()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return scanStaff(s.db.Reader(ctx).QueryRowContext(ctx, staffByIDSelect+`WHERE staff_id = $1`, id))
+}
+
+// GetStaffByUserID returns the staff member signed in as the given user.
+func (s *StaffStore) GetStaffByUserID(ctx context.Context, userID int64) (*Staff, error) {
+	defer metrics.ObserveQuery("StaffStore.GetStaffByUserID")()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return scanStaff(s.db.QueryRowContext(ctx, staffByIDSelect+`WHERE user_id = $1`, userID))
+}
+
+// GetStaffByIDs returns the staff members with the given IDs in no particular
+// order.
+func (s *StaffStore) GetStaffByIDs(ctx context.Context, ids []int64) ([]Staff, error) {
+	defer metrics.ObserveQuery("StaffStore.GetStaffByIDs")()
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), staffByIDSelect+`WHERE staff_id = ANY($1)`, []any{pq.Int64Array(ids)}, scanStaff)
+}
+
+func scanStaff(row rowScanner) (*Staff, error) {
+	var staff Staff
+	var userID sql.NullInt64
+	err := row.Scan(
+		&staff.ID,
+		&userID,
+		&staff.FirstName,
+		&staff.LastName,
+		&staff.Email,
+		&staff.StoreID,
+		&staff.Active,
+		&staff.Username,
+		&staff.LastUpdate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		userIDInt := int(userID.Int64)
+		staff.UserID = &userIDInt
+	}
+
+	return &staff, nil
+}
@@ -54,3 +54,19 @@ func (suite *StaffTestSuite) TestGetStaffByEmail() {
 		suite.Nil(staff)
 	})
 }
+
+func (suite *StaffTestSuite) TestGetStaffByID() {
+	suite.T().Run("it should return an existing staff member", func(t *testing.T) {
+		staff, err := suite.repository.GetStaffByID(suite.ctx, 1)
+		suite.NoError(err)
+		suite.Equal("Mike", staff.FirstName)
+		suite.Equal("Mike.Hillyer@sakilastaff.com", staff.Email)
+		suite.False(staff.LastUpdate.IsZero())
+	})
+
+	suite.T().Run("it should return sql.ErrNoRows if the staff member does not exist", func(t *testing.T) {
+		staff, err := suite.repository.GetStaffByID(suite.ctx, 10000)
+		suite.True(errors.Is(err, sql.ErrNoRows))
+		suite.Nil(staff)
+	})
+}
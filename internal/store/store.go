@@ -5,8 +5,17 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/listquery"
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var tracer = otel.Tracer("github.com/andras-szesztai/dev-rental-api/internal/store")
+
 var (
 	ErrEmailAlreadyExists    = errors.New("email already exists")
 	ErrUsernameAlreadyExists = errors.New("username already exists")
@@ -16,44 +25,171 @@ type Store struct {
 	Users interface {
 		RegisterUser(ctx context.Context, user *User) error
 		GetUserByID(ctx context.Context, id int64) (*User, error)
+		GetUserByEmail(ctx context.Context, email string) (*User, error)
+		GetUserByUsername(ctx context.Context, username string) (*User, error)
+		ListUsers(ctx context.Context) ([]User, error)
+		UpdateUserRole(ctx context.Context, id int64, role *Role) error
+		UpdateUserPassword(ctx context.Context, id int64, password utils.Password) (time.Time, error)
+		RevokeUserTokens(ctx context.Context, id int64) (time.Time, error)
 	}
 	Staff interface {
 		GetStaffByEmail(ctx context.Context, email string) (*Staff, error)
+		GetStaffByID(ctx context.Context, id int64) (*Staff, error)
+		GetStaffByIDs(ctx context.Context, ids []int64) ([]Staff, error)
+		GetStaffByUserID(ctx context.Context, userID int64) (*Staff, error)
 	}
 	Customers interface {
 		CreateCustomer(ctx context.Context, customer *Customer) error
 		GetCustomerByEmail(ctx context.Context, email string) (*Customer, error)
+		GetCustomerByID(ctx context.Context, id int64) (*Customer, error)
+		GetCustomersByIDs(ctx context.Context, ids []int64) ([]Customer, error)
+		UpdateCustomer(ctx context.Context, customer *Customer, expectedVersion *time.Time) error
+		ListCustomers(ctx context.Context, q *listquery.Query) ([]Customer, string, error)
+	}
+	Films interface {
+		GetFilmByID(ctx context.Context, id int64) (*Film, error)
+		GetFilmsByIDs(ctx context.Context, ids []int64) ([]Film, error)
+		GetFilmsByActorIDs(ctx context.Context, actorIDs []int64) (map[int64][]Film, error)
+		ListFilms(ctx context.Context, q *listquery.Query) ([]Film, string, error)
 	}
 	Roles interface {
 		GetRoleByName(ctx context.Context, name string) (*Role, error)
 		GetRoleByID(ctx context.Context, id int64) (*Role, error)
+		ListRoles(ctx context.Context) ([]Role, error)
 	}
 	Rentals interface {
 		GetRental(ctx context.Context, id int64) (*Rental, error)
+		GetRentalsByIDs(ctx context.Context, ids []int64) ([]Rental, error)
+		GetRentalsByCustomerIDs(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Rental, error)
+		ListRentals(ctx context.Context, q *listquery.Query) ([]Rental, string, error)
+		StreamRentals(ctx context.Context, filter ExportFilter, fn func(rental *Rental) error) error
+		CreateRental(ctx context.Context, rental *Rental) error
+		ReturnRental(ctx context.Context, id int64) (*Rental, error)
+		MarkOverdueRentals(ctx context.Context) ([]OverdueRental, error)
+	}
+	Payments interface {
+		CreatePayment(ctx context.Context, payment *Payment) error
+		ListPayments(ctx context.Context, q *listquery.Query) ([]Payment, string, error)
+		StreamPayments(ctx context.Context, filter ExportFilter, fn func(payment *Payment) error) error
+		GetPaymentsByCustomerIDs(ctx context.Context, customerIDs []int64, limit int) (map[int64][]Payment, error)
+		GetPaymentsByRentalIDs(ctx context.Context, rentalIDs []int64) (map[int64][]Payment, error)
 	}
 	RentalPlaces interface {
 		GetRentalPlaceByID(ctx context.Context, id int64) (*RentalPlace, error)
+		GetRentalPlacesByIDs(ctx context.Context, ids []int64) ([]RentalPlace, error)
+	}
+	Actors interface {
+		GetActorsByIDs(ctx context.Context, ids []int64) ([]Actor, error)
+		GetActorsByFilmIDs(ctx context.Context, filmIDs []int64) (map[int64][]Actor, error)
+	}
+	Categories interface {
+		ListCategories(ctx context.Context) ([]Category, error)
+		GetCategoriesByFilmIDs(ctx context.Context, filmIDs []int64) (map[int64][]Category, error)
+	}
+	Inventory interface {
+		GetInventoryItemsByIDs(ctx context.Context, ids []int64) ([]InventoryItem, error)
+	}
+	Webhooks interface {
+		CreateWebhookEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+		ListWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error)
+		DeleteWebhookEndpoint(ctx context.Context, id int64) error
+		EnqueueWebhookEvent(ctx context.Context, eventID, eventType string, payload []byte) (int64, error)
+		ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+		CompleteWebhookDelivery(ctx context.Context, id int64, statusCode int) error
+		FailWebhookDelivery(ctx context.Context, id int64, result WebhookFailure) error
+		ListDeadWebhookDeliveries(ctx context.Context, endpointID int64, limit int) ([]WebhookDelivery, error)
+		ReplayWebhookDelivery(ctx context.Context, id int64) error
+	}
+	Outbox interface {
+		RelayOutboxEvents(ctx context.Context, limit int, publish func(ctx context.Context, event *OutboxEvent) error) (int, error)
+		DeletePublishedOutboxEvents(ctx context.Context, before time.Time) (int64, error)
+		ListStoreRentalEvents(ctx context.Context, storeID, afterID int64, limit int) ([]StoreRentalEvent, error)
+		Notify(ctx context.Context, channel, payload string) error
+	}
+	Audit interface {
+		ListAuditEntries(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+	}
+	Idempotency interface {
+		BeginIdempotentRequest(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, bool, error)
+		CompleteIdempotentRequest(ctx context.Context, userID int, key string, status int, headers map[string]string, body []byte) error
+		ReleaseIdempotentRequest(ctx context.Context, userID int, key string) error
+		DeleteExpiredIdempotencyKeys(ctx context.Context, completedBefore, inFlightBefore time.Time) (int64, error)
+	}
+	Dunning interface {
+		OpenDunningCases(ctx context.Context) (int64, error)
+		ListOpenDunningCases(ctx context.Context, afterRentalID int64, limit int) ([]DunningCase, error)
+		GetDunningCase(ctx context.Context, rentalID int64) (*DunningCase, error)
+		RecordDunningReminder(ctx context.Context, rentalID int64, reminder int) error
+		BlockDunningCustomer(ctx context.Context, rentalID int64) error
+		MarkDunningRentalLost(ctx context.Context, rentalID int64) (*CustomerCharge, error)
+		UpdateDunningCase(ctx context.Context, rentalID int64, step, note string) (*DunningCase, error)
+	}
+	Notifications interface {
+		GetNotificationPreferences(ctx context.Context, customerID int64) (*NotificationPreferences, error)
+		UpdateNotificationPreferences(ctx context.Context, prefs *NotificationPreferences) error
+		RecordNotification(ctx context.Context, entry *NotificationLogEntry) error
+		ListNotifications(ctx context.Context, customerID int64, limit int) ([]NotificationLogEntry, error)
+	}
+	Jobs interface {
+		StartJobRun(ctx context.Context, name, trigger string, triggeredBy *int) (*JobRun, error)
+		FinishJobRun(ctx context.Context, run *JobRun, jobErr error) error
+		ListJobRuns(ctx context.Context, name string, limit int) ([]JobRun, error)
+		DeleteJobRuns(ctx context.Context, before time.Time) (int64, error)
+	}
+	Health interface {
+		Ping(ctx context.Context) error
+		MigrationVersion(ctx context.Context) (uint, bool, error)
+		Stats() sql.DBStats
+		Replica() db.ReplicaStatus
 	}
 }
 
-func NewStore(db *sql.DB) *Store {
+// NewStore builds the stores on db. Read-only methods that can tolerate
+// replication lag, like the catalog, lists, exports and the GraphQL batch
+// loads, read from the replica of db; authentication, background processing
+// and everything inside a transaction stays on the primary.
+func NewStore(db *db.DB) *Store {
 	return &Store{
-		Rentals:      NewRentalStore(db),
-		Users:        NewUserStore(db),
-		RentalPlaces: NewRentalPlaceStore(db),
-		Staff:        NewStaffStore(db),
-		Customers:    NewCustomerStore(db),
-		Roles:        NewRoleStore(db),
+		Rentals:       NewRentalStore(db),
+		Payments:      NewPaymentStore(db),
+		Users:         NewUserStore(db),
+		RentalPlaces:  NewRentalPlaceStore(db),
+		Staff:         NewStaffStore(db),
+		Customers:     NewCustomerStore(db),
+		Films:         NewFilmStore(db),
+		Actors:        NewActorStore(db),
+		Categories:    NewCategoryStore(db),
+		Inventory:     NewInventoryStore(db),
+		Roles:         NewRoleStore(db),
+		Webhooks:      NewWebhookStore(db),
+		Outbox:        NewOutboxStore(db),
+		Audit:         NewAuditStore(db),
+		Idempotency:   NewIdempotencyStore(db),
+		Jobs:          NewJobStore(db),
+		Dunning:       NewDunningStore(db),
+		Notifications: NewNotificationStore(db),
+		Health:        NewHealthStore(db),
 	}
 }
 
-func withTx(db *sql.DB, ctx context.Context, fn func(tx *sql.Tx) error) error {
-	tx, err := db.BeginTx(ctx, nil)
+// withTx runs fn in a transaction on the primary. After a commit, the
+// request's later reads go to the primary too, see db.WithSession.
+func withTx(pool *db.DB, ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) (err error) {
+	ctx, span := tracer.Start(ctx, "store.withTx")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	tx, err := pool.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	if err := fn(tx); err != nil {
+	if err := fn(ctx, tx); err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
 			return fmt.Errorf("failed to rollback transaction: %w (original error: %v)", rbErr, err)
 		}
@@ -63,6 +199,75 @@ func withTx(db *sql.DB, ctx context.Context, fn func(tx *sql.Tx) error) error {
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	db.MarkWritten(ctx)
 
 	return nil
 }
+
+// queryer is implemented by *sql.DB, the replica returned by db.Reader, and
+// *db.DB, the primary.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// keyedRow scans a leading key column, e.g. the film_id of a film_actor
+// join, before the columns of the wrapped scan function.
+type keyedRow struct {
+	row rowScanner
+	key *int64
+}
+
+func (k keyedRow) Scan(dest ...any) error {
+	return k.row.Scan(append([]any{k.key}, dest...)...)
+}
+
+// collectRows scans every row of a query.
+func collectRows[T any](ctx context.Context, db queryer, query string, args []any, scan func(row rowScanner) (*T, error)) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// groupRows scans rows whose first column is the key they are grouped by, as
+// returned by the batch queries behind the GraphQL loaders.
+func groupRows[T any](ctx context.Context, db queryer, query string, args []any, scan func(row rowScanner) (*T, error)) (map[int64][]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := map[int64][]T{}
+	for rows.Next() {
+		var key int64
+		item, err := scan(keyedRow{row: rows, key: &key})
+		if err != nil {
+			return nil, err
+		}
+		groups[key] = append(groups[key], *item)
+	}
+	return groups, rows.Err()
+}
+
+// SameVersion reports whether two last_update values are the same row
+// version. Postgres stores microseconds, so finer differences are ignored.
+func SameVersion(a, b time.Time) bool {
+	return a.UnixMicro() == b.UnixMicro()
+}
@@ -5,14 +5,16 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
 	"github.com/andras-szesztai/dev-rental-api/internal/utils"
 )
 
 type UserStore struct {
-	db *sql.DB
+	db *db.DB
 }
 
-func NewUserStore(db *sql.DB) *UserStore {
+func NewUserStore(db *db.DB) *UserStore {
 	return &UserStore{db: db}
 }
 
@@ -22,10 +24,15 @@ type User struct {
 	Username string         `json:"username"`
 	Role     *Role          `json:"role"`
 	Password utils.Password `json:"-"`
+	// TokensValidAfter is set when the user's tokens were revoked. Tokens
+	// issued at or before it are rejected.
+	TokensValidAfter *time.Time `json:"-"`
 }
 
 func (s *UserStore) RegisterUser(ctx context.Context, user *User) error {
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+	defer metrics.ObserveQuery("UserStore.RegisterUser")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
 		query := `
 			INSERT INTO users (username, role_id, password)
 			VALUES ($1, $2, $3)
@@ -38,7 +45,7 @@ func (s *UserStore) RegisterUser(ctx context.Context, user *User) error {
 		var userID int
 		err := tx.QueryRowContext(ctx, query, user.Username, user.Role.ID, user.Password.Hash).Scan(&userID)
 		if err != nil {
-			return err
+			return translateError(err)
 		}
 
 		user.ID = userID
@@ -59,34 +66,64 @@ func (s *UserStore) RegisterUser(ctx context.Context, user *User) error {
 			return err
 		}
 
-		return nil
+		return recordAudit(ctx, tx, "register", "user", int64(userID), nil, user)
 	})
 }
 
+// userSummaryQuery selects users with their role and the email of the staff
+// member or customer they are linked to.
+const userSummaryQuery = `
+	SELECT u.id, COALESCE(s.email, c.email, ''), u.username, u.tokens_valid_after, r.id, r.name, r.level
+	FROM users u
+	JOIN roles r ON r.id = u.role_id
+	LEFT JOIN staff s ON s.user_id = u.id
+	LEFT JOIN customer c ON c.user_id = u.id
+`
+
+func scanUserSummary(row rowScanner) (*User, error) {
+	user := User{Role: &Role{}}
+	err := row.Scan(&user.ID, &user.Email, &user.Username, &user.TokensValidAfter, &user.Role.ID, &user.Role.Name, &user.Role.Level)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByEmail finds the user linked to the staff member or customer with
+// the given email. The password is not loaded.
 func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
-	query := `
-		SELECT id, username, role_id, password
-		FROM users
-		WHERE email = $1
+	defer metrics.ObserveQuery("UserStore.GetUserByEmail")()
+
+	query := userSummaryQuery + `
+		WHERE s.email = $1 OR c.email = $1
+		LIMIT 1
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	row := s.db.QueryRowContext(ctx, query, email)
+	return scanUserSummary(s.db.QueryRowContext(ctx, query, email))
+}
 
-	var user User
-	err := row.Scan(&user.ID, &user.Username, &user.Role.ID, &user.Password)
-	if err != nil {
-		return nil, err
-	}
+// GetUserByUsername finds a user by username. The password is not loaded.
+func (s *UserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	defer metrics.ObserveQuery("UserStore.GetUserByUsername")()
 
-	return &user, nil
+	query := userSummaryQuery + `
+		WHERE u.username = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return scanUserSummary(s.db.QueryRowContext(ctx, query, username))
 }
 
 func (s *UserStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
+	defer metrics.ObserveQuery("UserStore.GetUserByID")()
+
 	query := `
-		SELECT id, username, role_id, password
+		SELECT id, username, role_id, password, tokens_valid_after
 		FROM users
 		WHERE id = $1
 	`
@@ -98,10 +135,108 @@ func (s *UserStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
 
 	var user User
 	user.Role = &Role{}
-	err := row.Scan(&user.ID, &user.Username, &user.Role.ID, &user.Password.Hash)
+	err := row.Scan(&user.ID, &user.Username, &user.Role.ID, &user.Password.Hash, &user.TokensValidAfter)
 	if err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
+
+// ListUsers returns every user with their role, ordered by id. The passwords
+// are not loaded.
+func (s *UserStore) ListUsers(ctx context.Context) ([]User, error) {
+	defer metrics.ObserveQuery("UserStore.ListUsers")()
+
+	query := userSummaryQuery + `
+		ORDER BY u.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db, query, nil, scanUserSummary)
+}
+
+// UpdateUserRole moves a user to another role. Existing tokens stay valid, as
+// the role is loaded on every request.
+func (s *UserStore) UpdateUserRole(ctx context.Context, id int64, role *Role) error {
+	defer metrics.ObserveQuery("UserStore.UpdateUserRole")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		var before Role
+		query := `
+			SELECT r.id, r.name, r.level
+			FROM users u
+			JOIN roles r ON r.id = u.role_id
+			WHERE u.id = $1
+			FOR UPDATE OF u
+		`
+		err := tx.QueryRowContext(ctx, query, id).Scan(&before.ID, &before.Name, &before.Level)
+		if err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE users SET role_id = $2, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`
+		if _, err := tx.ExecContext(ctx, query, id, role.ID); err != nil {
+			return translateError(err)
+		}
+
+		return recordAudit(ctx, tx, "update_role", "user", id, map[string]any{"role": before}, map[string]any{"role": role})
+	})
+}
+
+// UpdateUserPassword replaces the password of a user and revokes their
+// tokens. It returns the new revocation time.
+func (s *UserStore) UpdateUserPassword(ctx context.Context, id int64, password utils.Password) (time.Time, error) {
+	defer metrics.ObserveQuery("UserStore.UpdateUserPassword")()
+
+	query := `
+		UPDATE users SET password = $2, tokens_valid_after = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING tokens_valid_after
+	`
+	return s.revokeTokens(ctx, "reset_password", id, query, password.Hash)
+}
+
+// RevokeUserTokens rejects every token issued to a user until now. It
+// returns the revocation time.
+func (s *UserStore) RevokeUserTokens(ctx context.Context, id int64) (time.Time, error) {
+	defer metrics.ObserveQuery("UserStore.RevokeUserTokens")()
+
+	query := `
+		UPDATE users SET tokens_valid_after = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING tokens_valid_after
+	`
+	return s.revokeTokens(ctx, "revoke_tokens", id, query)
+}
+
+// revokeTokens runs an update that sets tokens_valid_after and records it in
+// the audit log under action.
+func (s *UserStore) revokeTokens(ctx context.Context, action string, id int64, query string, args ...any) (time.Time, error) {
+	var validAfter time.Time
+	err := withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		var before *time.Time
+		err := tx.QueryRowContext(ctx, `SELECT tokens_valid_after FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&before)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.QueryRowContext(ctx, query, append([]any{id}, args...)...).Scan(&validAfter); err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, action, "user", id, map[string]any{"tokens_valid_after": before}, map[string]any{"tokens_valid_after": validAfter})
+	})
+	return validAfter, err
+}
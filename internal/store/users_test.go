@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	"github.com/stretchr/testify/suite"
+)
+
+type UsersTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *UserStore
+	ctx         context.Context
+	userID      int64
+}
+
+// The session time zone is west of UTC, so timestamps that depend on it are
+// off by hours.
+const usersTestTimeZone = "America/Los_Angeles"
+
+func (suite *UsersTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.pgContainer = pgContainer
+
+	conn, err := sql.Open("postgres", pgContainer.ConnectionString+"&timezone="+usersTestTimeZone)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	suite.repository = NewUserStore(db.NewDB(conn, nil, 0))
+
+	err = suite.pgContainer.DB.QueryRowContext(suite.ctx, `
+		INSERT INTO users (username, role_id, password) VALUES ('revoked', 1, '\x00') RETURNING id
+	`).Scan(&suite.userID)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func TestUsersTestSuite(t *testing.T) {
+	suite.Run(t, new(UsersTestSuite))
+}
+
+func (suite *UsersTestSuite) TearDownSuite() {
+	suite.repository.db.Close()
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *UsersTestSuite) TestRevokeUserTokens() {
+	suite.T().Run("it should store the revocation time independent of the session time zone", func(t *testing.T) {
+		var timeZone string
+		suite.Require().NoError(suite.repository.db.QueryRowContext(suite.ctx, `SHOW TimeZone`).Scan(&timeZone))
+		suite.Require().Equal(usersTestTimeZone, timeZone)
+
+		validAfter, err := suite.repository.RevokeUserTokens(suite.ctx, suite.userID)
+		suite.NoError(err)
+		suite.WithinDuration(time.Now(), validAfter, time.Minute)
+
+		user, err := suite.repository.GetUserByID(suite.ctx, suite.userID)
+		suite.NoError(err)
+		suite.Require().NotNil(user.TokensValidAfter)
+		suite.WithinDuration(time.Now(), *user.TokensValidAfter, time.Minute)
+	})
+}
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/lib/pq"
+)
+
+// Webhook delivery statuses. A delivery is pending until it succeeds or runs
+// out of attempts and becomes dead.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+type WebhookStore struct {
+	db *db.DB
+}
+
+func NewWebhookStore(db *db.DB) *WebhookStore {
+	return &WebhookStore{db: db}
+}
+
+// WebhookEndpoint receives the events listed in Events. Secret signs the
+// deliveries and is never serialized.
+type WebhookEndpoint struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"-"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one event sent to one endpoint. Claimed deliveries also
+// carry the URL and secret of their endpoint.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int64           `json:"endpoint_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+// WebhookFailure is the outcome of a failed delivery attempt. The delivery
+// is retried after RetryIn, or moved to the dead letters if RetryIn is zero.
+type WebhookFailure struct {
+	StatusCode *int
+	Error      string
+	RetryIn    time.Duration
+}
+
+const webhookEndpointColumns = `id, url, secret, events, description, active, created_at`
+
+const webhookDeliveryColumns = `id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func (s *WebhookStore) CreateWebhookEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	defer metrics.ObserveQuery("WebhookStore.CreateWebhookEndpoint")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `
+			INSERT INTO webhook_endpoints (url, secret, events, description)
+			VALUES ($1, $2, $3, $4)
+			RETURNING ` + webhookEndpointColumns
+
+		created, err := scanWebhookEndpoint(tx.QueryRowContext(ctx, query, endpoint.URL, endpoint.Secret, pq.StringArray(endpoint.Events), endpoint.Description))
+		if err != nil {
+			return err
+		}
+		*endpoint = *created
+
+		return recordAudit(ctx, tx, "create", "webhook_endpoint", endpoint.ID, nil, endpoint)
+	})
+}
+
+func (s *WebhookStore) ListWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error) {
+	defer metrics.ObserveQuery("WebhookStore.ListWebhookEndpoints")()
+
+	query := `SELECT ` + webhookEndpointColumns + ` FROM webhook_endpoints ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db, query, nil, scanWebhookEndpoint)
+}
+
+// DeleteWebhookEndpoint removes an endpoint together with its deliveries.
+func (s *WebhookStore) DeleteWebhookEndpoint(ctx context.Context, id int64) error {
+	defer metrics.ObserveQuery("WebhookStore.DeleteWebhookEndpoint")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `DELETE FROM webhook_endpoints WHERE id = $1 RETURNING ` + webhookEndpointColumns
+
+		deleted, err := scanWebhookEndpoint(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, "delete", "webhook_endpoint", id, deleted, nil)
+	})
+}
+
+// EnqueueWebhookEvent creates a pending delivery of the event for every
+// active endpoint subscribed to its type, and returns how many it created.
+// Enqueuing the same event again is a no-op.
+func (s *WebhookStore) EnqueueWebhookEvent(ctx context.Context, eventID, eventType string, payload []byte) (int64, error) {
+	defer metrics.ObserveQuery("WebhookStore.EnqueueWebhookEvent")()
+
+	query := `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_endpoints
+		WHERE active AND $2 = ANY(events)
+		ON CONFLICT (endpoint_id, event_id) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, eventID, eventType, payload)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries that are due
+// and pushes their next attempt back by lease, so other replicas skip them
+// while they are being sent. A delivery whose sender crashes is retried once
+// the lease expires.
+func (s *WebhookStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	defer metrics.ObserveQuery("WebhookStore.ClaimWebhookDeliveries")()
+
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries AS d
+			SET next_attempt_at = CURRENT_TIMESTAMP + $2::bigint * INTERVAL '1 millisecond'
+			FROM due
+			WHERE d.id = due.id
+			RETURNING d.*
+		)
+		SELECT c.id, c.endpoint_id, c.event_id, c.event_type, c.payload, c.status, c.attempts, c.next_attempt_at,
+			c.last_status_code, c.last_error, c.created_at, c.delivered_at, e.url, e.secret
+		FROM claimed c
+		JOIN webhook_endpoints e ON e.id = c.endpoint_id
+		ORDER BY c.id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db, query, []any{limit, lease.Milliseconds()}, func(row rowScanner) (*WebhookDelivery, error) {
+		var delivery WebhookDelivery
+		err := scanWebhookDeliveryInto(row, &delivery, &delivery.URL, &delivery.Secret)
+		return &delivery, err
+	})
+}
+
+func (s *WebhookStore) CompleteWebhookDelivery(ctx context.Context, id int64, statusCode int) error {
+	defer metrics.ObserveQuery("WebhookStore.CompleteWebhookDelivery")()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = '', delivered_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, id, statusCode).Scan(new(int64))
+}
+
+func (s *WebhookStore) FailWebhookDelivery(ctx context.Context, id int64, result WebhookFailure) error {
+	defer metrics.ObserveQuery("WebhookStore.FailWebhookDelivery")()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1,
+			last_status_code = $2,
+			last_error = $3,
+			status = CASE WHEN $4::bigint > 0 THEN 'pending' ELSE 'dead' END,
+			next_attempt_at = CURRENT_TIMESTAMP + $4::bigint * INTERVAL '1 millisecond'
+		WHERE id = $1
+		RETURNING id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, id, result.StatusCode, result.Error, result.RetryIn.Milliseconds()).Scan(new(int64))
+}
+
+// ListDeadWebhookDeliveries returns the dead letters of an endpoint, oldest
+// first. An endpointID of 0 lists those of all endpoints.
+func (s *WebhookStore) ListDeadWebhookDeliveries(ctx context.Context, endpointID int64, limit int) ([]WebhookDelivery, error) {
+	defer metrics.ObserveQuery("WebhookStore.ListDeadWebhookDeliveries")()
+
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE status = 'dead' AND ($1 = 0 OR endpoint_id = $1)
+		ORDER BY id
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return collectRows(ctx, s.db.Reader(ctx), query, []any{endpointID, limit}, scanWebhookDelivery)
+}
+
+// ReplayWebhookDelivery makes a dead delivery pending again with a fresh
+// set of attempts. It returns sql.ErrNoRows if there is no such dead letter.
+func (s *WebhookStore) ReplayWebhookDelivery(ctx context.Context, id int64) error {
+	defer metrics.ObserveQuery("WebhookStore.ReplayWebhookDelivery")()
+
+	return withTx(s.db, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND status = 'dead' FOR UPDATE`
+
+		before, err := scanWebhookDelivery(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return err
+		}
+
+		query = `
+			UPDATE webhook_deliveries
+			SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING ` + webhookDeliveryColumns
+
+		after, err := scanWebhookDelivery(tx.QueryRowContext(ctx, query, id))
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, tx, "replay", "webhook_delivery", id, before, after)
+	})
+}
+
+func scanWebhookEndpoint(row rowScanner) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	err := row.Scan(
+		&endpoint.ID,
+		&endpoint.URL,
+		&endpoint.Secret,
+		(*pq.StringArray)(&endpoint.Events),
+		&endpoint.Description,
+		&endpoint.Active,
+		&endpoint.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := scanWebhookDeliveryInto(row, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func scanWebhookDeliveryInto(row rowScanner, delivery *WebhookDelivery, extra ...any) error {
+	var statusCode sql.NullInt64
+	var deliveredAt sql.NullTime
+	dest := append([]any{
+		&delivery.ID,
+		&delivery.EndpointID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&statusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	if statusCode.Valid {
+		code := int(statusCode.Int64)
+		delivery.LastStatusCode = &code
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	return nil
+}
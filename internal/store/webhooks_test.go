@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/testhelpers"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/suite"
+)
+
+type WebhooksTestSuite struct {
+	suite.Suite
+	pgContainer *testhelpers.PostgresContainer
+	repository  *WebhookStore
+	ctx         context.Context
+}
+
+func (suite *WebhooksTestSuite) SetupSuite() {
+	suite.ctx = context.Background()
+
+	pgContainer, err := testhelpers.CreatePostgresContainer()
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.pgContainer = pgContainer
+	suite.repository = NewWebhookStore(suite.pgContainer.DB)
+}
+
+func TestWebhooksTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhooksTestSuite))
+}
+
+func (suite *WebhooksTestSuite) TearDownSuite() {
+	if err := suite.pgContainer.Terminate(suite.ctx); err != nil {
+		suite.T().Fatalf("error terminating postgres container: %s", err)
+	}
+}
+
+func (suite *WebhooksTestSuite) TestDeliveryLifecycle() {
+	endpoint := &WebhookEndpoint{URL: "https://example.com/hooks", Secret: "whsec_test", Events: []string{"rental.created"}}
+	suite.Require().NoError(suite.repository.CreateWebhookEndpoint(suite.ctx, endpoint))
+	suite.NotZero(endpoint.ID)
+	suite.True(endpoint.Active)
+
+	suite.T().Run("it should only enqueue subscribed events, once", func(t *testing.T) {
+		created, err := suite.repository.EnqueueWebhookEvent(suite.ctx, "evt_1", "rental.created", []byte(`{"id":"evt_1"}`))
+		suite.NoError(err)
+		suite.Equal(int64(1), created)
+
+		created, err = suite.repository.EnqueueWebhookEvent(suite.ctx, "evt_1", "rental.created", []byte(`{"id":"evt_1"}`))
+		suite.NoError(err)
+		suite.Zero(created)
+
+		created, err = suite.repository.EnqueueWebhookEvent(suite.ctx, "evt_2", "payment.created", []byte(`{"id":"evt_2"}`))
+		suite.NoError(err)
+		suite.Zero(created)
+	})
+
+	var delivery WebhookDelivery
+	suite.T().Run("it should lease claimed deliveries", func(t *testing.T) {
+		claimed, err := suite.repository.ClaimWebhookDeliveries(suite.ctx, 10, time.Minute)
+		suite.NoError(err)
+		suite.Require().Len(claimed, 1)
+		delivery = claimed[0]
+		suite.Equal("evt_1", delivery.EventID)
+		suite.Equal(endpoint.URL, delivery.URL)
+		suite.Equal("whsec_test", delivery.Secret)
+		suite.JSONEq(`{"id":"evt_1"}`, string(delivery.Payload))
+
+		claimed, err = suite.repository.ClaimWebhookDeliveries(suite.ctx, 10, time.Minute)
+		suite.NoError(err)
+		suite.Empty(claimed)
+	})
+
+	suite.T().Run("it should dead-letter and replay a delivery", func(t *testing.T) {
+		status := 500
+		err := suite.repository.FailWebhookDelivery(suite.ctx, delivery.ID, WebhookFailure{StatusCode: &status, Error: "boom"})
+		suite.NoError(err)
+
+		dead, err := suite.repository.ListDeadWebhookDeliveries(suite.ctx, endpoint.ID, 10)
+		suite.NoError(err)
+		suite.Require().Len(dead, 1)
+		suite.Equal(1, dead[0].Attempts)
+		suite.Equal(&status, dead[0].LastStatusCode)
+		suite.Equal("boom", dead[0].LastError)
+
+		suite.NoError(suite.repository.ReplayWebhookDelivery(suite.ctx, delivery.ID))
+		suite.ErrorIs(suite.repository.ReplayWebhookDelivery(suite.ctx, delivery.ID), sql.ErrNoRows)
+
+		entries, err := NewAuditStore(suite.pgContainer.DB).ListAuditEntries(suite.ctx, AuditFilter{EntityType: "webhook_delivery", EntityID: &delivery.ID, Limit: 10})
+		suite.NoError(err)
+		suite.Require().Len(entries, 1)
+		suite.Equal("replay", entries[0].Action)
+		suite.Equal("dead", entries[0].Diff["status"].Before)
+		suite.Equal("pending", entries[0].Diff["status"].After)
+
+		claimed, err := suite.repository.ClaimWebhookDeliveries(suite.ctx, 10, time.Minute)
+		suite.NoError(err)
+		suite.Require().Len(claimed, 1)
+		suite.Zero(claimed[0].Attempts)
+
+		suite.NoError(suite.repository.CompleteWebhookDelivery(suite.ctx, delivery.ID, 204))
+		dead, err = suite.repository.ListDeadWebhookDeliveries(suite.ctx, endpoint.ID, 10)
+		suite.NoError(err)
+		suite.Empty(dead)
+	})
+
+	suite.T().Run("it should delete an endpoint", func(t *testing.T) {
+		suite.NoError(suite.repository.DeleteWebhookEndpoint(suite.ctx, endpoint.ID))
+		suite.ErrorIs(suite.repository.DeleteWebhookEndpoint(suite.ctx, endpoint.ID), sql.ErrNoRows)
+	})
+}
@@ -3,11 +3,13 @@ package testhelpers
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/andras-szesztai/dev-rental-api/internal/db"
+	"github.com/andras-szesztai/dev-rental-api/internal/migrate"
+	"github.com/andras-szesztai/dev-rental-api/migrations"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -16,7 +18,7 @@ import (
 type PostgresContainer struct {
 	*postgres.PostgresContainer
 	ConnectionString string
-	DB               *sql.DB
+	DB               *db.DB
 }
 
 func CreatePostgresContainer() (*PostgresContainer, error) {
@@ -57,25 +59,6 @@ func CreatePostgresContainer() (*PostgresContainer, error) {
 		return nil, err
 	}
 
-	// Copy migration files to the temporary directory
-	migrationFiles, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
-	if err != nil {
-		return nil, err
-	}
-
-	for i, migrationFile := range migrationFiles {
-		content, err := os.ReadFile(migrationFile)
-		if err != nil {
-			return nil, err
-		}
-
-		// Use a higher number prefix to ensure migrations run after the restore
-		err = os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("02-%03d-migration.sql", i+1)), content, 0644)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	// Create container files from the temporary directory
 	var containerFiles []testcontainers.ContainerFile
 	files, err := os.ReadDir(tempDir)
@@ -111,14 +94,23 @@ func CreatePostgresContainer() (*PostgresContainer, error) {
 		return nil, err
 	}
 
-	db, err := sql.Open("postgres", connStr)
+	conn, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, err
 	}
 
+	// Apply the embedded migrations the way the API does at startup
+	migrator, err := migrate.New(conn, migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		return nil, err
+	}
+
 	return &PostgresContainer{
 		PostgresContainer: pgContainer,
 		ConnectionString:  connStr,
-		DB:                db,
+		DB:                db.NewDB(conn, nil, 0),
 	}, nil
 }
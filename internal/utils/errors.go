@@ -1,11 +1,95 @@
 package utils
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// Kind classifies an Error and decides the HTTP status it is reported with.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindUnprocessable
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindNotAcceptable
+)
+
+func (k Kind) Status() int {
+	switch k {
+	case KindInvalid:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case KindNotAcceptable:
+		return http.StatusNotAcceptable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is an error that is safe to show to clients. Code is a stable,
+// machine-readable identifier such as customer_already_registered.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+func NewError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors by code, so a wrapped copy still matches its sentinel.
+func (e *Error) Is(target error) bool {
+	var t *Error
+	return errors.As(target, &t) && t.Code == e.Code
+}
+
+// Wrap returns a copy of e carrying err as the underlying cause. The cause is
+// logged but never sent to the client.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
 type ErrorHandler struct {
 	logger *zap.SugaredLogger
 }
@@ -14,34 +98,151 @@ func NewErrorHandler(logger *zap.SugaredLogger) *ErrorHandler {
 	return &ErrorHandler{logger: logger}
 }
 
-func (e *ErrorHandler) InternalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	e.logger.Errorw("internal server error", "method", r.Method, "url", r.URL.Path, "error", err.Error())
-	err = WriteJSONError(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
-	if err != nil {
-		e.logger.Errorw("failed to write JSON error", "error", err.Error())
+// Error responds with the status and code of a typed Error. sql.ErrNoRows is
+// reported as not found and anything else as an internal server error.
+func (e *ErrorHandler) Error(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *Error
+	switch {
+	case errors.As(err, &appErr):
+		e.write(w, r, appErr.Kind.Status(), appErr.Code, appErr.Message, err)
+	case errors.Is(err, sql.ErrNoRows):
+		e.NotFound(w, r)
+	default:
+		e.InternalServerError(w, r, err)
 	}
 }
 
+func (e *ErrorHandler) InternalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	e.write(w, r, http.StatusInternalServerError, "internal_error", "the server encountered a problem and could not process your request", err)
+}
+
+// BadRequest reports err to the client as is, so it must only be used with
+// errors about the request itself, e.g. malformed JSON. A *ValidationError is
+// reported with its field errors.
 func (e *ErrorHandler) BadRequest(w http.ResponseWriter, r *http.Request, err error) {
-	e.logger.Warnw("bad request", "method", r.Method, "url", r.URL.Path, "error", err.Error())
-	err = WriteJSONError(w, http.StatusBadRequest, err.Error())
-	if err != nil {
-		e.logger.Errorw("failed to write JSON error", "error", err.Error())
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		problem := e.problem(r, http.StatusBadRequest, "validation_failed", "the request payload is invalid")
+		problem.Errors = validationErr.Fields
+		e.log(r, problem, err)
+		e.writeProblem(w, r, problem)
+		return
 	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		e.write(w, r, http.StatusBadRequest, appErr.Code, appErr.Message, err)
+		return
+	}
+	e.write(w, r, http.StatusBadRequest, "bad_request", err.Error(), err)
 }
 
 func (e *ErrorHandler) NotFound(w http.ResponseWriter, r *http.Request) {
-	e.logger.Warnw("not found", "method", r.Method, "url", r.URL.Path)
-	err := WriteJSONError(w, http.StatusNotFound, "not found")
-	if err != nil {
-		e.logger.Errorw("failed to write JSON error", "error", err.Error())
-	}
+	e.write(w, r, http.StatusNotFound, "not_found", "the requested resource could not be found", nil)
 }
 
 func (e *ErrorHandler) Unauthorized(w http.ResponseWriter, r *http.Request, err error) {
-	e.logger.Warnw("unauthorized", "method", r.Method, "url", r.URL.Path, "error", err.Error())
-	err = WriteJSONError(w, http.StatusUnauthorized, "unauthorized")
-	if err != nil {
-		e.logger.Errorw("failed to write JSON error", "error", err.Error())
+	e.write(w, r, http.StatusUnauthorized, codeOr(err, "unauthorized"), "unauthorized", err)
+}
+
+func (e *ErrorHandler) Forbidden(w http.ResponseWriter, r *http.Request, err error) {
+	e.write(w, r, http.StatusForbidden, codeOr(err, "forbidden"), "you do not have permission to access this resource", err)
+}
+
+func (e *ErrorHandler) Conflict(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		e.write(w, r, http.StatusConflict, appErr.Code, appErr.Message, err)
+		return
+	}
+	e.write(w, r, http.StatusConflict, "conflict", "the request conflicts with the current state of the resource", err)
+}
+
+func (e *ErrorHandler) write(w http.ResponseWriter, r *http.Request, status int, code, detail string, cause error) {
+	problem := e.problem(r, status, code, detail)
+	e.log(r, problem, cause)
+	e.writeProblem(w, r, problem)
+}
+
+func (e *ErrorHandler) problem(r *http.Request, status int, code, detail string) *Problem {
+	problem := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+		problem.TraceID = spanContext.TraceID().String()
+	}
+	return problem
+}
+
+func (e *ErrorHandler) log(r *http.Request, problem *Problem, cause error) {
+	fields := []any{"method", r.Method, "url", r.URL.Path, "status", problem.Status, "code", problem.Code}
+	if cause != nil {
+		fields = append(fields, "error", cause.Error())
+	}
+	message := strings.ToLower(problem.Title)
+	if problem.Status >= http.StatusInternalServerError {
+		e.requestLogger(r).Errorw(message, fields...)
+	} else {
+		e.requestLogger(r).Warnw(message, fields...)
+	}
+}
+
+func (e *ErrorHandler) writeProblem(w http.ResponseWriter, r *http.Request, problem *Problem) {
+	if err := WriteProblem(w, problem); err != nil {
+		e.requestLogger(r).Errorw("failed to write problem response", "error", err.Error())
+	}
+}
+
+func codeOr(err error, fallback string) string {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return fallback
+}
+
+// requestLogger returns the request-scoped logger set by the access log
+// middleware, or the handler's logger tagged with the request fields.
+func (e *ErrorHandler) requestLogger(r *http.Request) *zap.SugaredLogger {
+	if logger, ok := LoggerFromContext(r.Context()); ok {
+		return logger
+	}
+	return e.logger.With(RequestLogFields(r)...)
+}
+
+// RequestLogFields returns the chi request id and the trace id of the request
+// span as zap key-value pairs, so log lines can be joined with traces.
+func RequestLogFields(r *http.Request) []any {
+	fields := []any{"request_id", middleware.GetReqID(r.Context())}
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+		fields = append(fields, "trace_id", spanContext.TraceID().String())
+	}
+	return fields
+}
+
+// FieldError describes a single failed validation rule.
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Rule    string `json:"rule" example:"email"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message" example:"email must be a valid email address"`
+}
+
+// ValidationError is returned when a request payload fails validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Message)
 	}
+	return strings.Join(messages, "; ")
 }
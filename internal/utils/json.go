@@ -11,12 +11,25 @@ func WriteJSONResponse(w http.ResponseWriter, status int, data any) error {
 	return json.NewEncoder(w).Encode(data)
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// Problem is an RFC 7807 problem details body, extended with a stable error
+// code and the ids needed to find the request in logs and traces.
+type Problem struct {
+	Type      string `json:"type" example:"about:blank"`
+	Title     string `json:"title" example:"Conflict"`
+	Status    int    `json:"status" example:"409"`
+	Detail    string `json:"detail,omitempty" example:"customer already registered"`
+	Instance  string `json:"instance,omitempty" example:"/v1/auth/register"`
+	Code      string `json:"code" example:"customer_already_registered"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+	// Errors lists the failed fields of a validation_failed problem.
+	Errors []FieldError `json:"errors,omitempty"`
 }
 
-func WriteJSONError(w http.ResponseWriter, status int, message string) error {
-	return WriteJSONResponse(w, status, &ErrorResponse{Error: message})
+func WriteProblem(w http.ResponseWriter, problem *Problem) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	return json.NewEncoder(w).Encode(problem)
 }
 
 func ReadJSON(w http.ResponseWriter, r *http.Request, data any) error {
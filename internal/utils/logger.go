@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type loggerContextKey struct{}
+
+// WithLogger returns a copy of ctx carrying a request-scoped logger.
+func WithLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// LoggerFromContext returns the logger stored by WithLogger.
+func LoggerFromContext(ctx context.Context) (*zap.SugaredLogger, bool) {
+	logger, ok := ctx.Value(loggerContextKey{}).(*zap.SugaredLogger)
+	return logger, ok
+}
@@ -0,0 +1,207 @@
+package validation
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	de_translations "github.com/go-playground/validator/v10/translations/de"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+// FilmRatings are the values of the mpaa_rating enum in the database.
+var FilmRatings = []string{"G", "PG", "PG-13", "R", "NC-17"}
+
+type language struct {
+	locale       locales.Translator
+	register     func(v *validator.Validate, trans ut.Translator) error
+	filmRating   string
+	defaultValue string
+}
+
+// languages lists the supported locales, the first one is the fallback.
+var languages = []language{
+	{
+		locale:       en.New(),
+		register:     en_translations.RegisterDefaultTranslations,
+		filmRating:   "{0} must be one of " + strings.Join(FilmRatings, ", "),
+		defaultValue: "{0} is invalid",
+	},
+	{
+		locale:       de.New(),
+		register:     de_translations.RegisterDefaultTranslations,
+		filmRating:   "{0} muss einer der folgenden Werte sein: " + strings.Join(FilmRatings, ", "),
+		defaultValue: "{0} ist ungültig",
+	},
+	{
+		locale:       es.New(),
+		register:     es_translations.RegisterDefaultTranslations,
+		filmRating:   "{0} debe ser uno de " + strings.Join(FilmRatings, ", "),
+		defaultValue: "{0} no es válido",
+	},
+	{
+		locale:       fr.New(),
+		register:     fr_translations.RegisterDefaultTranslations,
+		filmRating:   "{0} doit être l'une des valeurs suivantes : " + strings.Join(FilmRatings, ", "),
+		defaultValue: "{0} n'est pas valide",
+	},
+}
+
+// Validator validates request payloads and reports failures as field errors
+// translated into the language requested by the client.
+type Validator struct {
+	validate  *validator.Validate
+	translate *ut.UniversalTranslator
+	fallbacks map[string]string
+}
+
+func New() *Validator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterTagNameFunc(jsonFieldName)
+
+	if err := validate.RegisterValidation("film_rating", validateFilmRating); err != nil {
+		panic(err)
+	}
+
+	locales := make([]locales.Translator, 0, len(languages))
+	for _, lang := range languages {
+		locales = append(locales, lang.locale)
+	}
+	translate := ut.New(languages[0].locale, locales...)
+
+	v := &Validator{validate: validate, translate: translate, fallbacks: map[string]string{}}
+	for _, lang := range languages {
+		trans, _ := translate.GetTranslator(lang.locale.Locale())
+		if err := lang.register(validate, trans); err != nil {
+			panic(err)
+		}
+		registerTranslation(validate, trans, "film_rating", lang.filmRating)
+		v.fallbacks[lang.locale.Locale()] = lang.defaultValue
+	}
+
+	return v
+}
+
+// Struct validates s. Failed rules are returned as a *utils.ValidationError
+// with messages in the language preferred by the Accept-Language header.
+func (v *Validator) Struct(r *http.Request, s any) error {
+	err := v.validate.StructCtx(r.Context(), s)
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	trans, _ := v.translate.FindTranslator(acceptedLanguages(r.Header.Get("Accept-Language"))...)
+
+	fields := make([]utils.FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		message := fieldErr.Translate(trans)
+		if message == "" || message == fieldErr.Error() {
+			message = strings.ReplaceAll(v.fallbacks[trans.Locale()], "{0}", fieldErr.Field())
+		}
+		fields = append(fields, utils.FieldError{
+			Field:   fieldPath(fieldErr.Namespace()),
+			Rule:    fieldErr.Tag(),
+			Param:   fieldErr.Param(),
+			Message: message,
+		})
+	}
+
+	return &utils.ValidationError{Fields: fields}
+}
+
+func registerTranslation(validate *validator.Validate, trans ut.Translator, tag, text string) {
+	err := validate.RegisterTranslation(tag, trans,
+		func(trans ut.Translator) error {
+			return trans.Add(tag, text, true)
+		},
+		func(trans ut.Translator, fe validator.FieldError) string {
+			message, _ := trans.T(tag, fe.Field())
+			return message
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func validateFilmRating(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	for _, rating := range FilmRatings {
+		if value == rating {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFieldName reports fields by their JSON name, so clients see the same
+// names they sent.
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+// fieldPath drops the payload struct name from a namespace like
+// createCustomerPayload.store_id.
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+// acceptedLanguages returns the languages of an Accept-Language header ordered
+// by preference, e.g. "de-CH, en;q=0.8" gives de_CH, de, en.
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+		tags = append(tags, weighted{tag: tag, quality: quality})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	languages := make([]string, 0, len(tags)*2)
+	for _, t := range tags {
+		tag := strings.ReplaceAll(t.tag, "-", "_")
+		languages = append(languages, tag)
+		if base, _, ok := strings.Cut(tag, "_"); ok {
+			languages = append(languages, strings.ToLower(base))
+		}
+	}
+	return languages
+}
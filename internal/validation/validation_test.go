@@ -0,0 +1,81 @@
+package validation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testPayload struct {
+	Email    string  `json:"email" validate:"required,email"`
+	Username string  `json:"username" validate:"required,min=3"`
+	Rating   string  `json:"rating" validate:"omitempty,film_rating"`
+	Address  address `json:"address"`
+}
+
+func validate(t *testing.T, v *Validator, acceptLanguage string, payload any) []utils.FieldError {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+
+	err := v.Struct(req, payload)
+	var validationErr *utils.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	return validationErr.Fields
+}
+
+func TestValidator(t *testing.T) {
+	v := New()
+
+	t.Run("it should report fields by json name with rule and param", func(t *testing.T) {
+		fields := validate(t, v, "", testPayload{Email: "not-an-email", Username: "ab", Address: address{City: "Lethbridge"}})
+
+		assert.Equal(t, []utils.FieldError{
+			{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+			{Field: "username", Rule: "min", Param: "3", Message: "username must be at least 3 characters in length"},
+		}, fields)
+	})
+
+	t.Run("it should validate film ratings and report nested fields by path", func(t *testing.T) {
+		fields := validate(t, v, "", testPayload{Email: "john@example.com", Username: "john", Rating: "PG-18"})
+
+		require.Len(t, fields, 2)
+		assert.Equal(t, utils.FieldError{Field: "rating", Rule: "film_rating", Message: "rating must be one of G, PG, PG-13, R, NC-17"}, fields[0])
+		assert.Equal(t, utils.FieldError{Field: "address.city", Rule: "required", Message: "city is a required field"}, fields[1])
+
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		assert.NoError(t, v.Struct(req, testPayload{Email: "john@example.com", Username: "john", Rating: "NC-17", Address: address{City: "Woodridge"}}))
+	})
+
+	t.Run("it should translate messages using Accept-Language", func(t *testing.T) {
+		fields := validate(t, v, "fr;q=0.5, de-CH, en;q=0.8", testPayload{Username: "john", Rating: "X", Address: address{City: "Bern"}})
+
+		require.Len(t, fields, 2)
+		assert.Equal(t, "email ist ein Pflichtfeld", fields[0].Message)
+		assert.Equal(t, "rating muss einer der folgenden Werte sein: G, PG, PG-13, R, NC-17", fields[1].Message)
+	})
+
+	t.Run("it should fall back to english for unsupported languages", func(t *testing.T) {
+		fields := validate(t, v, "hu-HU", testPayload{Username: "john", Address: address{City: "Budapest"}})
+
+		require.Len(t, fields, 1)
+		assert.Equal(t, "email is a required field", fields[0].Message)
+	})
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	assert.Equal(t, []string{"de_CH", "de", "en", "fr"}, acceptedLanguages("fr;q=0.5, de-CH, en;q=0.8"))
+	assert.Empty(t, acceptedLanguages(""))
+	assert.Empty(t, acceptedLanguages("*"))
+}
@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/metrics"
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/andras-szesztai/dev-rental-api/internal/tracing"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 8
+	defaultBatchSize    = 50
+	defaultBaseBackoff  = 30 * time.Second
+	defaultMaxBackoff   = 6 * time.Hour
+	// maxErrorLength bounds the response excerpt kept on a failed delivery.
+	maxErrorLength = 512
+)
+
+// Store is the part of store.Store the dispatcher needs.
+type Store interface {
+	EnqueueWebhookEvent(ctx context.Context, eventID, eventType string, payload []byte) (int64, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error)
+	CompleteWebhookDelivery(ctx context.Context, id int64, statusCode int) error
+	FailWebhookDelivery(ctx context.Context, id int64, result store.WebhookFailure) error
+}
+
+type Options struct {
+	// PollInterval is how often due deliveries are looked for.
+	PollInterval time.Duration
+	// Timeout bounds a single delivery request.
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered.
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry. It doubles with every
+	// further attempt up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// BatchSize is the most deliveries sent per poll.
+	BatchSize int
+	// Client sends the deliveries. The default one traces them and passes
+	// the trace on in the traceparent header.
+	Client *http.Client
+	Logger *zap.SugaredLogger
+}
+
+// Dispatcher queues outbox events for the subscribed endpoints and delivers
+// them.
+// Deliveries live in the database, so they survive restarts and can be sent
+// by any replica.
+type Dispatcher struct {
+	store  Store
+	opts   Options
+	now    func() time.Time
+	jitter func(d time.Duration) time.Duration
+}
+
+func NewDispatcher(s Store, opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = defaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	if opts.Client == nil {
+		opts.Client = tracing.HTTPClient()
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop().Sugar()
+	}
+	return &Dispatcher{
+		store: s,
+		opts:  opts,
+		now:   time.Now,
+		// Spreading retries over [d/2, d) keeps endpoints that recover from
+		// an outage from being hit by every backlogged delivery at once.
+		jitter: func(d time.Duration) time.Duration {
+			return d/2 + rand.N(d/2+1)
+		},
+	}
+}
+
+// Publish queues an outbox event for every endpoint subscribed to its type.
+// Publishing the same event again is a no-op, so it can be relayed at least
+// once.
+func (d *Dispatcher) Publish(ctx context.Context, event *store.OutboxEvent) error {
+	id := EventID(event)
+	payload, err := json.Marshal(Event{ID: id, Type: event.EventType, OccurredAt: event.CreatedAt.UTC(), Data: event.Payload})
+	if err != nil {
+		return err
+	}
+	_, err = d.store.EnqueueWebhookEvent(ctx, id, event.EventType, payload)
+	return err
+}
+
+// Run delivers due deliveries every poll interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			d.opts.Logger.Errorw("failed to deliver webhooks", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends one batch of due deliveries and returns how many it
+// attempted.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	// The lease outlives the batch, so a delivery is only picked up again
+	// if this process dies before recording its outcome.
+	lease := time.Duration(d.opts.BatchSize)*d.opts.Timeout + time.Minute
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, d.opts.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if err := d.deliver(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// deliver sends a delivery and records its outcome. Only failures to record
+// the outcome are returned.
+func (d *Dispatcher) deliver(ctx context.Context, delivery store.WebhookDelivery) error {
+	statusCode, sendErr := d.send(ctx, delivery)
+	if sendErr == nil {
+		metrics.WebhookDeliveries.WithLabelValues("delivered").Inc()
+		return d.store.CompleteWebhookDelivery(ctx, delivery.ID, statusCode)
+	}
+	if ctx.Err() != nil {
+		// Shutting down: leave the delivery to be retried once its lease
+		// expires instead of counting the attempt against it.
+		return ctx.Err()
+	}
+
+	failure := store.WebhookFailure{Error: sendErr.Error()}
+	if statusCode != 0 {
+		failure.StatusCode = &statusCode
+	}
+
+	attempt := delivery.Attempts + 1
+	if attempt < d.opts.MaxAttempts {
+		failure.RetryIn = d.backoff(attempt)
+		metrics.WebhookDeliveries.WithLabelValues("retried").Inc()
+	} else {
+		metrics.WebhookDeliveries.WithLabelValues("dead").Inc()
+		d.opts.Logger.Warnw("webhook delivery dead-lettered",
+			"delivery_id", delivery.ID, "endpoint_id", delivery.EndpointID, "event_id", delivery.EventID, "error", sendErr)
+	}
+
+	return d.store.FailWebhookDelivery(ctx, delivery.ID, failure)
+}
+
+// send posts the delivery and returns the response status code, which is
+// zero if no response was received.
+func (d *Dispatcher) send(ctx context.Context, delivery store.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	now := d.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dvd-rental-api-webhooks")
+	req.Header.Set(HeaderID, delivery.EventID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, fmt.Sprint(now.Unix()))
+	req.Header.Set(HeaderSignature, SignatureHeader(delivery.Secret, now, delivery.Payload))
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorLength))
+		return resp.StatusCode, nil
+	}
+
+	excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	return resp.StatusCode, fmt.Errorf("endpoint responded with %d: %s", resp.StatusCode, bytes.TrimSpace(excerpt))
+}
+
+// backoff returns the delay before retrying a delivery that failed attempt
+// times.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.opts.BaseBackoff
+	for i := 1; i < attempt && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return d.jitter(min(delay, d.opts.MaxBackoff))
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func newTestDispatcher(s Store) *Dispatcher {
+	d := NewDispatcher(s, Options{PollInterval: time.Second, Timeout: time.Second, MaxAttempts: 3, BaseBackoff: time.Minute, MaxBackoff: time.Hour})
+	d.jitter = func(d time.Duration) time.Duration { return d }
+	return d
+}
+
+func TestDispatcher(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"rental.created","data":{"id":7}}`)
+	delivery := store.WebhookDelivery{ID: 1, EndpointID: 2, EventID: "evt_1", EventType: EventRentalCreated, Payload: payload, Secret: "whsec_test"}
+
+	t.Run("it should sign and complete a delivery", func(t *testing.T) {
+		var header http.Header
+		var body []byte
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer receiver.Close()
+
+		completed := 0
+		mock := &store.MockWebhookStore{
+			ClaimWebhookDeliveriesFunc: func(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+				d := delivery
+				d.URL = receiver.URL
+				return []store.WebhookDelivery{d}, nil
+			},
+			CompleteWebhookDeliveryFunc: func(ctx context.Context, id int64, statusCode int) error {
+				assert.Equal(t, int64(1), id)
+				assert.Equal(t, http.StatusNoContent, statusCode)
+				completed++
+				return nil
+			},
+		}
+
+		sent, err := newTestDispatcher(mock).DeliverDue(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		assert.Equal(t, 1, completed)
+		assert.Equal(t, payload, body)
+		assert.Equal(t, "evt_1", header.Get(HeaderID))
+		assert.Equal(t, EventRentalCreated, header.Get(HeaderEvent))
+		assert.NotEmpty(t, header.Get(HeaderTimestamp))
+		assert.NoError(t, Verify("whsec_test", header, body, time.Minute, time.Now()))
+	})
+
+	t.Run("it should pass the trace on to the endpoint", func(t *testing.T) {
+		previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		t.Cleanup(func() {
+			otel.SetTracerProvider(previousProvider)
+			otel.SetTextMapPropagator(previousPropagator)
+		})
+
+		var traceparent string
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("traceparent")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer receiver.Close()
+
+		mock := &store.MockWebhookStore{
+			ClaimWebhookDeliveriesFunc: func(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+				d := delivery
+				d.URL = receiver.URL
+				return []store.WebhookDelivery{d}, nil
+			},
+		}
+
+		_, err := newTestDispatcher(mock).DeliverDue(context.Background())
+		require.NoError(t, err)
+		assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, traceparent)
+	})
+
+	t.Run("it should back off exponentially and dead-letter the last attempt", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer receiver.Close()
+
+		var failures []store.WebhookFailure
+		attempts := 0
+		mock := &store.MockWebhookStore{
+			ClaimWebhookDeliveriesFunc: func(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+				d := delivery
+				d.URL = receiver.URL
+				d.Attempts = attempts
+				attempts++
+				return []store.WebhookDelivery{d}, nil
+			},
+			FailWebhookDeliveryFunc: func(ctx context.Context, id int64, result store.WebhookFailure) error {
+				failures = append(failures, result)
+				return nil
+			},
+		}
+
+		d := newTestDispatcher(mock)
+		for range 3 {
+			_, err := d.DeliverDue(context.Background())
+			require.NoError(t, err)
+		}
+
+		require.Len(t, failures, 3)
+		assert.Equal(t, time.Minute, failures[0].RetryIn)
+		assert.Equal(t, 2*time.Minute, failures[1].RetryIn)
+		assert.Zero(t, failures[2].RetryIn)
+		require.NotNil(t, failures[2].StatusCode)
+		assert.Equal(t, http.StatusServiceUnavailable, *failures[2].StatusCode)
+		assert.Contains(t, failures[2].Error, "unavailable")
+	})
+
+	t.Run("it should retry when the endpoint is unreachable", func(t *testing.T) {
+		receiver := httptest.NewServer(http.NotFoundHandler())
+		receiver.Close()
+
+		var failure store.WebhookFailure
+		mock := &store.MockWebhookStore{
+			ClaimWebhookDeliveriesFunc: func(ctx context.Context, limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+				d := delivery
+				d.URL = receiver.URL
+				return []store.WebhookDelivery{d}, nil
+			},
+			FailWebhookDeliveryFunc: func(ctx context.Context, id int64, result store.WebhookFailure) error {
+				failure = result
+				return nil
+			},
+		}
+
+		_, err := newTestDispatcher(mock).DeliverDue(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, failure.StatusCode)
+		assert.Equal(t, time.Minute, failure.RetryIn)
+	})
+
+	t.Run("it should cap the backoff", func(t *testing.T) {
+		d := newTestDispatcher(&store.MockWebhookStore{})
+		assert.Equal(t, time.Hour, d.backoff(40))
+	})
+}
+
+func TestPublish(t *testing.T) {
+	t.Run("it should enqueue the event envelope under a stable id", func(t *testing.T) {
+		var eventIDs []string
+		var eventType string
+		var payload []byte
+		mock := &store.MockWebhookStore{
+			EnqueueWebhookEventFunc: func(ctx context.Context, id, typ string, p []byte) (int64, error) {
+				eventIDs = append(eventIDs, id)
+				eventType, payload = typ, p
+				return 1, nil
+			},
+		}
+
+		event := &store.OutboxEvent{ID: 12, EventType: EventCustomerCreated, Payload: json.RawMessage(`{"id":3}`), CreatedAt: time.Now()}
+		d := newTestDispatcher(mock)
+		require.NoError(t, d.Publish(context.Background(), event))
+		require.NoError(t, d.Publish(context.Background(), event))
+
+		var envelope map[string]any
+		require.NoError(t, json.Unmarshal(payload, &envelope))
+		assert.Equal(t, []string{"evt_12", "evt_12"}, eventIDs)
+		assert.Equal(t, EventCustomerCreated, eventType)
+		assert.Equal(t, "evt_12", envelope["id"])
+		assert.Equal(t, EventCustomerCreated, envelope["type"])
+		assert.Equal(t, map[string]any{"id": float64(3)}, envelope["data"])
+	})
+}
@@ -0,0 +1,127 @@
+// Package webhook delivers domain events from the outbox to the endpoints
+// registered by admins and signs every delivery so receivers can verify it.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andras-szesztai/dev-rental-api/internal/store"
+)
+
+// Event types endpoints can subscribe to.
+const (
+	EventRentalCreated   = store.EventRentalCreated
+	EventRentalReturned  = store.EventRentalReturned
+	EventRentalOverdue   = store.EventRentalOverdue
+	EventCustomerCreated = store.EventCustomerCreated
+	EventCustomerUpdated = store.EventCustomerUpdated
+	EventPaymentCreated  = store.EventPaymentCreated
+)
+
+// EventTypes lists every event type, in the order they are documented.
+var EventTypes = []string{EventRentalCreated, EventRentalReturned, EventRentalOverdue, EventCustomerCreated, EventCustomerUpdated, EventPaymentCreated}
+
+// Headers set on every delivery.
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredSignature = errors.New("webhook timestamp outside tolerance")
+)
+
+// Event is the body of a delivery. ID is stable across retries so receivers
+// can discard duplicates.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// IsEventType reports whether t is a known event type.
+func IsEventType(t string) bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// EventID is the ID of the event delivered for an outbox event.
+func EventID(event *store.OutboxEvent) string {
+	return fmt.Sprintf("evt_%d", event.ID)
+}
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" under secret.
+// Covering the timestamp stops a captured delivery from being replayed later.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeader formats the Webhook-Signature header value,
+// "t=<unix timestamp>,v1=<signature>".
+func SignatureHeader(secret string, timestamp time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), Sign(secret, timestamp, body))
+}
+
+// Verify checks a delivery's signature header against its body and rejects
+// timestamps further than tolerance from now. Receivers can use it as is.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp time.Time
+	var signatures []string
+	for _, part := range strings.Split(header.Get(HeaderSignature), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			timestamp = time.Unix(unix, 0)
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp.IsZero() || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(timestamp); d > tolerance || d < -tolerance {
+		return ErrExpiredSignature
+	}
+
+	expected := Sign(secret, timestamp, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"id":"evt_1"}`)
+	sentAt := time.Unix(1700000000, 0)
+
+	signed := func(value string) http.Header {
+		header := http.Header{}
+		header.Set(HeaderSignature, value)
+		return header
+	}
+
+	t.Run("it should accept a valid signature", func(t *testing.T) {
+		header := signed(SignatureHeader(secret, sentAt, body))
+		assert.NoError(t, Verify(secret, header, body, 5*time.Minute, sentAt.Add(time.Minute)))
+	})
+
+	t.Run("it should reject a tampered body", func(t *testing.T) {
+		header := signed(SignatureHeader(secret, sentAt, body))
+		assert.ErrorIs(t, Verify(secret, header, []byte(`{"id":"evt_2"}`), 5*time.Minute, sentAt), ErrInvalidSignature)
+	})
+
+	t.Run("it should reject another secret", func(t *testing.T) {
+		header := signed(SignatureHeader("whsec_other", sentAt, body))
+		assert.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, sentAt), ErrInvalidSignature)
+	})
+
+	t.Run("it should reject a stale timestamp", func(t *testing.T) {
+		header := signed(SignatureHeader(secret, sentAt, body))
+		assert.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, sentAt.Add(time.Hour)), ErrExpiredSignature)
+	})
+
+	t.Run("it should reject a malformed header", func(t *testing.T) {
+		for _, value := range []string{"", "v1=abc", "t=abc,v1=abc", "t=1700000000"} {
+			assert.ErrorIs(t, Verify(secret, signed(value), body, 5*time.Minute, sentAt), ErrInvalidSignature, value)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS film_title_idx;
+DROP INDEX IF EXISTS customer_last_name_idx;
+DROP INDEX IF EXISTS payment_customer_id_idx;
+DROP INDEX IF EXISTS payment_payment_date_idx;
+DROP INDEX IF EXISTS rental_customer_id_idx;
+DROP INDEX IF EXISTS rental_rental_date_idx;
@@ -0,0 +1,7 @@
+-- Keyset pagination indexes for the default sort orders of the list endpoints
+CREATE INDEX IF NOT EXISTS rental_rental_date_idx ON rental (rental_date DESC, rental_id);
+CREATE INDEX IF NOT EXISTS rental_customer_id_idx ON rental (customer_id, rental_date DESC, rental_id);
+CREATE INDEX IF NOT EXISTS payment_payment_date_idx ON payment (payment_date DESC, payment_id);
+CREATE INDEX IF NOT EXISTS payment_customer_id_idx ON payment (customer_id, payment_date DESC, payment_id);
+CREATE INDEX IF NOT EXISTS customer_last_name_idx ON customer (last_name, first_name, customer_id);
+CREATE INDEX IF NOT EXISTS film_title_idx ON film (title, film_id);
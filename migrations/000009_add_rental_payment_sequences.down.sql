@@ -0,0 +1,3 @@
+-- The sequences and id defaults are left in place, the original schema may
+-- already have had them.
+DROP INDEX IF EXISTS rental_open_inventory_idx;
@@ -0,0 +1,12 @@
+-- Some restores of the sample database lost the id defaults of rental and
+-- payment. Make sure both draw ids from a sequence so new rows can be inserted.
+CREATE SEQUENCE IF NOT EXISTS rental_rental_id_seq OWNED BY rental.rental_id;
+SELECT setval('rental_rental_id_seq', COALESCE((SELECT MAX(rental_id) FROM rental), 0) + 1, false);
+ALTER TABLE rental ALTER COLUMN rental_id SET DEFAULT nextval('rental_rental_id_seq');
+
+CREATE SEQUENCE IF NOT EXISTS payment_payment_id_seq OWNED BY payment.payment_id;
+SELECT setval('payment_payment_id_seq', COALESCE((SELECT MAX(payment_id) FROM payment), 0) + 1, false);
+ALTER TABLE payment ALTER COLUMN payment_id SET DEFAULT nextval('payment_payment_id_seq');
+
+-- Finds the open rental of a copy when it is checked out or returned
+CREATE INDEX IF NOT EXISTS rental_open_inventory_idx ON rental (inventory_id) WHERE return_date IS NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    job_name VARCHAR(255) NOT NULL,
+    trigger VARCHAR(16) NOT NULL,
+    triggered_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'running',
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+-- Run history is listed per job, newest first
+CREATE INDEX IF NOT EXISTS job_runs_job_name_idx ON job_runs (job_name, id DESC);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS rental_open_not_overdue_idx;
+ALTER TABLE rental DROP COLUMN IF EXISTS overdue_at;
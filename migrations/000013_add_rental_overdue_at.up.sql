@@ -0,0 +1,3 @@
+-- Set once a rental is reported overdue, so it is reported only once
+ALTER TABLE rental ADD COLUMN IF NOT EXISTS overdue_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS rental_open_not_overdue_idx ON rental (rental_date) WHERE return_date IS NULL AND overdue_at IS NULL;
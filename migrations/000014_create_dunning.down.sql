@@ -0,0 +1,4 @@
+ALTER TABLE inventory DROP COLUMN IF EXISTS lost_at;
+DROP TABLE IF EXISTS customer_charges;
+DROP TABLE IF EXISTS dunning_steps;
+DROP TABLE IF EXISTS dunning_cases;
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS dunning_cases (
+    rental_id INTEGER PRIMARY KEY REFERENCES rental(rental_id) ON DELETE CASCADE,
+    customer_id INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    reminders_sent INTEGER NOT NULL DEFAULT 0,
+    blocked_at TIMESTAMP,
+    lost_at TIMESTAMP,
+    opened_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The dunning job walks the open cases, checkout looks up blocking ones
+CREATE INDEX IF NOT EXISTS dunning_cases_open_idx ON dunning_cases (rental_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS dunning_cases_blocking_idx ON dunning_cases (customer_id) WHERE blocked_at IS NOT NULL AND status IN ('open', 'paused', 'lost');
+
+CREATE TABLE IF NOT EXISTS dunning_steps (
+    id BIGSERIAL PRIMARY KEY,
+    rental_id INTEGER NOT NULL REFERENCES dunning_cases(rental_id) ON DELETE CASCADE,
+    step VARCHAR(32) NOT NULL,
+    reminder INTEGER,
+    note TEXT,
+    actor_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS dunning_steps_rental_idx ON dunning_steps (rental_id, id);
+
+CREATE TABLE IF NOT EXISTS customer_charges (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL,
+    rental_id INTEGER REFERENCES rental(rental_id) ON DELETE SET NULL,
+    reason VARCHAR(32) NOT NULL,
+    amount NUMERIC(5,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    voided_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS customer_charges_customer_idx ON customer_charges (customer_id);
+CREATE INDEX IF NOT EXISTS customer_charges_rental_idx ON customer_charges (rental_id);
+
+-- Set while a copy is considered lost by dunning
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS lost_at TIMESTAMP;
@@ -0,0 +1,21 @@
+-- Customers without a row get the defaults: email, not opted out
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    customer_id INTEGER PRIMARY KEY REFERENCES customer(customer_id) ON DELETE CASCADE,
+    channels TEXT[] NOT NULL DEFAULT '{email}',
+    opted_out_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notification_log (
+    id BIGSERIAL PRIMARY KEY,
+    template VARCHAR(64) NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    customer_id INTEGER REFERENCES customer(customer_id) ON DELETE SET NULL,
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS notification_log_customer_idx ON notification_log (customer_id, id DESC);
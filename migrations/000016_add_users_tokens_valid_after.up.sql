@@ -0,0 +1,4 @@
+-- Tokens issued before this time are rejected, so a password reset or an
+-- explicit revocation signs the user out everywhere. It is compared with the
+-- UTC iat of tokens, so it must not depend on the session time zone
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMPTZ;
@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations of the database schema, so
+// the API and the admin CLI can apply the ones they were built with.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS